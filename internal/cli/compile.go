@@ -2,21 +2,34 @@ package cli
 
 import (
 	"flint/internal/codegen"
+	"flint/internal/toolchain"
 	"fmt"
-	"os"
 	"strings"
 )
 
-func compileFile(filename string) {
-	prog, _ := loadAndParse(filename)
-	ir := codegen.GenerateLLVM(prog, filename)
-	outFile := filename
-	if idx := strings.LastIndex(filename, "."); idx != -1 {
-		outFile = filename[:idx]
+func compileFile(filename, output, emit string) {
+	kind, err := toolchain.ParseEmitKind(emit)
+	if err != nil {
+		fatal(err.Error())
 	}
-	outFile += ".ll"
-	if err := os.WriteFile(outFile, []byte(ir), 0644); err != nil {
-		fmt.Fprintln(os.Stderr, "Error writing LLVM IR:", err)
-		return
+	m, mods := loadModules(filename)
+	units := []toolchain.Unit{{Name: m.Path, IR: codegen.GenerateModule(m, true)}}
+	for _, dep := range mods {
+		if dep != m {
+			units = append(units, toolchain.Unit{
+				Name: strings.ReplaceAll(dep.Path, "/", "."),
+				IR:   codegen.GenerateModule(dep, false),
+			})
+		}
+	}
+	if output == "" {
+		output = filename
+		if idx := strings.LastIndex(filename, "."); idx != -1 {
+			output = filename[:idx]
+		}
+		output += kind.Ext()
+	}
+	if err := toolchain.Detect().BuildUnits(units, kind, output); err != nil {
+		fatal(fmt.Sprintf("error compiling %s: %v", filename, err))
 	}
 }
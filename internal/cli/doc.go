@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"flint/internal/doc"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// docFile writes the API documentation of the module in filename, as
+// Markdown or HTML, to output or to stdout if output is empty.
+func docFile(filename, format, output string) {
+	prog, tc := loadAndParse(filename)
+	name := strings.TrimSuffix(filepath.Base(filename), ".flint")
+	m := doc.New(name, prog, tc)
+
+	var out string
+	switch format {
+	case "md":
+		out = doc.Markdown(m)
+	case "html":
+		var err error
+		if out, err = doc.HTML(m); err != nil {
+			fatal(fmt.Sprintf("error rendering %s: %v", filename, err))
+		}
+	default:
+		fatal(fmt.Sprintf("unknown doc format %q: expected md or html", format))
+	}
+
+	if output == "" {
+		fmt.Print(out)
+		return
+	}
+	if err := os.WriteFile(output, []byte(out), 0644); err != nil {
+		fatal(fmt.Sprintf("error writing %s: %v", output, err))
+	}
+}
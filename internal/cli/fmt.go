@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"flint/internal/format"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// formatFiles formats each file, and each .flint file under each directory,
+// in paths. With check it only lists the files that are not formatted, and
+// with write it rewrites them; otherwise it prints the formatted source.
+func formatFiles(paths []string, check, write bool) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && (p == path || strings.HasSuffix(p, ".flint")) {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			fatal(fmt.Sprintf("error reading %s: %v", path, err))
+		}
+	}
+
+	failed := false
+	for _, filename := range files {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			fatal(fmt.Sprintf("error reading %s: %v", filename, err))
+		}
+		out, err := format.Source(string(src), filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		switch {
+		case check:
+			if out != string(src) {
+				fmt.Println(filename)
+				failed = true
+			}
+		case write:
+			if out != string(src) {
+				if err := os.WriteFile(filename, []byte(out), 0o644); err != nil {
+					fatal(fmt.Sprintf("error writing %s: %v", filename, err))
+				}
+			}
+		default:
+			fmt.Print(out)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
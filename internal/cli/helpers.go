@@ -1,11 +1,13 @@
 package cli
 
 import (
-	"flint/internal/lexer"
+	"flint/internal/module"
 	"flint/internal/parser"
+	"flint/internal/project"
 	"flint/internal/typechecker"
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 func fatal(msg string) {
@@ -13,34 +15,44 @@ func fatal(msg string) {
 	os.Exit(1)
 }
 
+// loadAndParse lexes, parses and typechecks filename, printing every
+// diagnostic found along the way. It exits if any of them is an error.
 func loadAndParse(filename string) (*parser.Program, *typechecker.TypeChecker) {
-	tc := typechecker.New()
+	m, _ := loadModules(filename)
+	return m.Prog, m.TC
+}
+
+// loadModules is loadAndParse for filename and the modules it uses, found
+// under the source roots of the project filename is in or, outside of a
+// project, next to it. It returns the module of filename and every module
+// loaded, each after those it imports.
+func loadModules(filename string) (*module.Module, []*module.Module) {
 	src, err := os.ReadFile(filename)
 	if err != nil {
 		fatal(fmt.Sprintf("error reading %s: %v", filename, err))
 	}
-
-	tokens, err := lexer.Tokenize(string(src), filename)
+	roots := []string{filepath.Dir(filename)}
+	p, err := project.Find(filepath.Dir(filename))
 	if err != nil {
 		fatal(err.Error())
 	}
-
-	prog, errs := parser.ParseProgram(tokens)
-	if len(errs) > 0 {
-		for _, e := range errs {
-			fmt.Fprintln(os.Stderr, e)
+	if p != nil {
+		roots = p.Roots()
+		if abs, err := filepath.Abs(filename); err == nil {
+			filename = abs
 		}
-		os.Exit(1)
 	}
+	loader := module.NewLoader(roots...)
+	m, diags := loader.Load(filename, string(src))
 
-	for _, ex := range prog.Exprs {
-		if _, err := tc.CheckExpr(ex); err != nil {
-			fatal("Type error: " + err.Error())
+	for _, d := range diags {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	if n := len(diags.Errors()); n > 0 {
+		if n == 1 {
+			fatal("found 1 error")
 		}
+		fatal(fmt.Sprintf("found %d errors", n))
 	}
-
-	// for _, ex := range prog.Exprs {
-	// 	fmt.Println(parser.DumpExpr(ex))
-	// }
-	return prog, tc
+	return m, loader.Modules()
 }
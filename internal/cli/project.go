@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"flint/internal/project"
+	"flint/internal/toolchain"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// currentProject returns the project the working directory is in, and
+// exits if there is none.
+func currentProject() *project.Project {
+	p, err := project.Find(".")
+	if err != nil {
+		fatal(err.Error())
+	}
+	if p == nil {
+		fatal(fmt.Sprintf("no %s found in this directory or any parent", project.ManifestFile))
+	}
+	return p
+}
+
+// sourceArg returns the file a command was given, or the entry of the
+// current project if it was given none.
+func sourceArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return currentProject().EntryFile()
+}
+
+func newProject(name string) {
+	p, err := project.New(name, filepath.Base(name))
+	if err != nil {
+		fatal(err.Error())
+	}
+	fmt.Printf("Created project %s in %s\n", p.Name, name)
+}
+
+func buildProject() {
+	p := currentProject()
+	m, mods := loadModules(p.EntryFile())
+	exe, err := p.Build(mods, m, toolchain.Detect(), os.Stdout)
+	if err != nil {
+		fatal(fmt.Sprintf("error building %s: %v", p.Name, err))
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, exe); err == nil {
+			exe = rel
+		}
+	}
+	fmt.Printf("Built %s\n", exe)
+}
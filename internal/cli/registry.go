@@ -19,18 +19,22 @@ func init() {
 	commands = []Command{
 		{
 			Name:        "run",
-			Description: "Run a Flint program from a source file.",
+			Description: "Run a Flint program from a source file, or the current project, passing it any further arguments.",
 			Run: func(fs *flag.FlagSet) {
-				fs.Parse(os.Args[2:])
-				runFile(os.Args[2])
+				runFile(runArgs(os.Args[2:]))
 			},
 		},
 		{
 			Name:        "compile",
-			Description: "Compile Flint code to a backend.",
+			Description: "Compile Flint code to LLVM IR, bitcode, an object file or an executable.",
 			Run: func(fs *flag.FlagSet) {
+				output := fs.String("o", "", "output file (defaults to the source name)")
+				emit := fs.String("emit", "exe", "output kind: ll, bc, obj or exe")
 				fs.Parse(os.Args[2:])
-				compileFile(os.Args[2])
+				if fs.NArg() < 1 {
+					fatal("usage: flint compile [-o output] [-emit=ll|bc|obj|exe] <file>")
+				}
+				compileFile(fs.Arg(0), *output, *emit)
 			},
 		},
 		{
@@ -38,7 +42,52 @@ func init() {
 			Description: "Type-check Flint code without executing it.",
 			Run: func(fs *flag.FlagSet) {
 				fs.Parse(os.Args[2:])
-				checkFile(os.Args[2])
+				checkFile(sourceArg(fs.Args()))
+			},
+		},
+		{
+			Name:        "new",
+			Description: "Create a Flint project in a new directory.",
+			Run: func(fs *flag.FlagSet) {
+				fs.Parse(os.Args[2:])
+				if fs.NArg() != 1 {
+					fatal("usage: flint new <name>")
+				}
+				newProject(fs.Arg(0))
+			},
+		},
+		{
+			Name:        "build",
+			Description: "Compile the current project into build/, recompiling only what changed.",
+			Run: func(fs *flag.FlagSet) {
+				fs.Parse(os.Args[2:])
+				buildProject()
+			},
+		},
+		{
+			Name:        "fmt",
+			Description: "Format Flint source files.",
+			Run: func(fs *flag.FlagSet) {
+				check := fs.Bool("check", false, "list the files that are not formatted, and fail if there are any")
+				write := fs.Bool("write", false, "rewrite the files in place")
+				fs.Parse(os.Args[2:])
+				if fs.NArg() < 1 {
+					fatal("usage: flint fmt [--check | --write] <file or directory>...")
+				}
+				formatFiles(fs.Args(), *check, *write)
+			},
+		},
+		{
+			Name:        "doc",
+			Description: "Generate API documentation for a Flint module.",
+			Run: func(fs *flag.FlagSet) {
+				format := fs.String("format", "md", "output format: md or html")
+				output := fs.String("o", "", "output file (defaults to stdout)")
+				fs.Parse(os.Args[2:])
+				if fs.NArg() < 1 {
+					fatal("usage: flint doc [-format=md|html] [-o output] <file>")
+				}
+				docFile(fs.Arg(0), *format, *output)
 			},
 		},
 		{
@@ -1,9 +1,56 @@
 package cli
 
-import "fmt"
+import (
+	"errors"
+	"flint/internal/interp"
+	"fmt"
+	"os"
+	"strings"
+)
 
-func runFile(filename string) {
-	fmt.Println("Running " + filename)
-	_, _ = loadAndParse(filename)
-	fmt.Println("Program executed (no backend yet)")
+// runArgs splits the arguments of `flint run` into the source file to run
+// and the arguments of the program, which are passed on as they are rather
+// than parsed as flags. Without a .flint file first, the file is empty,
+// meaning the entry file of the current project. One `--` may separate the
+// program's arguments from the file, or start them.
+func runArgs(args []string) (string, []string) {
+	file := ""
+	if len(args) > 0 && strings.HasSuffix(args[0], ".flint") {
+		file, args = args[0], args[1:]
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	return file, args
+}
+
+func runFile(filename string, args []string) {
+	if filename == "" {
+		filename = currentProject().EntryFile()
+	}
+	m, mods := loadModules(filename)
+	in := interp.New(os.Stdout)
+	in.SetArgs(args)
+	for _, dep := range mods {
+		if dep == m {
+			continue
+		}
+		if err := in.Load(dep.Path, dep.Prog); err != nil {
+			runFailed(err)
+		}
+	}
+	if err := in.Run(m.Prog); err != nil {
+		runFailed(err)
+	}
+}
+
+// runFailed ends `flint run` after err stopped the program: with the
+// status the program asked for if it called os:exit, or else as a runtime
+// error.
+func runFailed(err error) {
+	var exit *interp.Exit
+	if errors.As(err, &exit) {
+		os.Exit(exit.Code)
+	}
+	fatal(fmt.Sprintf("Runtime error: %s", err))
 }
@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRunArgsPassProgramArgumentsThrough(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		file string
+		rest []string
+	}{
+		{nil, "", nil},
+		{[]string{"-v"}, "", []string{"-v"}},
+		{[]string{"--", "-v", "x"}, "", []string{"-v", "x"}},
+		{[]string{"main.flint"}, "main.flint", nil},
+		{[]string{"main.flint", "-v", "x"}, "main.flint", []string{"-v", "x"}},
+		{[]string{"main.flint", "--", "x"}, "main.flint", []string{"x"}},
+		{[]string{"main.flint", "--", "--"}, "main.flint", []string{"--"}},
+	} {
+		file, rest := runArgs(tc.args)
+		if file != tc.file || !slices.Equal(rest, tc.rest) {
+			t.Errorf("runArgs(%q) = %q, %q; expected %q, %q", tc.args, file, rest, tc.file, tc.rest)
+		}
+	}
+}
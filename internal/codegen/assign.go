@@ -7,9 +7,9 @@ import (
 	"github.com/llir/llvm/ir/value"
 )
 
-func (cg *CodeGen) emitAssign(b *ir.Block, e *parser.AssignExpr) value.Value {
-	expr := cg.emitExpr(b, e.Value, false)
+func (cg *CodeGen) emitAssign(b *ir.Block, e *parser.AssignExpr) (value.Value, *ir.Block) {
+	expr, b := cg.emitExpr(b, e.Value, false)
 	alloc := cg.locals[e.Name.Name]
 	b.NewStore(expr, alloc)
-	return alloc
+	return expr, b
 }
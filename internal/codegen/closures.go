@@ -0,0 +1,200 @@
+package codegen
+
+import (
+	"flint/internal/parser"
+	"flint/internal/typechecker"
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Function values are closures: a function pointer paired with an
+// environment pointer that is passed to the function as its first
+// argument. A lambda's environment holds pointers to the variables it
+// captures, as does that of a nested function using the locals around it,
+// so it sees later assignments to them. Those variables are
+// allocated on the heap, which lets the closure outlive the call that
+// created it.
+
+func (cg *CodeGen) closureType(t *typechecker.Type) *types.StructType {
+	params := []types.Type{types.I8Ptr}
+	for _, p := range t.Params {
+		params = append(params, cg.llvmType(p))
+	}
+	fnType := types.NewFunc(cg.llvmType(t.Ret), params...)
+	return types.NewStruct(types.NewPointer(fnType), types.I8Ptr)
+}
+
+// closureOf makes a closure for a global function, through a wrapper that
+// ignores the environment.
+func (cg *CodeGen) closureOf(fn *ir.Func) value.Value {
+	wrapper, ok := cg.wrappers[fn]
+	if !ok {
+		params := []*ir.Param{ir.NewParam("env", types.I8Ptr)}
+		args := []value.Value{}
+		for _, p := range fn.Params {
+			param := ir.NewParam(p.Name(), p.Type())
+			params = append(params, param)
+			args = append(args, param)
+		}
+		wrapper = cg.mod.NewFunc(fn.Name()+"$closure", fn.Sig.RetType, params...)
+		wrapper.Linkage = enum.LinkageInternal
+		entry := wrapper.NewBlock("entry")
+		call := entry.NewCall(fn, args...)
+		if fn.Sig.RetType.Equal(types.Void) {
+			entry.NewRet(nil)
+		} else {
+			entry.NewRet(call)
+		}
+		cg.wrappers[fn] = wrapper
+	}
+	closure := types.NewStruct(wrapper.Type(), types.I8Ptr)
+	return constant.NewStruct(closure, wrapper, constant.NewNull(types.I8Ptr))
+}
+
+func (cg *CodeGen) emitLambda(b *ir.Block, l *parser.LambdaExpr) (value.Value, *ir.Block) {
+	cg.lambdaCount++
+	return cg.emitClosure(b, fmt.Sprintf("lambda.%d", cg.lambdaCount), l.Params, l.Body, cg.typeOf(l))
+}
+
+// captured returns the locals in scope that body mentions, in the order it
+// first mentions them.
+func (cg *CodeGen) captured(body parser.Expr) []string {
+	var names []string
+	seen := map[string]bool{}
+	parser.Inspect(body, func(e parser.Expr) bool {
+		if id, ok := e.(*parser.Identifier); ok && !seen[id.Name] && cg.locals[id.Name] != nil {
+			seen[id.Name] = true
+			names = append(names, id.Name)
+		}
+		return true
+	})
+	return names
+}
+
+// emitClosure emits a function called name, of type ty, with params and
+// body, and returns a closure for it whose environment holds the locals
+// body captures.
+func (cg *CodeGen) emitClosure(b *ir.Block, name string, params []parser.Param, body parser.Expr, ty *typechecker.Type) (value.Value, *ir.Block) {
+	closure := cg.closureType(ty)
+	fnType := closure.Fields[0].(*types.PointerType).ElemType.(*types.FuncType)
+
+	captured := cg.captured(body)
+	envFields := make([]types.Type, len(captured))
+	for i, name := range captured {
+		envFields[i] = cg.locals[name].Type()
+	}
+	envType := types.NewStruct(envFields...)
+
+	var env value.Value = constant.NewNull(types.I8Ptr)
+	if len(captured) > 0 {
+		env = cg.emitMalloc(b, envType)
+		typed := b.NewBitCast(env, types.NewPointer(envType))
+		for i, name := range captured {
+			field := b.NewGetElementPtr(envType, typed, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i)))
+			b.NewStore(cg.locals[name], field)
+		}
+	}
+
+	irParams := []*ir.Param{ir.NewParam("env", types.I8Ptr)}
+	for i, p := range params {
+		irParams = append(irParams, ir.NewParam(p.Name.Lexeme, fnType.Params[i+1]))
+	}
+	fn := cg.mod.NewFunc(name, fnType.RetType, irParams...)
+	fn.Linkage = enum.LinkageInternal
+
+	restore := cg.saveFunc()
+	cg.locals = map[string]value.Value{}
+	cg.orSlots = nil
+	cg.boxed = capturedNames(body)
+	cg.entry = fn.NewBlock("entry")
+	if len(captured) > 0 {
+		typed := cg.entry.NewBitCast(fn.Params[0], types.NewPointer(envType))
+		for i, name := range captured {
+			field := cg.entry.NewGetElementPtr(envType, typed, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i)))
+			cg.locals[name] = cg.entry.NewLoad(envFields[i], field)
+		}
+	}
+	for _, param := range fn.Params[1:] {
+		slot := cg.localSlot(cg.entry, param.Type(), param.Name())
+		cg.entry.NewStore(param, slot)
+		cg.locals[param.Name()] = slot
+	}
+	val, end := cg.emitExpr(cg.entry, body, true)
+	if end.Term == nil {
+		cg.emitReturn(end, val, fnType.RetType, false)
+	}
+	restore()
+
+	var agg value.Value = b.NewInsertValue(constant.NewUndef(closure), fn, 0)
+	return b.NewInsertValue(agg, env, 1), b
+}
+
+// emitClosureCall calls a closure, passing its environment first.
+func (cg *CodeGen) emitClosureCall(b *ir.Block, closure value.Value, args []value.Value) *ir.InstCall {
+	fnPtr := b.NewExtractValue(closure, 0)
+	env := b.NewExtractValue(closure, 1)
+	return b.NewCall(fnPtr, append([]value.Value{env}, args...)...)
+}
+
+// capturedNames returns the names mentioned by the lambdas and nested
+// functions in body. The variables of those names are allocated on the
+// heap.
+func capturedNames(body parser.Expr) map[string]bool {
+	names := map[string]bool{}
+	parser.Inspect(body, func(e parser.Expr) bool {
+		var inner parser.Expr
+		switch f := e.(type) {
+		case *parser.LambdaExpr:
+			inner = f.Body
+		case *parser.FuncDeclExpr:
+			inner = f.Body
+		default:
+			return true
+		}
+		parser.Inspect(inner, func(e parser.Expr) bool {
+			if id, ok := e.(*parser.Identifier); ok {
+				names[id.Name] = true
+			}
+			return true
+		})
+		return false
+	})
+	return names
+}
+
+// localSlot returns the storage for a new local variable: a stack slot, or
+// heap memory when a lambda may capture it.
+func (cg *CodeGen) localSlot(b *ir.Block, t types.Type, name string) value.Value {
+	if !cg.boxed[name] {
+		return cg.entryAlloca(t)
+	}
+	return b.NewBitCast(cg.emitMalloc(b, t), types.NewPointer(t))
+}
+
+// entrySlot is localSlot for variables that must be reachable from every
+// block of the function, such as those shared by the alternatives of an
+// or-pattern.
+func (cg *CodeGen) entrySlot(t types.Type, name string) value.Value {
+	if !cg.boxed[name] {
+		return cg.entryAlloca(t)
+	}
+	head := &ir.Block{}
+	slot := cg.localSlot(head, t, name)
+	cg.entry.Insts = append(head.Insts, cg.entry.Insts...)
+	return slot
+}
+
+// saveFunc records the state of the function being emitted and returns a
+// function that restores it, for emitting another function in the middle
+// of the current one.
+func (cg *CodeGen) saveFunc() func() {
+	locals, entry, orSlots, boxed := cg.locals, cg.entry, cg.orSlots, cg.boxed
+	return func() {
+		cg.locals, cg.entry, cg.orSlots, cg.boxed = locals, entry, orSlots, boxed
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
 
+	"flint/internal/module"
 	"flint/internal/parser"
+	"flint/internal/typechecker"
 )
 
 type CodeGen struct {
@@ -14,42 +16,133 @@ type CodeGen struct {
 	strGlobals       map[string]*ir.Global
 	globalMatchCount int
 
-	locals map[string]value.Value
-	funcs  map[string]*ir.Func
+	blockCount int
+	entry      *ir.Block
+	orSlots    map[string]value.Value
+	boxed      map[string]bool
+
+	locals   map[string]value.Value
+	funcs    map[string]*ir.Func
+	runtime  map[string]*ir.Func
+	records  map[string]*recordInfo
+	variants map[string]*variantInfo
+	ctors    map[string]*ctorInfo
+	wrappers map[*ir.Func]*ir.Func
+
+	lambdaCount int
+	list        types.Type
+	// std holds the functions of built-in modules defined so far.
+	std map[string]*ir.Func
+
+	tc       *typechecker.TypeChecker
+	generics map[string]*parser.FuncDeclExpr
+	subst    map[*typechecker.Type]*typechecker.Type
+
+	// unit is the module being compiled when it is one of several, whose
+	// names are qualified by its path, and uses and members map the names
+	// its `use` declarations bring into scope to the modules they name.
+	unit    *module.Module
+	uses    map[string]*module.Module
+	members map[string]*module.Module
+	// stdUses and stdMembers are uses and members for built-in modules,
+	// mapping names to module paths.
+	stdUses    map[string]string
+	stdMembers map[string]string
+	externs    map[string]*ir.Func
+	deps       map[*module.Module]*depState
+}
+
+// GenerateLLVM lowers a program that tc has checked. Generic functions are
+// only emitted once for each type they are called at.
+func GenerateLLVM(prog *parser.Program, tc *typechecker.TypeChecker, sourceFile string) string {
+	cg := newCodeGen(tc, sourceFile)
+	cg.generate(prog)
+	return cg.mod.String()
 }
 
-func GenerateLLVM(prog *parser.Program, sourceFile string) string {
+func newCodeGen(tc *typechecker.TypeChecker, sourceFile string) *CodeGen {
 	cg := &CodeGen{
+		tc:         tc,
+		generics:   map[string]*parser.FuncDeclExpr{},
 		mod:        ir.NewModule(),
 		locals:     map[string]value.Value{},
 		funcs:      map[string]*ir.Func{},
+		runtime:    map[string]*ir.Func{},
+		records:    map[string]*recordInfo{},
+		variants:   map[string]*variantInfo{},
+		ctors:      map[string]*ctorInfo{},
+		wrappers:   map[*ir.Func]*ir.Func{},
 		strGlobals: map[string]*ir.Global{},
+		uses:       map[string]*module.Module{},
+		members:    map[string]*module.Module{},
+		externs:    map[string]*ir.Func{},
+		std:        map[string]*ir.Func{},
+		deps:       map[*module.Module]*depState{},
 	}
 	cg.initModuleHeaders(sourceFile)
+	return cg
+}
+
+func (cg *CodeGen) generate(prog *parser.Program) {
+	cg.declareTypes(prog)
+	cg.stdUses, cg.stdMembers = stdUsesOf(prog)
+	cg.generateFuncs(prog)
+}
+
+func (cg *CodeGen) generateFuncs(prog *parser.Program) {
 	for _, e := range prog.Exprs {
 		if fn, ok := e.(*parser.FuncDeclExpr); ok {
 			name := fn.Name.Lexeme
-			ret := cg.resolveType(fn.Ret)
-			if name == "main" {
-				ret = types.I32
-			}
-			params := []*ir.Param{}
-			for _, p := range fn.Params {
-				params = append(params, ir.NewParam(p.Name.Lexeme, cg.resolveType(p.Type)))
+			ty := cg.tc.TypeOf(fn)
+			if ty.HasVars() {
+				cg.generics[name] = fn
+				continue
 			}
-			cg.funcs[name] = cg.mod.NewFunc(name, ret, params...)
+			cg.funcs[name] = cg.declareFunction(cg.symbolOf(fn), fn, ty)
 		}
 	}
 	for _, e := range prog.Exprs {
 		switch n := e.(type) {
 		case *parser.FuncDeclExpr:
-			cg.emitFunction(n)
+			if _, ok := cg.generics[n.Name.Lexeme]; !ok {
+				cg.emitFunction(n)
+			}
 		case *parser.IntLiteral, *parser.FloatLiteral, *parser.BoolLiteral,
-			*parser.ByteLiteral, *parser.StringLiteral:
+			*parser.ByteLiteral, *parser.StringLiteral, *parser.InterpolatedString:
 			cg.emitTopLiteral(n)
+		case *parser.TypeDeclExpr, *parser.UseExpr:
 		default:
 			panic("unsupported top-level expr")
 		}
 	}
-	return cg.mod.String()
+}
+
+// declareTypes declares the record and variant types of progs, and those
+// of the built-in modules they use, skipping any whose name is taken.
+// Every type is named first, and given its fields after, so that any type
+// may refer to any other.
+func (cg *CodeGen) declareTypes(progs ...*parser.Program) {
+	var decls []*parser.TypeDeclExpr
+	seen := map[string]bool{}
+	for _, prog := range progs {
+		for _, e := range prog.Exprs {
+			if t, ok := e.(*parser.TypeDeclExpr); ok && !seen[t.Name.Lexeme] {
+				seen[t.Name.Lexeme] = true
+				decls = append(decls, t)
+			}
+		}
+	}
+	for _, t := range stdTypes(progs) {
+		if !seen[t.Name.Lexeme] {
+			seen[t.Name.Lexeme] = true
+			decls = append(decls, t)
+		}
+	}
+	for _, t := range decls {
+		cg.declareVariant(t)
+		cg.declareRecord(t)
+	}
+	for _, t := range decls {
+		cg.declareType(t)
+	}
 }
@@ -1,8 +1,8 @@
 package codegen
 
 import (
-	"flint/internal/lexer"
 	"flint/internal/parser"
+	"flint/internal/typechecker"
 	"fmt"
 
 	"github.com/llir/llvm/ir"
@@ -12,217 +12,216 @@ import (
 	"github.com/llir/llvm/ir/value"
 )
 
-func (cg *CodeGen) emitIf(b *ir.Block, i *parser.IfExpr, isTail bool) value.Value {
-	cond := cg.emitExpr(b, i.Cond, false)
-	parent := b.Parent
-	thenBlock := parent.NewBlock("if.then")
-	elseBlock := parent.NewBlock("if.else")
-	mergeBlock := parent.NewBlock("if.merge")
+// branchResult is the value a branch of an if or match produced together
+// with the block it ended in.
+type branchResult struct {
+	val   value.Value
+	block *ir.Block
+}
+
+func (cg *CodeGen) emitIf(b *ir.Block, i *parser.IfExpr, isTail bool) (value.Value, *ir.Block) {
+	cond, b := cg.emitExpr(b, i.Cond, false)
+	thenBlock := cg.newBlock(b.Parent, "if.then")
+	elseBlock := cg.newBlock(b.Parent, "if.else")
 	b.NewCondBr(cond, thenBlock, elseBlock)
-	thenVal := cg.emitIfBody(thenBlock, i.Then, false)
-	if thenBlock.Term == nil {
-		thenBlock.NewBr(mergeBlock)
-	}
-	elseVal := cg.emitIfBody(elseBlock, i.Else, false)
-	if elseBlock.Term == nil {
-		elseBlock.NewBr(mergeBlock)
+	var results []branchResult
+	thenVal, thenEnd := cg.emitExpr(thenBlock, i.Then, isTail)
+	results = append(results, branchResult{thenVal, thenEnd})
+	if i.Else != nil {
+		elseVal, elseEnd := cg.emitExpr(elseBlock, i.Else, isTail)
+		results = append(results, branchResult{elseVal, elseEnd})
+	} else {
+		results = append(results, branchResult{nil, elseBlock})
 	}
+	return cg.emitMerge(b.Parent, "if.end", results)
+}
+
+// emitMerge joins the branches into a new block, merging their values with
+// a phi when every branch that falls through produced one of the same type.
+func (cg *CodeGen) emitMerge(fn *ir.Func, name string, results []branchResult) (value.Value, *ir.Block) {
+	merge := cg.newBlock(fn, name)
+	var incomings []*ir.Incoming
 	var phiType types.Type
-	if thenVal != nil {
-		phiType = thenVal.Type()
-	}
-	if elseVal != nil {
+	hasValue := true
+	fallsThrough := false
+	for _, r := range results {
+		if r.block.Term != nil {
+			continue
+		}
+		r.block.NewBr(merge)
+		fallsThrough = true
+		if r.val == nil || r.val.Type().Equal(types.Void) {
+			hasValue = false
+			continue
+		}
 		if phiType == nil {
-			phiType = elseVal.Type()
-		} else if !elseVal.Type().Equal(phiType) {
-			panic(fmt.Sprintf("if branch type mismatch: %v vs %v", elseVal.Type(), phiType))
+			phiType = r.val.Type()
+		} else if !phiType.Equal(r.val.Type()) {
+			panic(fmt.Sprintf("branch type mismatch: %v vs %v", r.val.Type(), phiType))
 		}
+		incomings = append(incomings, ir.NewIncoming(r.val, r.block))
 	}
-	if phiType == nil {
-		mergeBlock.NewRet(nil)
-		return nil
+	if !fallsThrough {
+		merge.NewUnreachable()
 	}
-	if phiType.Equal(types.Void) {
-		return mergeBlock
+	if !hasValue || len(incomings) == 0 {
+		return nil, merge
 	}
-	var incomings []*ir.Incoming
-	if thenVal != nil {
-		incomings = append(incomings, &ir.Incoming{X: thenVal, Pred: thenBlock})
-	} else {
-		incomings = append(incomings, &ir.Incoming{X: constant.NewUndef(phiType), Pred: thenBlock})
-	}
-	if elseVal != nil {
-		incomings = append(incomings, &ir.Incoming{X: elseVal, Pred: elseBlock})
-	} else {
-		incomings = append(incomings, &ir.Incoming{X: constant.NewUndef(phiType), Pred: elseBlock})
-	}
-	if referencesBlock(b, mergeBlock) {
-		incomings = append(incomings, ir.NewIncoming(constant.NewUndef(phiType), b))
-	}
-	phi := mergeBlock.NewPhi(incomings...)
-	if mergeBlock.Term == nil {
-		if phiType.Equal(types.Void) {
-			mergeBlock.NewRet(nil)
-		} else {
-			mergeBlock.NewRet(phi)
-		}
-	} else if isTail {
-		mergeBlock.NewRet(phi)
-	}
-	return phi
+	return merge.NewPhi(incomings...), merge
 }
 
-func (cg *CodeGen) emitIfBody(b *ir.Block, body parser.Expr, isTail bool) value.Value {
-	switch x := body.(type) {
-	case *parser.BlockExpr:
-		return cg.emitBlock(b, x, isTail)
-	default:
-		return cg.emitExpr(b, body, isTail)
-	}
+// emitMatch tests the arms in order. Each arm gets a fresh block for its
+// pattern tests which branch to the next arm on failure; falling off the
+// last arm aborts with the location of the match.
+func (cg *CodeGen) emitMatch(b *ir.Block, m *parser.MatchExpr, isTail bool) (value.Value, *ir.Block) {
+	scrutinee, b := cg.emitExpr(b, m.Value, false)
+	fn := b.Parent
+	savedLocals := cg.scope()
+	var results []branchResult
+	for _, arm := range m.Arms {
+		next := cg.newBlock(fn, "match.next")
+		matched := cg.emitPattern(b, scrutinee, cg.typeOf(m.Value), arm.Pattern, next)
+		if arm.Guard != nil {
+			guard, end := cg.emitExpr(matched, arm.Guard, false)
+			body := cg.newBlock(fn, "match.arm")
+			end.NewCondBr(guard, body, next)
+			matched = body
+		}
+		val, end := cg.emitExpr(matched, arm.Body, isTail)
+		results = append(results, branchResult{val, end})
+		cg.locals = savedLocals
+		savedLocals = cg.scope()
+		b = next
+	}
+	cg.emitPanic(b, "no match arm matched", m.Pos.Line, m.Pos.Column)
+	return cg.emitMerge(fn, "match.end", results)
 }
 
-func (cg *CodeGen) emitMatch(b *ir.Block, m *parser.MatchExpr, isTail bool) value.Value {
-	parent := b.Parent
-	matchId := cg.globalMatchCount
-	cg.globalMatchCount++
-	armNames := make([]string, 0)
-	armMap := make(map[string]*parser.MatchArm)
-	armBlockMap := make(map[string]*ir.Block)
-	armCheckMap := make(map[string]*ir.Block)
-	armBodyMap := make(map[string]value.Value)
-	checkList := make([]*ir.Block, 0)
-	nextList := make([]*ir.Block, 0)
-	checkList = append(checkList, b)
-	wildCardName := fmt.Sprintf("match.%d.wild", matchId)
-	for caseId, arm := range m.Arms {
-		var name string
-		if arm.IsWildCardArm() {
-			name = wildCardName
-		} else {
-			name = fmt.Sprintf("match.%d.arm.%d", matchId, caseId)
+// emitPattern emits the tests for pat against scr, a value of type ty, into
+// b, jumping to fail when they do not hold. Variables bound by the pattern
+// are added to the current scope. The returned block is where the pattern
+// has matched.
+func (cg *CodeGen) emitPattern(b *ir.Block, scr value.Value, ty *typechecker.Type, pat parser.Pattern, fail *ir.Block) *ir.Block {
+	switch p := pat.(type) {
+	case *parser.WildcardPattern:
+		return b
+	case *parser.BindingPattern:
+		cg.bindPattern(b, p.Name.Lexeme, scr)
+		return b
+	case *parser.AsPattern:
+		b = cg.emitPattern(b, scr, ty, p.Pattern, fail)
+		cg.bindPattern(b, p.Name.Lexeme, scr)
+		return b
+	case *parser.LiteralPattern:
+		lit, b := cg.emitExpr(b, p.Value, false)
+		return cg.emitTest(b, cg.emitEquals(b, scr, lit, enum.IPredEQ), fail)
+	case *parser.RangePattern:
+		low, b := cg.emitExpr(b, p.Low, false)
+		high, b := cg.emitExpr(b, p.High, false)
+		ge, lt := enum.IPredSGE, enum.IPredSLT
+		if scr.Type().Equal(types.I8) {
+			ge, lt = enum.IPredUGE, enum.IPredULT
 		}
-		armNames = append(armNames, name)
-		armBlock := parent.NewBlock(name)
-		if caseId != 0 && !arm.IsWildCardArm() {
-			checkName := fmt.Sprintf("match.%d.check.%d", matchId, caseId)
-			checkList = append(checkList, parent.NewBlock(checkName))
-			nextList = append(nextList, checkList[len(checkList)-1])
-			armCheckMap[checkName] = checkList[len(checkList)-1]
-		} else if caseId != 0 {
-			checkList = append(checkList, nil)
-			nextList = append(nextList, armBlock)
+		b = cg.emitTest(b, b.NewICmp(ge, scr, low), fail)
+		return cg.emitTest(b, b.NewICmp(lt, scr, high), fail)
+	case *parser.ConstructorPattern:
+		ctor := cg.ctors[p.Name.Lexeme]
+		if ctor == nil {
+			panic("undefined constructor: " + p.Name.Lexeme)
 		}
-		armMap[name] = arm
-		armBlockMap[name] = armBlock
-		armBodyMap[name] = cg.emitMatchBody(armBlock, arm.Body, isTail)
-	}
-	scrutinee := cg.emitExpr(b, m.Value, false)
-	var incomings []*ir.Incoming
-	var phiType types.Type
-	mergeBlock := parent.NewBlock(fmt.Sprintf("match.%d", matchId))
-	nextList = append(nextList, mergeBlock)
-	current := b
-	armId := 0
-	for _, name := range armNames {
-		armBlock := armBlockMap[name]
-		armBody := armBodyMap[name]
-		if armBody != nil {
-			if phiType == nil {
-				phiType = armBody.Type()
-			} else if !armBody.Type().Equal(phiType) {
-				panic(fmt.Sprintf("match arm type mismatch: %v vs %v (arm %d)", armBody.Type(), phiType, armId))
-			}
+		b = cg.emitTagTest(b, scr, ctor, fail)
+		if len(p.Args) == 0 {
+			return b
 		}
-		if !armMap[name].IsWildCardArm() {
-			current = checkList[armId]
-			if current != nil && current.Term == nil {
-				arm := armMap[name]
-				cond := cg.emitMatchCond(current, scrutinee, arm.Pattern, arm.Guard)
-				current.NewCondBr(cond, armBlock, nextList[armId])
-			}
+		_, variant, _ := ty.Variant(p.Name.Lexeme)
+		payload := cg.emitPayload(b, scr, ctor)
+		for i, sub := range p.Args {
+			field := b.NewExtractValue(payload, uint64(i))
+			b = cg.emitPattern(b, field, variant.Fields[i], sub, fail)
 		}
-		if armBlock.Term == nil {
-			armBlock.NewBr(mergeBlock)
+		return b
+	case *parser.TuplePattern:
+		for i, sub := range p.Elements {
+			elem := b.NewExtractValue(scr, uint64(i))
+			b = cg.emitPattern(b, elem, ty.TElems[i], sub, fail)
 		}
-		if phiType != nil {
-			if armBody != nil {
-				incomings = append(incomings, &ir.Incoming{X: armBody, Pred: armBlock})
-			} else {
-				incomings = append(incomings, &ir.Incoming{X: constant.NewUndef(phiType), Pred: armBlock})
-			}
+		return b
+	case *parser.ListPattern:
+		return cg.emitListPattern(b, scr, ty, p, fail)
+	case *parser.OrPattern:
+		saved := cg.orSlots
+		if cg.orSlots == nil {
+			cg.orSlots = map[string]value.Value{}
 		}
-		armId++
-	}
-	if current != nil && current.Term == nil {
-		current.NewBr(mergeBlock)
-	}
-	if phiType == nil || phiType.Equal(types.Void) {
-		if mergeBlock.Term == nil {
-			mergeBlock.NewRet(nil)
+		done := cg.newBlock(b.Parent, "match.or")
+		for i, alt := range p.Alternatives {
+			next := fail
+			if i < len(p.Alternatives)-1 {
+				next = cg.newBlock(b.Parent, "match.alt")
+			}
+			cg.emitPattern(b, scr, ty, alt, next).NewBr(done)
+			b = next
 		}
-		return mergeBlock
-	}
-	phi := mergeBlock.NewPhi(incomings...)
-	if mergeBlock.Term == nil {
-		mergeBlock.NewRet(phi)
+		cg.orSlots = saved
+		return done
 	}
-	return phi
+	panic("unsupported match pattern: " + pat.NodeType())
 }
 
-func (cg *CodeGen) emitMatchBody(b *ir.Block, body parser.Expr, isTail bool) value.Value {
-	switch x := body.(type) {
-	case *parser.BlockExpr:
-		return cg.emitBlock(b, x, isTail)
-	case *parser.MatchExpr:
-		return cg.emitMatch(b, x, isTail)
-	default:
-		return cg.emitExpr(b, body, isTail)
-	}
+// emitListPattern tests the length of scr, a list of type ty, then matches
+// its first elements and, unless it is `..` alone, a new list of the
+// remaining ones against the rest pattern.
+func (cg *CodeGen) emitListPattern(b *ir.Block, scr value.Value, ty *typechecker.Type, p *parser.ListPattern, fail *ir.Block) *ir.Block {
+	elem := cg.llvmType(ty.Elem)
+	n := constant.NewInt(cg.platformIntType(), int64(len(p.Elements)))
+	pred := enum.IPredEQ
+	if p.Rest != nil {
+		pred = enum.IPredSGE
+	}
+	b = cg.emitTest(b, b.NewICmp(pred, cg.listLen(b, scr), n), fail)
+	data := cg.listData(b, scr, elem)
+	for i, sub := range p.Elements {
+		x := b.NewLoad(elem, b.NewGetElementPtr(elem, data, constant.NewInt(cg.platformIntType(), int64(i))))
+		b = cg.emitPattern(b, x, ty.Elem, sub, fail)
+	}
+	if _, ok := p.Rest.(*parser.WildcardPattern); p.Rest == nil || ok {
+		return b
+	}
+	rest, b := cg.listTail(b, scr, elem, n)
+	return cg.emitPattern(b, rest, ty, p.Rest, fail)
 }
 
-func (cg *CodeGen) emitMatchCond(b *ir.Block, scr value.Value, pat parser.Expr, guard parser.Expr) value.Value {
-	var baseCond value.Value
-	switch p := pat.(type) {
-	case *parser.IntLiteral:
-		if _, ok := scr.Type().(*types.IntType); !ok {
-			panic("match scrutinee is not an integer for integer pattern")
-		}
-		lit := constant.NewInt(types.I64, p.Value)
-		baseCond = b.NewICmp(enum.IPredEQ, scr, lit)
-	case *parser.BoolLiteral:
-		if _, ok := scr.Type().(*types.IntType); !ok {
-			panic("match scrutinee is not an integer for bool pattern")
-		}
-		var intVal int64
-		if p.Value {
-			intVal = 1
-		} else {
-			intVal = 0
-		}
-		lit := constant.NewInt(types.I1, intVal)
-		baseCond = b.NewICmp(enum.IPredEQ, scr, lit)
-	case *parser.Identifier:
-		if p.Pos.Kind == lexer.Underscore {
-			baseCond = constant.True
-		} else {
-			alloc := b.NewAlloca(scr.Type())
-			b.NewStore(scr, alloc)
-			cg.locals[p.Name] = alloc
-			baseCond = constant.True
-		}
+// emitTest continues in a new block when cond holds and jumps to fail
+// otherwise.
+func (cg *CodeGen) emitTest(b *ir.Block, cond value.Value, fail *ir.Block) *ir.Block {
+	ok := cg.newBlock(b.Parent, "match.ok")
+	b.NewCondBr(cond, ok, fail)
+	return ok
+}
+
+// bindPattern stores v in a new local. The alternatives of an or-pattern
+// share their slots so the arm body sees whichever one matched.
+func (cg *CodeGen) bindPattern(b *ir.Block, name string, v value.Value) {
+	slot, ok := cg.orSlots[name]
+	switch {
+	case ok:
+	case cg.orSlots != nil:
+		slot = cg.entrySlot(v.Type(), name)
+		cg.orSlots[name] = slot
 	default:
-		panic("unsupported match pattern: " + pat.NodeType())
+		slot = cg.localSlot(b, v.Type(), name)
 	}
-	if guard != nil {
-		guardVal := cg.emitExpr(b, guard, false)
-		if _, ok := guardVal.Type().(*types.IntType); ok {
-			if guardVal.Type() != types.I1 {
-				guardVal = b.NewTrunc(guardVal, types.I1)
-			}
-		} else {
-			panic("guard expression is not a boolean")
-		}
-		baseCond = b.NewAnd(baseCond, guardVal)
-	}
-	return baseCond
+	b.NewStore(v, slot)
+	cg.locals[name] = slot
+}
+
+// emitPanic terminates b with a call to the runtime's flint_panic.
+func (cg *CodeGen) emitPanic(b *ir.Block, msg string, line, col int) {
+	b.NewCall(cg.runtimeFunc("flint_panic"),
+		cg.emitString(&parser.StringLiteral{Value: msg}),
+		cg.emitString(&parser.StringLiteral{Value: cg.mod.SourceFilename}),
+		constant.NewInt(types.I64, int64(line)),
+		constant.NewInt(types.I64, int64(col)),
+	)
+	b.NewUnreachable()
 }
@@ -9,12 +9,18 @@ import (
 	"github.com/llir/llvm/ir/value"
 )
 
-func (cg *CodeGen) emitVarDecl(b *ir.Block, e *parser.VarDeclExpr) value.Value {
-	expr := cg.emitExpr(b, e.Value, false)
-	alloc := b.NewAlloca(expr.Type())
-	cg.locals[e.Name.Lexeme] = alloc
-	b.NewStore(expr, alloc)
-	return alloc
+func (cg *CodeGen) emitVarDecl(b *ir.Block, e *parser.VarDeclExpr) (value.Value, *ir.Block) {
+	expr, b := cg.emitExpr(b, e.Value, false)
+	if e.Pattern != nil {
+		pos := parser.PatternPos(e.Pattern)
+		fail := cg.newBlock(b.Parent, "val.fail")
+		cg.emitPanic(fail, "value does not match the pattern", pos.Line, pos.Column)
+		return expr, cg.emitPattern(b, expr, cg.typeOf(e.Value), e.Pattern, fail)
+	}
+	slot := cg.localSlot(b, expr.Type(), e.Name.Lexeme)
+	cg.locals[e.Name.Lexeme] = slot
+	b.NewStore(expr, slot)
+	return expr, b
 }
 
 func (cg *CodeGen) resolveType(t parser.Expr) types.Type {
@@ -37,6 +43,15 @@ func (cg *CodeGen) resolveType(t parser.Expr) types.Type {
 		case "Nil":
 			return types.Void
 		default:
+			if rec, ok := cg.records[ty.Name]; ok {
+				return rec.typ
+			}
+			if sum, ok := cg.variants[ty.Name]; ok {
+				return sum.typ
+			}
+			if ty.Name == "List" && ty.Generic != nil {
+				return types.NewPointer(cg.listType())
+			}
 			return nil
 		}
 	case *parser.TupleTypeExpr:
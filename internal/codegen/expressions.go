@@ -3,6 +3,7 @@ package codegen
 import (
 	"flint/internal/lexer"
 	"flint/internal/parser"
+	"flint/internal/typechecker"
 	"fmt"
 
 	"github.com/llir/llvm/ir"
@@ -12,35 +13,50 @@ import (
 	"github.com/llir/llvm/ir/value"
 )
 
-func (cg *CodeGen) emitExpr(b *ir.Block, e parser.Expr, isTail bool) value.Value {
+// emitExpr lowers e into b. Expressions with control flow end in a
+// different block than they started in, so the block that further code
+// must be appended to is returned alongside the value.
+func (cg *CodeGen) emitExpr(b *ir.Block, e parser.Expr, isTail bool) (value.Value, *ir.Block) {
 	switch v := e.(type) {
 	case *parser.IntLiteral:
-		return constant.NewInt(cg.platformIntType(), v.Value)
+		return constant.NewInt(cg.platformIntType(), v.Value), b
 	case *parser.FloatLiteral:
-		return constant.NewFloat(cg.platformFloatType(), v.Value)
+		return constant.NewFloat(cg.platformFloatType(), v.Value), b
 	case *parser.BoolLiteral:
 		if v.Value {
-			return constant.NewInt(types.I1, 1)
+			return constant.NewInt(types.I1, 1), b
 		}
-		return constant.NewInt(types.I1, 0)
+		return constant.NewInt(types.I1, 0), b
 	case *parser.ByteLiteral:
-		return constant.NewInt(types.I8, int64(v.Value))
+		return constant.NewInt(types.I8, int64(v.Value)), b
 	case *parser.StringLiteral:
-		return cg.emitString(v)
+		return cg.emitString(v), b
+	case *parser.InterpolatedString:
+		return cg.emitInterpolatedString(b, v)
 	case *parser.CallExpr:
 		return cg.emitCall(b, v, isTail)
 	case *parser.Identifier:
-		ptr := cg.locals[v.Name]
-		if ptr == nil {
-			panic("undefined variable: " + v.Name)
+		if ctor := cg.ctors[v.Name]; ctor != nil {
+			return cg.emitNullaryConstructor(ctor), b
 		}
-		return b.NewLoad(ptr.Type().(*types.PointerType).ElemType, ptr)
+		return cg.emitName(b, v), b
+	case *parser.QualifiedExpr:
+		if ctor := cg.ctors[v.Right.Lexeme]; ctor != nil {
+			return cg.emitNullaryConstructor(ctor), b
+		}
+		return cg.closureOf(cg.qualified(v, cg.typeOf(v))), b
 	case *parser.InfixExpr:
 		return cg.emitInfix(b, v)
 	case *parser.IfExpr:
 		return cg.emitIf(b, v, isTail)
 	case *parser.MatchExpr:
 		return cg.emitMatch(b, v, isTail)
+	case *parser.ForExpr:
+		return cg.emitFor(b, v)
+	case *parser.BlockExpr:
+		return cg.emitBlock(b, v, isTail)
+	case *parser.PipelineExpr:
+		return cg.emitPipeline(b, v, isTail)
 	case *parser.VarDeclExpr:
 		return cg.emitVarDecl(b, v)
 	case *parser.AssignExpr:
@@ -53,175 +69,186 @@ func (cg *CodeGen) emitExpr(b *ir.Block, e parser.Expr, isTail bool) value.Value
 		return cg.emitTuple(b, v)
 	case *parser.IndexExpr:
 		return cg.emitIndex(b, v)
+	case *parser.RecordExpr:
+		return cg.emitRecord(b, v)
+	case *parser.FieldAccessExpr:
+		return cg.emitFieldAccess(b, v)
 	case *parser.FuncDeclExpr:
-		unique := fmt.Sprintf("%s$%d", v.Name.Lexeme, len(cg.funcs))
-		retTy := cg.resolveType(v.Ret)
-		params := []*ir.Param{}
-		for _, p := range v.Params {
-			params = append(params, ir.NewParam(p.Name.Lexeme, cg.resolveType(p.Type)))
-		}
-		irfn := cg.mod.NewFunc(unique, retTy, params...)
-		cg.funcs[v.Name.Lexeme] = irfn
-		savedLocals := cg.locals
-		cg.locals = map[string]value.Value{}
-		entry := irfn.NewBlock("entry")
-		for _, param := range irfn.Params {
-			alloc := entry.NewAlloca(param.Type())
-			entry.NewStore(param, alloc)
-			cg.locals[param.Name()] = alloc
-		}
-		block := v.Body.(*parser.BlockExpr)
-		lastVal := cg.emitBlock(entry, block, v.Recursion)
-		if retTy.Equal(types.Void) {
-			entry.NewRet(nil)
-		} else if lastVal != nil {
-			if parent := parentBlockOfValue(lastVal); parent != nil && parent.Term == nil {
-				parent.NewRet(lastVal)
-			} else if entry.Term == nil {
-				entry.NewRet(lastVal)
-			}
-		} else {
-			entry.NewRet(constant.NewInt(types.I64, 0))
-		}
-		cg.locals = savedLocals
-		return nil
+		return nil, cg.emitNestedFunction(b, v)
+	case *parser.LambdaExpr:
+		return cg.emitLambda(b, v)
+	case *parser.TypeDeclExpr:
+		return nil, b
 	default:
-		panic("unsupported expression type")
+		panic(fmt.Sprintf("unsupported expression type: %T", e))
 	}
 }
 
-func (cg *CodeGen) emitInfix(b *ir.Block, e *parser.InfixExpr) value.Value {
-	l := cg.emitExpr(b, e.Left, false)
-	r := cg.emitExpr(b, e.Right, false)
+func (cg *CodeGen) emitInfix(b *ir.Block, e *parser.InfixExpr) (value.Value, *ir.Block) {
+	switch e.Operator.Kind {
+	case lexer.AmperAmper, lexer.VbarVbar:
+		return cg.emitShortCircuit(b, e)
+	}
+	l, b := cg.emitExpr(b, e.Left, false)
+	r, b := cg.emitExpr(b, e.Right, false)
 	switch e.Operator.Kind {
 	case lexer.Plus:
-		return b.NewAdd(l, r)
+		return b.NewAdd(l, r), b
 	case lexer.Minus:
-		return b.NewSub(l, r)
+		return b.NewSub(l, r), b
 	case lexer.Star:
-		return b.NewMul(l, r)
+		return b.NewMul(l, r), b
 	case lexer.Slash:
-		return b.NewSDiv(l, r)
+		return b.NewSDiv(l, r), b
 	case lexer.Percent:
-		return b.NewSRem(l, r)
+		return b.NewSRem(l, r), b
 	case lexer.Less:
-		return b.NewICmp(enum.IPredSLT, l, r)
+		return b.NewICmp(enum.IPredSLT, l, r), b
 	case lexer.Greater:
-		return b.NewICmp(enum.IPredSGT, l, r)
+		return b.NewICmp(enum.IPredSGT, l, r), b
 	case lexer.LessEqual:
-		return b.NewICmp(enum.IPredSLE, l, r)
+		return b.NewICmp(enum.IPredSLE, l, r), b
 	case lexer.GreaterEqual:
-		return b.NewICmp(enum.IPredSGE, l, r)
+		return b.NewICmp(enum.IPredSGE, l, r), b
 	case lexer.EqualEqual:
-		return b.NewICmp(enum.IPredEQ, l, r)
+		return cg.emitEquals(b, l, r, enum.IPredEQ), b
 	case lexer.NotEqual:
-		return b.NewICmp(enum.IPredNE, l, r)
+		return cg.emitEquals(b, l, r, enum.IPredNE), b
 	case lexer.PlusDot:
-		return b.NewFAdd(l, r)
+		return b.NewFAdd(l, r), b
 	case lexer.MinusDot:
-		return b.NewFSub(l, r)
+		return b.NewFSub(l, r), b
 	case lexer.StarDot:
-		return b.NewFMul(l, r)
+		return b.NewFMul(l, r), b
 	case lexer.SlashDot:
-		return b.NewFDiv(l, r)
+		return b.NewFDiv(l, r), b
 	case lexer.LessDot:
-		return b.NewFCmp(enum.FPredOLT, l, r)
+		return b.NewFCmp(enum.FPredOLT, l, r), b
 	case lexer.GreaterDot:
-		return b.NewFCmp(enum.FPredOGT, l, r)
+		return b.NewFCmp(enum.FPredOGT, l, r), b
 	case lexer.LessEqualDot:
-		return b.NewFCmp(enum.FPredOLE, l, r)
+		return b.NewFCmp(enum.FPredOLE, l, r), b
 	case lexer.GreaterEqualDot:
-		return b.NewFCmp(enum.FPredOGE, l, r)
+		return b.NewFCmp(enum.FPredOGE, l, r), b
 	case lexer.LtGt:
-		// return cg.emitConcat(b, e, l, r)
-		return nil
-	case lexer.AmperAmper:
-		return b.NewAnd(l, r)
-	case lexer.VbarVbar:
-		return b.NewOr(l, r)
+		return b.NewCall(cg.runtimeFunc("flint_string_concat"), l, r), b
+	case lexer.DotDot:
+		rng := constant.NewUndef(types.NewStruct(l.Type(), r.Type()))
+		return b.NewInsertValue(b.NewInsertValue(rng, l, 0), r, 1), b
 	}
 	panic("unsupported operator")
 }
 
-func (cg *CodeGen) emitPrefix(b *ir.Block, e *parser.PrefixExpr) value.Value {
-	expr := cg.emitExpr(b, e.Right, false)
+// emitEquals compares two scalars. Strings are compared by content, every
+// other supported type by value.
+func (cg *CodeGen) emitEquals(b *ir.Block, l, r value.Value, pred enum.IPred) value.Value {
+	switch l.Type().(type) {
+	case *types.FloatType:
+		if pred == enum.IPredEQ {
+			return b.NewFCmp(enum.FPredOEQ, l, r)
+		}
+		return b.NewFCmp(enum.FPredUNE, l, r)
+	case *types.PointerType:
+		cmp := b.NewCall(cg.runtimeFunc("strcmp"), l, r)
+		return b.NewICmp(pred, cmp, constant.NewInt(types.I32, 0))
+	}
+	return b.NewICmp(pred, l, r)
+}
+
+func (cg *CodeGen) emitShortCircuit(b *ir.Block, e *parser.InfixExpr) (value.Value, *ir.Block) {
+	l, b := cg.emitExpr(b, e.Left, false)
+	rhs := cg.newBlock(b.Parent, "logic.rhs")
+	merge := cg.newBlock(b.Parent, "logic.end")
+	var short value.Value
+	if e.Operator.Kind == lexer.AmperAmper {
+		short = constant.False
+		b.NewCondBr(l, rhs, merge)
+	} else {
+		short = constant.True
+		b.NewCondBr(l, merge, rhs)
+	}
+	r, end := cg.emitExpr(rhs, e.Right, false)
+	end.NewBr(merge)
+	return merge.NewPhi(ir.NewIncoming(short, b), ir.NewIncoming(r, end)), merge
+}
+
+func (cg *CodeGen) emitPrefix(b *ir.Block, e *parser.PrefixExpr) (value.Value, *ir.Block) {
+	expr, b := cg.emitExpr(b, e.Right, false)
 	ty := expr.Type()
 	switch e.Operator.Kind {
 	case lexer.Minus:
 		intType := ty.(*types.IntType)
-		return b.NewSub(constant.NewInt(intType, 0), expr)
+		return b.NewSub(constant.NewInt(intType, 0), expr), b
 	case lexer.MinusDot:
 		floatType := ty.(*types.FloatType)
-		return b.NewFSub(constant.NewFloat(floatType, 0), expr)
+		return b.NewFSub(constant.NewFloat(floatType, 0), expr), b
 	case lexer.Bang:
-		return b.NewXor(constant.True, expr)
+		return b.NewXor(constant.True, expr), b
 	}
-	return nil
+	return nil, b
 }
 
-func (cg *CodeGen) emitList(b *ir.Block, e *parser.ListExpr) value.Value {
-	exprs := make([]value.Value, 0)
-	for _, elem := range e.Elements {
-		expr := cg.emitExpr(b, elem, false)
-		exprs = append(exprs, expr)
+// emitPipeline lowers `x |> f(a)` to `f(x, a)` and `x |> f` to `f(x)`.
+func (cg *CodeGen) emitPipeline(b *ir.Block, p *parser.PipelineExpr, isTail bool) (value.Value, *ir.Block) {
+	call := &parser.CallExpr{Callee: p.Right, Args: []parser.Expr{p.Left}, Pos: p.Pos}
+	if c, ok := p.Right.(*parser.CallExpr); ok {
+		call = &parser.CallExpr{Callee: c.Callee, Args: append([]parser.Expr{p.Left}, c.Args...), Pos: c.Pos}
 	}
-	listType := exprs[0].Type()
-	alloc := b.NewAlloca(types.NewArray(uint64(len(exprs)), listType))
-	for idx, expr := range exprs {
-		index := constant.NewInt(types.I32, int64(idx))
-		elemPtr := b.NewGetElementPtr(listType, alloc, index)
-		b.NewStore(expr, elemPtr)
+	return cg.emitCall(b, call, isTail)
+}
+
+// emitList copies the elements into a new list of the runtime, with room
+// for exactly as many elements.
+func (cg *CodeGen) emitList(b *ir.Block, e *parser.ListExpr) (value.Value, *ir.Block) {
+	exprs := make([]value.Value, len(e.Elements))
+	for i, elem := range e.Elements {
+		exprs[i], b = cg.emitExpr(b, elem, false)
+	}
+	elemType := cg.llvmType(cg.typeOf(e).Elem)
+	list := b.NewCall(cg.runtimeFunc("flint_list_new"), cg.sizeOf(elemType), constant.NewInt(types.I64, int64(len(exprs))))
+	if len(exprs) == 0 {
+		return list, b
+	}
+	data := cg.listData(b, list, elemType)
+	for i, expr := range exprs {
+		b.NewStore(expr, b.NewGetElementPtr(elemType, data, constant.NewInt(types.I64, int64(i))))
 	}
-	return alloc
+	return list, b
 }
 
-func (cg *CodeGen) emitIndex(b *ir.Block, e *parser.IndexExpr) value.Value {
-	target := cg.emitExpr(b, e.Target, false)
-	indexVal := cg.emitExpr(b, e.Index, false)
-	switch e.Target.(type) {
-	case *parser.TupleExpr, *parser.Identifier:
-		idxConst, ok := indexVal.(*constant.Int)
-		if !ok {
-			panic("Index must be constant int for tuple")
-		}
-		ptr, ok := target.(*ir.InstAlloca)
+func (cg *CodeGen) emitIndex(b *ir.Block, e *parser.IndexExpr) (value.Value, *ir.Block) {
+	target, b := cg.emitExpr(b, e.Target, false)
+	if _, ok := target.Type().(*types.StructType); ok {
+		idx, ok := e.Index.(*parser.IntLiteral)
 		if !ok {
-			ptrType := target.Type()
-			ptr = b.NewAlloca(ptrType)
-			b.NewStore(target, ptr)
+			panic("tuple index must be an integer literal")
 		}
-		structType := ptr.Type().(*types.PointerType).ElemType.(*types.StructType)
-		elemPtr := b.NewGetElementPtr(
-			structType,
-			ptr,
-			constant.NewInt(types.I32, 0),
-			constant.NewInt(types.I32, idxConst.X.Int64()),
-		)
-		return b.NewLoad(structType.Fields[idxConst.X.Int64()], elemPtr)
-	default:
-		elemType := target.Type().(*types.PointerType).ElemType
-		elemPtr := b.NewGetElementPtr(
-			elemType,
-			target,
-			indexVal,
-		)
-		return b.NewLoad(elemType, elemPtr)
+		return b.NewExtractValue(target, uint64(idx.Value)), b
+	}
+	indexVal, b := cg.emitExpr(b, e.Index, false)
+	ty := cg.typeOf(e.Target)
+	if ty != nil && ty.TKind == typechecker.TyList {
+		elemType := cg.llvmType(cg.typeOf(e))
+		return b.NewLoad(elemType, cg.listAt(b, target, indexVal, elemType, e.First.Line, e.First.Column)), b
+	}
+	if ty != nil && ty.TKind == typechecker.TyString {
+		return cg.stringAt(b, target, indexVal, e.First.Line, e.First.Column), b
 	}
+	elemType := target.Type().(*types.PointerType).ElemType
+	elemPtr := b.NewGetElementPtr(elemType, target, indexVal)
+	return b.NewLoad(elemType, elemPtr), b
 }
 
-func (cg *CodeGen) emitTuple(b *ir.Block, e *parser.TupleExpr) value.Value {
+func (cg *CodeGen) emitTuple(b *ir.Block, e *parser.TupleExpr) (value.Value, *ir.Block) {
 	exprs := make([]value.Value, len(e.Elements))
 	tTypes := make([]types.Type, len(e.Elements))
 	for i, elem := range e.Elements {
-		exprs[i] = cg.emitExpr(b, elem, false)
+		exprs[i], b = cg.emitExpr(b, elem, false)
 		tTypes[i] = exprs[i].Type()
 	}
-	tupleType := types.NewStruct(tTypes...)
-	alloc := b.NewAlloca(tupleType)
+	var agg value.Value = constant.NewUndef(types.NewStruct(tTypes...))
 	for i, expr := range exprs {
-		index := constant.NewInt(types.I32, int64(i))
-		elemPtr := b.NewGetElementPtr(tupleType, alloc, constant.NewInt(types.I32, 0), index)
-		b.NewStore(expr, elemPtr)
+		agg = b.NewInsertValue(agg, expr, uint64(i))
 	}
-	return alloc
+	return agg, b
 }
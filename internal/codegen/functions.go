@@ -2,6 +2,7 @@ package codegen
 
 import (
 	"flint/internal/parser"
+	"fmt"
 
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
@@ -17,61 +18,88 @@ func (cg *CodeGen) emitFunction(fn *parser.FuncDeclExpr) {
 		cg.emitExternalFunction(fn, 0, name, mainFn)
 		return
 	}
+	cg.emitBody(mainFn, fn, name == "main")
+}
+
+// emitNestedFunction lowers a function declared inside another one to a
+// module-level function with a unique name. One that uses the locals
+// around it is bound, as a closure capturing them, to a local of its name,
+// which it captures too so that it may call itself.
+func (cg *CodeGen) emitNestedFunction(b *ir.Block, fn *parser.FuncDeclExpr) *ir.Block {
+	ty := cg.typeOf(fn)
+	if ty.HasVars() {
+		cg.generics[fn.Name.Lexeme] = fn
+		return b
+	}
+	delete(cg.generics, fn.Name.Lexeme)
+	if len(cg.captured(fn.Body)) > 0 {
+		slot := cg.localSlot(b, cg.closureType(ty), fn.Name.Lexeme)
+		cg.locals[fn.Name.Lexeme] = slot
+		cg.lambdaCount++
+		name := fmt.Sprintf("%s$closure.%d", cg.symbol(fn.Name.Lexeme), cg.lambdaCount)
+		closure, b := cg.emitClosure(b, name, fn.Params, fn.Body, ty)
+		b.NewStore(closure, slot)
+		return b
+	}
+	unique := fmt.Sprintf("%s$%d", cg.symbol(fn.Name.Lexeme), len(cg.funcs))
+	irfn := cg.declareFunction(unique, fn, ty)
+	irfn.Linkage = enum.LinkageInternal
+	cg.funcs[fn.Name.Lexeme] = irfn
+	restore := cg.saveFunc()
+	cg.emitBody(irfn, fn, false)
+	restore()
+	return b
+}
+
+func (cg *CodeGen) emitBody(irfn *ir.Func, fn *parser.FuncDeclExpr, isMain bool) {
 	cg.locals = map[string]value.Value{}
-	entry := mainFn.NewBlock("entry")
-	for _, param := range mainFn.Params {
-		alloc := entry.NewAlloca(param.Type())
-		entry.NewStore(param, alloc)
-		cg.locals[param.Name()] = alloc
+	cg.orSlots = nil
+	cg.boxed = capturedNames(fn.Body)
+	cg.entry = irfn.NewBlock("entry")
+	params := irfn.Params
+	if isMain {
+		// Hand the command-line arguments to the runtime, for flint/os.
+		cg.entry.NewCall(cg.runtimeFunc("flint_set_args"), params[0], params[1])
+		params = params[2:]
 	}
-	if fn.Body == nil {
-		if name == "main" {
-			entry.NewRet(constant.NewInt(types.I32, 0))
-		} else if mainFn.Sig.RetType.Equal(types.Void) {
-			entry.NewRet(nil)
-		} else {
-			cg.emitDefaultReturn(entry, mainFn.Sig.RetType, false)
-		}
-		return
+	for _, param := range params {
+		slot := cg.localSlot(cg.entry, param.Type(), param.Name())
+		cg.entry.NewStore(param, slot)
+		cg.locals[param.Name()] = slot
 	}
-	block := fn.Body.(*parser.BlockExpr)
-	lastVal := cg.emitBlock(entry, block, fn.Recursion)
-	retTy := mainFn.Sig.RetType
-	if name == "main" {
-		exit := mainFn.NewBlock("main.exit")
-		for _, bb := range mainFn.Blocks {
-			if bb.Term == nil && bb != exit {
-				bb.NewBr(exit)
-			}
-		}
-		exit.NewRet(constant.NewInt(types.I32, 0))
+	if fn.Body == nil {
+		cg.emitReturn(cg.entry, nil, irfn.Sig.RetType, isMain)
 		return
 	}
-	if retTy.Equal(types.Void) {
-		if entry.Term == nil {
-			entry.NewRet(nil)
-		}
-		return
+	lastVal, end := cg.emitExpr(cg.entry, fn.Body, fn.Recursion)
+	if end.Term == nil {
+		cg.emitReturn(end, lastVal, irfn.Sig.RetType, isMain)
 	}
-	if lastVal != nil {
-		if b := parentBlockOfValue(lastVal); b != nil && b.Term == nil {
-			b.NewRet(lastVal)
-		} else if entry.Term == nil {
-			entry.NewRet(lastVal)
-		}
-	} else if entry.Term == nil {
-		cg.emitDefaultReturn(entry, retTy, false)
+}
+
+// emitReturn terminates b, returning v when it matches the function's
+// return type and a zero value otherwise.
+func (cg *CodeGen) emitReturn(b *ir.Block, v value.Value, ret types.Type, isMain bool) {
+	switch {
+	case isMain || ret.Equal(types.Void):
+		cg.emitDefaultReturn(b, ret, isMain)
+	case v != nil && v.Type().Equal(ret):
+		b.NewRet(v)
+	default:
+		cg.emitDefaultReturn(b, ret, false)
 	}
 }
 
-func (cg *CodeGen) emitBlock(b *ir.Block, blk *parser.BlockExpr, isTail bool) value.Value {
+func (cg *CodeGen) emitBlock(b *ir.Block, blk *parser.BlockExpr, isTail bool) (value.Value, *ir.Block) {
+	saved := cg.scope()
 	var last value.Value
 	n := len(blk.Exprs)
 	for i, e := range blk.Exprs {
 		tail := isTail && i == n-1
-		last = cg.emitExpr(b, e, tail)
+		last, b = cg.emitExpr(b, e, tail)
 	}
-	return last
+	cg.locals = saved
+	return last, b
 }
 
 func (cg *CodeGen) emitDefaultReturn(b *ir.Block, ret types.Type, isMain bool) {
@@ -86,6 +114,8 @@ func (cg *CodeGen) emitDefaultReturn(b *ir.Block, ret types.Type, isMain bool) {
 		b.NewRet(constant.NewFloat(t, 0))
 	case *types.PointerType:
 		b.NewRet(constant.NewNull(t))
+	case *types.StructType:
+		b.NewRet(constant.NewZeroInitializer(t))
 	case *types.VoidType:
 		b.NewRet(nil)
 	default:
@@ -93,26 +123,48 @@ func (cg *CodeGen) emitDefaultReturn(b *ir.Block, ret types.Type, isMain bool) {
 	}
 }
 
-func (cg *CodeGen) emitCall(b *ir.Block, c *parser.CallExpr, isTail bool) value.Value {
-	id, ok := c.Callee.(*parser.Identifier)
-	if !ok {
-		panic("only simple function calls supported")
+// emitCall calls global functions directly and any other function value
+// through its closure.
+func (cg *CodeGen) emitCall(b *ir.Block, c *parser.CallExpr, isTail bool) (value.Value, *ir.Block) {
+	var direct *ir.Func
+	var closure value.Value
+	if id, ok := c.Callee.(*parser.Identifier); ok && cg.locals[id.Name] == nil {
+		if ctor := cg.ctors[id.Name]; ctor != nil {
+			args, end := cg.emitArgs(b, c.Args)
+			return cg.emitConstructor(end, ctor, args), end
+		}
+		direct = cg.funcRef(id.Name, cg.typeOf(id))
+		if direct == nil {
+			panic("undefined function: " + id.Name)
+		}
+	} else if q, ok := c.Callee.(*parser.QualifiedExpr); ok {
+		if ctor := cg.ctors[q.Right.Lexeme]; ctor != nil {
+			args, end := cg.emitArgs(b, c.Args)
+			return cg.emitConstructor(end, ctor, args), end
+		}
+		direct = cg.qualified(q, cg.typeOf(q))
+	} else {
+		closure, b = cg.emitExpr(b, c.Callee, false)
 	}
-	fn := cg.funcs[id.Name]
-	if fn == nil {
-		panic("undefined function: " + id.Name)
+	args, b := cg.emitArgs(b, c.Args)
+	var callInst *ir.InstCall
+	if direct != nil {
+		callInst = b.NewCall(direct, args...)
+	} else {
+		callInst = cg.emitClosureCall(b, closure, args)
 	}
-	var args []value.Value
-	for _, arg := range c.Args {
-		args = append(args, cg.emitExpr(b, arg, false))
+	if isTail && callInst.Type().Equal(b.Parent.Sig.RetType) {
+		callInst.Tail = enum.TailTail
 	}
-	callInst := b.NewCall(fn, args...)
-	if isTail {
-		callerRet := b.Parent.Sig.RetType
-		calleeRet := fn.Sig.RetType
-		if calleeRet != nil && callerRet != nil && calleeRet.Equal(callerRet) {
-			callInst.Tail = enum.TailTail
-		}
+	return callInst, b
+}
+
+func (cg *CodeGen) emitArgs(b *ir.Block, exprs []parser.Expr) ([]value.Value, *ir.Block) {
+	var args []value.Value
+	for _, arg := range exprs {
+		var v value.Value
+		v, b = cg.emitExpr(b, arg, false)
+		args = append(args, v)
 	}
-	return callInst
+	return args, b
 }
@@ -0,0 +1,196 @@
+package codegen
+
+import (
+	"flint/internal/parser"
+	"flint/internal/typechecker"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// typeOf returns the inferred type of e with the type variables of the
+// function being specialised replaced by the types they stand for.
+func (cg *CodeGen) typeOf(e parser.Expr) *typechecker.Type {
+	if cg.tc == nil {
+		return nil
+	}
+	return cg.substitute(cg.tc.TypeOf(e))
+}
+
+func (cg *CodeGen) substitute(t *typechecker.Type) *typechecker.Type {
+	if t == nil {
+		return nil
+	}
+	if t.TKind == typechecker.TyVar {
+		if s, ok := cg.subst[t]; ok {
+			return s
+		}
+		return t
+	}
+	cp := *t
+	switch t.TKind {
+	case typechecker.TyFunc:
+		cp.Params = make([]*typechecker.Type, len(t.Params))
+		for i, p := range t.Params {
+			cp.Params[i] = cg.substitute(p)
+		}
+		cp.Ret = cg.substitute(t.Ret)
+	case typechecker.TyList, typechecker.TyRange:
+		cp.Elem = cg.substitute(t.Elem)
+	case typechecker.TyTuple:
+		cp.TElems = make([]*typechecker.Type, len(t.TElems))
+		for i, e := range t.TElems {
+			cp.TElems[i] = cg.substitute(e)
+		}
+	}
+	return &cp
+}
+
+// llvmType maps a checked type to its representation. Functions are passed
+// around as closures, and type variables that no use constrained get the
+// representation of Int.
+func (cg *CodeGen) llvmType(t *typechecker.Type) types.Type {
+	switch t.TKind {
+	case typechecker.TyInt, typechecker.TyVar:
+		return cg.platformIntType()
+	case typechecker.TyFloat:
+		return cg.platformFloatType()
+	case typechecker.TyBool:
+		return types.I1
+	case typechecker.TyByte:
+		return types.I8
+	case typechecker.TyString:
+		return types.I8Ptr
+	case typechecker.TyNil:
+		return types.Void
+	case typechecker.TyList:
+		return types.NewPointer(cg.listType())
+	case typechecker.TyRange:
+		return types.NewStruct(cg.platformIntType(), cg.platformIntType())
+	case typechecker.TyTuple:
+		elems := make([]types.Type, len(t.TElems))
+		for i, e := range t.TElems {
+			elems[i] = cg.llvmType(e)
+		}
+		return types.NewStruct(elems...)
+	case typechecker.TyRecord:
+		return cg.records[t.Name].typ
+	case typechecker.TyVariant:
+		return cg.variants[t.Name].typ
+	case typechecker.TyFunc:
+		return cg.closureType(t)
+	}
+	panic("unsupported type: " + t.String())
+}
+
+// declareFunction adds fn to the module with the parameter and return types
+// of ty. main always returns the process exit code, and takes the
+// command-line arguments as C's main does.
+func (cg *CodeGen) declareFunction(name string, fn *parser.FuncDeclExpr, ty *typechecker.Type) *ir.Func {
+	ret := cg.llvmType(ty.Ret)
+	params := []*ir.Param{}
+	if fn.Name.Lexeme == "main" {
+		ret = types.I32
+		params = append(params, ir.NewParam("argc", types.I32), ir.NewParam("argv", types.NewPointer(types.I8Ptr)))
+	}
+	for i, p := range fn.Params {
+		params = append(params, ir.NewParam(p.Name.Lexeme, cg.llvmType(ty.Params[i])))
+	}
+	return cg.mod.NewFunc(name, ret, params...)
+}
+
+// funcRef returns the function called name at the type it is used at,
+// emitting a specialisation of a generic function the first time it is
+// needed at a given type.
+func (cg *CodeGen) funcRef(name string, use *typechecker.Type) *ir.Func {
+	gen, ok := cg.generics[name]
+	if !ok {
+		if fn := cg.funcs[name]; fn != nil {
+			return fn
+		}
+		if dep := cg.members[name]; dep != nil {
+			return cg.importedFunc(dep, name, use)
+		}
+		if path, ok := cg.stdMembers[name]; ok {
+			return cg.stdFunc(path, name, use)
+		}
+		return nil
+	}
+	if use == nil {
+		panic("cannot determine the type of generic function " + name)
+	}
+	mangled := name + "$" + mangle(use)
+	if fn, ok := cg.funcs[mangled]; ok {
+		return fn
+	}
+	subst := map[*typechecker.Type]*typechecker.Type{}
+	matchTypes(cg.tc.TypeOf(gen), use, subst)
+	fn := cg.declareFunction(cg.symbol(mangled), gen, use)
+	fn.Linkage = enum.LinkageInternal
+	cg.funcs[mangled] = fn
+
+	restore, savedSubst, savedFunc := cg.saveFunc(), cg.subst, cg.funcs[name]
+	cg.subst = subst
+	cg.emitBody(fn, gen, false)
+	restore()
+	cg.subst, cg.funcs[name] = savedSubst, savedFunc
+	return fn
+}
+
+// emitName loads the local called id, or makes a closure of the global
+// function of that name at the type it is used at.
+func (cg *CodeGen) emitName(b *ir.Block, id *parser.Identifier) value.Value {
+	if ptr, ok := cg.locals[id.Name]; ok {
+		return b.NewLoad(ptr.Type().(*types.PointerType).ElemType, ptr)
+	}
+	fn := cg.funcRef(id.Name, cg.typeOf(id))
+	if fn == nil {
+		panic("undefined variable: " + id.Name)
+	}
+	return cg.closureOf(fn)
+}
+
+// matchTypes records in subst what each type variable of gen stands for in
+// the concrete type use.
+func matchTypes(gen, use *typechecker.Type, subst map[*typechecker.Type]*typechecker.Type) {
+	if gen == nil || use == nil {
+		return
+	}
+	if gen.TKind == typechecker.TyVar {
+		subst[gen] = use
+		return
+	}
+	switch gen.TKind {
+	case typechecker.TyFunc:
+		for i := range gen.Params {
+			if i < len(use.Params) {
+				matchTypes(gen.Params[i], use.Params[i], subst)
+			}
+		}
+		matchTypes(gen.Ret, use.Ret, subst)
+	case typechecker.TyList, typechecker.TyRange:
+		matchTypes(gen.Elem, use.Elem, subst)
+	case typechecker.TyTuple:
+		for i := range gen.TElems {
+			if i < len(use.TElems) {
+				matchTypes(gen.TElems[i], use.TElems[i], subst)
+			}
+		}
+	}
+}
+
+// mangle turns a type into a string usable in a symbol name.
+func mangle(t *typechecker.Type) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == ' ':
+			return -1
+		}
+		return '_'
+	}, t.String())
+}
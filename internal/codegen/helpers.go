@@ -3,9 +3,13 @@ package codegen
 import (
 	"bytes"
 	"flint/internal/typechecker"
+	"fmt"
 	"os/exec"
 
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
 )
 
 func (cg *CodeGen) newStrLabel() string {
@@ -14,6 +18,45 @@ func (cg *CodeGen) newStrLabel() string {
 	return name
 }
 
+// newBlock appends a basic block to fn with a label made unique by a
+// module-wide counter.
+func (cg *CodeGen) newBlock(fn *ir.Func, name string) *ir.Block {
+	cg.blockCount++
+	return fn.NewBlock(fmt.Sprintf("%s.%d", name, cg.blockCount))
+}
+
+// entryAlloca reserves a stack slot in the entry block of the current
+// function so that allocas inside loops and branches do not grow the stack.
+func (cg *CodeGen) entryAlloca(t types.Type) *ir.InstAlloca {
+	alloc := ir.NewAlloca(t)
+	cg.entry.Insts = append([]ir.Instruction{alloc}, cg.entry.Insts...)
+	return alloc
+}
+
+// emitMalloc allocates room for a value of type t on the heap and returns
+// the untyped pointer to it.
+func (cg *CodeGen) emitMalloc(b *ir.Block, t types.Type) value.Value {
+	return b.NewCall(cg.runtimeFunc("malloc"), cg.sizeOf(t))
+}
+
+// sizeOf returns the size of a value of type t in bytes, as an i64.
+func (cg *CodeGen) sizeOf(t types.Type) constant.Constant {
+	return constant.NewPtrToInt(
+		constant.NewGetElementPtr(t, constant.NewNull(types.NewPointer(t)), constant.NewInt(types.I32, 1)),
+		types.I64,
+	)
+}
+
+// scope returns a copy of the current locals, to be restored when the
+// lexical scope that is about to be entered ends.
+func (cg *CodeGen) scope() map[string]value.Value {
+	saved := make(map[string]value.Value, len(cg.locals))
+	for k, v := range cg.locals {
+		saved[k] = v
+	}
+	return saved
+}
+
 func (cg *CodeGen) platformIntType() *types.IntType {
 	if typechecker.PlatformIntBits == 32 {
 		return types.I32
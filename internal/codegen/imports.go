@@ -0,0 +1,292 @@
+package codegen
+
+import (
+	"flint/internal/lexer"
+	"flint/internal/module"
+	"flint/internal/parser"
+	"flint/internal/stdlib"
+	"flint/internal/typechecker"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+)
+
+// depState is what generating code for a module imported by the one being
+// compiled needs: its functions, declared in the module being compiled,
+// and the names its own `use` declarations bring into scope.
+type depState struct {
+	funcs      map[string]*ir.Func
+	generics   map[string]*parser.FuncDeclExpr
+	uses       map[string]*module.Module
+	members    map[string]*module.Module
+	stdUses    map[string]string
+	stdMembers map[string]string
+}
+
+// stdFuncs generate the functions of the built-in modules that have no
+// Symbol in the stdlib registry, by module path and name.
+var stdFuncs = map[string]map[string]func(cg *CodeGen, ty *typechecker.Type) *ir.Func{
+	"flint/list":   listFuncs,
+	"flint/string": stringFuncs,
+}
+
+// GenerateModule lowers m, one module of a program made of several that
+// are compiled to an LLVM module each and linked together. The functions
+// of the modules m imports are declared, and their generic functions are
+// specialised in m. Names are qualified by the module path, except in the
+// entry module, which holds main.
+func GenerateModule(m *module.Module, entry bool) string {
+	cg := newCodeGen(m.TC, m.File)
+	if !entry {
+		cg.unit = m
+	}
+	progs := []*parser.Program{m.Prog}
+	for _, dep := range imported(m) {
+		progs = append(progs, dep.Prog)
+	}
+	cg.declareTypes(progs...)
+	cg.uses, cg.members = usesOf(m)
+	cg.stdUses, cg.stdMembers = stdUsesOf(m.Prog)
+	cg.generateFuncs(m.Prog)
+	return cg.mod.String()
+}
+
+// imported returns the modules m imports, directly or not.
+func imported(m *module.Module) []*module.Module {
+	var out []*module.Module
+	seen := map[*module.Module]bool{m: true}
+	var walk func(m *module.Module)
+	walk = func(m *module.Module) {
+		for _, dep := range m.Imports {
+			if !seen[dep] {
+				seen[dep] = true
+				walk(dep)
+				out = append(out, dep)
+			}
+		}
+	}
+	walk(m)
+	return out
+}
+
+// usesOf maps the names the `use` declarations of m bring into scope to
+// the source modules they come from: module names such as `models` in
+// uses, and the members listed in `use app/models.{User}` in members.
+func usesOf(m *module.Module) (uses, members map[string]*module.Module) {
+	uses, members = map[string]*module.Module{}, map[string]*module.Module{}
+	for _, e := range m.Prog.Exprs {
+		u, ok := e.(*parser.UseExpr)
+		if !ok {
+			continue
+		}
+		dep := m.Imports[strings.Join(u.Path, "/")]
+		if dep == nil {
+			continue
+		}
+		if len(u.Members) == 0 {
+			name := u.Alias
+			if name == "" {
+				name = u.Path[len(u.Path)-1]
+			}
+			uses[name] = dep
+		}
+		for _, name := range u.Members {
+			members[name] = dep
+		}
+	}
+	return uses, members
+}
+
+// stdUsesOf is usesOf for the built-in modules prog uses, mapping names to
+// module paths.
+func stdUsesOf(prog *parser.Program) (uses, members map[string]string) {
+	uses, members = map[string]string{}, map[string]string{}
+	for _, e := range prog.Exprs {
+		u, ok := e.(*parser.UseExpr)
+		if !ok {
+			continue
+		}
+		path := strings.Join(u.Path, "/")
+		if !typechecker.HasModule(path) {
+			continue
+		}
+		if len(u.Members) == 0 {
+			name := u.Alias
+			if name == "" {
+				name = u.Path[len(u.Path)-1]
+			}
+			uses[name] = path
+		}
+		for _, name := range u.Members {
+			members[name] = path
+		}
+	}
+	return uses, members
+}
+
+// stdFunc returns the function name of the built-in module path at the type
+// use: one calling the runtime function the registry gives, or one of
+// stdFuncs.
+func (cg *CodeGen) stdFunc(path, name string, use *typechecker.Type) *ir.Func {
+	if gen, ok := stdFuncs[path][name]; ok {
+		return gen(cg, use)
+	}
+	m, _ := stdlib.Lookup(path)
+	if m == nil {
+		panic("unsupported built-in module " + path)
+	}
+	f, ok := m.Func(name)
+	if !ok || f.Symbol == "" {
+		panic("unsupported built-in function " + path + ":" + name)
+	}
+	qualified := strings.ReplaceAll(path, "/", ".") + "." + name
+	if f.Fallible {
+		return cg.fallibleStd(qualified, f.Symbol, use)
+	}
+	return cg.runtimeStd(qualified, f.Symbol, use)
+}
+
+// stdTypes returns the declarations of the types of the built-in modules
+// that progs use.
+func stdTypes(progs []*parser.Program) []*parser.TypeDeclExpr {
+	var out []*parser.TypeDeclExpr
+	for _, prog := range progs {
+		for _, e := range prog.Exprs {
+			u, ok := e.(*parser.UseExpr)
+			if !ok {
+				continue
+			}
+			m, ok := stdlib.Lookup(strings.Join(u.Path, "/"))
+			if !ok {
+				continue
+			}
+			for _, t := range m.Types {
+				tokens, err := lexer.Tokenize(t.Decl, m.Path)
+				decl, diags := parser.ParseProgram(tokens)
+				if err != nil || len(diags) > 0 || len(decl.Exprs) != 1 {
+					panic("bad declaration in " + m.Path + ": " + t.Decl)
+				}
+				out = append(out, decl.Exprs[0].(*parser.TypeDeclExpr))
+			}
+		}
+	}
+	return out
+}
+
+// symbol returns the name of a top-level function of the module being
+// compiled in generated code.
+func (cg *CodeGen) symbol(name string) string {
+	if cg.unit == nil {
+		return name
+	}
+	return cg.unit.Symbol(name)
+}
+
+// symbolOf is symbol for fn, or the C name of an external function.
+func (cg *CodeGen) symbolOf(fn *parser.FuncDeclExpr) string {
+	if name, ok := externalName(fn); ok {
+		return name
+	}
+	return cg.symbol(fn.Name.Lexeme)
+}
+
+func externalName(fn *parser.FuncDeclExpr) (string, bool) {
+	if len(fn.Decorators) == 0 || fn.Decorators[0].Name != "external" || len(fn.Decorators[0].Args) != 3 {
+		return "", false
+	}
+	lit, ok := fn.Decorators[0].Args[2].(*parser.StringLiteral)
+	if !ok {
+		return "", false
+	}
+	return lit.Value, true
+}
+
+// qualified returns the function that `module:name` names, at the type it
+// is used at.
+func (cg *CodeGen) qualified(q *parser.QualifiedExpr, use *typechecker.Type) *ir.Func {
+	id, _ := q.Left.(*parser.Identifier)
+	if id == nil {
+		panic("unsupported module in " + q.Right.Lexeme)
+	}
+	if path, ok := cg.stdUses[id.Name]; ok {
+		return cg.stdFunc(path, q.Right.Lexeme, use)
+	}
+	if cg.uses[id.Name] == nil {
+		panic("unsupported module in " + q.Right.Lexeme)
+	}
+	return cg.importedFunc(cg.uses[id.Name], q.Right.Lexeme, use)
+}
+
+// importedFunc returns the function name of dep at the type use. Generic
+// functions are specialised in the module being compiled, in the context
+// of dep; the others are declared and left for the linker.
+func (cg *CodeGen) importedFunc(dep *module.Module, name string, use *typechecker.Type) *ir.Func {
+	var fn *parser.FuncDeclExpr
+	for _, e := range dep.Prog.Exprs {
+		if f, ok := e.(*parser.FuncDeclExpr); ok && f.Name.Lexeme == name {
+			fn = f
+		}
+	}
+	if fn == nil {
+		panic("module " + dep.Path + " has no function " + name)
+	}
+	ty := dep.TC.TypeOf(fn)
+	if !ty.HasVars() {
+		return cg.declareExtern(dep, fn, ty)
+	}
+	restore := cg.enter(dep)
+	defer restore()
+	return cg.funcRef(name, use)
+}
+
+// declareExtern declares fn, a function of dep, once.
+func (cg *CodeGen) declareExtern(dep *module.Module, fn *parser.FuncDeclExpr, ty *typechecker.Type) *ir.Func {
+	name, ok := externalName(fn)
+	if !ok {
+		name = dep.Symbol(fn.Name.Lexeme)
+	}
+	if f, ok := cg.externs[name]; ok {
+		return f
+	}
+	for _, f := range cg.mod.Funcs {
+		if f.Name() == name {
+			cg.externs[name] = f
+			return f
+		}
+	}
+	f := cg.declareFunction(name, fn, ty)
+	cg.externs[name] = f
+	return f
+}
+
+// enter switches cg to generating code in the context of dep, so that the
+// names in the body of one of its generic functions resolve as they do
+// there, and returns a function switching back.
+func (cg *CodeGen) enter(dep *module.Module) func() {
+	st := cg.deps[dep]
+	if st == nil {
+		st = &depState{funcs: map[string]*ir.Func{}, generics: map[string]*parser.FuncDeclExpr{}}
+		st.uses, st.members = usesOf(dep)
+		st.stdUses, st.stdMembers = stdUsesOf(dep.Prog)
+		cg.deps[dep] = st
+		for _, e := range dep.Prog.Exprs {
+			fn, ok := e.(*parser.FuncDeclExpr)
+			if !ok {
+				continue
+			}
+			if ty := dep.TC.TypeOf(fn); ty.HasVars() {
+				st.generics[fn.Name.Lexeme] = fn
+			} else {
+				st.funcs[fn.Name.Lexeme] = cg.declareExtern(dep, fn, ty)
+			}
+		}
+	}
+	tc, unit, uses, members, funcs, generics := cg.tc, cg.unit, cg.uses, cg.members, cg.funcs, cg.generics
+	stdUses, stdMembers := cg.stdUses, cg.stdMembers
+	cg.tc, cg.unit, cg.uses, cg.members, cg.funcs, cg.generics = dep.TC, dep, st.uses, st.members, st.funcs, st.generics
+	cg.stdUses, cg.stdMembers = st.stdUses, st.stdMembers
+	return func() {
+		cg.tc, cg.unit, cg.uses, cg.members, cg.funcs, cg.generics = tc, unit, uses, members, funcs, generics
+		cg.stdUses, cg.stdMembers = stdUses, stdMembers
+	}
+}
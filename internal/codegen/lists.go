@@ -0,0 +1,233 @@
+package codegen
+
+import (
+	"flint/internal/parser"
+	"flint/internal/typechecker"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Lists are pointers to the runtime's flint_list, which holds the length
+// of the list and a pointer to its elements.
+
+// listType returns the flint_list struct, declaring it on first use.
+func (cg *CodeGen) listType() types.Type {
+	if cg.list == nil {
+		cg.list = cg.mod.NewTypeDef("flint_list", types.NewStruct(types.I64, types.I64, types.I64, types.I8Ptr))
+	}
+	return cg.list
+}
+
+// listField loads the field at index i of the flint_list that list points
+// to.
+func (cg *CodeGen) listField(b *ir.Block, list value.Value, i int64, t types.Type) value.Value {
+	ptr := b.NewGetElementPtr(cg.listType(), list, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, i))
+	return b.NewLoad(t, ptr)
+}
+
+// listLen returns the length of list as a platform Int.
+func (cg *CodeGen) listLen(b *ir.Block, list value.Value) value.Value {
+	return cg.fromI64(b, cg.listField(b, list, 0, types.I64))
+}
+
+// listData returns a pointer to the first element of list, whose elements
+// have type elem.
+func (cg *CodeGen) listData(b *ir.Block, list value.Value, elem types.Type) value.Value {
+	return b.NewBitCast(cg.listField(b, list, 3, types.I8Ptr), types.NewPointer(elem))
+}
+
+// listAt returns a pointer to the element at index of list, panicking with
+// the given source location when it is out of bounds.
+func (cg *CodeGen) listAt(b *ir.Block, list, index value.Value, elem types.Type, line, col int) value.Value {
+	ptr := b.NewCall(cg.runtimeFunc("flint_list_at"), list, toI64(b, index),
+		cg.emitString(&parser.StringLiteral{Value: cg.mod.SourceFilename}),
+		constant.NewInt(types.I64, int64(line)),
+		constant.NewInt(types.I64, int64(col)),
+	)
+	return b.NewBitCast(ptr, types.NewPointer(elem))
+}
+
+// listTail returns a new list of the elements of list, whose elements have
+// type elem, from index from on, and the block after the copy.
+func (cg *CodeGen) listTail(b *ir.Block, list value.Value, elem types.Type, from value.Value) (value.Value, *ir.Block) {
+	n := cg.listLen(b, list)
+	res := b.NewCall(cg.runtimeFunc("flint_list_new"), cg.sizeOf(elem), toI64(b, b.NewSub(n, from)))
+	src, dst := cg.listData(b, list, elem), cg.listData(b, res, elem)
+	end := cg.emitLoop(b, from, n, func(b *ir.Block, i value.Value) *ir.Block {
+		x := b.NewLoad(elem, b.NewGetElementPtr(elem, src, i))
+		b.NewStore(x, b.NewGetElementPtr(elem, dst, b.NewSub(i, from)))
+		return b
+	})
+	return res, end
+}
+
+// listPush appends v to list.
+func (cg *CodeGen) listPush(b *ir.Block, list, v value.Value) {
+	slot := b.NewCall(cg.runtimeFunc("flint_list_push"), list)
+	b.NewStore(v, b.NewBitCast(slot, types.NewPointer(v.Type())))
+}
+
+// fromI64 converts v, an int64 from the runtime, to a platform Int.
+func (cg *CodeGen) fromI64(b *ir.Block, v value.Value) value.Value {
+	if intType := cg.platformIntType(); !intType.Equal(types.I64) {
+		return b.NewTrunc(v, intType)
+	}
+	return v
+}
+
+// fromDouble converts v, a double from the runtime, to a platform Float.
+func (cg *CodeGen) fromDouble(b *ir.Block, v value.Value) value.Value {
+	if floatType := cg.platformFloatType(); !floatType.Equal(types.Double) {
+		return b.NewFPTrunc(v, floatType)
+	}
+	return v
+}
+
+func toDouble(b *ir.Block, v value.Value) value.Value {
+	if v.Type().Equal(types.Double) {
+		return v
+	}
+	return b.NewFPExt(v, types.Double)
+}
+
+func toI64(b *ir.Block, v value.Value) value.Value {
+	if v.Type().Equal(types.I64) {
+		return v
+	}
+	return b.NewSExt(v, types.I64)
+}
+
+// listFuncs generate the functions of flint/list that the runtime does not
+// implement, at the type they are used at. Those taking a function argument,
+// or an element, are specialised for each type.
+var listFuncs = map[string]func(cg *CodeGen, ty *typechecker.Type) *ir.Func{
+	"length": func(cg *CodeGen, ty *typechecker.Type) *ir.Func {
+		fn, b := cg.defineStd("flint.list.length", ty, false)
+		if b != nil {
+			b.NewRet(cg.listLen(b, fn.Params[0]))
+		}
+		return fn
+	},
+	"push": func(cg *CodeGen, ty *typechecker.Type) *ir.Func {
+		fn, b := cg.defineStd("flint.list.push", ty, true)
+		if b != nil {
+			cg.listPush(b, fn.Params[0], fn.Params[1])
+			b.NewRet(nil)
+		}
+		return fn
+	},
+	"map": func(cg *CodeGen, ty *typechecker.Type) *ir.Func {
+		fn, b := cg.defineStd("flint.list.map", ty, true)
+		if b == nil {
+			return fn
+		}
+		list, f := fn.Params[0], fn.Params[1]
+		in, out := cg.llvmType(ty.Params[0].Elem), cg.llvmType(ty.Ret.Elem)
+		n := cg.listLen(b, list)
+		res := b.NewCall(cg.runtimeFunc("flint_list_new"), cg.sizeOf(out), toI64(b, n))
+		src, dst := cg.listData(b, list, in), cg.listData(b, res, out)
+		end := cg.emitLoop(b, constant.NewInt(cg.platformIntType(), 0), n, func(b *ir.Block, i value.Value) *ir.Block {
+			x := b.NewLoad(in, b.NewGetElementPtr(in, src, i))
+			b.NewStore(cg.emitClosureCall(b, f, []value.Value{x}), b.NewGetElementPtr(out, dst, i))
+			return b
+		})
+		end.NewRet(res)
+		return fn
+	},
+	"filter": func(cg *CodeGen, ty *typechecker.Type) *ir.Func {
+		fn, b := cg.defineStd("flint.list.filter", ty, true)
+		if b == nil {
+			return fn
+		}
+		list, keep := fn.Params[0], fn.Params[1]
+		elem := cg.llvmType(ty.Ret.Elem)
+		res := b.NewCall(cg.runtimeFunc("flint_list_new"), cg.sizeOf(elem), constant.NewInt(types.I64, 0))
+		src := cg.listData(b, list, elem)
+		end := cg.emitLoop(b, constant.NewInt(cg.platformIntType(), 0), cg.listLen(b, list), func(b *ir.Block, i value.Value) *ir.Block {
+			x := b.NewLoad(elem, b.NewGetElementPtr(elem, src, i))
+			push := cg.newBlock(fn, "filter.push")
+			next := cg.newBlock(fn, "filter.next")
+			b.NewCondBr(cg.emitClosureCall(b, keep, []value.Value{x}), push, next)
+			cg.listPush(push, res, x)
+			push.NewBr(next)
+			return next
+		})
+		end.NewRet(res)
+		return fn
+	},
+	"fold": func(cg *CodeGen, ty *typechecker.Type) *ir.Func {
+		fn, b := cg.defineStd("flint.list.fold", ty, true)
+		if b == nil {
+			return fn
+		}
+		list, init, f := fn.Params[0], fn.Params[1], fn.Params[2]
+		elem := cg.llvmType(ty.Params[0].Elem)
+		acc := b.NewAlloca(init.Type())
+		b.NewStore(init, acc)
+		src := cg.listData(b, list, elem)
+		end := cg.emitLoop(b, constant.NewInt(cg.platformIntType(), 0), cg.listLen(b, list), func(b *ir.Block, i value.Value) *ir.Block {
+			x := b.NewLoad(elem, b.NewGetElementPtr(elem, src, i))
+			b.NewStore(cg.emitClosureCall(b, f, []value.Value{b.NewLoad(init.Type(), acc), x}), acc)
+			return b
+		})
+		end.NewRet(end.NewLoad(init.Type(), acc))
+		return fn
+	},
+}
+
+// defineStd declares name, a function of a built-in module, at the type ty
+// it is used at. It returns the function and its entry block, or a nil
+// block if the function has been defined already. Functions that are
+// specialised are named after the type.
+func (cg *CodeGen) defineStd(name string, ty *typechecker.Type, specialise bool) (*ir.Func, *ir.Block) {
+	if specialise {
+		name += "$" + mangle(ty)
+	}
+	if fn, ok := cg.std[name]; ok {
+		return fn, nil
+	}
+	params := make([]*ir.Param, len(ty.Params))
+	for i, p := range ty.Params {
+		params[i] = ir.NewParam("", cg.llvmType(p))
+	}
+	fn := cg.mod.NewFunc(name, cg.llvmType(ty.Ret), params...)
+	fn.Linkage = enum.LinkageInternal
+	cg.std[name] = fn
+	return fn, fn.NewBlock("entry")
+}
+
+// runtimeStd defines name, a function of a built-in module at the type ty,
+// as a call to the runtime function rt, converting Ints and Floats to and
+// from the int64s and doubles the runtime uses.
+func (cg *CodeGen) runtimeStd(name, rt string, ty *typechecker.Type) *ir.Func {
+	fn, b := cg.defineStd(name, ty, false)
+	if b == nil {
+		return fn
+	}
+	args := make([]value.Value, len(fn.Params))
+	for i, p := range fn.Params {
+		args[i] = p
+		switch ty.Params[i].TKind {
+		case typechecker.TyInt:
+			args[i] = toI64(b, p)
+		case typechecker.TyFloat:
+			args[i] = toDouble(b, p)
+		}
+	}
+	res := b.NewCall(cg.runtimeFuncOf(rt, ty), args...)
+	switch ty.Ret.TKind {
+	case typechecker.TyInt:
+		b.NewRet(cg.fromI64(b, res))
+	case typechecker.TyFloat:
+		b.NewRet(cg.fromDouble(b, res))
+	case typechecker.TyNil:
+		b.NewRet(nil)
+	default:
+		b.NewRet(res)
+	}
+	return fn
+}
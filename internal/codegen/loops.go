@@ -0,0 +1,65 @@
+package codegen
+
+import (
+	"flint/internal/parser"
+	"flint/internal/typechecker"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// emitFor lowers a loop over a range or a list. The element is bound to the
+// pattern in a scope of its own on each iteration, in stack slots reserved
+// once in the entry block.
+func (cg *CodeGen) emitFor(b *ir.Block, f *parser.ForExpr) (value.Value, *ir.Block) {
+	iter, b := cg.emitExpr(b, f.Iter, false)
+	var low, high, data value.Value
+	var elemType types.Type
+	ty := cg.typeOf(f.Iter)
+	if ty.TKind == typechecker.TyRange {
+		low, high = b.NewExtractValue(iter, 0), b.NewExtractValue(iter, 1)
+	} else {
+		elemType = cg.llvmType(ty.Elem)
+		low, high = constant.NewInt(cg.platformIntType(), 0), cg.listLen(b, iter)
+		data = cg.listData(b, iter, elemType)
+	}
+	pos := parser.PatternPos(f.Pattern)
+	fail := cg.newBlock(b.Parent, "for.fail")
+	cg.emitPanic(fail, "value does not match the pattern", pos.Line, pos.Column)
+	end := cg.emitLoop(b, low, high, func(body *ir.Block, i value.Value) *ir.Block {
+		var elem value.Value = i
+		if data != nil {
+			elem = body.NewLoad(elemType, body.NewGetElementPtr(elemType, data, i))
+		}
+		saved := cg.scope()
+		_, body = cg.emitExpr(cg.emitPattern(body, elem, ty.Elem, f.Pattern, fail), f.Body, false)
+		cg.locals = saved
+		return body
+	})
+	return nil, end
+}
+
+// emitLoop emits a loop running body for each Int i from low up to, but not
+// including, high, and returns the block after the loop. The counter is a
+// phi in the loop header, incremented in a latch block that the block body
+// returns falls through to.
+func (cg *CodeGen) emitLoop(b *ir.Block, low, high value.Value, body func(b *ir.Block, i value.Value) *ir.Block) *ir.Block {
+	fn := b.Parent
+	head := cg.newBlock(fn, "for.head")
+	start := cg.newBlock(fn, "for.body")
+	latch := cg.newBlock(fn, "for.next")
+	end := cg.newBlock(fn, "for.end")
+	b.NewBr(head)
+	i := head.NewPhi(ir.NewIncoming(low, b))
+	head.NewCondBr(head.NewICmp(enum.IPredSLT, i, high), start, end)
+	if last := body(start, i); last.Term == nil {
+		last.NewBr(latch)
+	}
+	next := latch.NewAdd(i, constant.NewInt(i.Type().(*types.IntType), 1))
+	latch.NewBr(head)
+	i.Incs = append(i.Incs, ir.NewIncoming(next, latch))
+	return end
+}
@@ -15,7 +15,7 @@ func (cg *CodeGen) initModuleHeaders(sourceFile string) {
 
 func (cg *CodeGen) emitTopLiteral(e parser.Expr) {
 	fn := cg.mod.NewFunc("main", types.I32)
-	b := fn.NewBlock("entry")
-	_ = cg.emitExpr(b, e, true)
+	cg.entry = fn.NewBlock("entry")
+	_, b := cg.emitExpr(cg.entry, e, true)
 	b.NewRet(constant.NewInt(types.I32, 0))
 }
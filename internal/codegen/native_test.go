@@ -0,0 +1,307 @@
+package codegen
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flint/internal/interp"
+	"flint/internal/lexer"
+	"flint/internal/module"
+	"flint/internal/parser"
+	"flint/internal/toolchain"
+	"flint/internal/typechecker"
+)
+
+// runNative compiles src to an executable with the host toolchain, runs it
+// with args and returns what it printed. The test is skipped when no
+// toolchain is installed.
+func runNative(t *testing.T, src string, args ...string) string {
+	t.Helper()
+
+	tc := toolchain.Detect()
+	if tc.Clang == "" && (tc.LLC == "" || tc.CC == "") {
+		t.Skip("no toolchain to compile LLVM IR with")
+	}
+
+	tokens, err := lexer.Tokenize(src, "test.flint")
+	if err != nil {
+		t.Fatalf("lex error: %v", err)
+	}
+	prog, errs := parser.ParseProgram(tokens)
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	checker := typechecker.New()
+	for _, e := range prog.Exprs {
+		if _, err := checker.CheckExpr(e); err != nil {
+			t.Fatalf("type error: %v", err)
+		}
+	}
+
+	exe := filepath.Join(t.TempDir(), "test")
+	if err := tc.Build(GenerateLLVM(prog, checker, "test.flint"), toolchain.EmitExe, exe); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(exe, args...).Output()
+	if err != nil {
+		t.Fatalf("running the program: %v\n%s", err, out)
+	}
+	return string(out)
+}
+
+// checkAgainstInterpreter checks that src prints the same compiled as it
+// does under flint run.
+func checkAgainstInterpreter(t *testing.T, src string) {
+	t.Helper()
+
+	tokens, err := lexer.Tokenize(src, "test.flint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, _ := parser.ParseProgram(tokens)
+	var want bytes.Buffer
+	if err := interp.New(&want).Run(prog); err != nil {
+		t.Fatal(err)
+	}
+	if got := runNative(t, src); got != want.String() {
+		t.Fatalf("compiled code printed %q, flint run %q", got, want.String())
+	}
+}
+
+func TestNativeListPatterns(t *testing.T) {
+	out := runNative(t, `
+use flint/io
+use flint/string
+
+fn describe(xs: List(Int)) String {
+	match xs {
+		| [] -> "empty"
+		| [1 | 2 as first, ..rest] -> "small " <> string:to_string(first)
+		| [x, ..] -> match x {
+			| 0..100 -> "medium"
+			| _ -> "large"
+		}
+	}
+}
+
+fn sum(xs: List(Int)) Int {
+	match xs {
+		| [] -> 0
+		| [h, ..t] -> h + sum(t)
+	}
+}
+
+fn main() Nil {
+	io:println(describe([]))
+	io:println(describe([2, 9]))
+	io:println(describe([50]))
+	io:println(describe([500, 1]))
+	io:println(string:to_string(sum([1, 2, 3, 4])))
+}
+`)
+	want := "empty\nsmall 2\nmedium\nlarge\n10\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+}
+
+func TestNativeNestedFunctionsCaptureLocals(t *testing.T) {
+	out := runNative(t, `
+use flint/io
+use flint/string
+
+fn main() Nil {
+	mut total = 1
+	fn bump(n: Int) Int {
+		total = total + n
+		total
+	}
+	bump(2)
+	bump(3) |> string:to_string |> io:println
+	fn countdown(n: Int) Int {
+		if n == 0 { total } else { countdown(n - 1) }
+	}
+	io:println(string:to_string(countdown(3)))
+}
+`)
+	if out != "6\n6\n" {
+		t.Fatalf("expected 6 twice, got %q", out)
+	}
+}
+
+func TestNativeFloatsPrintAsInterpreted(t *testing.T) {
+	checkAgainstInterpreter(t, `
+use flint/io
+use flint/string
+
+fn main() Nil {
+	io:println(string:from_float(100000.0))
+	io:println(string:from_float(1000000000000000000000.0))
+	io:println(string:from_float(0.0001))
+	io:println(string:from_float(1.5))
+	io:println("{1000000.0} {0.00001} {123456.7} {0.1 +. 0.2}")
+}
+`)
+}
+
+func TestNativeRestPatternsCopyTheTail(t *testing.T) {
+	checkAgainstInterpreter(t, `
+use flint/io
+use flint/list
+use flint/string
+
+fn main() Nil {
+	val xs = [1, 2, 3]
+	list:push(xs, 4)
+	match xs {
+		| [_, ..t] -> {
+			list:push(t, 9)
+			list:push(xs, 5)
+			for x in t {
+				io:print(string:to_string(x) <> " ")
+			}
+			io:println(string:to_string(list:length(xs)))
+		}
+		| [] -> io:println("empty")
+	}
+}
+`)
+}
+
+func TestNativeForOverRanges(t *testing.T) {
+	checkAgainstInterpreter(t, `
+use flint/io
+use flint/string
+
+fn main() Nil {
+	mut total = 0
+	for i in 1..5 {
+		total = total + i
+	}
+	val n = 3
+	for i in (0 - n)..n {
+		if i % 2 == 0 { io:print("{i} ") } else { io:print(". ") }
+	}
+	for i in 4..2 {
+		io:print("never")
+	}
+	for i in 0..3 {
+		for j in i..3 {
+			total = total + j
+		}
+	}
+	io:println(string:to_string(total))
+}
+`)
+}
+
+func TestNativeForOverLists(t *testing.T) {
+	checkAgainstInterpreter(t, `
+use flint/io
+use flint/string
+
+fn main() Nil {
+	for s in ["a", "b", "c"] {
+		io:print(s)
+	}
+	val empty: List(Int) = []
+	for x in empty {
+		io:print("never")
+	}
+	mut total = 0.0
+	for x in [1.5, 2.5] {
+		total = total +. x
+	}
+	io:println(" {total}")
+}
+`)
+}
+
+func TestNativeForWithPatterns(t *testing.T) {
+	checkAgainstInterpreter(t, `
+use flint/io
+
+type Point { x: Int, y: Int }
+
+fn main() Nil {
+	mut total = 0
+	for (a, b) in [(1, 2), (3, 4)] {
+		total = total + a * b
+	}
+	for (name, (lo, hi)) in [("low", (0, 1)), ("high", (8, 9))] {
+		io:print("{name}:{lo}-{hi} ")
+	}
+	for p in [Point { x: 1, y: 2 }, Point { x: 5, y: 6 }] {
+		total = total + p.x * p.y
+	}
+	for _ in 0..2 {
+		total = total + 1
+	}
+	io:println("{total}")
+}
+`)
+}
+
+func TestNativeFsAndOsModules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	out := runNative(t, `
+use flint/io
+use flint/fs
+use flint/os
+use flint/fs.{Result, Ok, Error}
+
+fn show(r: Result) Nil {
+	match r {
+		| Ok(s) -> io:println("ok " <> s)
+		| Error(msg) -> io:println("error " <> msg)
+	}
+}
+
+fn main() Nil {
+	show(fs:write_file("`+path+`", "a\nb"))
+	show(fs:read_file("`+path+`"))
+	show(fs:read_file("`+path+`.missing"))
+	for a in os:args() {
+		io:println(a)
+	}
+}
+`, "-v", "two words")
+	want := "ok " + path + "\nok a\nb\nerror " + path + ".missing: No such file or directory\n" +
+		"-v\ntwo words\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+}
+
+func TestRecordsMayUseImportedRecords(t *testing.T) {
+	root := t.TempDir()
+	geo := "pub type Point { x: Int, y: Int }\n"
+	if err := os.MkdirAll(filepath.Join(root, "app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "app", "geo.flint"), []byte(geo), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := `use app/geo
+use app/geo.{Point}
+
+type Line { from: Point, to: Point }
+
+fn main() Int {
+	val l = Line { from: Point { x: 1, y: 2 }, to: geo:Point { x: 3, y: 4 } }
+	l.to.y
+}
+`
+	m, diags := module.NewLoader(root).Load(filepath.Join(root, "main.flint"), src)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	ir := GenerateModule(m, true)
+	if !strings.Contains(ir, "%Line = type { %Point, %Point }") {
+		t.Fatalf("expected Line to hold two Points, got:\n%s", ir)
+	}
+}
@@ -0,0 +1,84 @@
+package codegen
+
+import (
+	"flint/internal/parser"
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+type recordInfo struct {
+	typ    *types.StructType
+	fields []string
+}
+
+// declareRecord names the struct of t, if it is a record type, leaving its
+// fields to declareType.
+func (cg *CodeGen) declareRecord(t *parser.TypeDeclExpr) {
+	if _, ok := t.Body.(*parser.RecordTypeExpr); !ok {
+		return
+	}
+	info := &recordInfo{typ: types.NewStruct()}
+	cg.mod.NewTypeDef(t.Name.Lexeme, info.typ)
+	cg.records[t.Name.Lexeme] = info
+}
+
+// declareType resolves the fields of t once every type has been named.
+func (cg *CodeGen) declareType(t *parser.TypeDeclExpr) {
+	rec, ok := t.Body.(*parser.RecordTypeExpr)
+	if !ok {
+		cg.declareVariantPayloads(t)
+		return
+	}
+	info := cg.records[t.Name.Lexeme]
+	for _, f := range rec.Fields {
+		info.typ.Fields = append(info.typ.Fields, cg.resolveType(f.Type))
+		info.fields = append(info.fields, f.Name.Lexeme)
+	}
+}
+
+func (cg *CodeGen) recordOf(t types.Type) *recordInfo {
+	st, ok := t.(*types.StructType)
+	if !ok {
+		return nil
+	}
+	return cg.records[st.Name()]
+}
+
+func (cg *CodeGen) emitRecord(b *ir.Block, r *parser.RecordExpr) (value.Value, *ir.Block) {
+	info := cg.records[r.Name.Lexeme]
+	if info == nil {
+		panic("undefined record type: " + r.Name.Lexeme)
+	}
+	var agg value.Value = constant.NewUndef(info.typ)
+	for _, f := range r.Fields {
+		var v value.Value
+		v, b = cg.emitExpr(b, f.Value, false)
+		agg = b.NewInsertValue(agg, v, uint64(fieldIndex(info, f.Name.Lexeme)))
+	}
+	return agg, b
+}
+
+func (cg *CodeGen) emitFieldAccess(b *ir.Block, f *parser.FieldAccessExpr) (value.Value, *ir.Block) {
+	target, b := cg.emitExpr(b, f.Left, false)
+	if ptr, ok := target.Type().(*types.PointerType); ok {
+		target = b.NewLoad(ptr.ElemType, target)
+	}
+	info := cg.recordOf(target.Type())
+	if info == nil {
+		panic(fmt.Sprintf("field access on non-record value of type %v", target.Type()))
+	}
+	return b.NewExtractValue(target, uint64(fieldIndex(info, f.Right))), b
+}
+
+func fieldIndex(info *recordInfo, name string) int {
+	for i, f := range info.fields {
+		if f == name {
+			return i
+		}
+	}
+	panic("unknown record field: " + name)
+}
@@ -0,0 +1,47 @@
+package codegen
+
+import (
+	"flint/internal/typechecker"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// fallibleStd defines name, a fallible function of a built-in module at the
+// type ty, as a call to the runtime function rt. rt takes an extra
+// `char **err` and returns NULL on failure, which is turned into the
+// Result the function returns: Ok of what rt returns, or Error of *err.
+func (cg *CodeGen) fallibleStd(name, rt string, ty *typechecker.Type) *ir.Func {
+	fn, b := cg.defineStd(name, ty, false)
+	if b == nil {
+		return fn
+	}
+	errSlot := b.NewAlloca(types.I8Ptr)
+	args := make([]value.Value, len(fn.Params), len(fn.Params)+1)
+	paramTypes := make([]types.Type, len(fn.Params), len(fn.Params)+1)
+	for i, p := range fn.Params {
+		args[i] = p
+		switch ty.Params[i].TKind {
+		case typechecker.TyInt:
+			args[i] = toI64(b, p)
+		case typechecker.TyFloat:
+			args[i] = toDouble(b, p)
+		}
+		paramTypes[i] = cg.runtimeType(ty.Params[i])
+	}
+	rtFn, ok := cg.runtime[rt]
+	if !ok {
+		rtFn = cg.declareRuntime(rt, types.I8Ptr, append(paramTypes, types.NewPointer(types.I8Ptr)))
+	}
+	res := b.NewCall(rtFn, append(args, errSlot)...)
+	okBlock, errBlock := fn.NewBlock("ok"), fn.NewBlock("error")
+	b.NewCondBr(b.NewICmp(enum.IPredEQ, res, constant.NewNull(types.I8Ptr)), errBlock, okBlock)
+	result := cg.variants[ty.Ret.Name]
+	okBlock.NewRet(cg.emitConstructor(okBlock, result.ctor("Ok"), []value.Value{res}))
+	msg := errBlock.NewLoad(types.I8Ptr, errSlot)
+	errBlock.NewRet(cg.emitConstructor(errBlock, result.ctor("Error"), []value.Value{msg}))
+	return fn
+}
@@ -2,11 +2,11 @@ package codegen
 
 import (
 	"flint/internal/parser"
-	"reflect"
+	"flint/internal/typechecker"
 
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/enum"
-	"github.com/llir/llvm/ir/value"
+	"github.com/llir/llvm/ir/types"
 )
 
 func (cg *CodeGen) emitExternalFunction(fn *parser.FuncDeclExpr, externDecorationIdx int, name string, mainFn *ir.Func) {
@@ -25,39 +25,103 @@ func (cg *CodeGen) emitExternalFunction(fn *parser.FuncDeclExpr, externDecoratio
 	}
 }
 
-func parentBlockOfValue(v value.Value) *ir.Block {
-	if v == nil {
-		return nil
+// runtimeSigs lists the functions of the bundled C runtime that generated
+// code may call. They are declared in the module on first use.
+var runtimeSigs = map[string]func(cg *CodeGen) (types.Type, []types.Type){
+	"flint_string_concat": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I8Ptr, []types.Type{types.I8Ptr, types.I8Ptr}
+	},
+	"flint_string_at": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I8, []types.Type{types.I8Ptr, types.I64, types.I8Ptr, types.I64, types.I64}
+	},
+	"flint_set_args": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.Void, []types.Type{types.I32, types.NewPointer(types.I8Ptr)}
+	},
+	"flint_string_parse_int": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I1, []types.Type{types.I8Ptr, types.NewPointer(types.I64)}
+	},
+	"flint_int_to_string": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I8Ptr, []types.Type{types.I64}
+	},
+	"flint_float_to_string": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I8Ptr, []types.Type{types.Double}
+	},
+	"flint_bool_to_string": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I8Ptr, []types.Type{types.I1}
+	},
+	"flint_byte_to_string": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I8Ptr, []types.Type{types.I8}
+	},
+	"flint_panic": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.Void, []types.Type{types.I8Ptr, types.I8Ptr, types.I64, types.I64}
+	},
+	"flint_list_new": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.NewPointer(cg.listType()), []types.Type{types.I64, types.I64}
+	},
+	"flint_list_at": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I8Ptr, []types.Type{types.NewPointer(cg.listType()), types.I64, types.I8Ptr, types.I64, types.I64}
+	},
+	"flint_list_push": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I8Ptr, []types.Type{types.NewPointer(cg.listType())}
+	},
+	"flint_list_concat": func(cg *CodeGen) (types.Type, []types.Type) {
+		list := types.NewPointer(cg.listType())
+		return list, []types.Type{list, list}
+	},
+	"flint_list_reverse": func(cg *CodeGen) (types.Type, []types.Type) {
+		list := types.NewPointer(cg.listType())
+		return list, []types.Type{list}
+	},
+	"malloc": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I8Ptr, []types.Type{types.I64}
+	},
+	"strcmp": func(cg *CodeGen) (types.Type, []types.Type) {
+		return types.I32, []types.Type{types.I8Ptr, types.I8Ptr}
+	},
+}
+
+func (cg *CodeGen) runtimeFunc(name string) *ir.Func {
+	if fn, ok := cg.runtime[name]; ok {
+		return fn
 	}
-	rv := reflect.ValueOf(v)
-	if rv.Kind() != reflect.Pointer || rv.IsNil() {
-		return nil
+	ret, paramTypes := runtimeSigs[name](cg)
+	return cg.declareRuntime(name, ret, paramTypes)
+}
+
+// runtimeFuncOf returns the runtime function name implementing a built-in
+// function of type ty. Unless it is one of runtimeSigs, it is declared with
+// the LLVM types of ty, Ints and Floats being passed as int64s and doubles.
+func (cg *CodeGen) runtimeFuncOf(name string, ty *typechecker.Type) *ir.Func {
+	if _, ok := runtimeSigs[name]; ok {
+		return cg.runtimeFunc(name)
 	}
-	elem := rv.Elem()
-	if elem.Kind() != reflect.Struct {
-		return nil
+	if fn, ok := cg.runtime[name]; ok {
+		return fn
 	}
-	field := elem.FieldByName("Parent")
-	if !field.IsValid() || field.IsNil() {
-		return nil
+	paramTypes := make([]types.Type, len(ty.Params))
+	for i, p := range ty.Params {
+		paramTypes[i] = cg.runtimeType(p)
 	}
-	parent, ok := field.Interface().(*ir.Block)
-	if !ok {
-		return nil
+	return cg.declareRuntime(name, cg.runtimeType(ty.Ret), paramTypes)
+}
+
+func (cg *CodeGen) runtimeType(t *typechecker.Type) types.Type {
+	switch t.TKind {
+	case typechecker.TyInt:
+		return types.I64
+	case typechecker.TyFloat:
+		return types.Double
 	}
-	return parent
+	return cg.llvmType(t)
 }
 
-func referencesBlock(b *ir.Block, blockToFind *ir.Block) bool {
-	if b != nil && b.Term != nil {
-		opLen := len(b.Term.Operands())
-		for i := range opLen {
-			op := *b.Term.Operands()[i]
-			v, ok := op.(*ir.Block)
-			if ok && v == blockToFind {
-				return true
-			}
-		}
-	}
-	return false
+func (cg *CodeGen) declareRuntime(name string, ret types.Type, paramTypes []types.Type) *ir.Func {
+	params := make([]*ir.Param, len(paramTypes))
+	for i, t := range paramTypes {
+		params[i] = ir.NewParam("", t)
+	}
+	fn := cg.mod.NewFunc(name, ret, params...)
+	fn.Linkage = enum.LinkageExternal
+	cg.runtime[name] = fn
+	return fn
 }
@@ -2,23 +2,97 @@ package codegen
 
 import (
 	"flint/internal/parser"
+	"flint/internal/typechecker"
 
+	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
 )
 
+// emitString returns a pointer to the bytes of a constant string. Like the
+// strings the runtime builds, it is NUL-terminated and has its length in
+// the i64 before its first byte.
 func (cg *CodeGen) emitString(v *parser.StringLiteral) value.Value {
+	zero, one := constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 1)
 	if g, ok := cg.strGlobals[v.Value]; ok {
-		zero := constant.NewInt(types.I32, 0)
-		return constant.NewGetElementPtr(g.Init.Type(), g, zero, zero)
+		return constant.NewGetElementPtr(g.Init.Type(), g, zero, one, zero)
 	}
 	label := cg.newStrLabel()
-	str := constant.NewCharArrayFromString(v.Value + "\x00")
+	str := constant.NewStruct(nil,
+		constant.NewInt(types.I64, int64(len(v.Value))),
+		constant.NewCharArrayFromString(v.Value+"\x00"),
+	)
+	str.Typ = types.NewStruct(str.Fields[0].Type(), str.Fields[1].Type())
 	global := cg.mod.NewGlobalDef(label, str)
 	global.Immutable = true
-	global.Align = 1
+	global.Linkage = enum.LinkagePrivate
+	global.Align = 8
 	cg.strGlobals[v.Value] = global
-	zero := constant.NewInt(types.I32, 0)
-	return constant.NewGetElementPtr(str.Typ, global, zero, zero)
+	return constant.NewGetElementPtr(str.Typ, global, zero, one, zero)
+}
+
+// emitInterpolatedString concatenates the parts of s, converting the
+// expressions in its holes with the to_string of their type.
+func (cg *CodeGen) emitInterpolatedString(b *ir.Block, s *parser.InterpolatedString) (value.Value, *ir.Block) {
+	var out value.Value
+	for _, part := range s.Parts {
+		v, next := cg.emitExpr(b, part, false)
+		b = next
+		if _, ok := part.(*parser.StringLiteral); !ok {
+			v = cg.toString(b, v, cg.typeOf(part))
+		}
+		if out == nil {
+			out = v
+		} else {
+			out = b.NewCall(cg.runtimeFunc("flint_string_concat"), out, v)
+		}
+	}
+	return out, b
+}
+
+// toString converts v, of type ty, to a string.
+func (cg *CodeGen) toString(b *ir.Block, v value.Value, ty *typechecker.Type) value.Value {
+	switch ty.TKind {
+	case typechecker.TyInt:
+		return b.NewCall(cg.runtimeFunc("flint_int_to_string"), toI64(b, v))
+	case typechecker.TyFloat:
+		return b.NewCall(cg.runtimeFunc("flint_float_to_string"), toDouble(b, v))
+	case typechecker.TyBool:
+		return b.NewCall(cg.runtimeFunc("flint_bool_to_string"), v)
+	case typechecker.TyByte:
+		return b.NewCall(cg.runtimeFunc("flint_byte_to_string"), v)
+	default:
+		return v
+	}
+}
+
+// stringAt returns the byte at index of s, panicking with the given source
+// location when it is out of bounds.
+func (cg *CodeGen) stringAt(b *ir.Block, s, index value.Value, line, col int) value.Value {
+	return b.NewCall(cg.runtimeFunc("flint_string_at"), s, toI64(b, index),
+		cg.emitString(&parser.StringLiteral{Value: cg.mod.SourceFilename}),
+		constant.NewInt(types.I64, int64(line)),
+		constant.NewInt(types.I64, int64(col)),
+	)
+}
+
+// stringFuncs generate parse_int, the function of flint/string that the
+// runtime does not implement alone: it returns the integer it reads through
+// a pointer.
+var stringFuncs = map[string]func(cg *CodeGen, ty *typechecker.Type) *ir.Func{
+	"parse_int": func(cg *CodeGen, ty *typechecker.Type) *ir.Func {
+		fn, b := cg.defineStd("flint.string.parse_int", ty, false)
+		if b == nil {
+			return fn
+		}
+		out := b.NewAlloca(types.I64)
+		ok := b.NewCall(cg.runtimeFunc("flint_string_parse_int"), fn.Params[0], out)
+		var res value.Value = constant.NewUndef(fn.Sig.RetType)
+		res = b.NewInsertValue(res, cg.fromI64(b, b.NewLoad(types.I64, out)), 0)
+		res = b.NewInsertValue(res, ok, 1)
+		b.NewRet(res)
+		return fn
+	},
 }
@@ -0,0 +1,108 @@
+package codegen
+
+import (
+	"flint/internal/parser"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Values of a variant type are lowered to `{ i32 tag, i8* payload }`. The
+// payload of a constructor with fields is a heap allocated struct holding
+// them, so recursive types have a finite size; nullary constructors carry a
+// null payload.
+type variantInfo struct {
+	typ   *types.StructType
+	ctors []*ctorInfo
+}
+
+type ctorInfo struct {
+	name    string
+	tag     int64
+	owner   *variantInfo
+	payload *types.StructType
+}
+
+// ctor returns the constructor of v called name.
+func (v *variantInfo) ctor(name string) *ctorInfo {
+	for _, c := range v.ctors {
+		if c.name == name {
+			return c
+		}
+	}
+	panic("variant has no constructor " + name)
+}
+
+func (cg *CodeGen) declareVariant(t *parser.TypeDeclExpr) {
+	body, ok := t.Body.(*parser.VariantTypeExpr)
+	if !ok {
+		return
+	}
+	info := &variantInfo{typ: types.NewStruct(types.I32, types.I8Ptr)}
+	cg.mod.NewTypeDef(t.Name.Lexeme, info.typ)
+	for i, v := range body.Variants {
+		ctor := &ctorInfo{name: v.Name.Lexeme, tag: int64(i), owner: info}
+		info.ctors = append(info.ctors, ctor)
+		cg.ctors[ctor.name] = ctor
+	}
+	cg.variants[t.Name.Lexeme] = info
+}
+
+// declareVariantPayloads resolves the field types of each constructor. It
+// runs after every variant type has been declared so payloads may refer to
+// any of them.
+func (cg *CodeGen) declareVariantPayloads(t *parser.TypeDeclExpr) {
+	body, ok := t.Body.(*parser.VariantTypeExpr)
+	if !ok {
+		return
+	}
+	for i, v := range body.Variants {
+		if len(v.Fields) == 0 {
+			continue
+		}
+		fieldTypes := make([]types.Type, len(v.Fields))
+		for j, f := range v.Fields {
+			fieldTypes[j] = cg.resolveType(f)
+		}
+		payload := types.NewStruct(fieldTypes...)
+		cg.mod.NewTypeDef(t.Name.Lexeme+"."+v.Name.Lexeme, payload)
+		cg.variants[t.Name.Lexeme].ctors[i].payload = payload
+	}
+}
+
+func (cg *CodeGen) emitNullaryConstructor(ctor *ctorInfo) value.Value {
+	return constant.NewStruct(ctor.owner.typ, constant.NewInt(types.I32, ctor.tag), constant.NewNull(types.I8Ptr))
+}
+
+func (cg *CodeGen) emitConstructor(b *ir.Block, ctor *ctorInfo, args []value.Value) value.Value {
+	if ctor.payload == nil {
+		return cg.emitNullaryConstructor(ctor)
+	}
+	raw := cg.emitMalloc(b, ctor.payload)
+	typed := b.NewBitCast(raw, types.NewPointer(ctor.payload))
+	zero := constant.NewInt(types.I32, 0)
+	for i, arg := range args {
+		fieldPtr := b.NewGetElementPtr(ctor.payload, typed, zero, constant.NewInt(types.I32, int64(i)))
+		b.NewStore(arg, fieldPtr)
+	}
+	var agg value.Value = b.NewInsertValue(constant.NewUndef(ctor.owner.typ), constant.NewInt(types.I32, ctor.tag), 0)
+	return b.NewInsertValue(agg, raw, 1)
+}
+
+// emitTagTest branches to fail unless scr was built by ctor and returns the
+// block where it was.
+func (cg *CodeGen) emitTagTest(b *ir.Block, scr value.Value, ctor *ctorInfo, fail *ir.Block) *ir.Block {
+	tag := b.NewExtractValue(scr, 0)
+	cond := b.NewICmp(enum.IPredEQ, tag, constant.NewInt(types.I32, ctor.tag))
+	return cg.emitTest(b, cond, fail)
+}
+
+// emitPayload loads the fields of scr, which must have been built by ctor.
+func (cg *CodeGen) emitPayload(b *ir.Block, scr value.Value, ctor *ctorInfo) value.Value {
+	raw := b.NewExtractValue(scr, 1)
+	typed := b.NewBitCast(raw, types.NewPointer(ctor.payload))
+	return b.NewLoad(ctor.payload, typed)
+}
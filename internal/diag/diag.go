@@ -0,0 +1,172 @@
+// Package diag holds the diagnostics reported by the lexer, parser and
+// typechecker, so that a run can collect every problem in a file before
+// showing them.
+package diag
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Span is a range of source text. Lines and columns start at 1 and the end
+// is exclusive.
+type Span struct {
+	File      string
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+}
+
+type Diagnostic struct {
+	Severity Severity
+	Span     Span
+	Message  string
+	Notes    []string
+
+	// Source is the text of Span.File, used to show the offending line.
+	Source []rune
+}
+
+// String renders d with the line it points at:
+//
+//	error: unknown type: 'Foo'
+//	  --> main.flint:3:10
+//	   |
+//	 3 | fn f(x: Foo) Int {
+//	   |         ^^^
+//
+// Notes follow the snippet.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n%s", d.Severity, d.Message, Snippet(d.Span, d.Source))
+	for _, n := range d.Notes {
+		fmt.Fprintf(&b, "   = note: %s\n", n)
+	}
+	return b.String()
+}
+
+// Snippet renders the line of source that s starts on, with s underlined
+// up to the end of that line:
+//
+//	 --> main.flint:3:10
+//	  |
+//	3 | fn f(x: Foo) Int {
+//	  |         ^^^
+func Snippet(s Span, source []rune) string {
+	line := lineText(source, s.Line)
+	return fmt.Sprintf("  --> %s:%d:%d\n   |\n%2d | %s\n   | %s\n",
+		s.File, s.Line, s.Column,
+		s.Line, line,
+		underline(s, line),
+	)
+}
+
+func lineText(source []rune, lineNum int) string {
+	start := len(source)
+	if lineNum <= 1 {
+		start = 0
+	}
+	cur := 1
+	for i, r := range source {
+		if cur >= lineNum {
+			break
+		}
+		if r == '\n' {
+			cur++
+			start = i + 1
+		}
+	}
+	end := len(source)
+	for i := start; i < len(source); i++ {
+		if source[i] == '\n' {
+			end = i
+			break
+		}
+	}
+	return string(source[start:end])
+}
+
+func underline(s Span, line string) string {
+	col := max(s.Column, 1)
+	width := 1
+	switch {
+	case s.EndLine == s.Line && s.EndColumn > col:
+		width = s.EndColumn - col
+	case s.EndLine > s.Line:
+		width = max(utf8.RuneCountInString(line)-col+1, 1)
+	}
+	// Tabs are kept so that the carets line up under the text.
+	var pad strings.Builder
+	for i, r := range []rune(line) {
+		if i >= col-1 {
+			break
+		}
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+	pad.WriteString(strings.Repeat(" ", max(col-1-utf8.RuneCountInString(line), 0)))
+	return pad.String() + strings.Repeat("^", width)
+}
+
+// List collects diagnostics in the order they were reported. A List with
+// at least one error is also an error value.
+type List []Diagnostic
+
+func (l *List) Add(d Diagnostic) {
+	*l = append(*l, d)
+}
+
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns the diagnostics of severity Error.
+func (l List) Errors() List {
+	var out List
+	for _, d := range l {
+		if d.Severity == Error {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func (l List) Error() string {
+	parts := make([]string, len(l))
+	for i, d := range l {
+		parts[i] = d.String()
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Err returns l as an error if it holds any errors, and nil otherwise.
+func (l List) Err() error {
+	if !l.HasErrors() {
+		return nil
+	}
+	return l
+}
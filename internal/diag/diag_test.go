@@ -0,0 +1,48 @@
+package diag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiagnosticString(t *testing.T) {
+	d := Diagnostic{
+		Severity: Error,
+		Span:     Span{File: "main.flint", Line: 2, Column: 9, EndLine: 2, EndColumn: 12},
+		Message:  "unknown type: 'Foo'",
+		Notes:    []string{"types start with an upper-case letter"},
+		Source:   []rune("fn main() Nil {}\nfn f(x: Foo) Int { 1 }\n"),
+	}
+
+	expected := "error: unknown type: 'Foo'\n" +
+		"  --> main.flint:2:9\n" +
+		"   |\n" +
+		" 2 | fn f(x: Foo) Int { 1 }\n" +
+		"   |         ^^^\n" +
+		"   = note: types start with an upper-case letter\n"
+	if got := d.String(); got != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestListCollectsErrorsAndWarnings(t *testing.T) {
+	var l List
+	if l.Err() != nil {
+		t.Fatal("empty list should not be an error")
+	}
+
+	l.Add(Diagnostic{Severity: Warning, Message: "unused"})
+	if l.Err() != nil || l.HasErrors() {
+		t.Fatal("warnings alone should not be an error")
+	}
+
+	l.Add(Diagnostic{Severity: Error, Message: "first"})
+	l.Add(Diagnostic{Severity: Error, Message: "second"})
+	if len(l.Errors()) != 2 {
+		t.Fatalf("expected 2 errors, got %d", len(l.Errors()))
+	}
+	err := l.Err()
+	if err == nil || !strings.Contains(err.Error(), "first") || !strings.Contains(err.Error(), "second") {
+		t.Fatalf("expected both errors in %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+// Package doc extracts the API documentation of a Flint module: its public
+// functions and types, their signatures and their /// doc comments.
+package doc
+
+import (
+	"flint/internal/parser"
+	"flint/internal/typechecker"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type Module struct {
+	Name  string
+	Items []Item
+}
+
+// An Item is a public declaration of a module.
+type Item struct {
+	Kind      string // "fn" or "type"
+	Name      string
+	Signature string
+	Doc       string
+
+	// External is set for functions implemented outside Flint through
+	// @external.
+	External *External
+}
+
+type External struct {
+	Lang   string
+	Lib    string
+	Symbol string
+}
+
+// New documents the public declarations of prog, which tc has checked, in
+// the order they are declared.
+func New(name string, prog *parser.Program, tc *typechecker.TypeChecker) *Module {
+	m := &Module{Name: name}
+	for _, e := range prog.Exprs {
+		switch n := e.(type) {
+		case *parser.FuncDeclExpr:
+			if !n.Pub {
+				continue
+			}
+			m.Items = append(m.Items, Item{
+				Kind:      "fn",
+				Name:      n.Name.Lexeme,
+				Signature: FuncSignature(n, tc.TypeOf(n)),
+				Doc:       n.Doc,
+				External:  external(n),
+			})
+		case *parser.TypeDeclExpr:
+			if !n.Pub {
+				continue
+			}
+			ty, _ := tc.TypeNamed(n.Name.Lexeme)
+			m.Items = append(m.Items, Item{
+				Kind:      "type",
+				Name:      n.Name.Lexeme,
+				Signature: TypeSignature(n.Name.Lexeme, ty),
+				Doc:       n.Doc,
+			})
+		}
+	}
+	return m
+}
+
+// FuncSignature renders the declaration of fn with the types inferred for
+// it, ty, in place of any annotations it left out.
+func FuncSignature(fn *parser.FuncDeclExpr, ty *typechecker.Type) string {
+	ty = typechecker.Resolve(ty)
+	if ty == nil || ty.TKind != typechecker.TyFunc || len(ty.Params) != len(fn.Params) {
+		return "fn " + fn.Name.Lexeme
+	}
+	names := typechecker.TypeStrings(slices.Concat(ty.Params, []*typechecker.Type{ty.Ret})...)
+	params := make([]string, len(fn.Params))
+	for i, p := range fn.Params {
+		params[i] = p.Name.Lexeme + ": " + names[i]
+	}
+	return fmt.Sprintf("fn %s(%s) %s", fn.Name.Lexeme, strings.Join(params, ", "), names[len(names)-1])
+}
+
+// TypeSignature renders the declaration of the record or variant type ty.
+func TypeSignature(name string, ty *typechecker.Type) string {
+	if ty == nil {
+		return "type " + name
+	}
+	switch ty.TKind {
+	case typechecker.TyRecord:
+		fields := make([]*typechecker.Type, len(ty.Fields))
+		for i, f := range ty.Fields {
+			fields[i] = f.Ty
+		}
+		names := typechecker.TypeStrings(fields...)
+		parts := make([]string, len(ty.Fields))
+		for i, f := range ty.Fields {
+			parts[i] = f.Name + ": " + names[i]
+		}
+		return fmt.Sprintf("type %s { %s }", name, strings.Join(parts, ", "))
+	case typechecker.TyVariant:
+		var b strings.Builder
+		b.WriteString("type " + name + " {")
+		for _, v := range ty.Variants {
+			b.WriteString(" | " + v.Name)
+			if len(v.Fields) > 0 {
+				b.WriteString("(" + strings.Join(typechecker.TypeStrings(v.Fields...), ", ") + ")")
+			}
+		}
+		b.WriteString(" }")
+		return b.String()
+	}
+	return "type " + name
+}
+
+func external(fn *parser.FuncDeclExpr) *External {
+	for _, d := range fn.Decorators {
+		if d.Name != "external" || len(d.Args) != 3 {
+			continue
+		}
+		ext := &External{}
+		if id, ok := d.Args[0].(*parser.Identifier); ok {
+			ext.Lang = id.Name
+		}
+		if s, ok := d.Args[1].(*parser.StringLiteral); ok {
+			ext.Lib = s.Value
+		}
+		if s, ok := d.Args[2].(*parser.StringLiteral); ok {
+			ext.Symbol = s.Value
+		}
+		return ext
+	}
+	return nil
+}
+
+// String renders the decorator that declares e.
+func (e *External) String() string {
+	return fmt.Sprintf("@external(%s, %s, %s)", e.Lang, strconv.Quote(e.Lib), strconv.Quote(e.Symbol))
+}
@@ -0,0 +1,104 @@
+package doc
+
+import (
+	"flint/internal/lexer"
+	"flint/internal/parser"
+	"flint/internal/typechecker"
+	"strings"
+	"testing"
+)
+
+const src = `/// Prints a line.
+@external(c, "flint_stdlib", "print")
+pub fn print(s: String) Nil
+
+/// Returns its argument.
+///
+/// Works for <any> type.
+pub fn id(x) { x }
+
+fn helper() Int { 1 }
+
+/// A shape.
+pub type Shape {
+    | Circle(Float)
+    | Square(Float)
+}
+
+pub type Point { x: Int, y: Int }
+`
+
+func module(t *testing.T) *Module {
+	t.Helper()
+	tokens, err := lexer.Tokenize(src, "shapes.flint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, errs := parser.ParseProgram(tokens)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	tc := typechecker.New()
+	for _, e := range prog.Exprs {
+		tc.CheckExpr(e)
+	}
+	if diags := tc.Diagnostics(); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	return New("shapes", prog, tc)
+}
+
+func TestModuleListsPublicDeclarations(t *testing.T) {
+	m := module(t)
+	want := []Item{
+		{Kind: "fn", Name: "print", Signature: "fn print(s: String) Nil", Doc: "Prints a line."},
+		{Kind: "fn", Name: "id", Signature: "fn id(x: a) a", Doc: "Returns its argument.\n\nWorks for <any> type."},
+		{Kind: "type", Name: "Shape", Signature: "type Shape { | Circle(Float) | Square(Float) }", Doc: "A shape."},
+		{Kind: "type", Name: "Point", Signature: "type Point { x: Int, y: Int }"},
+	}
+	if len(m.Items) != len(want) {
+		t.Fatalf("expected %d items, got %+v", len(want), m.Items)
+	}
+	for i, w := range want {
+		got := m.Items[i]
+		if got.Kind != w.Kind || got.Name != w.Name || got.Signature != w.Signature || got.Doc != w.Doc {
+			t.Errorf("item %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+	ext := m.Items[0].External
+	if ext == nil || ext.String() != `@external(c, "flint_stdlib", "print")` {
+		t.Errorf("expected print to be external, got %v", ext)
+	}
+	if m.Items[1].External != nil {
+		t.Errorf("expected id not to be external")
+	}
+}
+
+func TestRenderings(t *testing.T) {
+	m := module(t)
+	md := Markdown(m)
+	for _, want := range []string{
+		"# shapes\n",
+		"## print\n\n```flint\n@external(c, \"flint_stdlib\", \"print\")\npub fn print(s: String) Nil\n```\n",
+		"Implemented by `print` in `flint_stdlib` (c).",
+		"Returns its argument.\n\nWorks for <any> type.",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected the Markdown to contain %q:\n%s", want, md)
+		}
+	}
+	html, err := HTML(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<h1>shapes</h1>",
+		`<section id="type.Shape">`,
+		"<p>Works for &lt;any&gt; type.</p>",
+		"<pre><code>@external(c, &#34;flint_stdlib&#34;, &#34;print&#34;)\npub fn print(s: String) Nil</code></pre>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected the HTML to contain %q:\n%s", want, html)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package doc
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+)
+
+// Markdown renders m as a Markdown document.
+func Markdown(m *Module) string {
+	var b strings.Builder
+	b.WriteString("# " + m.Name + "\n")
+	for _, it := range m.Items {
+		b.WriteString("\n## " + it.Name + "\n\n```flint\n")
+		if it.External != nil {
+			b.WriteString(it.External.String() + "\n")
+		}
+		b.WriteString("pub " + it.Signature + "\n```\n")
+		if it.External != nil {
+			b.WriteString("\nImplemented by `" + it.External.Symbol + "` in `" + it.External.Lib + "` (" + it.External.Lang + ").\n")
+		}
+		if it.Doc != "" {
+			b.WriteString("\n" + it.Doc + "\n")
+		}
+	}
+	return b.String()
+}
+
+var page = template.Must(template.New("doc").Funcs(template.FuncMap{"paragraphs": paragraphs}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+{{- range .Items}}
+<section id="{{.Kind}}.{{.Name}}">
+<h2>{{.Name}}</h2>
+<pre><code>{{with .External}}{{.String}}
+{{end}}pub {{.Signature}}</code></pre>
+{{- with .External}}
+<p>Implemented by <code>{{.Symbol}}</code> in <code>{{.Lib}}</code> ({{.Lang}}).</p>
+{{- end}}
+{{- range paragraphs .Doc}}
+<p>{{.}}</p>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+// HTML renders m as a standalone HTML page.
+func HTML(m *Module) (string, error) {
+	var b bytes.Buffer
+	if err := page.Execute(&b, m); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// paragraphs splits doc text at its blank lines.
+func paragraphs(doc string) []string {
+	var out []string
+	for _, p := range strings.Split(doc, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,652 @@
+// Package format prints Flint programs in their canonical layout.
+package format
+
+import (
+	"flint/internal/diag"
+	"flint/internal/lexer"
+	"flint/internal/parser"
+	"strings"
+)
+
+const indentWidth = 4
+
+// Source formats the Flint program in src. A program that does not lex or
+// parse is returned unchanged, with the errors that stopped it.
+func Source(src, filename string) (string, error) {
+	var diags diag.List
+	tokens, err := lexer.Tokenize(src, filename)
+	if lexDiags, ok := err.(diag.List); ok {
+		diags = append(diags, lexDiags...)
+	}
+	prog, parseDiags := parser.ParseProgram(tokens)
+	diags = append(diags, parseDiags...)
+	if err := diags.Err(); err != nil {
+		return src, err
+	}
+	return Program(prog, tokens), nil
+}
+
+// Program prints prog, putting back the comments in the trivia of the
+// tokens it was parsed from. Blank lines in the source are kept, though
+// runs of them become one.
+func Program(prog *parser.Program, tokens []lexer.Token) string {
+	p := &printer{lines: strings.Split(lexer.Text(tokens), "\n")}
+	for _, tok := range tokens {
+		p.addComments(tok.Leading, false)
+		p.addComments(tok.Trailing, true)
+	}
+	p.stmts(prog.Exprs, nil)
+	return string(p.buf)
+}
+
+func (p *printer) addComments(trivia []lexer.Trivia, trailing bool) {
+	for _, t := range trivia {
+		if t.Kind == lexer.LineComment {
+			tok := lexer.Token{Kind: lexer.Comment, Lexeme: t.Text, Line: t.Line, Column: t.Column}
+			p.comments = append(p.comments, comment{tok: tok, trailing: trailing})
+		}
+	}
+}
+
+type comment struct {
+	tok lexer.Token
+
+	// trailing comments follow code on the same line, and stay there.
+	trailing bool
+}
+
+type printer struct {
+	buf      []byte
+	indent   int
+	lines    []string
+	comments []comment
+	next     int
+}
+
+func (p *printer) write(s string) {
+	p.buf = append(p.buf, s...)
+}
+
+func (p *printer) newline() {
+	p.buf = append(p.buf, '\n')
+	p.buf = append(p.buf, strings.Repeat(" ", p.indent*indentWidth)...)
+}
+
+// line starts a new line at the current indentation, unless nothing has
+// been written yet.
+func (p *printer) line() {
+	p.trim()
+	if len(p.buf) > 0 {
+		p.newline()
+	}
+}
+
+// reindent replaces the indentation of the line being started with the
+// current one.
+func (p *printer) reindent() {
+	p.trim()
+	p.write(strings.Repeat(" ", p.indent*indentWidth))
+}
+
+func (p *printer) trim() {
+	p.buf = []byte(strings.TrimRight(string(p.buf), " "))
+}
+
+// lastLine returns the last line written, without its indentation.
+func (p *printer) lastLine() string {
+	out := strings.TrimRight(string(p.buf), " ")
+	if !strings.HasSuffix(out, "\n") {
+		return "?"
+	}
+	out = out[:len(out)-1]
+	return strings.TrimSpace(out[strings.LastIndex(out, "\n")+1:])
+}
+
+// blankBefore writes an empty line if the source had one above line, the
+// output has something to separate it from and no block has just opened.
+func (p *printer) blankBefore(line int) {
+	if line < 2 || line-2 >= len(p.lines) || strings.TrimSpace(p.lines[line-2]) != "" {
+		return
+	}
+	last := p.lastLine()
+	if last == "" || strings.HasSuffix(last, "{") {
+		return
+	}
+	p.trim()
+	p.newline()
+}
+
+// flush writes the comments that come before tok, or all of those left if
+// tok is nil. Each starts a line of its own unless it trailed code.
+func (p *printer) flush(tok *lexer.Token) {
+	for ; p.next < len(p.comments); p.next++ {
+		c := p.comments[p.next]
+		if tok != nil && !before(c.tok, *tok) {
+			return
+		}
+		text := strings.TrimRight(c.tok.Lexeme, " \t\r")
+		out := strings.TrimRight(string(p.buf), " ")
+		if c.trailing && strings.HasSuffix(out, "\n") {
+			p.buf = []byte(out[:len(out)-1] + " " + text + "\n")
+			p.write(strings.Repeat(" ", p.indent*indentWidth))
+			continue
+		}
+		p.blankBefore(c.tok.Line)
+		p.write(text)
+		p.line()
+	}
+}
+
+// hasComments reports whether any comment lies between from and to.
+func (p *printer) hasComments(from, to lexer.Token) bool {
+	for _, c := range p.comments[p.next:] {
+		if before(from, c.tok) && before(c.tok, to) {
+			return true
+		}
+	}
+	return false
+}
+
+func before(a, b lexer.Token) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
+
+// stmts writes one expression per line, keeping the comments between them
+// and single blank lines. end is the brace closing them, or nil at the top
+// level.
+func (p *printer) stmts(exprs []parser.Expr, end *lexer.Token) {
+	for _, e := range exprs {
+		start, _ := e.Tokens()
+		p.flush(&start)
+		p.blankBefore(start.Line)
+		p.expr(e)
+		p.line()
+	}
+	p.flush(end)
+}
+
+const (
+	prefixPrec = 7
+	atomPrec   = 8
+)
+
+// precedence returns how tightly e binds as an operand, using the
+// parser's operator precedences.
+func precedence(e parser.Expr) int {
+	switch e := e.(type) {
+	case *parser.InfixExpr:
+		return e.Operator.Kind.Precedence()
+	case *parser.PipelineExpr:
+		return lexer.Pipe.Precedence()
+	case *parser.PrefixExpr:
+		return prefixPrec
+	case *parser.IfExpr:
+		if isBlockIf(e) {
+			return atomPrec
+		}
+		return 0
+	case *parser.VarDeclExpr, *parser.AssignExpr:
+		return 0
+	}
+	return atomPrec
+}
+
+// operand writes e, in parentheses if it binds less tightly than prec.
+func (p *printer) operand(e parser.Expr, prec int) {
+	if precedence(e) < prec {
+		p.write("(")
+		p.expr(e)
+		p.write(")")
+		return
+	}
+	p.expr(e)
+}
+
+func (p *printer) exprs(es []parser.Expr) {
+	for i, e := range es {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expr(e)
+	}
+}
+
+func (p *printer) expr(e parser.Expr) {
+	switch e := e.(type) {
+	case *parser.Identifier:
+		p.write(e.Name)
+	case *parser.IntLiteral:
+		p.write(e.Raw)
+	case *parser.FloatLiteral:
+		p.write(e.Raw)
+	case *parser.StringLiteral:
+		p.write(e.Pos.Lexeme)
+	case *parser.InterpolatedString:
+		p.write(e.Pos.Lexeme)
+	case *parser.ByteLiteral:
+		p.write(e.Raw)
+	case *parser.BoolLiteral:
+		p.write(e.Pos.Lexeme)
+	case *parser.PrefixExpr:
+		p.write(e.Operator.Lexeme)
+		p.operand(e.Right, atomPrec)
+	case *parser.InfixExpr:
+		prec := e.Operator.Kind.Precedence()
+		p.operand(e.Left, prec)
+		if e.Operator.Kind == lexer.DotDot {
+			// Ranges are written like range patterns, without spaces.
+			p.write("..")
+		} else {
+			p.write(" " + e.Operator.Lexeme + " ")
+		}
+		p.operand(e.Right, prec+1)
+	case *parser.PipelineExpr:
+		prec := lexer.Pipe.Precedence()
+		p.operand(e.Left, prec)
+		p.write(" |> ")
+		p.operand(e.Right, prec+1)
+	case *parser.CallExpr:
+		p.operand(e.Callee, atomPrec)
+		p.write("(")
+		p.exprs(e.Args)
+		p.write(")")
+	case *parser.VarDeclExpr:
+		if e.Mutable {
+			p.write("mut ")
+		} else {
+			p.write("val ")
+		}
+		if e.Pattern != nil {
+			p.pattern(e.Pattern)
+		} else {
+			p.write(e.Name.Lexeme)
+		}
+		if e.Type != nil {
+			p.write(": ")
+			p.typ(e.Type)
+		}
+		p.write(" = ")
+		p.expr(e.Value)
+	case *parser.FuncDeclExpr:
+		p.funcDecl(e)
+	case *parser.LambdaExpr:
+		p.write("fn")
+		p.params(e.Params)
+		if e.Ret != nil {
+			p.write(" ")
+			p.typ(e.Ret)
+		}
+		p.write(" ")
+		p.expr(e.Body)
+	case *parser.BlockExpr:
+		p.block(e, false)
+	case *parser.UseExpr:
+		p.write("use " + strings.Join(e.Path, "/"))
+		if len(e.Members) > 0 {
+			p.write(".{" + strings.Join(e.Members, ", ") + "}")
+		}
+		if e.Alias != "" {
+			p.write(" as " + e.Alias)
+		}
+	case *parser.QualifiedExpr:
+		p.operand(e.Left, atomPrec)
+		p.write(":" + e.Right.Lexeme)
+	case *parser.FieldAccessExpr:
+		p.operand(e.Left, atomPrec)
+		p.write("." + e.Right)
+	case *parser.IndexExpr:
+		p.operand(e.Target, atomPrec)
+		p.write("[")
+		p.expr(e.Index)
+		p.write("]")
+	case *parser.IfExpr:
+		p.write("if ")
+		p.expr(e.Cond)
+		if isBlockIf(e) {
+			// The branches are both on one line or both spread out.
+			then, _ := e.Then.(*parser.BlockExpr)
+			els, _ := e.Else.(*parser.BlockExpr)
+			_, inline := p.inline(then)
+			if els != nil {
+				_, elseInline := p.inline(els)
+				inline = inline && elseInline
+			}
+			p.write(" ")
+			p.block(then, !inline)
+			if els != nil {
+				p.write(" else ")
+				p.block(els, !inline)
+			}
+			return
+		}
+		p.write(" then ")
+		p.expr(e.Then)
+		p.write(" else ")
+		p.expr(e.Else)
+	case *parser.ForExpr:
+		p.write("for ")
+		p.pattern(e.Pattern)
+		p.write(" in ")
+		p.expr(e.Iter)
+		p.write(" ")
+		p.block(e.Body, false)
+	case *parser.MatchExpr:
+		p.write("match ")
+		p.expr(e.Value)
+		p.write(" {")
+		p.indent++
+		p.line()
+		for _, arm := range e.Arms {
+			p.flush(&arm.First)
+			p.blankBefore(arm.First.Line)
+			p.write("| ")
+			p.pattern(arm.Pattern)
+			if arm.Guard != nil {
+				p.write(" if ")
+				p.expr(arm.Guard)
+			}
+			p.write(" -> ")
+			p.expr(arm.Body)
+			p.line()
+		}
+		p.flush(&e.Last)
+		p.indent--
+		p.reindent()
+		p.write("}")
+	case *parser.ListExpr:
+		if !p.hasComments(e.First, e.Last) {
+			p.write("[")
+			p.exprs(e.Elements)
+			p.write("]")
+			return
+		}
+		elems := make([]field, len(e.Elements))
+		for i, el := range e.Elements {
+			start, _ := el.Tokens()
+			elems[i] = field{start, func() { p.expr(el) }}
+		}
+		p.write("[")
+		p.eachOnALine(e.Last, elems, ",")
+		p.write("]")
+	case *parser.TupleExpr:
+		p.write("(")
+		p.exprs(e.Elements)
+		p.write(")")
+	case *parser.RecordExpr:
+		if e.Module != nil {
+			p.write(e.Module.Name + ":")
+		}
+		p.write(e.Name.Lexeme + " ")
+		fields := make([]field, len(e.Fields))
+		for i, f := range e.Fields {
+			fields[i] = field{f.Name, func() {
+				p.write(f.Name.Lexeme + ": ")
+				p.expr(f.Value)
+			}}
+		}
+		p.fields(e.Name, e.Last, fields, ",")
+	case *parser.TypeDeclExpr:
+		if e.Pub {
+			p.write("pub ")
+		}
+		p.write("type " + e.Name.Lexeme)
+		switch body := e.Body.(type) {
+		case *parser.RecordTypeExpr:
+			fields := make([]field, len(body.Fields))
+			for i, f := range body.Fields {
+				fields[i] = field{f.Name, func() {
+					p.write(f.Name.Lexeme + ": ")
+					p.typ(f.Type)
+				}}
+			}
+			p.write(" ")
+			p.fields(e.Name, body.Last, fields, ",")
+		case *parser.VariantTypeExpr:
+			fields := make([]field, len(body.Variants))
+			for i, v := range body.Variants {
+				fields[i] = field{v.Name, func() {
+					p.write("| " + v.Name.Lexeme)
+					if len(v.Fields) > 0 {
+						p.write("(")
+						p.types(v.Fields)
+						p.write(")")
+					}
+				}}
+			}
+			p.write(" ")
+			p.fields(e.Name, body.Last, fields, "")
+		}
+	case *parser.AssignExpr:
+		p.write(e.Name.Name + " = ")
+		p.expr(e.Value)
+	}
+}
+
+// isBlockIf reports whether e is written `if c { ... } else { ... }` rather
+// than `if c then a else b`.
+func isBlockIf(e *parser.IfExpr) bool {
+	_, then := e.Then.(*parser.BlockExpr)
+	_, els := e.Else.(*parser.BlockExpr)
+	return then && (e.Else == nil || els)
+}
+
+func (p *printer) funcDecl(fn *parser.FuncDeclExpr) {
+	for _, d := range fn.Decorators {
+		p.write("@" + d.Name)
+		if len(d.Args) > 0 {
+			p.write("(")
+			p.exprs(d.Args)
+			p.write(")")
+		}
+		p.newline()
+	}
+	if fn.Pub {
+		p.write("pub ")
+	}
+	p.write("fn " + fn.Name.Lexeme)
+	p.params(fn.Params)
+	if fn.Ret != nil {
+		p.write(" ")
+		p.typ(fn.Ret)
+	}
+	if fn.Body != nil {
+		p.write(" ")
+		p.expr(fn.Body)
+	}
+}
+
+func (p *printer) params(params []parser.Param) {
+	p.write("(")
+	for i, param := range params {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.write(param.Name.Lexeme)
+		if param.Type != nil {
+			p.write(": ")
+			p.typ(param.Type)
+		}
+	}
+	p.write(")")
+}
+
+// block writes b on one line, if inline allows it and spread is false, and
+// one expression per line otherwise.
+func (p *printer) block(b *parser.BlockExpr, spread bool) {
+	if s, ok := p.inline(b); ok && !spread {
+		p.write(s)
+		return
+	}
+	p.write("{")
+	p.indent++
+	p.line()
+	p.stmts(b.Exprs, &b.Last)
+	p.indent--
+	p.reindent()
+	p.write("}")
+}
+
+// inline returns b written on one line. Only empty blocks, and blocks the
+// source wrote on one line with a single expression that fits on one, are
+// written that way.
+func (p *printer) inline(b *parser.BlockExpr) (string, bool) {
+	if p.hasComments(b.First, b.Last) {
+		return "", false
+	}
+	switch {
+	case len(b.Exprs) == 0:
+		return "{}", true
+	case len(b.Exprs) == 1 && b.First.Line == b.Last.Line:
+		if s := p.render(func() { p.expr(b.Exprs[0]) }); !strings.Contains(s, "\n") {
+			return "{ " + s + " }", true
+		}
+	}
+	return "", false
+}
+
+// render returns what f writes instead of writing it.
+func (p *printer) render(f func()) string {
+	start := len(p.buf)
+	f()
+	s := string(p.buf[start:])
+	p.buf = p.buf[:start]
+	return s
+}
+
+// A field is one entry of a record literal, type body or list.
+type field struct {
+	name  lexer.Token
+	write func()
+}
+
+// fields writes the braces around the fields of a record or type body:
+// on one line when the source did and there are no comments among them,
+// and one field per line, each followed by sep, otherwise.
+func (p *printer) fields(open, end lexer.Token, fields []field, sep string) {
+	if len(fields) == 0 && !p.hasComments(open, end) {
+		p.write("{}")
+		return
+	}
+	if open.Line == end.Line && !p.hasComments(open, end) {
+		p.write("{ ")
+		for i, f := range fields {
+			if i > 0 && sep != "" {
+				p.write(sep)
+			}
+			if i > 0 {
+				p.write(" ")
+			}
+			f.write()
+		}
+		p.write(" }")
+		return
+	}
+	p.write("{")
+	p.eachOnALine(end, fields, sep)
+	p.write("}")
+}
+
+// eachOnALine writes fields indented one per line, each followed by sep,
+// along with the comments among them and before end, which closes them.
+func (p *printer) eachOnALine(end lexer.Token, fields []field, sep string) {
+	p.indent++
+	p.line()
+	for _, f := range fields {
+		p.flush(&f.name)
+		p.blankBefore(f.name.Line)
+		f.write()
+		p.write(sep)
+		p.line()
+	}
+	p.flush(&end)
+	p.indent--
+	p.reindent()
+}
+
+func (p *printer) types(ts []parser.Expr) {
+	for i, t := range ts {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.typ(t)
+	}
+}
+
+func (p *printer) typ(t parser.Expr) {
+	switch t := t.(type) {
+	case *parser.TypeExpr:
+		p.write(t.Name)
+		if t.Generic != nil {
+			p.write("(")
+			p.typ(t.Generic)
+			p.write(")")
+		}
+	case *parser.TupleTypeExpr:
+		p.write("(")
+		p.types(t.Types)
+		p.write(")")
+	case *parser.FuncTypeExpr:
+		p.write("(")
+		p.types(t.Params)
+		p.write(") -> ")
+		p.typ(t.Ret)
+	}
+}
+
+func (p *printer) patterns(ps []parser.Pattern) {
+	for i, pat := range ps {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.pattern(pat)
+	}
+}
+
+func (p *printer) pattern(pat parser.Pattern) {
+	switch pat := pat.(type) {
+	case *parser.WildcardPattern:
+		p.write("_")
+	case *parser.BindingPattern:
+		p.write(pat.Name.Lexeme)
+	case *parser.LiteralPattern:
+		p.expr(pat.Value)
+	case *parser.RangePattern:
+		p.expr(pat.Low)
+		p.write("..")
+		p.expr(pat.High)
+	case *parser.TuplePattern:
+		p.write("(")
+		p.patterns(pat.Elements)
+		p.write(")")
+	case *parser.ListPattern:
+		p.write("[")
+		p.patterns(pat.Elements)
+		if pat.Rest != nil {
+			if len(pat.Elements) > 0 {
+				p.write(", ")
+			}
+			p.write("..")
+			if w, ok := pat.Rest.(*parser.WildcardPattern); !ok || w.Pos.Kind != lexer.DotDot {
+				p.pattern(pat.Rest)
+			}
+		}
+		p.write("]")
+	case *parser.ConstructorPattern:
+		p.write(pat.Name.Lexeme)
+		if pat.Args != nil {
+			p.write("(")
+			p.patterns(pat.Args)
+			p.write(")")
+		}
+	case *parser.OrPattern:
+		for i, alt := range pat.Alternatives {
+			if i > 0 {
+				p.write(" | ")
+			}
+			p.pattern(alt)
+		}
+	case *parser.AsPattern:
+		p.pattern(pat.Pattern)
+		p.write(" as " + pat.Name.Lexeme)
+	}
+}
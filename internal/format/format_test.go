@@ -0,0 +1,196 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func formatSrc(t *testing.T, src string) string {
+	t.Helper()
+	out, err := Source(src, "test.flint")
+	if err != nil {
+		t.Fatalf("unexpected errors: %v", err)
+	}
+	again, err := Source(out, "test.flint")
+	if err != nil {
+		t.Fatalf("formatted source does not parse: %v\n%s", err, out)
+	}
+	if again != out {
+		t.Fatalf("formatting is not stable:\n%s\nbecame\n%s", out, again)
+	}
+	return out
+}
+
+func TestFormatLayout(t *testing.T) {
+	src := `use flint/io
+type Shape {   | Circle(Float)| Square(Float) }
+fn area(s: Shape) Float {
+  match s {
+  Circle(r) -> 3.14 *. r *. r
+  | Square(w) if w >. 0.0 -> w *. w
+  }
+}
+fn main() Nil {
+    mut xs = [1,2,3]
+    val (a, b) = (1, 2)
+    val f = fn(x) { x + 1 }
+    if a > 0 {
+      io:println("pos")
+    } else { io:println("neg") }
+}`
+	want := `use flint/io
+type Shape { | Circle(Float) | Square(Float) }
+fn area(s: Shape) Float {
+    match s {
+        | Circle(r) -> 3.14 *. r *. r
+        | Square(w) if w >. 0.0 -> w *. w
+    }
+}
+fn main() Nil {
+    mut xs = [1, 2, 3]
+    val (a, b) = (1, 2)
+    val f = fn(x) { x + 1 }
+    if a > 0 {
+        io:println("pos")
+    } else {
+        io:println("neg")
+    }
+}
+`
+	if got := formatSrc(t, src); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatKeepsNeededParentheses(t *testing.T) {
+	tests := map[string]string{
+		"(1 + 2) * 3":                "(1 + 2) * 3\n",
+		"1 + (2 * 3)":                "1 + 2 * 3\n",
+		"a - (b - c)":                "a - (b - c)\n",
+		"(a - b) - c":                "a - b - c\n",
+		"-(a + b)":                   "-(a + b)\n",
+		"(a || b) && c":              "(a || b) && c\n",
+		"(if c then 1 else 2) + 3":   "(if c then 1 else 2) + 3\n",
+		"(xs |> f) == ys":            "(xs |> f) == ys\n",
+		"p.x + q[0]":                 "p.x + q[0]\n",
+		"val x: List(Int) = f(1)(2)": "val x: List(Int) = f(1)(2)\n",
+	}
+	for src, want := range tests {
+		if got := formatSrc(t, src); got != want {
+			t.Errorf("%s: got %q, want %q", src, got, want)
+		}
+	}
+}
+
+func TestFormatPreservesComments(t *testing.T) {
+	src := `// header
+
+
+type Point {
+  x: Int, // across
+  // down
+  y: Int
+}
+fn main() Nil { // entry
+    // first
+    val p = Point { x: 1, y: 2 }
+
+
+    p.x // trailing
+    // last
+}
+// end`
+	want := `// header
+
+type Point {
+    x: Int, // across
+    // down
+    y: Int,
+}
+fn main() Nil { // entry
+    // first
+    val p = Point { x: 1, y: 2 }
+
+    p.x // trailing
+    // last
+}
+// end
+`
+	if got := formatSrc(t, src); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatKeepsCommentsInLists(t *testing.T) {
+	src := "val xs = [\n 1, // one\n // two\n 2\n]\nval ys = [\n 1,\n 2 // last\n]\nval zs = [\n 1,\n 2\n]"
+	want := "val xs = [\n    1, // one\n    // two\n    2,\n]\nval ys = [\n    1,\n    2, // last\n]\nval zs = [1, 2]\n"
+	if got := formatSrc(t, src); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatPatterns(t *testing.T) {
+	src := "match xs { | [h, ..rest] -> h | [..] -> 0 | [1 | 2 as z, .._] -> z | (-1, 0..9) -> 1 | Some(_) -> 2 }"
+	want := `match xs {
+    | [h, ..rest] -> h
+    | [..] -> 0
+    | [1 | 2 as z, .._] -> z
+    | (-1, 0..9) -> 1
+    | Some(_) -> 2
+}
+`
+	if got := formatSrc(t, src); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatForLoops(t *testing.T) {
+	src := "fn f() Nil {\nfor i in 0 .. n { g(i) }\nfor (k, v) in pairs {\ng(k)\ng(v)\n}\n}"
+	want := `fn f() Nil {
+    for i in 0..n { g(i) }
+    for (k, v) in pairs {
+        g(k)
+        g(v)
+    }
+}
+`
+	if got := formatSrc(t, src); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatRefusesInvalidSource(t *testing.T) {
+	src := "fn main( {"
+	out, err := Source(src, "test.flint")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if out != src {
+		t.Fatalf("expected the source back, got %q", out)
+	}
+}
+
+func TestExamplesAreFormatted(t *testing.T) {
+	files, _ := filepath.Glob("../../example/*.flint")
+	if len(files) == 0 {
+		t.Fatal("no examples found")
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := formatSrc(t, string(src)); got != string(src) {
+			t.Errorf("%s is not formatted:\n%s", file, got)
+		}
+	}
+}
+
+func TestFormatKeepsCommentsBetweenMatchArms(t *testing.T) {
+	src := "match x {\n  // small\n  | 0 -> a // zero\n\n  // big\n  | _ -> b\n  // done\n}"
+	want := "match x {\n    // small\n    | 0 -> a // zero\n\n    // big\n    | _ -> b\n    // done\n}\n"
+	if got := formatSrc(t, src); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,298 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"flint/internal/lexer"
+	"flint/internal/parser"
+	"flint/internal/stdlib"
+)
+
+// externals maps the C symbol named in an @external decorator, or given to
+// a built-in function by the stdlib registry, to the host-side
+// implementation used when the program is interpreted.
+var externals = map[string]*Builtin{}
+
+// defined holds the built-in functions the registry gives no runtime
+// symbol, by module path and name.
+var defined = map[string]map[string]func(in *Interpreter, args []Value) Value{}
+
+var modules = map[string]map[string]Value{}
+
+// stdCtors holds the constructors of the types of the built-in modules,
+// which every interpreter starts out knowing so that patterns can match
+// the values built-in functions return.
+var stdCtors = map[string]*Constructor{}
+
+func registerExternal(name string, fn func(in *Interpreter, args []Value) Value) *Builtin {
+	b := &Builtin{Name: name, Fn: fn}
+	externals[name] = b
+	return b
+}
+
+func init() {
+	printFn := func(in *Interpreter, args []Value) Value {
+		fmt.Fprint(in.out, args[0].String())
+		return Nil{}
+	}
+	printlnFn := func(in *Interpreter, args []Value) Value {
+		fmt.Fprintln(in.out, args[0].String())
+		return Nil{}
+	}
+	toString := func(in *Interpreter, args []Value) Value {
+		return String(args[0].String())
+	}
+	registerExternal("print", printFn)
+	registerExternal("println", printlnFn)
+	registerExternal("to_string", toString)
+	registerExternal("assert", func(in *Interpreter, args []Value) Value {
+		if ok, _ := args[0].(Bool); !ok {
+			panic(&RuntimeError{Msg: "assertion failed"})
+		}
+		return Nil{}
+	})
+	registerExternal("flint_print", printFn)
+	registerExternal("flint_println", printlnFn)
+	registerExternal("flint_int_to_string", toString)
+	registerExternal("flint_float_to_string", toString)
+	registerStrings()
+	registerLists()
+	registerOS()
+
+	for _, m := range stdlib.Modules() {
+		modules[m.Path] = builtinModule(m)
+	}
+}
+
+// builtinModule binds the functions of m to their implementations, and
+// the constructors of its types to their values.
+func builtinModule(m *stdlib.Module) map[string]Value {
+	prefix := m.Path[strings.LastIndex(m.Path, "/")+1:] + ":"
+	mod := map[string]Value{}
+	for _, t := range m.Types {
+		// Types have no value, but may be imported by name.
+		mod[t.Name()] = nil
+		for _, ctor := range stdType(t) {
+			stdCtors[ctor.Name] = ctor
+			mod[ctor.Name] = ctor
+			if ctor.Arity == 0 {
+				mod[ctor.Name] = &Variant{Type: ctor.Type, Name: ctor.Name}
+			}
+		}
+	}
+	for _, f := range m.Funcs {
+		fn := defined[m.Path][f.Name()]
+		if b, ok := externals[f.Symbol]; ok {
+			fn = b.Fn
+		}
+		if fn == nil {
+			panic("interp: no implementation of " + prefix + f.Name())
+		}
+		mod[f.Name()] = &Builtin{Name: prefix + f.Name(), Fn: fn}
+	}
+	return mod
+}
+
+// stdType returns the constructors of t.
+func stdType(t stdlib.Type) []*Constructor {
+	tokens, err := lexer.Tokenize(t.Decl, t.Name())
+	prog, diags := parser.ParseProgram(tokens)
+	if err != nil || len(diags) > 0 || len(prog.Exprs) != 1 {
+		panic("interp: bad declaration " + t.Decl)
+	}
+	body, _ := prog.Exprs[0].(*parser.TypeDeclExpr).Body.(*parser.VariantTypeExpr)
+	if body == nil {
+		panic("interp: " + t.Name() + " is not a variant type")
+	}
+	var ctors []*Constructor
+	for _, v := range body.Variants {
+		ctors = append(ctors, &Constructor{Type: t.Name(), Name: v.Name.Lexeme, Arity: len(v.Fields)})
+	}
+	return ctors
+}
+
+// registerStrings implements the string functions of the runtime. Strings
+// are bytes: lengths and offsets count bytes, and to_upper only changes
+// ASCII letters, as in the runtime.
+func registerStrings() {
+	registerExternal("flint_string_length", func(in *Interpreter, args []Value) Value {
+		return Int(len(args[0].(String)))
+	})
+	registerExternal("flint_string_slice", func(in *Interpreter, args []Value) Value {
+		s := args[0].(String)
+		clamp := func(i Int) int { return int(min(max(i, 0), Int(len(s)))) }
+		start, end := clamp(args[1].(Int)), clamp(args[2].(Int))
+		if end < start {
+			return String("")
+		}
+		return s[start:end]
+	})
+	registerExternal("flint_string_split", func(in *Interpreter, args []Value) Value {
+		s, sep := string(args[0].(String)), string(args[1].(String))
+		var parts []string
+		if sep == "" {
+			for i := range len(s) {
+				parts = append(parts, s[i:i+1])
+			}
+		} else {
+			parts = strings.Split(s, sep)
+		}
+		out := make([]Value, len(parts))
+		for i, p := range parts {
+			out[i] = String(p)
+		}
+		return &List{Elems: out}
+	})
+	registerExternal("flint_string_contains", func(in *Interpreter, args []Value) Value {
+		return Bool(strings.Contains(string(args[0].(String)), string(args[1].(String))))
+	})
+	registerExternal("flint_string_to_upper", func(in *Interpreter, args []Value) Value {
+		b := []byte(args[0].(String))
+		for i, c := range b {
+			if 'a' <= c && c <= 'z' {
+				b[i] = c - 'a' + 'A'
+			}
+		}
+		return String(b)
+	})
+	defined["flint/string"] = map[string]func(in *Interpreter, args []Value) Value{
+		"parse_int": func(in *Interpreter, args []Value) Value {
+			n, err := strconv.ParseInt(string(args[0].(String)), 10, 64)
+			if err != nil {
+				return &Tuple{Elems: []Value{Int(0), Bool(false)}}
+			}
+			return &Tuple{Elems: []Value{Int(n), Bool(true)}}
+		},
+	}
+}
+
+// registerLists implements flint/list. push appends to the list it is
+// given; the other functions return new lists.
+func registerLists() {
+	registerExternal("flint_list_reverse", func(in *Interpreter, args []Value) Value {
+		out := slices.Clone(args[0].(*List).Elems)
+		slices.Reverse(out)
+		return &List{Elems: out}
+	})
+	registerExternal("flint_list_concat", func(in *Interpreter, args []Value) Value {
+		return &List{Elems: slices.Concat(args[0].(*List).Elems, args[1].(*List).Elems)}
+	})
+	defined["flint/list"] = map[string]func(in *Interpreter, args []Value) Value{
+		"length": func(in *Interpreter, args []Value) Value {
+			return Int(len(args[0].(*List).Elems))
+		},
+		"push": func(in *Interpreter, args []Value) Value {
+			l := args[0].(*List)
+			l.Elems = append(l.Elems, args[1])
+			return Nil{}
+		},
+		"map": func(in *Interpreter, args []Value) Value {
+			elems := args[0].(*List).Elems
+			out := make([]Value, len(elems))
+			for i, e := range elems {
+				out[i] = in.call(args[1], []Value{e}, lexer.Token{})
+			}
+			return &List{Elems: out}
+		},
+		"filter": func(in *Interpreter, args []Value) Value {
+			out := []Value{}
+			for _, e := range args[0].(*List).Elems {
+				if keep, _ := in.call(args[1], []Value{e}, lexer.Token{}).(Bool); keep {
+					out = append(out, e)
+				}
+			}
+			return &List{Elems: out}
+		},
+		"fold": func(in *Interpreter, args []Value) Value {
+			acc := args[1]
+			for _, e := range args[0].(*List).Elems {
+				acc = in.call(args[2], []Value{acc, e}, lexer.Token{})
+			}
+			return acc
+		},
+	}
+}
+
+// registerOS implements flint/fs and flint/os. Failures are returned as
+// the Error of a Result, with the messages of the runtime.
+func registerOS() {
+	ok := func(s string) Value {
+		return &Variant{Type: stdlib.Result.Name(), Name: "Ok", Values: []Value{String(s)}}
+	}
+	failed := func(msg string) Value {
+		return &Variant{Type: stdlib.Result.Name(), Name: "Error", Values: []Value{String(msg)}}
+	}
+	registerExternal("flint_fs_read_file", func(in *Interpreter, args []Value) Value {
+		data, err := os.ReadFile(string(args[0].(String)))
+		if err != nil {
+			return failed(ioError(err))
+		}
+		return ok(string(data))
+	})
+	registerExternal("flint_fs_write_file", func(in *Interpreter, args []Value) Value {
+		path := string(args[0].(String))
+		if err := os.WriteFile(path, []byte(args[1].(String)), 0644); err != nil {
+			return failed(ioError(err))
+		}
+		return ok(path)
+	})
+	registerExternal("flint_os_read_line", func(in *Interpreter, args []Value) Value {
+		line, err := in.stdin.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return failed("end of input")
+			}
+			return failed(ioError(err))
+		}
+		line = strings.TrimSuffix(line, "\n")
+		return ok(strings.TrimSuffix(line, "\r"))
+	})
+	registerExternal("flint_os_args", func(in *Interpreter, args []Value) Value {
+		out := make([]Value, len(in.args))
+		for i, a := range in.args {
+			out[i] = String(a)
+		}
+		return &List{Elems: out}
+	})
+	registerExternal("flint_os_env", func(in *Interpreter, args []Value) Value {
+		name := string(args[0].(String))
+		v, set := os.LookupEnv(name)
+		if !set {
+			return failed("environment variable " + name + " is not set")
+		}
+		return ok(v)
+	})
+	registerExternal("flint_os_exit", func(in *Interpreter, args []Value) Value {
+		panic(&Exit{Code: int(args[0].(Int))})
+	})
+}
+
+// ioError formats err as the runtime does, with the path of the file and
+// the message strerror gives for the error.
+func ioError(err error) string {
+	var pe *fs.PathError
+	if !errors.As(err, &pe) {
+		return err.Error()
+	}
+	msg := pe.Err.Error()
+	if msg != "" {
+		msg = strings.ToUpper(msg[:1]) + msg[1:]
+	}
+	return pe.Path + ": " + msg
+}
+
+func (in *Interpreter) getModule(path []string) (map[string]Value, bool) {
+	key := strings.Join(path, "/")
+	if m, ok := in.imports[key]; ok {
+		return m, true
+	}
+	m, ok := modules[key]
+	return m, ok
+}
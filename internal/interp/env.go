@@ -0,0 +1,46 @@
+package interp
+
+import "maps"
+
+type Env struct {
+	vars    map[string]Value
+	parent  *Env
+	modules map[string]map[string]Value
+}
+
+func NewEnv(parent *Env) *Env {
+	modules := make(map[string]map[string]Value)
+	if parent != nil && parent.modules != nil {
+		maps.Copy(modules, parent.modules)
+	}
+	return &Env{
+		vars:    make(map[string]Value),
+		parent:  parent,
+		modules: modules,
+	}
+}
+
+func (e *Env) Get(name string) (Value, bool) {
+	if v, ok := e.vars[name]; ok {
+		return v, true
+	}
+	if e.parent != nil {
+		return e.parent.Get(name)
+	}
+	return nil, false
+}
+
+func (e *Env) Set(name string, v Value) {
+	e.vars[name] = v
+}
+
+func (e *Env) Assign(name string, v Value) bool {
+	if _, ok := e.vars[name]; ok {
+		e.vars[name] = v
+		return true
+	}
+	if e.parent != nil {
+		return e.parent.Assign(name, v)
+	}
+	return false
+}
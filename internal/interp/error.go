@@ -0,0 +1,42 @@
+package interp
+
+import (
+	"flint/internal/diag"
+	"flint/internal/lexer"
+	"flint/internal/parser"
+	"fmt"
+)
+
+// RuntimeError is raised while evaluating a program, e.g. an out of bounds
+// index or a match that no arm covers.
+type RuntimeError struct {
+	Msg  string
+	Span diag.Span
+
+	// Source is the text of Span.File, used to show the offending line.
+	Source []rune
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Source == nil {
+		return e.Msg
+	}
+	return e.Msg + "\n" + diag.Snippet(e.Span, e.Source)
+}
+
+// Exit is returned by Run and Load when the program calls os:exit.
+type Exit struct {
+	Code int
+}
+
+func (e *Exit) Error() string { return fmt.Sprintf("exit status %d", e.Code) }
+
+func (in *Interpreter) errorAt(tok lexer.Token, msg string) {
+	panic(&RuntimeError{Msg: msg, Span: tok.Span(), Source: tok.Source})
+}
+
+// errorIn raises an error that covers the whole of n.
+func (in *Interpreter) errorIn(n parser.Node, msg string) {
+	first, _ := n.Tokens()
+	panic(&RuntimeError{Msg: msg, Span: n.Span(), Source: first.Source})
+}
@@ -0,0 +1,570 @@
+package interp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"flint/internal/lexer"
+	"flint/internal/parser"
+)
+
+type Interpreter struct {
+	env     *Env
+	out     io.Writer
+	records map[string][]string
+	ctors   map[string]*Constructor
+
+	// imports holds the pub members of the source modules loaded so far.
+	imports map[string]map[string]Value
+
+	// stdin and args are what flint/os reads the input and the
+	// command-line arguments of the program from.
+	stdin *bufio.Reader
+	args  []string
+}
+
+func New(out io.Writer) *Interpreter {
+	return &Interpreter{
+		env:     NewEnv(nil),
+		out:     out,
+		records: map[string][]string{},
+		ctors:   maps.Clone(stdCtors),
+		imports: map[string]map[string]Value{},
+		stdin:   bufio.NewReader(os.Stdin),
+	}
+}
+
+// SetInput makes r the standard input of the program.
+func (in *Interpreter) SetInput(r io.Reader) {
+	in.stdin = bufio.NewReader(r)
+}
+
+// SetArgs sets the command-line arguments of the program, without the
+// program name.
+func (in *Interpreter) SetArgs(args []string) {
+	in.args = args
+}
+
+// Load evaluates the top level of prog, a module that others use by path,
+// in an environment of its own, and makes its pub members available to
+// them. Modules must be loaded before those that use them.
+func (in *Interpreter) Load(path string, prog *parser.Program) (err error) {
+	defer catchRuntimeError(&err)
+	old := in.env
+	in.env = NewEnv(nil)
+	defer func() { in.env = old }()
+	for _, e := range prog.Exprs {
+		in.Eval(e)
+	}
+	exports := map[string]Value{}
+	for _, e := range prog.Exprs {
+		switch n := e.(type) {
+		case *parser.FuncDeclExpr:
+			if n.Pub {
+				exports[n.Name.Lexeme], _ = in.env.Get(n.Name.Lexeme)
+			}
+		case *parser.TypeDeclExpr:
+			if !n.Pub {
+				continue
+			}
+			// Types have no value, but may be imported by name.
+			exports[n.Name.Lexeme] = nil
+			if v, ok := n.Body.(*parser.VariantTypeExpr); ok {
+				for _, c := range v.Variants {
+					exports[c.Name.Lexeme], _ = in.env.Get(c.Name.Lexeme)
+				}
+			}
+		}
+	}
+	in.imports[path] = exports
+	return nil
+}
+
+// Run evaluates every top-level expression of prog and then calls `main`
+// when the program defines one. Runtime failures are reported as a
+// *RuntimeError.
+func (in *Interpreter) Run(prog *parser.Program) (err error) {
+	defer catchRuntimeError(&err)
+	for _, e := range prog.Exprs {
+		in.Eval(e)
+	}
+	if mainFn, ok := in.env.Get("main"); ok {
+		in.call(mainFn, nil, lexer.Token{})
+	}
+	return nil
+}
+
+// catchRuntimeError turns the panic of a runtime error, or of a call to
+// os:exit, into *err.
+func catchRuntimeError(err *error) {
+	if r := recover(); r != nil {
+		switch e := r.(type) {
+		case *RuntimeError:
+			*err = e
+		case *Exit:
+			*err = e
+		default:
+			panic(r)
+		}
+	}
+}
+
+func (in *Interpreter) Eval(expr parser.Expr) Value {
+	switch e := expr.(type) {
+	case *parser.IntLiteral:
+		return Int(e.Value)
+	case *parser.FloatLiteral:
+		return Float(e.Value)
+	case *parser.BoolLiteral:
+		return Bool(e.Value)
+	case *parser.StringLiteral:
+		return String(e.Value)
+	case *parser.InterpolatedString:
+		return in.visitInterpolatedString(e)
+	case *parser.ByteLiteral:
+		return Byte(e.Value)
+	case *parser.Identifier:
+		return in.visitIdentifier(e)
+	case *parser.PrefixExpr:
+		return in.visitPrefix(e)
+	case *parser.InfixExpr:
+		return in.visitInfix(e)
+	case *parser.VarDeclExpr:
+		v := in.Eval(e.Value)
+		if e.Pattern != nil {
+			if !in.matchPattern(e.Pattern, v) {
+				in.errorAt(parser.PatternPos(e.Pattern), fmt.Sprintf("value %s does not match the pattern", v.String()))
+			}
+			return v
+		}
+		in.env.Set(e.Name.Lexeme, v)
+		return v
+	case *parser.AssignExpr:
+		v := in.Eval(e.Value)
+		if !in.env.Assign(e.Name.Name, v) {
+			in.errorAt(e.Pos, fmt.Sprintf("undefined variable '%s'", e.Name.Name))
+		}
+		return v
+	case *parser.FuncDeclExpr:
+		return in.visitFuncDecl(e)
+	case *parser.LambdaExpr:
+		return in.visitLambda(e)
+	case *parser.CallExpr:
+		callee := in.Eval(e.Callee)
+		args := make([]Value, len(e.Args))
+		for i, a := range e.Args {
+			args[i] = in.Eval(a)
+		}
+		return in.call(callee, args, e.Pos)
+	case *parser.BlockExpr:
+		return in.visitBlock(e)
+	case *parser.UseExpr:
+		return in.visitUse(e)
+	case *parser.QualifiedExpr:
+		return in.visitQualified(e)
+	case *parser.IfExpr:
+		return in.visitIf(e)
+	case *parser.MatchExpr:
+		return in.visitMatch(e)
+	case *parser.ForExpr:
+		return in.visitFor(e)
+	case *parser.PipelineExpr:
+		return in.visitPipeline(e)
+	case *parser.ListExpr:
+		elems := make([]Value, len(e.Elements))
+		for i, el := range e.Elements {
+			elems[i] = in.Eval(el)
+		}
+		return &List{Elems: elems}
+	case *parser.TupleExpr:
+		elems := make([]Value, len(e.Elements))
+		for i, el := range e.Elements {
+			elems[i] = in.Eval(el)
+		}
+		return &Tuple{Elems: elems}
+	case *parser.IndexExpr:
+		return in.visitIndex(e)
+	case *parser.TypeDeclExpr:
+		return in.visitTypeDecl(e)
+	case *parser.RecordExpr:
+		return in.visitRecord(e)
+	case *parser.FieldAccessExpr:
+		return in.visitFieldAccess(e)
+	default:
+		panic(fmt.Sprintf("interp: unsupported expression %T", expr))
+	}
+}
+
+func (in *Interpreter) visitIdentifier(id *parser.Identifier) Value {
+	v, ok := in.env.Get(id.Name)
+	if !ok {
+		in.errorAt(id.Pos, fmt.Sprintf("undefined variable: '%s'", id.Name))
+	}
+	return v
+}
+
+func (in *Interpreter) visitFuncDecl(fn *parser.FuncDeclExpr) Value {
+	var v Value = &Function{Decl: fn, Env: in.env}
+	if len(fn.Decorators) != 0 && fn.Decorators[0].Name == "external" {
+		dec := fn.Decorators[0]
+		name := fn.Name.Lexeme
+		if len(dec.Args) == 3 {
+			if lit, ok := dec.Args[2].(*parser.StringLiteral); ok {
+				name = lit.Value
+			}
+		}
+		b, ok := externals[name]
+		if !ok {
+			in.errorAt(fn.Name, fmt.Sprintf("no interpreter implementation for external function '%s'", name))
+		}
+		v = b
+	}
+	in.env.Set(fn.Name.Lexeme, v)
+	return v
+}
+
+// visitLambda closes over the current environment, so the function sees
+// later assignments to the variables it captures.
+func (in *Interpreter) visitLambda(l *parser.LambdaExpr) Value {
+	name := l.Pos
+	name.Lexeme = "anonymous"
+	decl := &parser.FuncDeclExpr{Name: name, Params: l.Params, Ret: l.Ret, Body: l.Body}
+	return &Function{Decl: decl, Env: in.env}
+}
+
+func (in *Interpreter) call(callee Value, args []Value, pos lexer.Token) Value {
+	switch fn := callee.(type) {
+	case *Builtin:
+		return fn.Fn(in, args)
+	case *Constructor:
+		if len(args) != fn.Arity {
+			in.errorAt(pos, fmt.Sprintf("constructor %s expects %d arguments, got %d", fn.Name, fn.Arity, len(args)))
+		}
+		return &Variant{Type: fn.Type, Name: fn.Name, Values: args}
+	case *Function:
+		if fn.Decl.Body == nil {
+			in.errorAt(fn.Decl.Name, fmt.Sprintf("function '%s' has no body", fn.Decl.Name.Lexeme))
+		}
+		if len(args) != len(fn.Decl.Params) {
+			in.errorAt(pos, fmt.Sprintf("wrong number of arguments: expected %d, got %d", len(fn.Decl.Params), len(args)))
+		}
+		oldEnv := in.env
+		in.env = NewEnv(fn.Env)
+		for i, p := range fn.Decl.Params {
+			in.env.Set(p.Name.Lexeme, args[i])
+		}
+		result := in.Eval(fn.Decl.Body)
+		in.env = oldEnv
+		return result
+	default:
+		in.errorAt(pos, fmt.Sprintf("attempt to call non-function value %s", callee.String()))
+		return nil
+	}
+}
+
+func (in *Interpreter) visitBlock(b *parser.BlockExpr) Value {
+	old := in.env
+	in.env = NewEnv(old)
+	var last Value = Nil{}
+	for _, ex := range b.Exprs {
+		last = in.Eval(ex)
+	}
+	in.env = old
+	return last
+}
+
+func (in *Interpreter) visitUse(u *parser.UseExpr) Value {
+	mod, ok := in.getModule(u.Path)
+	if !ok {
+		in.errorAt(u.Pos, fmt.Sprintf("cannot find module %s", strings.Join(u.Path, "/")))
+	}
+	if len(u.Members) == 0 {
+		name := u.Alias
+		if name == "" && len(u.Path) > 0 {
+			name = u.Path[len(u.Path)-1]
+		}
+		in.env.modules[name] = mod
+		return Nil{}
+	}
+	for _, m := range u.Members {
+		v, ok := mod[m]
+		if !ok {
+			in.errorAt(u.Pos, fmt.Sprintf("module %s has no member %s", strings.Join(u.Path, "/"), m))
+		}
+		if v != nil {
+			in.env.Set(m, v)
+		}
+	}
+	return Nil{}
+}
+
+func (in *Interpreter) visitQualified(q *parser.QualifiedExpr) Value {
+	leftIdent, ok := q.Left.(*parser.Identifier)
+	if !ok {
+		in.errorAt(q.Pos, "expected module identifier on the left of ':'")
+	}
+	mod, ok := in.env.modules[leftIdent.Name]
+	if !ok {
+		in.errorAt(q.Pos, fmt.Sprintf("unknown module: %s", leftIdent.Name))
+	}
+	v, ok := mod[q.Right.Lexeme]
+	if !ok {
+		in.errorAt(q.Pos, fmt.Sprintf("module %s has no member %s", leftIdent.Name, q.Right.Lexeme))
+	}
+	return v
+}
+
+func (in *Interpreter) visitIf(i *parser.IfExpr) Value {
+	cond, ok := in.Eval(i.Cond).(Bool)
+	if !ok {
+		in.errorIn(i.Cond, "if condition must be Bool")
+	}
+	if cond {
+		return in.Eval(i.Then)
+	}
+	if i.Else != nil {
+		return in.Eval(i.Else)
+	}
+	return Nil{}
+}
+
+func (in *Interpreter) visitFor(f *parser.ForExpr) Value {
+	body := func(elem Value) {
+		oldEnv := in.env
+		in.env = NewEnv(oldEnv)
+		if !in.matchPattern(f.Pattern, elem) {
+			in.errorAt(parser.PatternPos(f.Pattern), fmt.Sprintf("value %s does not match the pattern", elem.String()))
+		}
+		in.Eval(f.Body)
+		in.env = oldEnv
+	}
+	switch iter := in.Eval(f.Iter).(type) {
+	case *Range:
+		for i := iter.Low; i < iter.High; i++ {
+			body(i)
+		}
+	case *List:
+		for _, elem := range iter.Elems {
+			body(elem)
+		}
+	default:
+		in.errorIn(f.Iter, fmt.Sprintf("cannot iterate over %s", iter.String()))
+	}
+	return Nil{}
+}
+
+func (in *Interpreter) visitMatch(m *parser.MatchExpr) Value {
+	value := in.Eval(m.Value)
+	for _, arm := range m.Arms {
+		oldEnv := in.env
+		in.env = NewEnv(oldEnv)
+		if in.matchPattern(arm.Pattern, value) && in.guardHolds(arm) {
+			result := in.Eval(arm.Body)
+			in.env = oldEnv
+			return result
+		}
+		in.env = oldEnv
+	}
+	in.errorAt(m.Pos, fmt.Sprintf("no match arm matched value %s", value.String()))
+	return nil
+}
+
+// matchPattern reports whether value matches pat, binding the variables of
+// the pattern in the current environment when it does.
+func (in *Interpreter) matchPattern(pat parser.Pattern, value Value) bool {
+	switch p := pat.(type) {
+	case *parser.WildcardPattern:
+		return true
+	case *parser.BindingPattern:
+		in.env.Set(p.Name.Lexeme, value)
+		return true
+	case *parser.AsPattern:
+		if !in.matchPattern(p.Pattern, value) {
+			return false
+		}
+		in.env.Set(p.Name.Lexeme, value)
+		return true
+	case *parser.LiteralPattern:
+		return valuesEqual(in.Eval(p.Value), value)
+	case *parser.RangePattern:
+		return inRange(in.Eval(p.Low), in.Eval(p.High), value)
+	case *parser.ConstructorPattern:
+		v, ok := value.(*Variant)
+		ctor := in.ctors[p.Name.Lexeme]
+		if !ok || ctor == nil || v.Type != ctor.Type || v.Name != ctor.Name || len(v.Values) != len(p.Args) {
+			return false
+		}
+		for i, arg := range p.Args {
+			if !in.matchPattern(arg, v.Values[i]) {
+				return false
+			}
+		}
+		return true
+	case *parser.TuplePattern:
+		t, ok := value.(*Tuple)
+		if !ok || len(t.Elems) != len(p.Elements) {
+			return false
+		}
+		for i, el := range p.Elements {
+			if !in.matchPattern(el, t.Elems[i]) {
+				return false
+			}
+		}
+		return true
+	case *parser.ListPattern:
+		l, ok := value.(*List)
+		if !ok || len(l.Elems) < len(p.Elements) || (p.Rest == nil && len(l.Elems) != len(p.Elements)) {
+			return false
+		}
+		for i, el := range p.Elements {
+			if !in.matchPattern(el, l.Elems[i]) {
+				return false
+			}
+		}
+		if p.Rest != nil {
+			rest := slices.Clone(l.Elems[len(p.Elements):])
+			return in.matchPattern(p.Rest, &List{Elems: rest})
+		}
+		return true
+	case *parser.OrPattern:
+		for _, alt := range p.Alternatives {
+			if in.matchPattern(alt, value) {
+				return true
+			}
+		}
+		return false
+	}
+	in.errorAt(parser.PatternPos(pat), fmt.Sprintf("unsupported pattern %s", pat.NodeType()))
+	return false
+}
+
+func inRange(low, high, v Value) bool {
+	switch x := v.(type) {
+	case Int:
+		return low.(Int) <= x && x < high.(Int)
+	case Byte:
+		return low.(Byte) <= x && x < high.(Byte)
+	}
+	return false
+}
+
+func (in *Interpreter) guardHolds(arm *parser.MatchArm) bool {
+	if arm.Guard == nil {
+		return true
+	}
+	ok, _ := in.Eval(arm.Guard).(Bool)
+	return bool(ok)
+}
+
+func (in *Interpreter) visitPipeline(p *parser.PipelineExpr) Value {
+	left := in.Eval(p.Left)
+	switch r := p.Right.(type) {
+	case *parser.CallExpr:
+		callee := in.Eval(r.Callee)
+		args := []Value{left}
+		for _, a := range r.Args {
+			args = append(args, in.Eval(a))
+		}
+		return in.call(callee, args, r.Pos)
+	default:
+		return in.call(in.Eval(p.Right), []Value{left}, p.Pos)
+	}
+}
+
+func (in *Interpreter) visitIndex(idx *parser.IndexExpr) Value {
+	target := in.Eval(idx.Target)
+	i, ok := in.Eval(idx.Index).(Int)
+	if !ok {
+		in.errorIn(idx.Index, "index must be Int")
+	}
+	switch t := target.(type) {
+	case *List:
+		if i < 0 || int(i) >= len(t.Elems) {
+			in.errorIn(idx, fmt.Sprintf("list index out of bounds: %d (length %d)", i, len(t.Elems)))
+		}
+		return t.Elems[i]
+	case *Tuple:
+		if i < 0 || int(i) >= len(t.Elems) {
+			in.errorIn(idx, fmt.Sprintf("tuple index out of bounds: %d (tuple length %d)", i, len(t.Elems)))
+		}
+		return t.Elems[i]
+	case String:
+		if i < 0 || int(i) >= len(t) {
+			in.errorIn(idx, fmt.Sprintf("string index out of bounds: %d (length %d)", i, len(t)))
+		}
+		return Byte(t[i])
+	default:
+		in.errorIn(idx, fmt.Sprintf("cannot index value %s", target.String()))
+		return nil
+	}
+}
+
+func (in *Interpreter) visitInterpolatedString(s *parser.InterpolatedString) Value {
+	var b strings.Builder
+	for _, part := range s.Parts {
+		switch v := in.Eval(part).(type) {
+		case Byte:
+			b.WriteByte(byte(v))
+		default:
+			b.WriteString(v.String())
+		}
+	}
+	return String(b.String())
+}
+
+func (in *Interpreter) visitTypeDecl(t *parser.TypeDeclExpr) Value {
+	switch body := t.Body.(type) {
+	case *parser.RecordTypeExpr:
+		fields := make([]string, len(body.Fields))
+		for i, f := range body.Fields {
+			fields[i] = f.Name.Lexeme
+		}
+		in.records[t.Name.Lexeme] = fields
+	case *parser.VariantTypeExpr:
+		for _, v := range body.Variants {
+			ctor := &Constructor{Type: t.Name.Lexeme, Name: v.Name.Lexeme, Arity: len(v.Fields)}
+			in.ctors[ctor.Name] = ctor
+			if ctor.Arity == 0 {
+				in.env.Set(ctor.Name, &Variant{Type: ctor.Type, Name: ctor.Name})
+				continue
+			}
+			in.env.Set(ctor.Name, ctor)
+		}
+	}
+	return Nil{}
+}
+
+func (in *Interpreter) visitRecord(r *parser.RecordExpr) Value {
+	fields, ok := in.records[r.Name.Lexeme]
+	if !ok {
+		in.errorAt(r.Name, fmt.Sprintf("unknown record type: '%s'", r.Name.Lexeme))
+	}
+	values := make([]Value, len(fields))
+	for _, f := range r.Fields {
+		v := in.Eval(f.Value)
+		for i, name := range fields {
+			if name == f.Name.Lexeme {
+				values[i] = v
+			}
+		}
+	}
+	return &Record{Name: r.Name.Lexeme, Fields: fields, Values: values}
+}
+
+func (in *Interpreter) visitFieldAccess(f *parser.FieldAccessExpr) Value {
+	left := in.Eval(f.Left)
+	rec, ok := left.(*Record)
+	if !ok {
+		in.errorAt(f.Pos, fmt.Sprintf("cannot access field '%s' on %s", f.Right, left.String()))
+	}
+	v, ok := rec.Get(f.Right)
+	if !ok {
+		in.errorAt(f.Pos, fmt.Sprintf("%s has no field '%s'", rec.Name, f.Right))
+	}
+	return v
+}
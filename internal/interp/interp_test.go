@@ -0,0 +1,504 @@
+package interp
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flint/internal/lexer"
+	"flint/internal/parser"
+)
+
+func runSrc(t *testing.T, src string) (string, error) {
+	t.Helper()
+
+	tokens, err := lexer.Tokenize(src, "test.flint")
+	if err != nil {
+		t.Fatalf("lex error: %v", err)
+	}
+
+	prog, errs := parser.ParseProgram(tokens)
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	var out bytes.Buffer
+	err = New(&out).Run(prog)
+	return out.String(), err
+}
+
+func TestRunPrintsFromMain(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+
+fn main() Nil {
+	io:println("hello")
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "hello\n" {
+		t.Fatalf("expected %q, got %q", "hello\n", out)
+	}
+}
+
+func TestRunExternalStubs(t *testing.T) {
+	out, err := runSrc(t, `
+@external(c, "flint_stdlib", "print")
+pub fn print(string: String) Nil
+
+@external(c, "flint_stdlib", "to_string")
+pub fn to_string(int: Int) String
+
+fn fib(n: Int) Int {
+	fn aux(m: Int, a: Int, b: Int) Int {
+		match m {
+			| 0 -> a
+			| _ -> aux(m - 1, b, a + b)
+		}
+	}
+	aux(n, 0, 1)
+}
+
+pub fn main() Nil {
+	print(to_string(fib(10)))
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "55" {
+		t.Fatalf("expected 55, got %q", out)
+	}
+}
+
+func TestRunClosureCapturesEnclosingScope(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+use flint/string
+
+fn main() Nil {
+	mut total = 1
+	fn bump(n: Int) Int {
+		total = total + n
+		total
+	}
+	bump(2)
+	bump(3) |> string:to_string |> io:println
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "6\n" {
+		t.Fatalf("expected 6, got %q", out)
+	}
+}
+
+func TestRunTuplesListsAndIf(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+
+fn pick(xs: List(Int), i: Int) Int {
+	val pair = (xs[i], i)
+	if pair[0] > 10 then pair[0] else pair[1]
+}
+
+fn main() Nil {
+	val xs = [5, 20, 7]
+	match pick(xs, 1) {
+		| 20 -> io:print("twenty")
+		| _ -> io:print("other")
+	}
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "twenty" {
+		t.Fatalf("expected twenty, got %q", out)
+	}
+}
+
+func TestRunIndexOutOfBounds(t *testing.T) {
+	_, err := runSrc(t, `
+fn main() Int {
+	val xs = [1, 2]
+	xs[5]
+}
+`)
+	if err == nil {
+		t.Fatal("expected runtime error, got none")
+	}
+	if !strings.Contains(err.Error(), "out of bounds") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRunRecords(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+
+type User { name: String, admin: Bool }
+
+fn greet(u: User) String {
+	if u.admin then "hi boss " <> u.name else "hi " <> u.name
+}
+
+fn main() Nil {
+	io:println(greet(User { admin: True, name: "ada" }))
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "hi boss ada\n" {
+		t.Fatalf("expected greeting, got %q", out)
+	}
+}
+
+func TestRunVariantsAndMatch(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+use flint/string
+
+type Expr { | Num(Int) | Add(Expr, Expr) | Neg(Expr) }
+
+fn eval(e: Expr) Int {
+	match e {
+		| Num(n) -> n
+		| Add(l, r) -> eval(l) + eval(r)
+		| Neg(inner) -> 0 - eval(inner)
+	}
+}
+
+fn main() Nil {
+	Add(Num(40), Neg(Num(-2))) |> eval |> string:to_string |> io:println
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "42\n" {
+		t.Fatalf("expected 42, got %q", out)
+	}
+}
+
+func TestRunStructuredPatterns(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+use flint/string
+
+fn describe(xs: List(Int)) String {
+	match xs {
+		| [] -> "empty"
+		| [1 | 2 as first, ..rest] -> "small " <> string:to_string(first)
+		| [x, ..] -> match x {
+			| 0..100 -> "medium"
+			| _ -> "large"
+		}
+	}
+}
+
+fn divmod(a: Int, b: Int) (Int, Int) {
+	(a / b, a % b)
+}
+
+fn main() Nil {
+	val (q, r) = divmod(17, 5)
+	io:println(string:to_string(q) <> " " <> string:to_string(r))
+	io:println(describe([]))
+	io:println(describe([2, 9]))
+	io:println(describe([50]))
+	io:println(describe([500, 1]))
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "3 2\nempty\nsmall 2\nmedium\nlarge\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+}
+
+func TestRunRestPatternsCopyTheTail(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+use flint/list
+use flint/string
+
+fn main() Nil {
+	val xs = [1, 2, 3]
+	list:push(xs, 4)
+	match xs {
+		| [_, ..t] -> {
+			list:push(t, 9)
+			list:push(xs, 5)
+			for x in t {
+				io:print(string:to_string(x) <> " ")
+			}
+			io:println(string:to_string(list:length(xs)))
+		}
+		| [] -> io:println("empty")
+	}
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "2 3 4 9 5\n" {
+		t.Fatalf("expected %q, got %q", "2 3 4 9 5\n", out)
+	}
+}
+
+func TestRunGenericFunctions(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+
+fn id(x) { x }
+
+fn twice(f, x) { f(f(x)) }
+
+fn shout(s: String) String { s <> "!" }
+
+fn main() Nil {
+	io:println(twice(shout, id("hey")))
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "hey!!\n" {
+		t.Fatalf("expected %q, got %q", "hey!!\n", out)
+	}
+}
+
+func TestRunClosures(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+use flint/string
+
+fn make_counter() () -> Int {
+	mut count = 0
+	fn() {
+		count = count + 1
+		count
+	}
+}
+
+fn main() Nil {
+	val next = make_counter()
+	next()
+	next() |> fn(n) { n * 10 } |> string:to_string |> io:println
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "20\n" {
+		t.Fatalf("expected %q, got %q", "20\n", out)
+	}
+}
+
+func TestRunForLoops(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+use flint/string
+
+fn main() Nil {
+	mut total = 0
+	for i in 1..4 {
+		total = total + i
+	}
+	for (a, b) in [(1, 2), (3, 4)] {
+		total = total + a * b
+	}
+	for s in ["x", "y"] {
+		io:print(s)
+	}
+	io:println(string:to_string(total))
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "xy20\n" {
+		t.Fatalf("expected %q, got %q", "xy20\n", out)
+	}
+}
+
+func TestRunListModule(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+use flint/list
+use flint/string
+
+fn main() Nil {
+	val xs = [3, 1]
+	list:push(xs, 2)
+	val ys = list:concat(list:reverse(xs), list:filter(xs, fn(x) { x > 1 }))
+	val total = list:fold(list:map(ys, fn(x) { x * 10 }), 0, fn(acc, x) { acc + x })
+	io:println(string:to_string(list:length(ys)) <> " " <> string:to_string(total))
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "5 110\n" {
+		t.Fatalf("expected %q, got %q", "5 110\n", out)
+	}
+}
+
+func TestRunStringModule(t *testing.T) {
+	out, err := runSrc(t, `
+use flint/io
+use flint/list
+use flint/string
+
+fn main() Nil {
+	val name = "flint"
+	val parts = string:split("a,b,,c", ",")
+	val (n, ok) = string:parse_int("-42")
+	val (_, bad) = string:parse_int("4x")
+	io:println("{string:to_upper(name)} {string:length(name)} {list:length(parts)} {name[0]}")
+	io:println("{n} {ok} {bad} {string:contains(name, "lin")} {string:from_float(2.5)} \{\}")
+	io:println(string:slice(name, 1, 3) <> string:slice(name, 3, 99) <> string:slice(name, 3, 1))
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "FLINT 5 4 f\n-42 True False True 2.5 {}\nlint\n"
+	if out != want {
+		t.Fatalf("expected %q, got %q", want, out)
+	}
+}
+
+func TestRunFsAndOsModules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	tokens, err := lexer.Tokenize(`
+use flint/io
+use flint/fs
+use flint/os
+use flint/fs.{Result, Ok, Error}
+
+fn show(r: Result) Nil {
+	match r {
+		| Ok(s) -> io:println("ok " <> s)
+		| Error(msg) -> io:println("error " <> msg)
+	}
+}
+
+fn main() Nil {
+	show(fs:write_file("`+path+`", "a\nb"))
+	show(fs:read_file("`+path+`"))
+	show(fs:read_file("`+path+`.missing"))
+	show(os:read_line())
+	show(os:read_line())
+	show(os:read_line())
+	for a in os:args() {
+		io:println(a)
+	}
+	os:exit(3)
+	io:println("unreachable")
+}
+`, "test.flint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, errs := parser.ParseProgram(tokens)
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	var out bytes.Buffer
+	in := New(&out)
+	in.SetInput(strings.NewReader("first\r\nsecond"))
+	in.SetArgs([]string{"-v", "two words"})
+	err = in.Run(prog)
+	var exit *Exit
+	if !errors.As(err, &exit) || exit.Code != 3 {
+		t.Fatalf("expected exit status 3, got %v", err)
+	}
+	want := "ok " + path + "\nok a\nb\nerror " + path + ".missing: No such file or directory\n" +
+		"ok first\nok second\nerror end of input\n-v\ntwo words\n"
+	if out.String() != want {
+		t.Fatalf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestRuntimeErrorsUnderlineTheExpression(t *testing.T) {
+	_, err := runSrc(t, `fn main() Int {
+	val xs = [1, 2]
+	xs[1 + 4]
+}
+`)
+	if err == nil {
+		t.Fatal("expected runtime error, got none")
+	}
+	if !strings.Contains(err.Error(), "\n   | \t^^^^^^^^^\n") {
+		t.Fatalf("expected xs[1 + 4] to be underlined:\n%v", err)
+	}
+}
+
+func TestRunUsesLoadedModules(t *testing.T) {
+	tokens, err := lexer.Tokenize(`pub type Shape {
+	| Circle(Int)
+	| Dot
+}
+
+pub fn size(s: Shape) Int {
+	match s {
+		| Circle(r) -> scale(r)
+		| Dot -> 0
+	}
+}
+
+fn scale(n: Int) Int { n * 10 }
+
+pub type Box { w: Int }
+`, "shapes.flint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib, errs := parser.ParseProgram(tokens)
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	tokens, err = lexer.Tokenize(`use flint/io
+use flint/string
+use app/shapes
+use app/shapes.{Shape, Circle}
+
+fn main() Nil {
+	io:println(string:to_string(shapes:size(Circle(4)) + shapes:size(shapes:Dot)))
+	io:println(string:to_string(shapes:Box { w: 2 }.w))
+}
+`, "main.flint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, errs := parser.ParseProgram(tokens)
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	var out bytes.Buffer
+	in := New(&out)
+	if err := in.Load("app/shapes", lib); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Run(prog); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "40\n2\n" {
+		t.Fatalf("expected %q, got %q", "40\n2\n", out.String())
+	}
+	if _, ok := in.imports["app/shapes"]["scale"]; ok {
+		t.Fatalf("expected scale not to be exported")
+	}
+}
@@ -0,0 +1,136 @@
+package interp
+
+import (
+	"fmt"
+
+	"flint/internal/lexer"
+	"flint/internal/parser"
+)
+
+func (in *Interpreter) visitPrefix(e *parser.PrefixExpr) Value {
+	right := in.Eval(e.Right)
+	switch e.Operator.Kind {
+	case lexer.Minus:
+		switch v := right.(type) {
+		case Int:
+			return -v
+		case Float:
+			return -v
+		}
+	case lexer.MinusDot:
+		if v, ok := right.(Float); ok {
+			return -v
+		}
+	case lexer.Bang:
+		if v, ok := right.(Bool); ok {
+			return !v
+		}
+	}
+	in.errorAt(e.Operator, fmt.Sprintf("invalid operand for '%s': %s", e.Operator.Lexeme, right.String()))
+	return nil
+}
+
+func (in *Interpreter) visitInfix(e *parser.InfixExpr) Value {
+	switch e.Operator.Kind {
+	case lexer.AmperAmper:
+		if !in.evalBool(e.Left, e.Operator) {
+			return Bool(false)
+		}
+		return Bool(in.evalBool(e.Right, e.Operator))
+	case lexer.VbarVbar:
+		if in.evalBool(e.Left, e.Operator) {
+			return Bool(true)
+		}
+		return Bool(in.evalBool(e.Right, e.Operator))
+	}
+	left := in.Eval(e.Left)
+	right := in.Eval(e.Right)
+	switch e.Operator.Kind {
+	case lexer.EqualEqual:
+		return Bool(valuesEqual(left, right))
+	case lexer.NotEqual:
+		return Bool(!valuesEqual(left, right))
+	case lexer.LtGt:
+		l, lok := left.(String)
+		r, rok := right.(String)
+		if lok && rok {
+			return l + r
+		}
+	}
+	switch l := left.(type) {
+	case Int:
+		if r, ok := right.(Int); ok {
+			return in.intOp(e.Operator, l, r)
+		}
+	case Float:
+		if r, ok := right.(Float); ok {
+			return in.floatOp(e.Operator, l, r)
+		}
+	}
+	in.errorIn(e, fmt.Sprintf("invalid operands for '%s': %s and %s", e.Operator.Lexeme, left.String(), right.String()))
+	return nil
+}
+
+func (in *Interpreter) evalBool(e parser.Expr, op lexer.Token) bool {
+	b, ok := in.Eval(e).(Bool)
+	if !ok {
+		in.errorIn(e, fmt.Sprintf("operand of '%s' must be Bool", op.Lexeme))
+	}
+	return bool(b)
+}
+
+func (in *Interpreter) intOp(op lexer.Token, l, r Int) Value {
+	switch op.Kind {
+	case lexer.Plus:
+		return l + r
+	case lexer.Minus:
+		return l - r
+	case lexer.Star:
+		return l * r
+	case lexer.Slash:
+		if r == 0 {
+			in.errorAt(op, "division by zero")
+		}
+		return l / r
+	case lexer.Percent:
+		if r == 0 {
+			in.errorAt(op, "division by zero")
+		}
+		return l % r
+	case lexer.Less:
+		return Bool(l < r)
+	case lexer.LessEqual:
+		return Bool(l <= r)
+	case lexer.Greater:
+		return Bool(l > r)
+	case lexer.GreaterEqual:
+		return Bool(l >= r)
+	case lexer.DotDot:
+		return &Range{Low: l, High: r}
+	}
+	in.errorAt(op, fmt.Sprintf("invalid operands for '%s': Int and Int", op.Lexeme))
+	return nil
+}
+
+func (in *Interpreter) floatOp(op lexer.Token, l, r Float) Value {
+	switch op.Kind {
+	case lexer.PlusDot:
+		return l + r
+	case lexer.MinusDot:
+		return l - r
+	case lexer.StarDot:
+		return l * r
+	case lexer.SlashDot:
+		return l / r
+	case lexer.LessDot:
+		return Bool(l < r)
+	case lexer.LessEqualDot:
+		return Bool(l <= r)
+	case lexer.GreaterDot:
+		return Bool(l > r)
+	case lexer.GreaterEqualDot:
+		return Bool(l >= r)
+	}
+	in.errorAt(op, fmt.Sprintf("invalid operands for '%s': Float and Float", op.Lexeme))
+	return nil
+}
@@ -0,0 +1,191 @@
+package interp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"flint/internal/parser"
+)
+
+type Value interface {
+	String() string
+}
+
+type Int int64
+
+func (v Int) String() string { return strconv.FormatInt(int64(v), 10) }
+
+type Float float64
+
+func (v Float) String() string { return strconv.FormatFloat(float64(v), 'g', -1, 64) }
+
+type Bool bool
+
+func (v Bool) String() string {
+	if v {
+		return "True"
+	}
+	return "False"
+}
+
+type Byte byte
+
+func (v Byte) String() string { return fmt.Sprintf("'%c'", byte(v)) }
+
+type String string
+
+func (v String) String() string { return string(v) }
+
+type Nil struct{}
+
+func (Nil) String() string { return "Nil" }
+
+type List struct {
+	Elems []Value
+}
+
+func (l *List) String() string {
+	return "[" + joinValues(l.Elems) + "]"
+}
+
+// Range is the integers from Low up to, but not including, High.
+type Range struct {
+	Low, High Int
+}
+
+func (r *Range) String() string {
+	return r.Low.String() + ".." + r.High.String()
+}
+
+type Tuple struct {
+	Elems []Value
+}
+
+func (t *Tuple) String() string {
+	return "(" + joinValues(t.Elems) + ")"
+}
+
+type Record struct {
+	Name   string
+	Fields []string
+	Values []Value
+}
+
+func (r *Record) String() string {
+	parts := make([]string, len(r.Fields))
+	for i, f := range r.Fields {
+		parts[i] = f + ": " + joinValues(r.Values[i:i+1])
+	}
+	return r.Name + " { " + strings.Join(parts, ", ") + " }"
+}
+
+func (r *Record) Get(field string) (Value, bool) {
+	for i, f := range r.Fields {
+		if f == field {
+			return r.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+type Variant struct {
+	Type   string
+	Name   string
+	Values []Value
+}
+
+func (v *Variant) String() string {
+	if len(v.Values) == 0 {
+		return v.Name
+	}
+	return v.Name + "(" + joinValues(v.Values) + ")"
+}
+
+// Constructor builds a Variant when called. Nullary constructors are bound
+// directly to their Variant value instead.
+type Constructor struct {
+	Type  string
+	Name  string
+	Arity int
+}
+
+func (c *Constructor) String() string { return "<constructor " + c.Name + ">" }
+
+type Function struct {
+	Decl *parser.FuncDeclExpr
+	Env  *Env
+}
+
+func (f *Function) String() string { return "<fn " + f.Decl.Name.Lexeme + ">" }
+
+type Builtin struct {
+	Name string
+	Fn   func(in *Interpreter, args []Value) Value
+}
+
+func (b *Builtin) String() string { return "<builtin " + b.Name + ">" }
+
+func joinValues(vals []Value) string {
+	parts := make([]string, len(vals))
+	for i, v := range vals {
+		if s, ok := v.(String); ok {
+			parts[i] = strconv.Quote(string(s))
+			continue
+		}
+		parts[i] = v.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func valuesEqual(a, b Value) bool {
+	switch x := a.(type) {
+	case *List:
+		y, ok := b.(*List)
+		if !ok || len(x.Elems) != len(y.Elems) {
+			return false
+		}
+		for i := range x.Elems {
+			if !valuesEqual(x.Elems[i], y.Elems[i]) {
+				return false
+			}
+		}
+		return true
+	case *Tuple:
+		y, ok := b.(*Tuple)
+		if !ok || len(x.Elems) != len(y.Elems) {
+			return false
+		}
+		for i := range x.Elems {
+			if !valuesEqual(x.Elems[i], y.Elems[i]) {
+				return false
+			}
+		}
+		return true
+	case *Record:
+		y, ok := b.(*Record)
+		if !ok || x.Name != y.Name || len(x.Fields) != len(y.Fields) {
+			return false
+		}
+		for i, f := range x.Fields {
+			v, ok := y.Get(f)
+			if !ok || !valuesEqual(x.Values[i], v) {
+				return false
+			}
+		}
+		return true
+	case *Variant:
+		y, ok := b.(*Variant)
+		if !ok || x.Type != y.Type || x.Name != y.Name || len(x.Values) != len(y.Values) {
+			return false
+		}
+		for i := range x.Values {
+			if !valuesEqual(x.Values[i], y.Values[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
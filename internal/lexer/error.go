@@ -1,56 +1,36 @@
 package lexer
 
-import (
-	"fmt"
-	"os"
-	"strings"
-)
+import "flint/internal/diag"
 
+// error reports a problem at the current position. The token being scanned
+// is turned into an Illegal one so that the parser skips it.
 func (l *Lexer) error(msg string) {
-	line := l.getLineText(l.lineNumber)
-	caret := makeCaret(l.columnNumber)
-
-	fmt.Printf(
-		"%s: %s\n  %s %s:%d:%d\n   %s\n%2d | %s\n   | %s\n",
-		"error",
-		msg,
-		"-->",
-		l.fileName, l.lineNumber, l.columnNumber,
-		"|",
-		l.lineNumber, line, caret,
-	)
+	l.errorFrom(l.lineNumber, l.columnNumber, msg)
+}
 
-	os.Exit(1)
+// errorFrom is error for a problem at the given line and column, such as
+// the opening quote of a literal that is never closed.
+func (l *Lexer) errorFrom(line, column int, msg string) {
+	l.failed = true
+	l.diags.Add(diag.Diagnostic{
+		Severity: diag.Error,
+		Span: diag.Span{
+			File:      l.fileName,
+			Line:      line,
+			Column:    column,
+			EndLine:   line,
+			EndColumn: column + 1,
+		},
+		Message: msg,
+		Source:  l.source,
+	})
 }
 
-func (l *Lexer) getLineText(lineNum int) string {
-	if lineNum < 1 {
-		return ""
-	}
-	start := 0
-	currentLine := 1
-	for i, r := range l.source {
-		if currentLine == lineNum {
-			start = i
-			break
-		}
-		if r == '\n' {
-			currentLine++
-		}
-	}
-	end := len(l.source)
-	for i := start; i < len(l.source); i++ {
-		if l.source[i] == '\n' {
-			end = i
-			break
-		}
-	}
-	return string(l.source[start:end])
+func (l *Lexer) errorAt(tok Token, msg string) {
+	l.diags.Add(diag.Diagnostic{Severity: diag.Error, Span: tok.Span(), Message: msg, Source: l.source})
 }
 
-func makeCaret(col int) string {
-	if col < 1 {
-		col = 1
-	}
-	return fmt.Sprintf("%s^", strings.Repeat(" ", col-2))
+// Diagnostics returns the problems found in the tokens scanned so far.
+func (l *Lexer) Diagnostics() diag.List {
+	return l.diags
 }
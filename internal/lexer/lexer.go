@@ -1,8 +1,10 @@
 package lexer
 
 import (
+	"flint/internal/diag"
 	"fmt"
 	"strconv"
+	"strings"
 	"unicode"
 )
 
@@ -12,22 +14,65 @@ type Lexer struct {
 	lineNumber   int
 	columnNumber int
 	fileName     string
+
+	diags  diag.List
+	failed bool
+
+	// trivia is the whitespace skipped before the last token.
+	trivia []Trivia
 }
 
+// Tokenize scans the whole of source. Problems do not stop the scan: they
+// are returned together as a diag.List and the tokens concerned are marked
+// Illegal.
+//
+// Unlike Next, Tokenize returns no Comment tokens. Comments, whitespace and
+// newlines are attached to the tokens around them as trivia instead, so that
+// Text(tokens) == source.
 func Tokenize(source, filename string) ([]Token, error) {
 	lx := New(source, filename)
 	out := []Token{}
+	var trivia []Trivia
 	for {
 		tok := lx.Next()
+		trivia = append(trivia, lx.trivia...)
+		if tok.Kind == Comment {
+			trivia = append(trivia, Trivia{Kind: LineComment, Text: tok.Lexeme, Line: tok.Line, Column: tok.Column})
+			continue
+		}
+		if len(out) > 0 {
+			prev := &out[len(out)-1]
+			n := 0
+			for n < len(trivia) {
+				n++
+				if trivia[n-1].Kind == Newline {
+					break
+				}
+			}
+			prev.Trailing, trivia = trivia[:n:n], trivia[n:]
+		}
+		tok.Leading, trivia = trivia, nil
 		out = append(out, tok)
 		if tok.Kind == EndOfFile {
 			break
 		}
-		if tok.Kind == Illegal {
-			lx.error("Illegal character")
+	}
+	return out, lx.diags.Err()
+}
+
+// Text returns the source tokens were scanned from by Tokenize.
+func Text(tokens []Token) string {
+	var b strings.Builder
+	for _, tok := range tokens {
+		for _, t := range tok.Leading {
+			b.WriteString(t.Text)
+		}
+		b.WriteString(tok.Lexeme)
+		for _, t := range tok.Trailing {
+			b.WriteString(t.Text)
 		}
 	}
-	return out, nil
+	return b.String()
 }
 
 func New(source, filename string) *Lexer {
@@ -36,6 +81,20 @@ func New(source, filename string) *Lexer {
 }
 
 func (l *Lexer) Next() Token {
+	tok := l.next()
+	if tok.Kind == Illegal && !l.failed {
+		if unicode.IsDigit([]rune(tok.Lexeme)[0]) {
+			l.errorAt(tok, fmt.Sprintf("invalid number literal %q", tok.Lexeme))
+		} else {
+			l.errorAt(tok, fmt.Sprintf("illegal character %q", tok.Lexeme))
+		}
+	}
+	return tok
+}
+
+func (l *Lexer) next() Token {
+	l.failed = false
+	l.trivia = nil
 	l.consumeWhitespace()
 	startlineNumber, startcolumnNumber := l.lineNumber, l.columnNumber
 	ch := l.peekRuneAt(0)
@@ -57,11 +116,17 @@ func (l *Lexer) Next() Token {
 
 	if ch == '"' {
 		lex := l.scanStringLiteral()
+		if l.failed {
+			return l.makeToken(Illegal, lex, startlineNumber, startcolumnNumber)
+		}
 		return l.makeToken(String, lex, startlineNumber, startcolumnNumber)
 	}
 
 	if ch == '\'' {
 		lex := l.scanByteLiteral()
+		if l.failed {
+			return l.makeToken(Illegal, lex, startlineNumber, startcolumnNumber)
+		}
 		return l.makeToken(Byte, lex, startlineNumber, startcolumnNumber)
 	}
 
@@ -314,6 +379,7 @@ func (l *Lexer) scanNumberLiteral() (string, TokenKind) {
 }
 
 func (l *Lexer) scanStringLiteral() string {
+	line, column := l.lineNumber, l.columnNumber
 	quote := l.advanceRune()
 	start := l.position - 1
 	if quote != '"' {
@@ -321,7 +387,7 @@ func (l *Lexer) scanStringLiteral() string {
 	}
 	ch := l.advanceRune()
 	if ch == 0 {
-		l.error("unterminated string literal")
+		l.errorFrom(line, column, "unterminated string literal")
 		return string(l.source[start:l.position])
 	}
 	if ch == quote {
@@ -338,9 +404,13 @@ func (l *Lexer) scanStringLiteral() string {
 				return string(l.source[start:l.position])
 			}
 			switch esc {
-			case 'n', 't', 'r', '\\', '\'', '"', '0':
+			case 'n', 't', 'r', '\\', '\'', '"', '0', '{', '}':
 			default:
 				l.error(fmt.Sprintf("invalid escape character: \\%c", esc))
+			}
+		} else if ch == '{' {
+			runeCount++
+			if !l.skipHole(l.lineNumber, l.columnNumber-1) {
 				return string(l.source[start:l.position])
 			}
 		} else {
@@ -348,7 +418,7 @@ func (l *Lexer) scanStringLiteral() string {
 		}
 		ch = l.advanceRune()
 		if ch == 0 {
-			l.error("unterminated string literal")
+			l.errorFrom(line, column, "unterminated string literal")
 			return string(l.source[start:l.position])
 		}
 		if ch == quote {
@@ -358,7 +428,34 @@ func (l *Lexer) scanStringLiteral() string {
 	return string(l.source[start:l.position])
 }
 
+// skipHole consumes the expression in a `{expr}` hole of a string literal,
+// and the closing brace. The expression may hold braces and strings of its
+// own. line and column are those of the opening brace.
+func (l *Lexer) skipHole(line, column int) bool {
+	depth := 1
+	for {
+		switch l.peekRuneAt(0) {
+		case 0:
+			l.errorFrom(line, column, "unterminated { in string literal")
+			return false
+		case '"':
+			l.scanStringLiteral()
+			continue
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				l.advanceRune()
+				return true
+			}
+		}
+		l.advanceRune()
+	}
+}
+
 func (l *Lexer) scanByteLiteral() string {
+	line, column := l.lineNumber, l.columnNumber
 	quote := l.advanceRune()
 	start := l.position - 1
 	if quote != '\'' {
@@ -366,7 +463,7 @@ func (l *Lexer) scanByteLiteral() string {
 	}
 	ch := l.advanceRune()
 	if ch == 0 {
-		l.error("unterminated character literal")
+		l.errorFrom(line, column, "unterminated character literal")
 		return string(l.source[start:l.position])
 	}
 	if ch == quote {
@@ -392,7 +489,7 @@ func (l *Lexer) scanByteLiteral() string {
 	}
 	end := l.advanceRune()
 	if end == 0 {
-		l.error("unterminated character literal")
+		l.errorFrom(line, column, "unterminated character literal")
 		return string(l.source[start:l.position])
 	}
 	if end != '\'' {
@@ -419,16 +516,24 @@ func (l *Lexer) scanLineComment() string {
 	return string(l.source[start:l.position])
 }
 
+// consumeWhitespace skips whitespace, keeping it as trivia: one Newline
+// per line break and one Whitespace for each run of anything else.
 func (l *Lexer) consumeWhitespace() {
 	for {
 		ch := l.peekRuneAt(0)
-		if ch == 0 {
-			return
-		}
-		if ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n' {
+		line, col := l.lineNumber, l.columnNumber
+		switch ch {
+		case '\n':
 			l.advanceRune()
-			continue
+			l.trivia = append(l.trivia, Trivia{Kind: Newline, Text: "\n", Line: line, Column: col})
+		case ' ', '\t', '\r':
+			start := l.position
+			for ch := l.peekRuneAt(0); ch == ' ' || ch == '\t' || ch == '\r'; ch = l.peekRuneAt(0) {
+				l.advanceRune()
+			}
+			l.trivia = append(l.trivia, Trivia{Kind: Whitespace, Text: string(l.source[start:l.position]), Line: line, Column: col})
+		default:
+			return
 		}
-		break
 	}
 }
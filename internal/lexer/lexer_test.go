@@ -1,6 +1,10 @@
 package lexer
 
-import "testing"
+import (
+	"flint/internal/diag"
+	"strings"
+	"testing"
+)
 
 func TestLexerBasicToken(t *testing.T) {
 	input := `mut x = 10
@@ -115,6 +119,18 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestStringHolesMayHoldStrings(t *testing.T) {
+	lexer := New(`"a {f("}", x)} \{b\}" c`, "holes.flint")
+	tok := lexer.Next()
+
+	if tok.Kind != String || tok.Lexeme != `"a {f("}", x)} \{b\}"` {
+		t.Fatalf("unexpected token: %v %q", tok.Kind, tok.Lexeme)
+	}
+	if tok := lexer.Next(); tok.Kind != Identifier {
+		t.Fatalf("expected Identifier, got %v", tok.Kind)
+	}
+}
+
 func TestByteLiteral(t *testing.T) {
 	lexer := New(`'\n'`, "byte.flint")
 	tok := lexer.Next()
@@ -258,23 +274,38 @@ func TestCommentEdgeCases(t *testing.T) {
 	}
 }
 
-// func TestUnterminatedString(t *testing.T) {
-// 	lexer := New(`"hello world`, "bad_string.flint")
-// 	tok := lexer.Next()
+func TestUnterminatedString(t *testing.T) {
+	lexer := New(`"hello world`, "bad_string.flint")
+	tok := lexer.Next()
+
+	if tok.Kind != Illegal {
+		t.Fatalf("expected Error token, got %v", tok.Kind)
+	}
+}
+
+func TestInvalidByte(t *testing.T) {
+	lexer := New(`'ab'`, "bad_byte.flint")
+	tok := lexer.Next()
 
-// 	if tok.Kind != Illegal {
-// 		t.Fatalf("expected Error token, got %v", tok.Kind)
-// 	}
-// }
+	if tok.Kind != Illegal {
+		t.Fatalf("expected Error token, got %v", tok.Kind)
+	}
+}
 
-// func TestInvalidByte(t *testing.T) {
-// 	lexer := New(`'ab'`, "bad_byte.flint")
-// 	tok := lexer.Next()
+func TestTokenizeReportsEveryProblem(t *testing.T) {
+	tokens, err := Tokenize("a & b\n\"x\\qy\" ~", "bad.flint")
 
-// 	if tok.Kind != Illegal {
-// 		t.Fatalf("expected Error token, got %v", tok.Kind)
-// 	}
-// }
+	diags, ok := err.(diag.List)
+	if !ok || len(diags) != 3 {
+		t.Fatalf("expected 3 diagnostics, got %v", err)
+	}
+	if diags[1].Span.Line != 2 || !strings.Contains(diags[1].Message, "invalid escape") {
+		t.Fatalf("unexpected diagnostic: %+v", diags[1])
+	}
+	if tokens[len(tokens)-1].Kind != EndOfFile {
+		t.Fatal("expected to scan to the end of the input")
+	}
+}
 
 func TestEscapeSequences(t *testing.T) {
 	input := `"\n\t\\\""`
@@ -309,3 +340,48 @@ func TestEOFRepeatable(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenizeKeepsTrivia(t *testing.T) {
+	src := "// lead\nfn main() { // open\n\tx  + 1\r\n\n  // close\n}\n\"unterminated"
+	tokens, _ := Tokenize(src, "trivia.flint")
+	if got := Text(tokens); got != src {
+		t.Fatalf("round trip lost text:\n%q\n%q", src, got)
+	}
+	for _, tok := range tokens {
+		if tok.Kind == Comment {
+			t.Fatalf("unexpected comment token %q", tok.Lexeme)
+		}
+	}
+
+	fn := tokens[0]
+	if len(fn.Leading) != 2 || fn.Leading[0].Kind != LineComment || fn.Leading[0].Text != "// lead" {
+		t.Fatalf("unexpected leading trivia of fn: %+v", fn.Leading)
+	}
+	brace := tokens[4]
+	if brace.Lexeme != "{" || len(brace.Trailing) != 3 || brace.Trailing[1].Text != "// open" || brace.Trailing[2].Kind != Newline {
+		t.Fatalf("unexpected trailing trivia of {: %+v", brace.Trailing)
+	}
+	close := tokens[8]
+	if close.Lexeme != "}" || close.Leading[2].Text != "// close" || close.Leading[2].Line != 5 || close.Leading[2].Column != 3 {
+		t.Fatalf("unexpected leading trivia of }: %+v", close.Leading)
+	}
+}
+
+func TestUnterminatedLiteralsAreReportedWhereTheyOpen(t *testing.T) {
+	for src, want := range map[string][2]int{
+		"x = \"abc\n\n\ndef": {1, 5},
+		"\"a {f(1)\n\n":      {1, 4},
+		"y\n  '\\n":          {2, 3},
+		"\"{\"inner\n\n":     {1, 3},
+	} {
+		_, err := Tokenize(src, "open.flint")
+		diags, _ := err.(diag.List)
+		if len(diags) == 0 || !strings.Contains(diags[0].Message, "unterminated") {
+			t.Errorf("%q: expected an unterminated literal, got %v", src, err)
+			continue
+		}
+		if got := [2]int{diags[0].Span.Line, diags[0].Span.Column}; got != want {
+			t.Errorf("%q: reported at %v, expected %v", src, got, want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package lexer
 
+import "flint/internal/diag"
+
 // TokenKind is an enum-like type describing the category of a token.
 // Using a custom type instead of strings avoids mistakes and improves performance.
 type TokenKind int
@@ -13,6 +15,42 @@ type Token struct {
 
 	File   string
 	Source []rune
+
+	// Leading is the trivia between the token and the one before it, apart
+	// from the Trailing trivia of that one, which runs to the end of its
+	// line.
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+// Trivia is source text that does not change the meaning of a program.
+type Trivia struct {
+	Kind   TriviaKind
+	Text   string
+	Line   int
+	Column int
+}
+
+type TriviaKind int
+
+const (
+	Whitespace TriviaKind = iota
+	Newline
+	LineComment
+)
+
+// Span returns the source range covered by the token.
+func (t Token) Span() diag.Span {
+	s := diag.Span{File: t.File, Line: t.Line, Column: t.Column, EndLine: t.Line, EndColumn: t.Column}
+	for _, r := range t.Lexeme {
+		if r == '\n' {
+			s.EndLine++
+			s.EndColumn = 1
+			continue
+		}
+		s.EndColumn++
+	}
+	return s
 }
 
 const (
@@ -80,6 +118,7 @@ const (
 	KwElse
 	KwFloat
 	KwFn
+	KwFor
 	KwIf
 	KwIn
 	KwInt
@@ -103,7 +142,9 @@ var KeywordMap = map[string]TokenKind{
 	"False":  Bool,
 	"Float":  KwFloat,
 	"fn":     KwFn,
+	"for":    KwFor,
 	"if":     KwIf,
+	"in":     KwIn,
 	"Int":    KwInt,
 	"List":   KwList,
 	"match":  KwMatch,
@@ -0,0 +1,183 @@
+package lsp
+
+import (
+	"flint/internal/diag"
+	"flint/internal/lexer"
+	"flint/internal/module"
+	"flint/internal/parser"
+	"flint/internal/project"
+	"flint/internal/typechecker"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// analysis is what the server knows about a version of a document.
+type analysis struct {
+	text   string
+	tokens []lexer.Token
+	prog   *parser.Program
+	index  *index
+	diags  diag.List
+
+	// tc is the typechecker of the document, or nil if it did not parse.
+	tc *typechecker.TypeChecker
+}
+
+// analyze lexes, parses and typechecks a document. As in `flint check`, the
+// typechecker only runs on documents that parsed cleanly. The index is
+// built from whatever the parser recovered.
+func analyze(uri, text string) (a *analysis) {
+	a = &analysis{text: text, index: &index{}}
+	defer func() {
+		if r := recover(); r != nil {
+			a.tc = nil
+			a.diags = append(a.diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Span:     diag.Span{File: uri, Line: 1, Column: 1, EndLine: 1, EndColumn: 1},
+				Message:  fmt.Sprintf("internal compiler error: %v", r),
+			})
+		}
+	}()
+	tokens, err := lexer.Tokenize(text, uri)
+	if lexDiags, ok := err.(diag.List); ok {
+		a.diags = append(a.diags, lexDiags...)
+	}
+	prog, parseDiags := parser.ParseProgram(tokens)
+	a.tokens, a.prog = tokens, prog
+	a.diags = append(a.diags, parseDiags...)
+	a.index = buildIndex(prog)
+	if a.diags.HasErrors() {
+		return a
+	}
+	var diags diag.List
+	a.tc, diags = check(uri, text, prog)
+	a.diags = append(a.diags, diags...)
+	return a
+}
+
+// check typechecks prog, the program of a document. A file that uses
+// source modules is loaded as a module of the project it is in, or of its
+// directory outside of a project, so that they are found, and only the
+// diagnostics in the file are kept.
+func check(uri, text string, prog *parser.Program) (*typechecker.TypeChecker, diag.List) {
+	path, ok := filePath(uri)
+	if !ok || !usesSourceModules(prog) {
+		tc := typechecker.New()
+		for _, ex := range prog.Exprs {
+			tc.CheckExpr(ex)
+		}
+		return tc, tc.Diagnostics()
+	}
+	m, all := module.NewLoader(rootsOf(path)...).Load(path, text)
+	var diags diag.List
+	for _, d := range all {
+		if d.Span.File == path {
+			diags = append(diags, d)
+		}
+	}
+	if m.TC == nil && !diags.HasErrors() {
+		for _, e := range m.Prog.Exprs {
+			u, ok := e.(*parser.UseExpr)
+			if !ok {
+				continue
+			}
+			if dep := m.Imports[strings.Join(u.Path, "/")]; dep != nil && dep.TC == nil {
+				diags.Add(diag.Diagnostic{Severity: diag.Error, Span: u.Pos.Span(), Message: fmt.Sprintf("module %s has errors", dep.Path)})
+			}
+		}
+	}
+	return m.TC, diags
+}
+
+// rootsOf returns the source roots the modules used by the file at path
+// are found under: those of its project or, outside of one, its directory.
+func rootsOf(path string) []string {
+	if p, _ := project.Find(filepath.Dir(path)); p != nil {
+		return p.Roots()
+	}
+	return []string{filepath.Dir(path)}
+}
+
+func usesSourceModules(prog *parser.Program) bool {
+	for _, e := range prog.Exprs {
+		if u, ok := e.(*parser.UseExpr); ok && !typechecker.HasModule(strings.Join(u.Path, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// filePath returns the path of a file: URI.
+func filePath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// toDiagnostic converts a compiler diagnostic to the protocol's form.
+func toDiagnostic(text string, d diag.Diagnostic) Diagnostic {
+	severity := 1
+	if d.Severity == diag.Warning {
+		severity = 2
+	}
+	msg := d.Message
+	for _, n := range d.Notes {
+		msg += "\nnote: " + n
+	}
+	lines := strings.Split(text, "\n")
+	return Diagnostic{
+		Range: Range{
+			Start: toPosition(lines, d.Span.Line, d.Span.Column),
+			End:   toPosition(lines, d.Span.EndLine, d.Span.EndColumn),
+		},
+		Severity: severity,
+		Source:   "flint",
+		Message:  msg,
+	}
+}
+
+// toPosition converts a 1-based line and rune column to a protocol
+// position, whose lines start at 0 and whose characters count UTF-16 code
+// units.
+func toPosition(lines []string, line, col int) Position {
+	if line < 1 {
+		return Position{}
+	}
+	if line > len(lines) {
+		return Position{Line: line - 1}
+	}
+	runes := []rune(lines[line-1])
+	n := min(max(col-1, 0), len(runes))
+	return Position{Line: line - 1, Character: len(utf16.Encode(runes[:n]))}
+}
+
+// toRange returns the range of a token.
+func toRange(lines []string, tok lexer.Token) *Range {
+	span := tok.Span()
+	return &Range{
+		Start: toPosition(lines, span.Line, span.Column),
+		End:   toPosition(lines, span.EndLine, span.EndColumn),
+	}
+}
+
+// fromPosition is the inverse of toPosition.
+func fromPosition(lines []string, pos Position) (line, col int) {
+	if pos.Line >= len(lines) {
+		return pos.Line + 1, 1
+	}
+	units := 0
+	col = 1
+	for _, r := range lines[pos.Line] {
+		if units >= pos.Character {
+			break
+		}
+		units += utf16.RuneLen(r)
+		col++
+	}
+	return pos.Line + 1, col
+}
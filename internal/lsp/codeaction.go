@@ -0,0 +1,145 @@
+package lsp
+
+import (
+	"encoding/json"
+	"flint/internal/lexer"
+	"flint/internal/parser"
+	"flint/internal/typechecker"
+	"strings"
+)
+
+type quickFix struct {
+	title string
+	edits []TextEdit
+}
+
+// handleCodeAction offers quick fixes for the diagnostics the client sends
+// back, which are those the server published for the range.
+func handleCodeAction(req RequestMessage) {
+	var params CodeActionParams
+	json.Unmarshal(req.Params, &params)
+
+	uri := params.TextDocument.URI
+	actions := []CodeAction{}
+	if a := analysisOf(uri); a != nil {
+		for _, d := range params.Context.Diagnostics {
+			if d.Source != "flint" {
+				continue
+			}
+			for _, fix := range quickFixes(a, d) {
+				actions = append(actions, CodeAction{
+					Title:       fix.title,
+					Kind:        "quickfix",
+					Diagnostics: []Diagnostic{d},
+					Edit:        &WorkspaceEdit{Changes: map[string][]TextEdit{uri: fix.edits}},
+				})
+			}
+		}
+	}
+
+	reply(ResponseMessage{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result:  actions,
+	})
+}
+
+func quickFixes(a *analysis, d Diagnostic) []quickFix {
+	lines := strings.Split(a.text, "\n")
+	line, col := fromPosition(lines, d.Range.Start)
+	msg, _, _ := strings.Cut(d.Message, "\n")
+
+	if name, ok := quoted(msg, "cannot assign to immutable variable '"); ok {
+		return makeMutable(a, lines, line, col, name)
+	}
+	if name, ok := strings.CutPrefix(msg, "unknown module: "); ok {
+		return importModule(a, name)
+	}
+	if _, ok := quoted(msg, "parameter '"); ok && strings.HasSuffix(msg, "needs a type annotation") {
+		return annotateParam(a, lines, line, col)
+	}
+	return nil
+}
+
+// quoted returns the text between prefix, which ends in a quote, and the
+// next quote in msg.
+func quoted(msg, prefix string) (string, bool) {
+	rest, ok := strings.CutPrefix(msg, prefix)
+	if !ok {
+		return "", false
+	}
+	name, _, ok := strings.Cut(rest, "'")
+	return name, ok
+}
+
+// makeMutable turns the `val` declaring the variable assigned to at line
+// and col into a `mut`. The error points at the `=` of the assignment, so
+// the variable is the last use of name before it.
+func makeMutable(a *analysis, lines []string, line, col int, name string) []quickFix {
+	var decl *lexer.Token
+	for _, o := range a.index.occurrences {
+		if o.tok.Line == line && o.tok.Column < col && o.tok.Lexeme == name {
+			decl = &o.sym.Pos
+		}
+	}
+	if decl == nil {
+		return nil
+	}
+	for i, tok := range a.tokens {
+		if !samePos(tok, *decl) {
+			continue
+		}
+		for j := i - 1; j >= 0 && a.tokens[j].Line == decl.Line; j-- {
+			if a.tokens[j].Kind == lexer.KwVal {
+				return []quickFix{{
+					title: "Change 'val' to 'mut'",
+					edits: []TextEdit{{Range: *toRange(lines, a.tokens[j]), NewText: "mut"}},
+				}}
+			}
+		}
+		break
+	}
+	return nil
+}
+
+// importModule adds a `use` for each known module called name, after the
+// imports already in the document.
+func importModule(a *analysis, name string) []quickFix {
+	at := Position{}
+	if a.prog != nil {
+		for _, e := range a.prog.Exprs {
+			if u, ok := e.(*parser.UseExpr); ok {
+				at = Position{Line: u.Pos.Line}
+			}
+		}
+	}
+	var fixes []quickFix
+	for _, path := range typechecker.ModulesNamed(name) {
+		fixes = append(fixes, quickFix{
+			title: "Import " + path,
+			edits: []TextEdit{{Range: Range{Start: at, End: at}, NewText: "use " + path + "\n"}},
+		})
+	}
+	return fixes
+}
+
+// annotateParam annotates the parameter at line and col with the type its
+// uses gave it.
+func annotateParam(a *analysis, lines []string, line, col int) []quickFix {
+	if a.tc == nil {
+		return nil
+	}
+	ref, ok := a.tc.RefAt(line, col)
+	if !ok {
+		return nil
+	}
+	t := typechecker.Resolve(ref.Type)
+	if t == nil || t.HasVars() {
+		return nil
+	}
+	end := toRange(lines, ref.Pos).End
+	return []quickFix{{
+		title: "Add missing type annotation ': " + t.String() + "'",
+		edits: []TextEdit{{Range: Range{Start: end, End: end}, NewText: ": " + t.String()}},
+	}}
+}
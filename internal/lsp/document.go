@@ -0,0 +1,71 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// A document is the text of an open file. It is kept as lines so that an
+// incremental change only rebuilds the lines it touches.
+type document struct {
+	lines []string
+
+	// version counts the changes applied, so that an analysis can tell
+	// whether the text it worked on is still current.
+	version int
+}
+
+func newDocument(text string) *document {
+	return &document{lines: strings.Split(text, "\n")}
+}
+
+func (d *document) text() string {
+	return strings.Join(d.lines, "\n")
+}
+
+// apply replaces the text in r with text, or the whole document if r is
+// nil.
+func (d *document) apply(r *Range, text string) {
+	d.version++
+	if r == nil {
+		d.lines = strings.Split(text, "\n")
+		return
+	}
+	start, end := d.clamp(r.Start), d.clamp(r.End)
+	if end.Line < start.Line || (end.Line == start.Line && end.Character < start.Character) {
+		start, end = end, start
+	}
+	first, last := d.lines[start.Line], d.lines[end.Line]
+	replaced := first[:byteOffset(first, start.Character)] + text + last[byteOffset(last, end.Character):]
+	d.lines = append(d.lines[:start.Line], append(strings.Split(replaced, "\n"), d.lines[end.Line+1:]...)...)
+}
+
+// clamp moves a position past the end of the document to its end.
+func (d *document) clamp(p Position) Position {
+	if p.Line >= len(d.lines) {
+		last := len(d.lines) - 1
+		return Position{Line: last, Character: len(utf16.Encode([]rune(d.lines[last])))}
+	}
+	return p
+}
+
+// byteOffset converts a character offset in UTF-16 code units, as the
+// protocol counts them, to a byte offset in line.
+func byteOffset(line string, char int) int {
+	units := 0
+	for i, r := range line {
+		if units >= char {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+// line returns line n, or "" past the end of the document.
+func (d *document) line(n int) string {
+	if n < 0 || n >= len(d.lines) {
+		return ""
+	}
+	return d.lines[n]
+}
@@ -0,0 +1,39 @@
+package lsp
+
+import (
+	"encoding/json"
+	"flint/internal/format"
+)
+
+// handleFormatting formats a document in the canonical style, as `flint fmt`
+// does, replacing all of it. The client's options are ignored: there is one
+// style. Documents that do not parse are left alone.
+func handleFormatting(req RequestMessage) {
+	var params DocumentFormattingParams
+	json.Unmarshal(req.Params, &params)
+	mu.RLock()
+	doc := docs[params.TextDocument.URI]
+	var text string
+	var end Position
+	if doc != nil {
+		text = doc.text()
+		end = doc.clamp(Position{Line: len(doc.lines)})
+	}
+	mu.RUnlock()
+
+	var edits []TextEdit
+	if doc != nil {
+		if out, err := format.Source(text, params.TextDocument.URI); err == nil {
+			edits = []TextEdit{}
+			if out != text {
+				edits = append(edits, TextEdit{Range: Range{End: end}, NewText: out})
+			}
+		}
+	}
+
+	reply(ResponseMessage{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result:  edits,
+	})
+}
@@ -2,20 +2,30 @@ package lsp
 
 import (
 	"encoding/json"
+	"flint/internal/doc"
+	"flint/internal/typechecker"
 	"strings"
 )
 
 func handleInitialize(req RequestMessage) {
 	result := InitializeResult{
 		Capabilities: ServerCapabilities{
-			TextDocumentSync: 1,
+			TextDocumentSync: 2,
 			CompletionProvider: &CompletionOptions{
 				ResolveProvider:   false,
 				TriggerCharacters: []string{"."},
 			},
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			DocumentSymbolProvider: true,
+			RenameProvider:         &RenameOptions{PrepareProvider: true},
+			CodeActionProvider:     true,
+
+			DocumentFormattingProvider: true,
 		},
 	}
-	send(ResponseMessage{
+	reply(ResponseMessage{
 		Jsonrpc: "2.0",
 		ID:      req.ID,
 		Result:  result,
@@ -25,47 +35,80 @@ func handleInitialize(req RequestMessage) {
 func handleDidOpen(params json.RawMessage) {
 	var p DidOpenTextDocumentParams
 	json.Unmarshal(params, &p)
-	docs[p.TextDocument.URI] = p.TextDocument.Text
-	updateSymbols(p.TextDocument.URI, p.TextDocument.Text)
-	runDiagnostics(p.TextDocument.URI)
+	mu.Lock()
+	defer mu.Unlock()
+	docs[p.TextDocument.URI] = newDocument(p.TextDocument.Text)
+	scheduleAnalysis(p.TextDocument.URI, 0)
 }
 
+// handleDidChange applies the changes in order. A change without a range
+// replaces the whole document.
 func handleDidChange(params json.RawMessage) {
 	var p DidChangeTextDocumentParams
 	json.Unmarshal(params, &p)
-	if len(p.ContentChanges) > 0 {
-		docs[p.TextDocument.URI] = p.ContentChanges[0].Text
-		updateSymbols(p.TextDocument.URI, p.ContentChanges[0].Text)
-		runDiagnostics(p.TextDocument.URI)
+	mu.Lock()
+	defer mu.Unlock()
+	doc := docs[p.TextDocument.URI]
+	if doc == nil || len(p.ContentChanges) == 0 {
+		return
 	}
+	for _, c := range p.ContentChanges {
+		doc.apply(c.Range, c.Text)
+	}
+	scheduleAnalysis(p.TextDocument.URI, debounce)
+}
+
+func handleDidClose(params json.RawMessage) {
+	var p DidCloseTextDocumentParams
+	json.Unmarshal(params, &p)
+	mu.Lock()
+	defer mu.Unlock()
+	uri := p.TextDocument.URI
+	cancelAnalysis(uri)
+	delete(docs, uri)
+	delete(analyses, uri)
+	delete(symbols, uri)
+	publishDiagnostics(uri, []Diagnostic{})
 }
 
 func handleShutdown(req RequestMessage) {
-	send(ResponseMessage{
+	reply(ResponseMessage{
 		Jsonrpc: "2.0",
 		ID:      req.ID,
 		Result:  nil,
 	})
 }
 
+// runDiagnostics analyses a document and publishes its diagnostics, unless
+// the document changed in the meantime, in which case another analysis is
+// on its way.
 func runDiagnostics(uri string) {
-	text := docs[uri]
-	diagnostics := []Diagnostic{}
+	mu.RLock()
+	doc := docs[uri]
+	if doc == nil {
+		mu.RUnlock()
+		return
+	}
+	text, version := doc.text(), doc.version
+	mu.RUnlock()
 
-	lines := strings.Split(text, "\n")
-	for i, line := range lines {
-		if strings.Contains(line, "error") {
-			diagnostics = append(diagnostics, Diagnostic{
-				Severity: 1,
-				Message:  "Detected the word 'error'",
-				Range: Range{
-					Start: Position{Line: i, Character: 0},
-					End:   Position{Line: i, Character: len(line)},
-				},
-			})
-		}
+	a := analyze(uri, text)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if doc := docs[uri]; doc == nil || doc.version != version {
+		return
 	}
+	analyses[uri] = a
+	updateSymbols(uri, a.index)
+	diagnostics := []Diagnostic{}
+	for _, d := range a.diags {
+		diagnostics = append(diagnostics, toDiagnostic(a.text, d))
+	}
+	publishDiagnostics(uri, diagnostics)
+}
 
+func publishDiagnostics(uri string, diagnostics []Diagnostic) {
 	send(map[string]any{
 		"jsonrpc": "2.0",
 		"method":  "textDocument/publishDiagnostics",
@@ -84,15 +127,16 @@ func handleCompletion(req RequestMessage) {
 	var params CompletionParams
 	json.Unmarshal(req.Params, &params)
 
-	text := docs[params.TextDocument.URI]
-	lines := strings.Split(text, "\n")
+	mu.RLock()
 	line := ""
-	if params.Position.Line < len(lines) {
-		line = lines[params.Position.Line]
+	if doc := docs[params.TextDocument.URI]; doc != nil {
+		line = doc.line(params.Position.Line)
 	}
+	syms := symbols[params.TextDocument.URI]
+	mu.RUnlock()
 
 	prefix := ""
-	fields := strings.Fields(line[:params.Position.Character])
+	fields := strings.Fields(line[:byteOffset(line, params.Position.Character)])
 	if len(fields) > 0 {
 		prefix = fields[len(fields)-1]
 	}
@@ -108,22 +152,44 @@ func handleCompletion(req RequestMessage) {
 		}
 	}
 
-	for _, sym := range symbols[params.TextDocument.URI] {
+	a := analysisOf(params.TextDocument.URI)
+	for _, sym := range syms {
 		if strings.HasPrefix(sym.Name, prefix) {
 			kind := 6
 			if sym.Kind == FunctionSymbol {
 				kind = 3
 			}
 			suggestions = append(suggestions, CompletionItem{
-				Label: sym.Name,
-				Kind:  kind,
+				Label:         sym.Name,
+				Kind:          kind,
+				Detail:        completionDetail(a, sym),
+				Documentation: sym.Doc,
 			})
 		}
 	}
 
-	send(ResponseMessage{
+	reply(ResponseMessage{
 		Jsonrpc: "2.0",
 		ID:      req.ID,
 		Result:  suggestions,
 	})
 }
+
+// completionDetail is the signature of a function symbol, or the type of
+// another name, as of the last analysis that typechecked.
+func completionDetail(a *analysis, sym Symbol) string {
+	if a == nil || a.tc == nil {
+		return ""
+	}
+	ref, ok := a.tc.RefAt(sym.Pos.Line, sym.Pos.Column)
+	if !ok || !ref.IsDecl() || ref.Name != sym.Name {
+		return ""
+	}
+	if ref.Func != nil {
+		return doc.FuncSignature(ref.Func, ref.Type)
+	}
+	if t := typechecker.Resolve(ref.Type); t != nil {
+		return t.String()
+	}
+	return ""
+}
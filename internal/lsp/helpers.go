@@ -5,12 +5,62 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"strings"
+	"sync"
 )
 
+// out serialises the messages written by concurrent handlers.
+var out sync.Mutex
+
 func send(msg any) {
 	data, _ := json.Marshal(msg)
-	fmt.Printf("Content-Length: %d\r\n\r\n%s", len(data), data)
+	out.Lock()
+	defer out.Unlock()
+	fmt.Fprintf(os.Stdout, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+var (
+	// requests holds the IDs of the requests being served, and whether
+	// the client has cancelled them.
+	requests   = map[string]bool{}
+	requestsMu sync.Mutex
+)
+
+func startRequest(id *json.RawMessage) {
+	requestsMu.Lock()
+	defer requestsMu.Unlock()
+	requests[string(*id)] = false
+}
+
+// cancelRequest marks a request the client no longer needs the result of.
+func cancelRequest(id json.RawMessage) {
+	requestsMu.Lock()
+	defer requestsMu.Unlock()
+	if _, ok := requests[string(id)]; ok {
+		requests[string(id)] = true
+	}
+}
+
+func cancelled(id *json.RawMessage) bool {
+	requestsMu.Lock()
+	defer requestsMu.Unlock()
+	return id != nil && requests[string(*id)]
+}
+
+// reply sends the response to a request, or a RequestCancelled error if
+// the client cancelled it.
+func reply(resp ResponseMessage) {
+	if resp.ID != nil {
+		requestsMu.Lock()
+		if requests[string(*resp.ID)] {
+			resp.Result = nil
+			resp.Error = &ResponseError{Code: RequestCancelled, Message: "request cancelled"}
+		}
+		delete(requests, string(*resp.ID))
+		requestsMu.Unlock()
+	}
+	send(resp)
 }
 
 func readMessage(r *bufio.Reader) ([]byte, error) {
@@ -0,0 +1,80 @@
+package lsp
+
+import (
+	"encoding/json"
+	"flint/internal/parser"
+	"flint/internal/typechecker"
+	"strconv"
+	"strings"
+)
+
+// handleHover shows the type of the name under the cursor, as inferred
+// where it appears. Functions also show their decorators and doc comment.
+func handleHover(req RequestMessage) {
+	var params TextDocumentPositionParams
+	json.Unmarshal(req.Params, &params)
+
+	var result *Hover
+	if a := analysisOf(params.TextDocument.URI); a != nil && a.tc != nil {
+		lines := strings.Split(a.text, "\n")
+		line, col := fromPosition(lines, params.Position)
+		if ref, ok := a.tc.RefAt(line, col); ok {
+			result = &Hover{
+				Contents: MarkupContent{Kind: "markdown", Value: hoverMarkdown(ref)},
+				Range:    toRange(lines, ref.Pos),
+			}
+		}
+	}
+
+	reply(ResponseMessage{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result:  result,
+	})
+}
+
+func hoverMarkdown(ref typechecker.Ref) string {
+	md := "```flint\n" + hoverText(ref) + "\n```"
+	if ref.Func != nil && ref.Func.Doc != "" {
+		md += "\n\n" + ref.Func.Doc
+	}
+	return md
+}
+
+func hoverText(ref typechecker.Ref) string {
+	var b strings.Builder
+	if ref.Func != nil {
+		for _, d := range ref.Func.Decorators {
+			b.WriteString("@" + d.Name)
+			if len(d.Args) > 0 {
+				args := make([]string, len(d.Args))
+				for i, a := range d.Args {
+					args[i] = exprText(a)
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString("\n")
+		}
+	}
+	ty := "?"
+	if t := typechecker.Resolve(ref.Type); t != nil {
+		ty = t.String()
+	}
+	b.WriteString(ref.Name + ": " + ty)
+	return b.String()
+}
+
+// exprText renders the literal arguments that decorators take.
+func exprText(e parser.Expr) string {
+	switch e := e.(type) {
+	case *parser.Identifier:
+		return e.Name
+	case *parser.StringLiteral:
+		return strconv.Quote(e.Value)
+	case *parser.IntLiteral:
+		return e.Pos.Lexeme
+	case *parser.BoolLiteral:
+		return e.Pos.Lexeme
+	}
+	return "..."
+}
@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func resetState() {
-	docs = map[string]string{}
+	docs = map[string]*document{}
+	debounce = 0
 	symbols = map[string][]Symbol{}
+	analyses = map[string]*analysis{}
 }
 
 func captureStdout(fn func()) string {
@@ -42,7 +46,7 @@ func TestInitialize(t *testing.T) {
 		handleInitialize(req)
 	})
 
-	if !strings.Contains(out, `"textDocumentSync":1`) {
+	if !strings.Contains(out, `"textDocumentSync":2`) {
 		t.Fatalf("expected textDocumentSync in response, got:\n%s", out)
 	}
 
@@ -65,35 +69,63 @@ func TestDidOpenStoresDocument(t *testing.T) {
 
 	captureStdout(func() {
 		handleDidOpen(data)
+		pending.Wait()
 	})
 
-	if docs["file:///test.flint"] != "val x = 10" {
+	if docs["file:///test.flint"].text() != "val x = 10" {
 		t.Fatalf("document not stored")
 	}
 }
 
-func TestDiagnosticsDetectsErrorWord(t *testing.T) {
+func TestDiagnosticsReportTypeErrors(t *testing.T) {
 	resetState()
-
 	params := DidOpenTextDocumentParams{
 		TextDocument: TextDocumentItem{
 			URI:  "file:///err.flint",
-			Text: "this is an error here",
+			Text: "fn f() Int {\n\tval s: String = 1\n\tmissing\n}\n",
 		},
 	}
-
 	data, _ := json.Marshal(params)
-
 	out := captureStdout(func() {
 		handleDidOpen(data)
+		pending.Wait()
 	})
 
 	if !strings.Contains(out, `"textDocument/publishDiagnostics"`) {
 		t.Fatalf("did not publish diagnostics")
 	}
+	var msg struct {
+		Params PublishDiagnosticsParams `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(out[strings.Index(out, "{"):]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	diags := msg.Params.Diagnostics
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %+v", diags)
+	}
+	want := Range{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 8}}
+	if diags[1].Range != want || !strings.Contains(diags[1].Message, "undefined variable") {
+		t.Fatalf("unexpected diagnostic %+v", diags[1])
+	}
+}
 
-	if !strings.Contains(out, "Detected the word 'error'") {
-		t.Fatalf("missing diagnostic message, got:\n%s", out)
+func TestDiagnosticsSurviveLexerErrors(t *testing.T) {
+	resetState()
+	params := DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{
+			URI:  "file:///lex.flint",
+			Text: "fn f() String { \"abc\\q\" & }",
+		},
+	}
+	data, _ := json.Marshal(params)
+	out := captureStdout(func() {
+		handleDidOpen(data)
+		pending.Wait()
+	})
+
+	if !strings.Contains(out, "invalid escape character") || !strings.Contains(out, "illegal character") {
+		t.Fatalf("missing lexer diagnostics, got:\n%s", out)
 	}
 }
 
@@ -113,6 +145,7 @@ mut world = 20
 
 	data, _ := json.Marshal(params)
 	handleDidOpen(data)
+	pending.Wait()
 
 	syms := symbols[uri]
 
@@ -133,7 +166,7 @@ func TestDidChangeUpdatesDocument(t *testing.T) {
 	resetState()
 
 	uri := "file:///change.flint"
-	docs[uri] = "old"
+	docs[uri] = newDocument("old")
 
 	params := DidChangeTextDocumentParams{
 		TextDocument: VersionedTextDocumentIdentifier{URI: uri},
@@ -143,9 +176,532 @@ func TestDidChangeUpdatesDocument(t *testing.T) {
 	}
 
 	data, _ := json.Marshal(params)
-	handleDidChange(data)
+	captureStdout(func() {
+		handleDidChange(data)
+		pending.Wait()
+	})
 
-	if docs[uri] != "new content" {
+	if docs[uri].text() != "new content" {
 		t.Fatalf("document did not update")
 	}
 }
+
+func hover(t *testing.T, uri string, line, char int) *Hover {
+	t.Helper()
+	id := json.RawMessage(`2`)
+	params, _ := json.Marshal(TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: char},
+	})
+	out := captureStdout(func() {
+		handleHover(RequestMessage{Jsonrpc: "2.0", ID: &id, Method: "textDocument/hover", Params: params})
+	})
+	var msg struct {
+		Result *Hover `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(out[strings.Index(out, "{"):]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg.Result
+}
+
+func TestHoverShowsTypes(t *testing.T) {
+	resetState()
+	uri := "file:///hover.flint"
+	text := `@external(c, "flint_stdlib", "print")
+fn print(s: String) Nil
+
+fn add(x: Int, y: Int) Int { x + y }
+
+fn main() Nil {
+	val total = add(1, 2)
+	print("done")
+}
+`
+	data, _ := json.Marshal(DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, Text: text}})
+	captureStdout(func() {
+		handleDidOpen(data)
+		pending.Wait()
+	})
+
+	h := hover(t, uri, 6, 14)
+	if h == nil || !strings.Contains(h.Contents.Value, "add: (Int, Int) -> Int") {
+		t.Fatalf("unexpected hover for add: %+v", h)
+	}
+	if want := (Range{Start: Position{Line: 6, Character: 13}, End: Position{Line: 6, Character: 16}}); *h.Range != want {
+		t.Fatalf("unexpected range %+v", *h.Range)
+	}
+
+	h = hover(t, uri, 7, 2)
+	if h == nil || !strings.Contains(h.Contents.Value, "@external(c, \"flint_stdlib\", \"print\")\nprint: (String) -> Nil") {
+		t.Fatalf("unexpected hover for print: %+v", h)
+	}
+
+	h = hover(t, uri, 6, 6)
+	if h == nil || !strings.Contains(h.Contents.Value, "total: Int") {
+		t.Fatalf("unexpected hover for total: %+v", h)
+	}
+
+	if h := hover(t, uri, 5, 0); h != nil {
+		t.Fatalf("expected no hover on a blank line, got %+v", h)
+	}
+}
+
+func open(uri, text string) {
+	data, _ := json.Marshal(DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, Text: text}})
+	captureStdout(func() {
+		handleDidOpen(data)
+		pending.Wait()
+	})
+}
+
+// request calls handler with params and decodes the result into result.
+func request(t *testing.T, handler func(RequestMessage), params any, result any) {
+	t.Helper()
+	id := json.RawMessage(`3`)
+	data, _ := json.Marshal(params)
+	out := captureStdout(func() {
+		handler(RequestMessage{Jsonrpc: "2.0", ID: &id, Params: data})
+	})
+	msg := struct {
+		Result any `json:"result"`
+	}{Result: result}
+	if err := json.Unmarshal([]byte(out[strings.Index(out, "{"):]), &msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const shadowing = `fn main() Int {
+	val x = 1
+	val y = {
+		val x = 2
+		x + helper(x)
+	}
+	x + y
+}
+
+fn helper(n: Int) Int { n }
+`
+
+func TestDefinitionResolvesShadowedNames(t *testing.T) {
+	resetState()
+	uri := "file:///shadow.flint"
+	open(uri, shadowing)
+
+	tests := []struct {
+		line, char int
+		want       Position
+	}{
+		{4, 2, Position{Line: 3, Character: 6}},
+		{6, 1, Position{Line: 1, Character: 5}},
+		{4, 6, Position{Line: 9, Character: 3}},
+		{9, 24, Position{Line: 9, Character: 10}},
+	}
+	for _, tt := range tests {
+		var loc *Location
+		pos := TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: tt.line, Character: tt.char}}
+		request(t, handleDefinition, pos, &loc)
+		if loc == nil || loc.Range.Start != tt.want {
+			t.Errorf("definition at %d:%d: got %+v, want %+v", tt.line, tt.char, loc, tt.want)
+		}
+	}
+}
+
+func TestReferencesFollowScopes(t *testing.T) {
+	resetState()
+	uri := "file:///shadow.flint"
+	open(uri, shadowing)
+
+	var locs []Location
+	params := ReferenceParams{
+		TextDocumentPositionParams: TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: 3, Character: 6}},
+		Context:                    ReferenceContext{IncludeDeclaration: true},
+	}
+	request(t, handleReferences, params, &locs)
+	var got []Position
+	for _, l := range locs {
+		got = append(got, l.Range.Start)
+	}
+	want := []Position{{Line: 3, Character: 6}, {Line: 4, Character: 2}, {Line: 4, Character: 13}}
+	if len(got) != len(want) {
+		t.Fatalf("got references %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got references %+v, want %+v", got, want)
+		}
+	}
+
+	params.Context.IncludeDeclaration = false
+	request(t, handleReferences, params, &locs)
+	if len(locs) != 2 {
+		t.Fatalf("expected 2 references without the declaration, got %+v", locs)
+	}
+}
+
+func TestReferencesReachIntoStringHoles(t *testing.T) {
+	resetState()
+	uri := "file:///holes.flint"
+	open(uri, "fn greet(name: String) String {\n  \"hi {name}, {name}\"\n}\n")
+
+	var locs []Location
+	params := ReferenceParams{
+		TextDocumentPositionParams: TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: 0, Character: 9}},
+	}
+	request(t, handleReferences, params, &locs)
+	if len(locs) != 2 || locs[0].Range.Start != (Position{Line: 1, Character: 7}) || locs[1].Range.Start != (Position{Line: 1, Character: 15}) {
+		t.Fatalf("unexpected references %+v", locs)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	resetState()
+	uri := "file:///outline.flint"
+	open(uri, `use flint/io
+
+type Shape { | Circle(Float) | Square(Float) }
+
+fn area(s: Shape) Float {
+	fn double(x: Float) Float { x *. 2.0 }
+	val r = match s {
+		| Circle(r) -> r
+		| Square(w) -> w
+	}
+	double(r)
+}
+`)
+	var syms []DocumentSymbol
+	request(t, handleDocumentSymbol, DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &syms)
+
+	var names func([]DocumentSymbol) string
+	names = func(ds []DocumentSymbol) string {
+		var parts []string
+		for _, d := range ds {
+			p := d.Name
+			if len(d.Children) > 0 {
+				p += "(" + names(d.Children) + ")"
+			}
+			parts = append(parts, p)
+		}
+		return strings.Join(parts, " ")
+	}
+	if got, want := names(syms), "io Shape(Circle Square) area(double r)"; got != want {
+		t.Fatalf("got outline %q, want %q", got, want)
+	}
+	if syms[2].Kind != 12 || syms[2].SelectionRange.Start != (Position{Line: 4, Character: 3}) {
+		t.Fatalf("unexpected symbol %+v", syms[2])
+	}
+}
+
+func positionParams(uri string, line, char int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: line, Character: char}}
+}
+
+func editStarts(edits []TextEdit) []Position {
+	var out []Position
+	for _, e := range edits {
+		out = append(out, e.Range.Start)
+	}
+	return out
+}
+
+func TestRenameRespectsScopes(t *testing.T) {
+	resetState()
+	uri := "file:///shadow.flint"
+	open(uri, shadowing)
+
+	var edit WorkspaceEdit
+	request(t, handleRename, RenameParams{TextDocumentPositionParams: positionParams(uri, 6, 1), NewName: "outer"}, &edit)
+	got := editStarts(edit.Changes[uri])
+	want := []Position{{Line: 1, Character: 5}, {Line: 6, Character: 1}}
+	if len(edit.Changes) != 1 || len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got edits %+v, want %+v", edit.Changes, want)
+	}
+}
+
+func TestRenameAcrossDocuments(t *testing.T) {
+	resetState()
+	util := "file:///src/util.flint"
+	main := "file:///src/main.flint"
+	open(util, "pub fn helper(n: Int) Int { n }\n\nfn twice(n: Int) Int { helper(helper(n)) }\n")
+	open(main, "use util\nuse util.{helper}\n\nfn main() Int {\n\tutil:helper(1) + helper(2)\n}\n")
+
+	var edit WorkspaceEdit
+	request(t, handleRename, RenameParams{TextDocumentPositionParams: positionParams(main, 4, 8), NewName: "assist"}, &edit)
+	if got := editStarts(edit.Changes[util]); len(got) != 3 {
+		t.Fatalf("expected 3 edits in util, got %+v", got)
+	}
+	got := editStarts(edit.Changes[main])
+	want := []Position{{Line: 1, Character: 10}, {Line: 4, Character: 6}, {Line: 4, Character: 18}}
+	if len(got) != len(want) {
+		t.Fatalf("got edits %+v in main, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] || edit.Changes[main][i].NewText != "assist" {
+			t.Fatalf("got edits %+v in main, want %+v", got, want)
+		}
+	}
+}
+
+func TestRenameTellsModulesOfTheSameNameApart(t *testing.T) {
+	resetState()
+	a := "file:///src/a/util.flint"
+	b := "file:///src/b/util.flint"
+	main := "file:///src/main.flint"
+	open(a, "pub fn helper(n: Int) Int { n }\n")
+	open(b, "pub fn helper(n: Int) Int { n }\n")
+	open(main, "use a/util\nuse b/util as other\n\nfn main() Int {\n\tutil:helper(1) + other:helper(2)\n}\n")
+
+	var edit WorkspaceEdit
+	request(t, handleRename, RenameParams{TextDocumentPositionParams: positionParams(main, 4, 8), NewName: "assist"}, &edit)
+	if got := editStarts(edit.Changes[a]); len(got) != 1 {
+		t.Fatalf("expected 1 edit in a/util, got %+v", got)
+	}
+	if got := edit.Changes[b]; len(got) != 0 {
+		t.Fatalf("expected b/util to be left alone, got %+v", got)
+	}
+	got := editStarts(edit.Changes[main])
+	if len(got) != 1 || got[0] != (Position{Line: 4, Character: 6}) {
+		t.Fatalf("expected only util:helper to be renamed in main, got %+v", got)
+	}
+}
+
+func TestPrepareRename(t *testing.T) {
+	resetState()
+	uri := "file:///prep.flint"
+	open(uri, "use flint/io\n\nfn main() Nil {\n\tval greeting = \"hi\"\n\tio:println(greeting)\n}\n")
+
+	var res *PrepareRenameResult
+	request(t, handlePrepareRename, positionParams(uri, 4, 13), &res)
+	if res == nil || res.Placeholder != "greeting" || res.Range.Start != (Position{Line: 4, Character: 12}) {
+		t.Fatalf("unexpected prepareRename result %+v", res)
+	}
+	for _, pos := range []Position{{Line: 4, Character: 1}, {Line: 4, Character: 5}} {
+		res = nil
+		request(t, handlePrepareRename, positionParams(uri, pos.Line, pos.Character), &res)
+		if res != nil {
+			t.Fatalf("expected %+v not to be renamable, got %+v", pos, res)
+		}
+	}
+
+	id := json.RawMessage(`4`)
+	data, _ := json.Marshal(RenameParams{TextDocumentPositionParams: positionParams(uri, 3, 5), NewName: "fn"})
+	out := captureStdout(func() { handleRename(RequestMessage{ID: &id, Params: data}) })
+	if !strings.Contains(out, `"code":-32602`) {
+		t.Fatalf("expected an error renaming to a keyword, got %s", out)
+	}
+}
+
+func codeActions(t *testing.T, uri string) []CodeAction {
+	t.Helper()
+	a := analyses[uri]
+	var diags []Diagnostic
+	for _, d := range a.diags {
+		diags = append(diags, toDiagnostic(a.text, d))
+	}
+	var actions []CodeAction
+	request(t, handleCodeAction, CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Context:      CodeActionContext{Diagnostics: diags},
+	}, &actions)
+	return actions
+}
+
+func TestCodeActions(t *testing.T) {
+	tests := []struct {
+		name, text, title string
+		want              TextEdit
+	}{
+		{
+			"val to mut",
+			"fn main() Int {\n\tval count = 1\n\tcount = 2\n}\n",
+			"Change 'val' to 'mut'",
+			TextEdit{Range: Range{Start: Position{Line: 1, Character: 1}, End: Position{Line: 1, Character: 4}}, NewText: "mut"},
+		},
+		{
+			"import module",
+			"fn main() Nil {\n\tio:println(\"hi\")\n}\n",
+			"Import flint/io",
+			TextEdit{NewText: "use flint/io\n"},
+		},
+		{
+			"annotate parameter",
+			"@external(c, \"flint_stdlib\", \"print\")\nfn print(s) Nil\n\nfn main() Nil {\n\tprint(\"hi\")\n}\n",
+			"Add missing type annotation ': String'",
+			TextEdit{Range: Range{Start: Position{Line: 1, Character: 10}, End: Position{Line: 1, Character: 10}}, NewText: ": String"},
+		},
+	}
+	for _, tt := range tests {
+		resetState()
+		uri := "file:///fix.flint"
+		open(uri, tt.text)
+		actions := codeActions(t, uri)
+		if len(actions) != 1 || actions[0].Title != tt.title || actions[0].Kind != "quickfix" {
+			t.Fatalf("%s: unexpected actions %+v", tt.name, actions)
+		}
+		edits := actions[0].Edit.Changes[uri]
+		if len(edits) != 1 || edits[0] != tt.want {
+			t.Fatalf("%s: got edits %+v, want %+v", tt.name, edits, tt.want)
+		}
+	}
+}
+
+func TestDocumentAppliesRangeEdits(t *testing.T) {
+	doc := newDocument("fn main() Int {\n\tval é = 1\n\té\n}")
+	doc.apply(&Range{Start: Position{Line: 1, Character: 9}, End: Position{Line: 1, Character: 10}}, "42")
+	doc.apply(&Range{Start: Position{Line: 2, Character: 2}, End: Position{Line: 2, Character: 2}}, " + 1\n\t// done")
+	doc.apply(&Range{Start: Position{Line: 0, Character: 15}, End: Position{Line: 1, Character: 0}}, " ")
+	want := "fn main() Int { \tval é = 42\n\té + 1\n\t// done\n}"
+	if got := doc.text(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	doc.apply(nil, "replaced")
+	if doc.text() != "replaced" || doc.version != 4 {
+		t.Fatalf("unexpected document %q at version %d", doc.text(), doc.version)
+	}
+}
+
+func TestIncrementalChangesAreAnalysedOnce(t *testing.T) {
+	resetState()
+	debounce = 20 * time.Millisecond
+	uri := "file:///incremental.flint"
+	open(uri, "fn main() Int {\n\t1\n}\n")
+
+	out := captureStdout(func() {
+		// Each change replaces the expression on the second line.
+		for i, text := range []string{"x", "xy", "xyz"} {
+			data, _ := json.Marshal(DidChangeTextDocumentParams{
+				TextDocument: VersionedTextDocumentIdentifier{URI: uri},
+				ContentChanges: []TextDocumentContentChange{
+					{Range: &Range{Start: Position{Line: 1, Character: 1}, End: Position{Line: 1, Character: 1 + max(i, 1)}}, Text: text},
+				},
+			})
+			handleDidChange(data)
+		}
+		pending.Wait()
+	})
+	if n := strings.Count(out, "publishDiagnostics"); n != 1 {
+		t.Fatalf("expected one analysis after the burst of changes, got %d:\n%s", n, out)
+	}
+	if !strings.Contains(out, "undefined variable: 'xyz'") {
+		t.Fatalf("analysis did not see the last change:\n%s", out)
+	}
+}
+
+func TestCancelledRequestsReplyWithAnError(t *testing.T) {
+	resetState()
+	id := json.RawMessage(`7`)
+	startRequest(&id)
+	cancelRequest(id)
+	out := captureStdout(func() {
+		serve(handleHover, RequestMessage{Jsonrpc: "2.0", ID: &id, Params: json.RawMessage(`{}`)})
+	})
+	if !strings.Contains(out, `"code":-32800`) {
+		t.Fatalf("expected RequestCancelled, got %s", out)
+	}
+	if len(requests) != 0 {
+		t.Fatalf("request was not forgotten: %v", requests)
+	}
+}
+
+func TestFormattingReplacesTheDocument(t *testing.T) {
+	resetState()
+	uri := "file:///fmt.flint"
+	open(uri, "fn main() Int {\n  val x = 1\n  x+1\n}")
+
+	var edits []TextEdit
+	request(t, handleFormatting, DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &edits)
+	want := TextEdit{
+		Range:   Range{End: Position{Line: 3, Character: 1}},
+		NewText: "fn main() Int {\n    val x = 1\n    x + 1\n}\n",
+	}
+	if len(edits) != 1 || edits[0] != want {
+		t.Fatalf("unexpected edits %+v", edits)
+	}
+
+	open(uri, want.NewText)
+	edits = nil
+	request(t, handleFormatting, DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &edits)
+	if edits == nil || len(edits) != 0 {
+		t.Fatalf("expected no edits for a formatted document, got %+v", edits)
+	}
+
+	open(uri, "fn main( {")
+	id := json.RawMessage(`4`)
+	data, _ := json.Marshal(DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	out := captureStdout(func() { handleFormatting(RequestMessage{ID: &id, Params: data}) })
+	if !strings.Contains(out, `"result":null`) {
+		t.Fatalf("expected no result for a document that does not parse, got %s", out)
+	}
+}
+
+func TestDocCommentsShowInHoverAndCompletion(t *testing.T) {
+	resetState()
+	uri := "file:///docs.flint"
+	open(uri, `/// Adds two numbers.
+pub fn add(x: Int, y: Int) Int { x + y }
+
+fn main() Int {
+	ad
+}
+`)
+
+	h := hover(t, uri, 1, 8)
+	if h == nil || !strings.HasSuffix(h.Contents.Value, "```\n\nAdds two numbers.") {
+		t.Fatalf("unexpected hover for add: %+v", h)
+	}
+
+	var items []CompletionItem
+	request(t, handleCompletion, CompletionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: 4, Character: 3},
+	}, &items)
+	for _, it := range items {
+		if it.Label == "add" {
+			if it.Detail != "fn add(x: Int, y: Int) Int" || it.Documentation != "Adds two numbers." {
+				t.Fatalf("unexpected completion for add: %+v", it)
+			}
+			return
+		}
+	}
+	t.Fatalf("expected a completion for add, got %+v", items)
+}
+
+func TestHoverShowsBuiltinDocs(t *testing.T) {
+	resetState()
+	uri := "file:///builtin.flint"
+	open(uri, `use flint/io
+
+fn main() Nil {
+	io:println("hi")
+}
+`)
+
+	h := hover(t, uri, 3, 5)
+	want := "```flint\nprintln: (String) -> Nil\n```\n\nWrites s and a newline to standard output."
+	if h == nil || h.Contents.Value != want {
+		t.Fatalf("unexpected hover for io:println: %+v", h)
+	}
+}
+
+func TestSourceModulesResolveNextToTheDocument(t *testing.T) {
+	resetState()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "models.flint"), []byte("/// The answer.\npub fn answer() Int { 42 }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uri := "file://" + filepath.ToSlash(filepath.Join(dir, "main.flint"))
+	open(uri, `use models
+
+fn main() Int { models:answer() }
+`)
+
+	a := analysisOf(uri)
+	if len(a.diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", a.diags)
+	}
+	h := hover(t, uri, 2, 24)
+	if h == nil || h.Contents.Value != "```flint\nanswer: () -> Int\n```\n\nThe answer." {
+		t.Fatalf("unexpected hover for answer: %+v", h)
+	}
+}
@@ -0,0 +1,99 @@
+package lsp
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// symbolAt finds the symbol whose declaration or use is at pos.
+func symbolAt(uri string, pos Position) (*analysis, occurrence, bool) {
+	a := analysisOf(uri)
+	if a == nil {
+		return nil, occurrence{}, false
+	}
+	line, col := fromPosition(strings.Split(a.text, "\n"), pos)
+	o, ok := a.index.at(line, col)
+	return a, o, ok
+}
+
+func handleDefinition(req RequestMessage) {
+	var params TextDocumentPositionParams
+	json.Unmarshal(req.Params, &params)
+
+	var result *Location
+	if a, o, ok := symbolAt(params.TextDocument.URI, params.Position); ok {
+		lines := strings.Split(a.text, "\n")
+		result = &Location{URI: params.TextDocument.URI, Range: *toRange(lines, o.sym.Pos)}
+	}
+
+	reply(ResponseMessage{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result:  result,
+	})
+}
+
+func handleReferences(req RequestMessage) {
+	var params ReferenceParams
+	json.Unmarshal(req.Params, &params)
+
+	locations := []Location{}
+	if a, o, ok := symbolAt(params.TextDocument.URI, params.Position); ok {
+		lines := strings.Split(a.text, "\n")
+		for _, tok := range a.index.refs(o.sym, params.Context.IncludeDeclaration) {
+			locations = append(locations, Location{URI: params.TextDocument.URI, Range: *toRange(lines, tok)})
+		}
+	}
+
+	reply(ResponseMessage{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result:  locations,
+	})
+}
+
+func handleDocumentSymbol(req RequestMessage) {
+	var params DocumentSymbolParams
+	json.Unmarshal(req.Params, &params)
+
+	result := []DocumentSymbol{}
+	if a := analysisOf(params.TextDocument.URI); a != nil {
+		result = documentSymbols(strings.Split(a.text, "\n"), a.index.symbols)
+	}
+
+	reply(ResponseMessage{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result:  result,
+	})
+}
+
+func documentSymbols(lines []string, syms []*Symbol) []DocumentSymbol {
+	out := []DocumentSymbol{}
+	for _, s := range syms {
+		r := *toRange(lines, s.Pos)
+		ds := DocumentSymbol{Name: s.Name, Kind: protocolKind(s.Kind), Range: r, SelectionRange: r}
+		if len(s.Children) > 0 {
+			ds.Children = documentSymbols(lines, s.Children)
+		}
+		out = append(out, ds)
+	}
+	return out
+}
+
+// protocolKind maps a symbol kind to the protocol's SymbolKind.
+func protocolKind(k SymbolKind) int {
+	switch k {
+	case FunctionSymbol:
+		return 12
+	case ParameterSymbol, VariableSymbol:
+		return 13
+	case TypeSymbol:
+		return 5
+	case ConstructorSymbol:
+		return 22
+	case ModuleSymbol:
+		return 2
+	}
+	return 13
+}
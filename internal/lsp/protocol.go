@@ -21,6 +21,12 @@ type ResponseError struct {
 	Message string `json:"message"`
 }
 
+const (
+	MethodNotFound   = -32601
+	InvalidParams    = -32602
+	RequestCancelled = -32800
+)
+
 type InitializeParams struct {
 	Capabilities any `json:"capabilities"`
 }
@@ -32,6 +38,20 @@ type InitializeResult struct {
 type ServerCapabilities struct {
 	TextDocumentSync   int                `json:"textDocumentSync"`
 	CompletionProvider *CompletionOptions `json:"completionProvider,omitempty"`
+	HoverProvider      bool               `json:"hoverProvider,omitempty"`
+
+	DefinitionProvider     bool `json:"definitionProvider,omitempty"`
+	ReferencesProvider     bool `json:"referencesProvider,omitempty"`
+	DocumentSymbolProvider bool `json:"documentSymbolProvider,omitempty"`
+
+	RenameProvider     *RenameOptions `json:"renameProvider,omitempty"`
+	CodeActionProvider bool           `json:"codeActionProvider,omitempty"`
+
+	DocumentFormattingProvider bool `json:"documentFormattingProvider,omitempty"`
+}
+
+type RenameOptions struct {
+	PrepareProvider bool `json:"prepareProvider"`
 }
 
 type DidOpenTextDocumentParams struct {
@@ -55,8 +75,19 @@ type VersionedTextDocumentIdentifier struct {
 	Version int    `json:"version"`
 }
 
+// TextDocumentContentChange replaces the text in Range with Text, or the
+// whole document if Range is nil.
 type TextDocumentContentChange struct {
-	Text string `json:"text"`
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type CancelParams struct {
+	ID json.RawMessage `json:"id"`
 }
 
 type PublishDiagnosticsParams struct {
@@ -67,6 +98,7 @@ type PublishDiagnosticsParams struct {
 type Diagnostic struct {
 	Range    Range  `json:"range"`
 	Severity int    `json:"severity"`
+	Source   string `json:"source,omitempty"`
 	Message  string `json:"message"`
 }
 
@@ -100,3 +132,90 @@ type CompletionOptions struct {
 	ResolveProvider   bool     `json:"resolveProvider"`
 	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
 }
+
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+type ReferenceParams struct {
+	TextDocumentPositionParams
+	Context ReferenceContext `json:"context"`
+}
+
+type ReferenceContext struct {
+	IncludeDeclaration bool `json:"includeDeclaration"`
+}
+
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Kind           int              `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+type RenameParams struct {
+	TextDocumentPositionParams
+	NewName string `json:"newName"`
+}
+
+type PrepareRenameResult struct {
+	Range       Range  `json:"range"`
+	Placeholder string `json:"placeholder"`
+}
+
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+type WorkspaceEdit struct {
+	Changes map[string][]TextEdit `json:"changes"`
+}
+
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      CodeActionContext      `json:"context"`
+}
+
+type DocumentFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Options      FormattingOptions      `json:"options"`
+}
+
+type FormattingOptions struct {
+	TabSize      int  `json:"tabSize"`
+	InsertSpaces bool `json:"insertSpaces"`
+}
+
+type CodeActionContext struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+type CodeAction struct {
+	Title       string         `json:"title"`
+	Kind        string         `json:"kind,omitempty"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	Edit        *WorkspaceEdit `json:"edit,omitempty"`
+}
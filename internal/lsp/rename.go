@@ -0,0 +1,180 @@
+package lsp
+
+import (
+	"encoding/json"
+	"flint/internal/lexer"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A renameTarget is the name being renamed. Top-level names can also be
+// used from other documents, through `use`; module is then the document
+// defining the name.
+type renameTarget struct {
+	name   string
+	uri    string
+	sym    *Symbol
+	module string
+}
+
+// moduleDocument returns the open document that the document uri imports
+// as modulePath, found as the loader would under the roots of uri, or ""
+// if that module is not open.
+func moduleDocument(uri, modulePath string) string {
+	from, ok := filePath(uri)
+	if !ok {
+		return ""
+	}
+	for _, root := range rootsOf(from) {
+		file := filepath.Join(root, filepath.FromSlash(modulePath)+".flint")
+		for doc := range analyses {
+			if p, ok := filePath(doc); ok && p == file {
+				return doc
+			}
+		}
+		if _, err := os.Stat(file); err == nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+// topLevel returns the top-level symbol called name in a document.
+func topLevel(a *analysis, name string) *Symbol {
+	for _, s := range a.index.symbols {
+		if s.Name == name && s.Kind != ModuleSymbol && s.Import == "" {
+			return s
+		}
+	}
+	return nil
+}
+
+// defined reports whether the open document module defines name.
+func defined(module, name string) bool {
+	a := analyses[module]
+	return a != nil && topLevel(a, name) != nil
+}
+
+// findRenameTarget returns the name at pos and its token, if it is one
+// that can be renamed. Modules and the members of modules that are not
+// open cannot be.
+func findRenameTarget(uri string, pos Position) (renameTarget, lexer.Token, bool) {
+	a := analyses[uri]
+	if a == nil {
+		return renameTarget{}, lexer.Token{}, false
+	}
+	line, col := fromPosition(strings.Split(a.text, "\n"), pos)
+	if o, ok := a.index.at(line, col); ok {
+		sym := o.sym
+		t := renameTarget{name: sym.Name, uri: uri, sym: sym}
+		switch {
+		case sym.Kind == ModuleSymbol:
+			return renameTarget{}, lexer.Token{}, false
+		case sym.Import != "":
+			t.module = moduleDocument(uri, sym.Import)
+			if !defined(t.module, sym.Name) {
+				return renameTarget{}, lexer.Token{}, false
+			}
+		case slices.Contains(a.index.symbols, sym):
+			t.module = uri
+		}
+		return t, o.tok, true
+	}
+	for _, m := range a.index.members {
+		s := m.tok.Span()
+		if s.Line != line || col < s.Column || col >= s.EndColumn {
+			continue
+		}
+		module := moduleDocument(uri, m.module)
+		if !defined(module, m.tok.Lexeme) {
+			break
+		}
+		return renameTarget{name: m.tok.Lexeme, module: module}, m.tok, true
+	}
+	return renameTarget{}, lexer.Token{}, false
+}
+
+// renameEdits returns the edits that rename t to newName in every open
+// document. It and findRenameTarget are called with mu held.
+func renameEdits(t renameTarget, newName string) map[string][]TextEdit {
+	toks := map[string][]lexer.Token{}
+	if t.sym != nil {
+		toks[t.uri] = analyses[t.uri].index.refs(t.sym, true)
+	}
+	if t.module != "" {
+		for uri, a := range analyses {
+			if uri == t.module {
+				if s := topLevel(a, t.name); s != nil && s != t.sym {
+					toks[uri] = append(toks[uri], a.index.refs(s, true)...)
+				}
+			}
+			for _, m := range a.index.members {
+				if m.tok.Lexeme == t.name && moduleDocument(uri, m.module) == t.module {
+					toks[uri] = append(toks[uri], m.tok)
+				}
+			}
+			for _, o := range a.index.occurrences {
+				if o.sym != t.sym && o.sym.Name == t.name && o.sym.Import != "" && moduleDocument(uri, o.sym.Import) == t.module {
+					toks[uri] = append(toks[uri], o.tok)
+				}
+			}
+		}
+	}
+
+	changes := map[string][]TextEdit{}
+	for uri, ts := range toks {
+		slices.SortFunc(ts, comparePos)
+		ts = slices.CompactFunc(ts, samePos)
+		lines := strings.Split(analyses[uri].text, "\n")
+		for _, tok := range ts {
+			changes[uri] = append(changes[uri], TextEdit{Range: *toRange(lines, tok), NewText: newName})
+		}
+	}
+	return changes
+}
+
+// isIdentifier reports whether name can be used as the name of a variable.
+func isIdentifier(name string) bool {
+	tokens, err := lexer.Tokenize(name, "")
+	return err == nil && len(tokens) == 2 && tokens[0].Kind == lexer.Identifier
+}
+
+func handlePrepareRename(req RequestMessage) {
+	var params TextDocumentPositionParams
+	json.Unmarshal(req.Params, &params)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var result *PrepareRenameResult
+	if _, tok, ok := findRenameTarget(params.TextDocument.URI, params.Position); ok {
+		lines := strings.Split(analyses[params.TextDocument.URI].text, "\n")
+		result = &PrepareRenameResult{Range: *toRange(lines, tok), Placeholder: tok.Lexeme}
+	}
+
+	reply(ResponseMessage{
+		Jsonrpc: "2.0",
+		ID:      req.ID,
+		Result:  result,
+	})
+}
+
+func handleRename(req RequestMessage) {
+	var params RenameParams
+	json.Unmarshal(req.Params, &params)
+	mu.RLock()
+	defer mu.RUnlock()
+
+	resp := ResponseMessage{Jsonrpc: "2.0", ID: req.ID}
+	t, _, ok := findRenameTarget(params.TextDocument.URI, params.Position)
+	switch {
+	case !ok:
+		resp.Error = &ResponseError{Code: InvalidParams, Message: "nothing to rename here"}
+	case !isIdentifier(params.NewName):
+		resp.Error = &ResponseError{Code: InvalidParams, Message: "'" + params.NewName + "' is not a valid name"}
+	default:
+		resp.Result = WorkspaceEdit{Changes: renameEdits(t, params.NewName)}
+	}
+	reply(resp)
+}
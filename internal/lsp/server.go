@@ -4,8 +4,29 @@ import (
 	"bufio"
 	"encoding/json"
 	"os"
+	"sync"
 )
 
+// handlers serve requests, each on its own goroutine.
+var handlers = map[string]func(RequestMessage){
+	"textDocument/completion":     handleCompletion,
+	"textDocument/hover":          handleHover,
+	"textDocument/definition":     handleDefinition,
+	"textDocument/references":     handleReferences,
+	"textDocument/documentSymbol": handleDocumentSymbol,
+	"textDocument/prepareRename":  handlePrepareRename,
+	"textDocument/rename":         handleRename,
+	"textDocument/codeAction":     handleCodeAction,
+	"textDocument/formatting":     handleFormatting,
+}
+
+// serving counts the requests being served, which shutdown waits for.
+var serving sync.WaitGroup
+
+// StartLsp serves the client on stdin and stdout. Notifications are handled
+// in the order they arrive, so that changes apply in order; requests are
+// served concurrently and see the documents as of when they arrived or
+// later.
 func StartLsp() {
 	reader := bufio.NewReader(os.Stdin)
 
@@ -27,12 +48,44 @@ func StartLsp() {
 			handleDidOpen(req.Params)
 		case "textDocument/didChange":
 			handleDidChange(req.Params)
-		case "textDocument/completion":
-			handleCompletion(req)
+		case "textDocument/didClose":
+			handleDidClose(req.Params)
+		case "$/cancelRequest":
+			var p CancelParams
+			json.Unmarshal(req.Params, &p)
+			cancelRequest(p.ID)
 		case "shutdown":
+			serving.Wait()
 			handleShutdown(req)
 		case "exit":
 			os.Exit(0)
+		default:
+			handler, ok := handlers[req.Method]
+			if !ok || req.ID == nil {
+				if req.ID != nil {
+					reply(ResponseMessage{
+						Jsonrpc: "2.0",
+						ID:      req.ID,
+						Error:   &ResponseError{Code: MethodNotFound, Message: "unsupported method " + req.Method},
+					})
+				}
+				continue
+			}
+			startRequest(req.ID)
+			serving.Add(1)
+			go func() {
+				defer serving.Done()
+				serve(handler, req)
+			}()
 		}
 	}
 }
+
+// serve runs handler unless the request was cancelled while it waited.
+func serve(handler func(RequestMessage), req RequestMessage) {
+	if cancelled(req.ID) {
+		reply(ResponseMessage{Jsonrpc: "2.0", ID: req.ID})
+		return
+	}
+	handler(req)
+}
@@ -1,3 +1,19 @@
 package lsp
 
-var docs = map[string]string{}
+import "sync"
+
+// mu guards the open documents and what is known about them. Requests are
+// served concurrently, with each other and with analysis.
+var mu sync.RWMutex
+
+var docs = map[string]*document{}
+
+// analyses holds the last analysis of each document.
+var analyses = map[string]*analysis{}
+
+// analysisOf returns the last analysis of a document, or nil.
+func analysisOf(uri string) *analysis {
+	mu.RLock()
+	defer mu.RUnlock()
+	return analyses[uri]
+}
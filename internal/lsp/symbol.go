@@ -1,7 +1,10 @@
 package lsp
 
 import (
-	"regexp"
+	"cmp"
+	"flint/internal/lexer"
+	"flint/internal/parser"
+	"slices"
 	"strings"
 )
 
@@ -10,35 +13,458 @@ type SymbolKind int
 const (
 	FunctionSymbol SymbolKind = iota + 1
 	VariableSymbol
+	ParameterSymbol
+	TypeSymbol
+	ConstructorSymbol
+	ModuleSymbol
 )
 
+// A Symbol is a name declared in a document. Children holds the symbols
+// declared inside it that the document outline shows: the locals and
+// nested functions of a function, and the constructors of a type.
 type Symbol struct {
-	Name string
-	Kind SymbolKind
+	Name     string
+	Kind     SymbolKind
+	Pos      lexer.Token
+	Children []*Symbol
+
+	// Import is the path of the module that a name brought in by `use`
+	// comes from.
+	Import string
+
+	// Doc is the doc comment of a function or type.
+	Doc string
 }
 
-var symbols = map[string][]Symbol{}
+// An occurrence is a declaration of a symbol or a use of it.
+type occurrence struct {
+	tok lexer.Token
+	sym *Symbol
+}
 
-var (
-	fnRegex  = regexp.MustCompile(`^(?:pub\s+)?fn\s+([A-Za-z_][A-Za-z0-9_]*)\s*\(`)
-	varRegex = regexp.MustCompile(`^(?:val|mut)\s+([A-Za-z_][A-Za-z0-9_]*)`)
-)
+// index is what the language server knows about the names in a document.
+// It is built from the syntax tree alone, so it is available even when the
+// document does not typecheck.
+type index struct {
+	symbols     []*Symbol
+	occurrences []occurrence
+
+	// members holds the uses of names qualified by a module, `io:println`,
+	// which are declared in another document if at all.
+	members []member
+}
 
-func updateSymbols(uri, text string) {
+type member struct {
+	module string
+	tok    lexer.Token
+}
+
+var symbols = map[string][]Symbol{}
+
+// updateSymbols records the top-level symbols of a document for completion.
+func updateSymbols(uri string, idx *index) {
 	syms := []Symbol{}
-	lines := strings.SplitSeq(text, "\n")
+	for _, s := range idx.symbols {
+		syms = append(syms, *s)
+	}
+	symbols[uri] = syms
+}
+
+// at returns the occurrence covering a 1-based line and column.
+func (idx *index) at(line, col int) (occurrence, bool) {
+	for _, o := range idx.occurrences {
+		s := o.tok.Span()
+		if s.Line == line && col >= s.Column && col < s.EndColumn {
+			return o, true
+		}
+	}
+	return occurrence{}, false
+}
 
-	for line := range lines {
-		line = strings.TrimSpace(line)
-		if fnMatch := fnRegex.FindStringSubmatch(line); fnMatch != nil {
-			syms = append(syms, Symbol{Name: fnMatch[1], Kind: FunctionSymbol})
+// refs returns the occurrences of sym in source order, with or without its
+// declaration.
+func (idx *index) refs(sym *Symbol, withDecl bool) []lexer.Token {
+	var out []lexer.Token
+	for _, o := range idx.occurrences {
+		if o.sym != sym || (!withDecl && samePos(o.tok, sym.Pos)) {
 			continue
 		}
+		out = append(out, o.tok)
+	}
+	return out
+}
+
+func samePos(a, b lexer.Token) bool {
+	return a.Line == b.Line && a.Column == b.Column
+}
 
-		if varMatch := varRegex.FindStringSubmatch(line); varMatch != nil {
-			syms = append(syms, Symbol{Name: varMatch[1], Kind: VariableSymbol})
+// scope maps names to symbols. Types and constructors have separate
+// namespaces, as a record type and its constructor share a name.
+type scope struct {
+	names  map[string]*Symbol
+	types  map[string]*Symbol
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{names: map[string]*Symbol{}, types: map[string]*Symbol{}, parent: parent}
+}
+
+func (s *scope) lookup(name string, types bool) *Symbol {
+	for ; s != nil; s = s.parent {
+		m := s.names
+		if types {
+			m = s.types
+		}
+		if sym, ok := m[name]; ok {
+			return sym
 		}
 	}
+	return nil
+}
 
-	symbols[uri] = syms
+// indexer resolves every name in a program to its declaration, following
+// the scoping rules of the typechecker: a block, function, lambda or match
+// arm opens a scope, and a declaration is visible from the expression after
+// it. Top-level functions, types and imports are visible everywhere.
+type indexer struct {
+	idx    *index
+	scope  *scope
+	parent *Symbol
+}
+
+func buildIndex(prog *parser.Program) *index {
+	ix := &indexer{idx: &index{}, scope: newScope(nil)}
+	if prog == nil {
+		return ix.idx
+	}
+	for _, e := range prog.Exprs {
+		ix.hoist(e)
+	}
+	for _, e := range prog.Exprs {
+		ix.expr(e)
+	}
+	slices.SortStableFunc(ix.idx.symbols, func(a, b *Symbol) int { return comparePos(a.Pos, b.Pos) })
+	slices.SortStableFunc(ix.idx.occurrences, func(a, b occurrence) int { return comparePos(a.tok, b.tok) })
+	return ix.idx
+}
+
+func comparePos(a, b lexer.Token) int {
+	return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+}
+
+// hoist declares the top-level names of e ahead of the walk so that
+// functions can refer to those declared later in the file.
+func (ix *indexer) hoist(e parser.Expr) {
+	switch n := e.(type) {
+	case *parser.FuncDeclExpr:
+		ix.declare(n.Name, FunctionSymbol, false).Doc = n.Doc
+	case *parser.TypeDeclExpr:
+		t := ix.declare(n.Name, TypeSymbol, true)
+		t.Doc = n.Doc
+		outer := ix.parent
+		ix.parent = t
+		switch body := n.Body.(type) {
+		case *parser.VariantTypeExpr:
+			for _, v := range body.Variants {
+				ix.declare(v.Name, ConstructorSymbol, false)
+			}
+		}
+		ix.parent = outer
+	case *parser.UseExpr:
+		ix.imports(n)
+	}
+}
+
+// imports declares the names brought into scope by u.
+func (ix *indexer) imports(u *parser.UseExpr) {
+	kind := ModuleSymbol
+	if len(u.Members) > 0 {
+		kind = FunctionSymbol
+	}
+	for _, tok := range u.Names {
+		if ix.declared(tok, false) == nil {
+			ix.declare(tok, kind, false).Import = strings.Join(u.Path, "/")
+		}
+	}
+}
+
+func (ix *indexer) declare(tok lexer.Token, kind SymbolKind, isType bool) *Symbol {
+	sym := &Symbol{Name: tok.Lexeme, Kind: kind, Pos: tok}
+	if isType {
+		ix.scope.types[tok.Lexeme] = sym
+	} else {
+		ix.scope.names[tok.Lexeme] = sym
+	}
+	ix.idx.occurrences = append(ix.idx.occurrences, occurrence{tok, sym})
+	if kind == ParameterSymbol {
+		return sym
+	}
+	if ix.parent == nil {
+		ix.idx.symbols = append(ix.idx.symbols, sym)
+	} else {
+		ix.parent.Children = append(ix.parent.Children, sym)
+	}
+	return sym
+}
+
+// declared returns the symbol hoisted for tok at the top level, if any.
+func (ix *indexer) declared(tok lexer.Token, isType bool) *Symbol {
+	if ix.scope.parent != nil {
+		return nil
+	}
+	sym := ix.scope.lookup(tok.Lexeme, isType)
+	if sym == nil || !samePos(sym.Pos, tok) {
+		return nil
+	}
+	return sym
+}
+
+func (ix *indexer) use(tok lexer.Token, name string, isType bool) {
+	if sym := ix.scope.lookup(name, isType); sym != nil {
+		ix.idx.occurrences = append(ix.idx.occurrences, occurrence{tok, sym})
+	}
+}
+
+func (ix *indexer) push() func() {
+	outer := ix.scope
+	ix.scope = newScope(outer)
+	return func() { ix.scope = outer }
+}
+
+func (ix *indexer) expr(e parser.Expr) {
+	switch n := e.(type) {
+	case *parser.Identifier:
+		ix.use(n.Pos, n.Name, false)
+	case *parser.PrefixExpr:
+		ix.expr(n.Right)
+	case *parser.InfixExpr:
+		ix.expr(n.Left)
+		ix.expr(n.Right)
+	case *parser.CallExpr:
+		ix.expr(n.Callee)
+		for _, a := range n.Args {
+			ix.expr(a)
+		}
+	case *parser.VarDeclExpr:
+		ix.expr(n.Value)
+		ix.typeExpr(n.Type)
+		if n.Pattern != nil {
+			ix.pattern(n.Pattern, VariableSymbol)
+		} else {
+			ix.declare(n.Name, VariableSymbol, false)
+		}
+	case *parser.FuncDeclExpr:
+		fn := ix.declared(n.Name, false)
+		if fn == nil {
+			fn = ix.declare(n.Name, FunctionSymbol, false)
+			fn.Doc = n.Doc
+		}
+		outer := ix.parent
+		ix.parent = fn
+		pop := ix.push()
+		ix.params(n.Params, n.Ret)
+		ix.expr(n.Body)
+		pop()
+		ix.parent = outer
+	case *parser.LambdaExpr:
+		pop := ix.push()
+		ix.params(n.Params, n.Ret)
+		ix.expr(n.Body)
+		pop()
+	case *parser.BlockExpr:
+		pop := ix.push()
+		for _, x := range n.Exprs {
+			ix.expr(x)
+		}
+		pop()
+	case *parser.UseExpr:
+		ix.imports(n)
+	case *parser.QualifiedExpr:
+		ix.expr(n.Left)
+		if id, ok := n.Left.(*parser.Identifier); ok {
+			if mod := ix.scope.lookup(id.Name, false); mod != nil && mod.Kind == ModuleSymbol {
+				ix.idx.members = append(ix.idx.members, member{mod.Import, n.Right})
+			}
+		}
+	case *parser.FieldAccessExpr:
+		ix.expr(n.Left)
+	case *parser.IfExpr:
+		ix.expr(n.Cond)
+		ix.expr(n.Then)
+		ix.expr(n.Else)
+	case *parser.ForExpr:
+		ix.expr(n.Iter)
+		// The loop variables are left out of the outline, like those of
+		// match arms.
+		pop := ix.push()
+		ix.pattern(n.Pattern, ParameterSymbol)
+		ix.expr(n.Body)
+		pop()
+	case *parser.MatchExpr:
+		ix.expr(n.Value)
+		for _, arm := range n.Arms {
+			// Like parameters, the variables of an arm are left out of
+			// the outline.
+			pop := ix.push()
+			ix.pattern(arm.Pattern, ParameterSymbol)
+			ix.expr(arm.Guard)
+			ix.expr(arm.Body)
+			pop()
+		}
+	case *parser.PipelineExpr:
+		ix.expr(n.Left)
+		ix.expr(n.Right)
+	case *parser.ListExpr:
+		for _, x := range n.Elements {
+			ix.expr(x)
+		}
+	case *parser.TupleExpr:
+		for _, x := range n.Elements {
+			ix.expr(x)
+		}
+	case *parser.InterpolatedString:
+		for _, x := range n.Parts {
+			ix.expr(x)
+		}
+	case *parser.RecordExpr:
+		if n.Module != nil {
+			ix.expr(n.Module)
+			if mod := ix.scope.lookup(n.Module.Name, false); mod != nil && mod.Kind == ModuleSymbol {
+				ix.idx.members = append(ix.idx.members, member{mod.Import, n.Name})
+			}
+		} else {
+			ix.use(n.Name, n.Name.Lexeme, true)
+		}
+		for _, f := range n.Fields {
+			ix.expr(f.Value)
+		}
+	case *parser.TypeDeclExpr:
+		t := ix.declared(n.Name, true)
+		if t == nil {
+			t = ix.declare(n.Name, TypeSymbol, true)
+			t.Doc = n.Doc
+		}
+		outer := ix.parent
+		ix.parent = t
+		switch body := n.Body.(type) {
+		case *parser.RecordTypeExpr:
+			for _, f := range body.Fields {
+				ix.typeExpr(f.Type)
+			}
+		case *parser.VariantTypeExpr:
+			for _, v := range body.Variants {
+				if ix.declared(v.Name, false) == nil {
+					ix.declare(v.Name, ConstructorSymbol, false)
+				}
+				for _, f := range v.Fields {
+					ix.typeExpr(f)
+				}
+			}
+		}
+		ix.parent = outer
+	case *parser.AssignExpr:
+		ix.expr(n.Name)
+		ix.expr(n.Value)
+	case *parser.IndexExpr:
+		ix.expr(n.Target)
+		ix.expr(n.Index)
+	}
+}
+
+// params declares the parameters of a function in the current scope.
+func (ix *indexer) params(params []parser.Param, ret parser.Expr) {
+	for _, p := range params {
+		ix.typeExpr(p.Type)
+		ix.declare(p.Name, ParameterSymbol, false)
+	}
+	ix.typeExpr(ret)
+}
+
+func (ix *indexer) typeExpr(e parser.Expr) {
+	switch n := e.(type) {
+	case *parser.TypeExpr:
+		ix.use(n.Pos, n.Name, true)
+		ix.typeExpr(n.Generic)
+	case *parser.TupleTypeExpr:
+		for _, t := range n.Types {
+			ix.typeExpr(t)
+		}
+	case *parser.FuncTypeExpr:
+		for _, t := range n.Params {
+			ix.typeExpr(t)
+		}
+		ix.typeExpr(n.Ret)
+	}
+}
+
+// pattern declares the variables bound by p. The alternatives of an
+// or-pattern bind the same names; uses resolve to the first alternative.
+func (ix *indexer) pattern(p parser.Pattern, kind SymbolKind) {
+	switch n := p.(type) {
+	case *parser.BindingPattern:
+		ix.declare(n.Name, kind, false)
+	case *parser.AsPattern:
+		ix.pattern(n.Pattern, kind)
+		ix.declare(n.Name, kind, false)
+	case *parser.LiteralPattern:
+		ix.expr(n.Value)
+	case *parser.RangePattern:
+		ix.expr(n.Low)
+		ix.expr(n.High)
+	case *parser.TuplePattern:
+		for _, el := range n.Elements {
+			ix.pattern(el, kind)
+		}
+	case *parser.ListPattern:
+		for _, el := range n.Elements {
+			ix.pattern(el, kind)
+		}
+		ix.pattern(n.Rest, kind)
+	case *parser.ConstructorPattern:
+		ix.use(n.Name, n.Name.Lexeme, false)
+		for _, a := range n.Args {
+			ix.pattern(a, kind)
+		}
+	case *parser.OrPattern:
+		for i, alt := range n.Alternatives {
+			if i == 0 {
+				ix.pattern(alt, kind)
+				continue
+			}
+			ix.orAlternative(alt)
+		}
+	}
+}
+
+// orAlternative records the names bound by a later alternative of an
+// or-pattern as uses of those bound by the first.
+func (ix *indexer) orAlternative(p parser.Pattern) {
+	switch n := p.(type) {
+	case *parser.BindingPattern:
+		ix.use(n.Name, n.Name.Lexeme, false)
+	case *parser.AsPattern:
+		ix.orAlternative(n.Pattern)
+		ix.use(n.Name, n.Name.Lexeme, false)
+	case *parser.TuplePattern:
+		for _, el := range n.Elements {
+			ix.orAlternative(el)
+		}
+	case *parser.ListPattern:
+		for _, el := range n.Elements {
+			ix.orAlternative(el)
+		}
+		ix.orAlternative(n.Rest)
+	case *parser.ConstructorPattern:
+		ix.use(n.Name, n.Name.Lexeme, false)
+		for _, a := range n.Args {
+			ix.orAlternative(a)
+		}
+	case *parser.OrPattern:
+		for _, alt := range n.Alternatives {
+			ix.orAlternative(alt)
+		}
+	default:
+		ix.pattern(p, ParameterSymbol)
+	}
 }
@@ -0,0 +1,45 @@
+package lsp
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// debounce is how long analysis waits after a change for further changes,
+// so that typing does not queue an analysis per keystroke.
+var debounce = 150 * time.Millisecond
+
+var (
+	// timers holds the analysis scheduled for each document. It is
+	// guarded by mu.
+	timers = map[string]*time.Timer{}
+
+	// workers bounds the number of analyses running at once.
+	workers = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+	// pending counts the analyses scheduled or running.
+	pending sync.WaitGroup
+)
+
+// scheduleAnalysis analyses uri on a worker once delay has passed without
+// another change. It is called with mu held.
+func scheduleAnalysis(uri string, delay time.Duration) {
+	cancelAnalysis(uri)
+	pending.Add(1)
+	timers[uri] = time.AfterFunc(delay, func() {
+		defer pending.Done()
+		workers <- struct{}{}
+		defer func() { <-workers }()
+		runDiagnostics(uri)
+	})
+}
+
+// cancelAnalysis stops the analysis scheduled for uri if it has not
+// started. It is called with mu held.
+func cancelAnalysis(uri string) {
+	if t, ok := timers[uri]; ok && t.Stop() {
+		pending.Done()
+	}
+	delete(timers, uri)
+}
@@ -0,0 +1,170 @@
+// Package module loads a Flint program spread over several source files.
+// `use app/models` names the file app/models.flint under one of the source
+// roots of the project; each such file is parsed and checked once, and only
+// its pub members are visible to the modules that use it. Built-in modules
+// such as flint/io are left to the typechecker.
+package module
+
+import (
+	"errors"
+	"flint/internal/diag"
+	"flint/internal/lexer"
+	"flint/internal/parser"
+	"flint/internal/typechecker"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A Module is one source file of a program.
+type Module struct {
+	// Path is the name other modules use it by, such as "app/models".
+	Path string
+	File string
+
+	Tokens []lexer.Token
+	Prog   *parser.Program
+	// TC is the typechecker that checked the module, or nil if it or a
+	// module it imports could not be checked.
+	TC *typechecker.TypeChecker
+
+	// Imports holds the source modules named by the module's `use`
+	// declarations, by path.
+	Imports map[string]*Module
+}
+
+// Symbol returns the name name, declared at the top level of m, has in
+// generated code. Modules share one namespace once linked, so names are
+// qualified by the module path.
+func (m *Module) Symbol(name string) string {
+	return strings.ReplaceAll(m.Path, "/", ".") + "." + name
+}
+
+// A Loader loads modules relative to the source roots of a project,
+// remembering those it has loaded. The first root holding a module wins.
+type Loader struct {
+	Roots []string
+
+	modules map[string]*Module
+	order   []*Module
+	// loading holds the paths of the modules being loaded, each importing
+	// the next, to detect cycles.
+	loading []string
+	diags   diag.List
+}
+
+func NewLoader(roots ...string) *Loader {
+	return &Loader{Roots: roots, modules: map[string]*Module{}}
+}
+
+// Load loads the module in file, whose source is src, and every module it
+// imports, returning the diagnostics found in any of them.
+func (l *Loader) Load(file, src string) (*Module, diag.List) {
+	path := l.pathOf(file)
+	if m, ok := l.modules[path]; ok {
+		return m, nil
+	}
+	start := len(l.diags)
+	m := l.load(path, file, src)
+	return m, l.diags[start:]
+}
+
+// Modules returns the modules loaded so far, each after those it imports.
+func (l *Loader) Modules() []*Module {
+	return l.order
+}
+
+// pathOf returns the module path of file, its name relative to the root
+// holding it without the extension.
+func (l *Loader) pathOf(file string) string {
+	rel := filepath.Base(file)
+	for _, root := range l.Roots {
+		if r, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(r, "..") {
+			rel = r
+			break
+		}
+	}
+	return filepath.ToSlash(strings.TrimSuffix(rel, ".flint"))
+}
+
+func (l *Loader) load(path, file, src string) *Module {
+	m := &Module{Path: path, File: file, Imports: map[string]*Module{}}
+	l.modules[path] = m
+	tokens, err := lexer.Tokenize(src, file)
+	if lexDiags, ok := err.(diag.List); ok {
+		l.diags = append(l.diags, lexDiags...)
+	}
+	prog, parseDiags := parser.ParseProgram(tokens)
+	l.diags = append(l.diags, parseDiags...)
+	m.Tokens, m.Prog = tokens, prog
+	if parseDiags.HasErrors() {
+		l.order = append(l.order, m)
+		return m
+	}
+
+	l.loading = append(l.loading, path)
+	checked := true
+	for _, e := range prog.Exprs {
+		u, ok := e.(*parser.UseExpr)
+		if !ok {
+			continue
+		}
+		if dep := l.use(u); dep != nil {
+			m.Imports[dep.Path] = dep
+			checked = checked && dep.TC != nil
+		}
+	}
+	l.loading = l.loading[:len(l.loading)-1]
+	if !checked {
+		// A module that is part of a cycle or failed to parse has been
+		// reported already, and leaves nothing to check m against.
+		l.order = append(l.order, m)
+		return m
+	}
+
+	m.TC = typechecker.New()
+	for p, dep := range m.Imports {
+		m.TC.Import(p, dep.TC.Exports(dep.Prog))
+	}
+	for _, e := range prog.Exprs {
+		m.TC.CheckExpr(e)
+	}
+	l.diags = append(l.diags, m.TC.Diagnostics()...)
+	l.order = append(l.order, m)
+	return m
+}
+
+// use loads the source module u names. Modules that are neither built in
+// nor found under a root are left for the typechecker to report.
+func (l *Loader) use(u *parser.UseExpr) *Module {
+	path := strings.Join(u.Path, "/")
+	if typechecker.HasModule(path) {
+		return nil
+	}
+	for i, p := range l.loading {
+		if p == path {
+			cycle := strings.Join(slices.Concat(l.loading[i:], []string{path}), " -> ")
+			l.diags.Add(diag.Diagnostic{Severity: diag.Error, Span: u.Pos.Span(), Message: "import cycle: " + cycle, Source: u.Pos.Source})
+			return l.modules[path]
+		}
+	}
+	if m, ok := l.modules[path]; ok {
+		return m
+	}
+	for _, root := range l.Roots {
+		file := filepath.Join(root, filepath.FromSlash(path)+".flint")
+		src, err := os.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			l.diags.Add(diag.Diagnostic{Severity: diag.Error, Span: u.Pos.Span(), Message: fmt.Sprintf("cannot read module %s: %v", path, err), Source: u.Pos.Source})
+			return nil
+		}
+		return l.load(path, file, string(src))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// project writes files, keyed by their path relative to the root, to a
+// fresh root and loads main.flint from it.
+func project(t *testing.T, files map[string]string) (*Loader, *Module, []string) {
+	t.Helper()
+	root := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := NewLoader(root)
+	m, diags := l.Load(filepath.Join(root, "main.flint"), files["main.flint"])
+	var msgs []string
+	for _, d := range diags {
+		msgs = append(msgs, d.Message)
+	}
+	return l, m, msgs
+}
+
+func TestLoadResolvesModulesUnderTheRoot(t *testing.T) {
+	l, m, errs := project(t, map[string]string{
+		"main.flint": `use app/models
+use app/util.{double}
+
+fn main() Int { double(models:answer()) }
+`,
+		"app/models.flint": `use app/util
+
+pub fn answer() Int { util:double(21) }
+`,
+		"app/util.flint": `pub fn double(x: Int) Int { x * 2 }
+`,
+	})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	var order []string
+	for _, mod := range l.Modules() {
+		order = append(order, mod.Path)
+	}
+	if got := strings.Join(order, " "); got != "app/util app/models main" {
+		t.Fatalf("expected each module after its imports, got %s", got)
+	}
+	if m.Imports["app/util"] != m.Imports["app/models"].Imports["app/util"] {
+		t.Fatalf("expected app/util to be loaded once")
+	}
+	if got := m.Imports["app/models"].Symbol("answer"); got != "app.models.answer" {
+		t.Fatalf("unexpected symbol %s", got)
+	}
+}
+
+func TestOnlyPublicMembersAreExported(t *testing.T) {
+	_, _, errs := project(t, map[string]string{
+		"main.flint": `use models
+use models.{Point}
+
+fn main() Int {
+	val p = Point { x: 1, y: 2 }
+	models:shown(p) + models:hidden()
+}
+`,
+		"models.flint": `pub type Point { x: Int, y: Int }
+
+pub fn shown(p: Point) Int { p.x }
+
+fn hidden() Int { 1 }
+`,
+	})
+	if len(errs) != 1 || errs[0] != "module models has no member hidden" {
+		t.Fatalf("expected only hidden to be missing, got %v", errs)
+	}
+}
+
+func TestRecordLiteralsMayBeQualified(t *testing.T) {
+	_, _, errs := project(t, map[string]string{
+		"main.flint": `use models
+
+fn main() Int {
+	val p = models:Point { x: 1, y: 2 }
+	models:shown(p)
+}
+
+fn secret() Int { models:Secret { key: 3 }.key }
+`,
+		"models.flint": `pub type Point { x: Int, y: Int }
+
+type Secret { key: Int }
+
+pub fn shown(p: Point) Int { p.x }
+`,
+	})
+	if len(errs) != 1 || errs[0] != "module models has no type Secret" {
+		t.Fatalf("expected only Secret to be missing, got %v", errs)
+	}
+}
+
+func TestImportCyclesAreReported(t *testing.T) {
+	_, m, errs := project(t, map[string]string{
+		"main.flint": `use a
+fn main() Int { 0 }
+`,
+		"a.flint": `use b
+`,
+		"b.flint": `use a
+`,
+	})
+	if len(errs) != 1 || errs[0] != "import cycle: a -> b -> a" {
+		t.Fatalf("expected the cycle to be reported once, got %v", errs)
+	}
+	if m.TC != nil {
+		t.Fatalf("expected a module importing a cycle not to be checked")
+	}
+}
+
+func TestMissingModulesAreLeftToTheTypechecker(t *testing.T) {
+	_, _, errs := project(t, map[string]string{
+		"main.flint": `use app/nowhere
+use flint/io
+`,
+	})
+	if len(errs) != 1 || errs[0] != "cannot find module app/nowhere" {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
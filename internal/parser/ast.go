@@ -1,11 +1,34 @@
 package parser
 
 import (
+	"flint/internal/diag"
 	"flint/internal/lexer"
 )
 
 type Node interface {
 	NodeType() string
+
+	// Tokens returns the first and last token the node was parsed from.
+	Tokens() (first, last lexer.Token)
+	Span() diag.Span
+}
+
+// A Range is embedded in every node to record the tokens it covers.
+type Range struct {
+	First lexer.Token
+	Last  lexer.Token
+}
+
+func (r *Range) Tokens() (first, last lexer.Token) {
+	return r.First, r.Last
+}
+
+// Span returns the source the node covers, from the start of its first
+// token to the end of its last.
+func (r *Range) Span() diag.Span {
+	s, end := r.First.Span(), r.Last.Span()
+	s.EndLine, s.EndColumn = end.EndLine, end.EndColumn
+	return s
 }
 
 type Expr interface {
@@ -14,6 +37,8 @@ type Expr interface {
 }
 
 type Identifier struct {
+	Range
+
 	Name string
 	Pos  lexer.Token
 }
@@ -24,6 +49,8 @@ func (i *Identifier) NodeType() string {
 }
 
 type IntLiteral struct {
+	Range
+
 	Value int64
 	Raw   string
 	Pos   lexer.Token
@@ -35,6 +62,8 @@ func (i *IntLiteral) NodeType() string {
 }
 
 type FloatLiteral struct {
+	Range
+
 	Value float64
 	Raw   string
 	Pos   lexer.Token
@@ -46,6 +75,8 @@ func (i *FloatLiteral) NodeType() string {
 }
 
 type StringLiteral struct {
+	Range
+
 	Value string
 	Pos   lexer.Token
 }
@@ -55,7 +86,24 @@ func (s *StringLiteral) NodeType() string {
 	return "StringLiteral"
 }
 
+// An InterpolatedString is a string literal with `{expr}` holes in it. Its
+// parts are the expressions in the holes and the StringLiterals between them,
+// in order.
+type InterpolatedString struct {
+	Range
+
+	Parts []Expr
+	Pos   lexer.Token
+}
+
+func (s *InterpolatedString) exprNode() {}
+func (s *InterpolatedString) NodeType() string {
+	return "InterpolatedString"
+}
+
 type ByteLiteral struct {
+	Range
+
 	Value byte
 	Raw   string
 	Pos   lexer.Token
@@ -67,6 +115,8 @@ func (b *ByteLiteral) NodeType() string {
 }
 
 type BoolLiteral struct {
+	Range
+
 	Value bool
 	Pos   lexer.Token
 }
@@ -77,6 +127,8 @@ func (b *BoolLiteral) NodeType() string {
 }
 
 type PrefixExpr struct {
+	Range
+
 	Operator lexer.Token
 	Right    Expr
 }
@@ -87,6 +139,8 @@ func (p *PrefixExpr) NodeType() string {
 }
 
 type InfixExpr struct {
+	Range
+
 	Left     Expr
 	Operator lexer.Token
 	Right    Expr
@@ -98,6 +152,8 @@ func (i *InfixExpr) NodeType() string {
 }
 
 type CallExpr struct {
+	Range
+
 	Callee Expr
 	Args   []Expr
 	Pos    lexer.Token
@@ -109,8 +165,13 @@ func (c *CallExpr) NodeType() string {
 }
 
 type VarDeclExpr struct {
+	Range
+
 	Mutable bool
 	Name    lexer.Token
+	// Pattern is set instead of Name for destructuring declarations such
+	// as `val (q, r) = divmod(a, b)`.
+	Pattern Pattern
 	Type    Expr
 	Value   Expr
 }
@@ -121,6 +182,8 @@ func (d *VarDeclExpr) NodeType() string {
 }
 
 type FuncDeclExpr struct {
+	Range
+
 	Pub        bool
 	Recursion  bool
 	Name       lexer.Token
@@ -128,6 +191,8 @@ type FuncDeclExpr struct {
 	Ret        Expr
 	Body       Expr
 	Decorators []Decorator
+	// Doc is the text of the /// comments directly above the declaration.
+	Doc string
 }
 
 func (f *FuncDeclExpr) exprNode() {}
@@ -135,7 +200,25 @@ func (f *FuncDeclExpr) NodeType() string {
 	return "FuncDeclExpr"
 }
 
+// LambdaExpr is an anonymous function, `fn(x: Int) { x + 1 }`. It closes
+// over the variables of the scope it appears in.
+type LambdaExpr struct {
+	Range
+
+	Params []Param
+	Ret    Expr
+	Body   Expr
+	Pos    lexer.Token
+}
+
+func (l *LambdaExpr) exprNode() {}
+func (l *LambdaExpr) NodeType() string {
+	return "LambdaExpr"
+}
+
 type Param struct {
+	Range
+
 	Name lexer.Token
 	Type Expr
 }
@@ -146,6 +229,8 @@ func (p *Param) NodeType() string {
 }
 
 type BlockExpr struct {
+	Range
+
 	Exprs []Expr
 }
 
@@ -155,10 +240,17 @@ func (b *BlockExpr) NodeType() string {
 }
 
 type UseExpr struct {
+	Range
+
 	Path    []string
 	Alias   string
 	Members []string
 	Pos     lexer.Token
+
+	// Names holds the tokens of the names the import brings into scope:
+	// the members if there are any, otherwise the alias or the last
+	// segment of the path.
+	Names []lexer.Token
 }
 
 func (u *UseExpr) exprNode() {}
@@ -167,6 +259,8 @@ func (u *UseExpr) NodeType() string {
 }
 
 type QualifiedExpr struct {
+	Range
+
 	Left  Expr
 	Right lexer.Token
 	Pos   lexer.Token
@@ -178,6 +272,8 @@ func (q *QualifiedExpr) NodeType() string {
 }
 
 type FieldAccessExpr struct {
+	Range
+
 	Left  Expr
 	Right string
 	Pos   lexer.Token
@@ -189,6 +285,8 @@ func (q *FieldAccessExpr) NodeType() string {
 }
 
 type IfExpr struct {
+	Range
+
 	Cond Expr
 	Then Expr
 	Else Expr
@@ -200,8 +298,26 @@ func (i *IfExpr) NodeType() string {
 	return "IfExpr"
 }
 
+// ForExpr is `for <pattern> in <iterable> { ... }`. The body is evaluated
+// once for each element of a range or list, and the loop evaluates to Nil.
+type ForExpr struct {
+	Range
+
+	Pattern Pattern
+	Iter    Expr
+	Body    *BlockExpr
+	Pos     lexer.Token
+}
+
+func (f *ForExpr) exprNode() {}
+func (f *ForExpr) NodeType() string {
+	return "ForExpr"
+}
+
 type MatchArm struct {
-	Pattern Expr
+	Range
+
+	Pattern Pattern
 	Guard   Expr
 	Body    Expr
 	Pos     lexer.Token
@@ -213,10 +329,13 @@ func (m *MatchArm) NodeType() string {
 }
 
 func (m *MatchArm) IsWildCardArm() bool {
-	return m.Pattern.NodeType() == "Identifier" && m.Pattern.(*Identifier).Name == "_"
+	_, ok := m.Pattern.(*WildcardPattern)
+	return ok
 }
 
 type MatchExpr struct {
+	Range
+
 	Value Expr
 	Arms  []*MatchArm
 	Pos   lexer.Token
@@ -228,6 +347,8 @@ func (m *MatchExpr) NodeType() string {
 }
 
 type PipelineExpr struct {
+	Range
+
 	Left  Expr
 	Right Expr
 	Pos   lexer.Token
@@ -239,6 +360,8 @@ func (p *PipelineExpr) NodeType() string {
 }
 
 type ListExpr struct {
+	Range
+
 	Elements []Expr
 	Pos      lexer.Token
 }
@@ -249,6 +372,8 @@ func (l *ListExpr) NodeType() string {
 }
 
 type TypeExpr struct {
+	Range
+
 	Name    string
 	Pos     lexer.Token
 	Generic Expr
@@ -260,6 +385,8 @@ func (t *TypeExpr) NodeType() string {
 }
 
 type TupleTypeExpr struct {
+	Range
+
 	Types []Expr
 	Pos   lexer.Token
 }
@@ -269,7 +396,23 @@ func (t *TupleTypeExpr) NodeType() string {
 	return "TupleTypeExpr"
 }
 
+// FuncTypeExpr is the type of a function, written `(a, b) -> c`.
+type FuncTypeExpr struct {
+	Range
+
+	Params []Expr
+	Ret    Expr
+	Pos    lexer.Token
+}
+
+func (t *FuncTypeExpr) exprNode() {}
+func (t *FuncTypeExpr) NodeType() string {
+	return "FuncTypeExpr"
+}
+
 type TupleExpr struct {
+	Range
+
 	Elements []Expr
 	Pos      lexer.Token
 }
@@ -280,6 +423,8 @@ func (t *TupleExpr) NodeType() string {
 }
 
 type RecordTypeExpr struct {
+	Range
+
 	Name   lexer.Token
 	Fields []Param
 	Pos    lexer.Token
@@ -290,11 +435,53 @@ func (r *RecordTypeExpr) NodeType() string {
 	return "RecordTypeExpr"
 }
 
+type Variant struct {
+	Name   lexer.Token
+	Fields []Expr
+}
+
+type VariantTypeExpr struct {
+	Range
+
+	Name     lexer.Token
+	Variants []Variant
+	Pos      lexer.Token
+}
+
+func (v *VariantTypeExpr) exprNode() {}
+func (v *VariantTypeExpr) NodeType() string {
+	return "VariantTypeExpr"
+}
+
+type FieldInit struct {
+	Name  lexer.Token
+	Value Expr
+}
+
+type RecordExpr struct {
+	Range
+
+	// Module is the module qualifying Name, as in `models:User { ... }`,
+	// or nil.
+	Module *Identifier
+	Name   lexer.Token
+	Fields []FieldInit
+	Pos    lexer.Token
+}
+
+func (r *RecordExpr) exprNode() {}
+func (r *RecordExpr) NodeType() string {
+	return "RecordExpr"
+}
+
 type TypeDeclExpr struct {
+	Range
+
 	Pub  bool
 	Name lexer.Token
 	Body Expr
 	Pos  lexer.Token
+	Doc  string
 }
 
 func (t *TypeDeclExpr) exprNode() {}
@@ -303,6 +490,8 @@ func (t *TypeDeclExpr) NodeType() string {
 }
 
 type Decorator struct {
+	Range
+
 	Name string
 	Args []Expr
 	Pos  lexer.Token
@@ -314,6 +503,8 @@ func (t *Decorator) NodeType() string {
 }
 
 type AssignExpr struct {
+	Range
+
 	Name  *Identifier
 	Value Expr
 	Pos   lexer.Token
@@ -325,6 +516,8 @@ func (a *AssignExpr) NodeType() string {
 }
 
 type IndexExpr struct {
+	Range
+
 	Target Expr
 	Index  Expr
 	Pos    lexer.Token
@@ -338,3 +531,146 @@ func (i *IndexExpr) NodeType() string {
 type Program struct {
 	Exprs []Expr
 }
+
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+type WildcardPattern struct {
+	Range
+
+	Pos lexer.Token
+}
+
+func (w *WildcardPattern) patternNode() {}
+func (w *WildcardPattern) NodeType() string {
+	return "WildcardPattern"
+}
+
+type BindingPattern struct {
+	Range
+
+	Name lexer.Token
+}
+
+func (b *BindingPattern) patternNode() {}
+func (b *BindingPattern) NodeType() string {
+	return "BindingPattern"
+}
+
+// LiteralPattern matches a single Int, Float, String, Byte or Bool literal.
+type LiteralPattern struct {
+	Range
+
+	Value Expr
+	Pos   lexer.Token
+}
+
+func (l *LiteralPattern) patternNode() {}
+func (l *LiteralPattern) NodeType() string {
+	return "LiteralPattern"
+}
+
+// RangePattern matches the half-open range `Low..High`.
+type RangePattern struct {
+	Range
+
+	Low  Expr
+	High Expr
+	Pos  lexer.Token
+}
+
+func (r *RangePattern) patternNode() {}
+func (r *RangePattern) NodeType() string {
+	return "RangePattern"
+}
+
+type TuplePattern struct {
+	Range
+
+	Elements []Pattern
+	Pos      lexer.Token
+}
+
+func (t *TuplePattern) patternNode() {}
+func (t *TuplePattern) NodeType() string {
+	return "TuplePattern"
+}
+
+// ListPattern matches lists starting with Elements. Without a Rest the list
+// must have exactly that many elements; `[a, ..rest]` binds the remainder.
+type ListPattern struct {
+	Range
+
+	Elements []Pattern
+	Rest     Pattern
+	Pos      lexer.Token
+}
+
+func (l *ListPattern) patternNode() {}
+func (l *ListPattern) NodeType() string {
+	return "ListPattern"
+}
+
+type ConstructorPattern struct {
+	Range
+
+	Name lexer.Token
+	Args []Pattern
+}
+
+func (c *ConstructorPattern) patternNode() {}
+func (c *ConstructorPattern) NodeType() string {
+	return "ConstructorPattern"
+}
+
+type OrPattern struct {
+	Range
+
+	Alternatives []Pattern
+	Pos          lexer.Token
+}
+
+func (o *OrPattern) patternNode() {}
+func (o *OrPattern) NodeType() string {
+	return "OrPattern"
+}
+
+// AsPattern binds Name to the whole value matched by Pattern.
+type AsPattern struct {
+	Range
+
+	Pattern Pattern
+	Name    lexer.Token
+}
+
+func (a *AsPattern) patternNode() {}
+func (a *AsPattern) NodeType() string {
+	return "AsPattern"
+}
+
+// PatternPos returns the token a pattern starts at.
+func PatternPos(p Pattern) lexer.Token {
+	switch n := p.(type) {
+	case *WildcardPattern:
+		return n.Pos
+	case *BindingPattern:
+		return n.Name
+	case *LiteralPattern:
+		return n.Pos
+	case *RangePattern:
+		return n.Pos
+	case *TuplePattern:
+		return n.Pos
+	case *ListPattern:
+		return n.Pos
+	case *ConstructorPattern:
+		return n.Name
+	case *OrPattern:
+		return n.Pos
+	case *AsPattern:
+		return PatternPos(n.Pattern)
+	}
+	return lexer.Token{}
+}
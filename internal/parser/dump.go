@@ -33,6 +33,14 @@ func dump(e Expr, indent string, last bool) string {
 	case *StringLiteral:
 		line, _ := node(indent, last, fmt.Sprintf("String %q", n.Value))
 		return line
+	case *InterpolatedString:
+		line, next := node(indent, last, "InterpolatedString")
+		var out strings.Builder
+		out.WriteString(line)
+		for i, e := range n.Parts {
+			out.WriteString(dump(e, next, i == len(n.Parts)-1))
+		}
+		return out.String()
 	case *ByteLiteral:
 		line, _ := node(indent, last, fmt.Sprintf("Byte '%c'", n.Value))
 		return line
@@ -118,6 +126,20 @@ func dump(e Expr, indent string, last bool) string {
 			out.WriteString(dump(n.Else, eNext, true))
 		}
 		return out.String()
+	case *ForExpr:
+		line, next := node(indent, last, "ForExpr")
+		var out strings.Builder
+		out.WriteString(line)
+		pLine, pNext := node(next, false, "Pattern")
+		out.WriteString(pLine)
+		out.WriteString(dumpPattern(n.Pattern, pNext, true))
+		iLine, iNext := node(next, false, "Iter")
+		out.WriteString(iLine)
+		out.WriteString(dump(n.Iter, iNext, true))
+		bLine, bNext := node(next, true, "Body")
+		out.WriteString(bLine)
+		out.WriteString(dump(n.Body, bNext, true))
+		return out.String()
 	case *MatchExpr:
 		line, next := node(indent, last, "MatchExpr")
 		var out strings.Builder
@@ -133,7 +155,7 @@ func dump(e Expr, indent string, last bool) string {
 			out.WriteString(armLine)
 			pLine, pNext := node(armNext, false, "Pattern")
 			out.WriteString(pLine)
-			out.WriteString(dump(arm.Pattern, pNext, true))
+			out.WriteString(dumpPattern(arm.Pattern, pNext, true))
 			if arm.Guard != nil {
 				gLine, gNext := node(armNext, false, "Guard")
 				out.WriteString(gLine)
@@ -145,6 +167,12 @@ func dump(e Expr, indent string, last bool) string {
 		}
 		return strings.TrimRight(out.String(), "\n")
 	case *VarDeclExpr:
+		if n.Pattern != nil {
+			line, next := node(indent, last, fmt.Sprintf("VarDecl mutable=%t", n.Mutable))
+			pLine, pNext := node(next, false, "Pattern")
+			vLine, vNext := node(next, true, "Value")
+			return line + pLine + dumpPattern(n.Pattern, pNext, true) + vLine + dump(n.Value, vNext, true)
+		}
 		line, next := node(indent, last, fmt.Sprintf("VarDecl name=%s mutable=%t", n.Name.Lexeme, n.Mutable))
 		var out strings.Builder
 		out.WriteString(line)
@@ -189,6 +217,28 @@ func dump(e Expr, indent string, last bool) string {
 		out.WriteString(bLine)
 		out.WriteString(dump(n.Body, bNext, true))
 		return out.String()
+	case *LambdaExpr:
+		line, next := node(indent, last, "Lambda")
+		var out strings.Builder
+		out.WriteString(line)
+		pLine, pNext := node(next, false, "Params")
+		out.WriteString(pLine)
+		for i, p := range n.Params {
+			paramLine, pIndent := node(pNext, i == len(n.Params)-1, "Param name="+p.Name.Lexeme)
+			out.WriteString(paramLine)
+			if p.Type != nil {
+				out.WriteString(dump(p.Type, pIndent, true))
+			}
+		}
+		if n.Ret != nil {
+			rLine, rNext := node(next, false, "ReturnType")
+			out.WriteString(rLine)
+			out.WriteString(dump(n.Ret, rNext, true))
+		}
+		bLine, bNext := node(next, true, "Body")
+		out.WriteString(bLine)
+		out.WriteString(dump(n.Body, bNext, true))
+		return out.String()
 	case *TypeDeclExpr:
 		line, next := node(indent, last, "TypeDecl name="+n.Name.Lexeme)
 		var out strings.Builder
@@ -214,6 +264,17 @@ func dump(e Expr, indent string, last bool) string {
 			out.WriteString(dump(t, next, i == len(n.Types)-1))
 		}
 		return out.String()
+	case *FuncTypeExpr:
+		line, next := node(indent, last, "FuncType")
+		var out strings.Builder
+		out.WriteString(line)
+		for _, t := range n.Params {
+			out.WriteString(dump(t, next, false))
+		}
+		rLine, rNext := node(next, true, "Returns")
+		out.WriteString(rLine)
+		out.WriteString(dump(n.Ret, rNext, true))
+		return out.String()
 	case *RecordTypeExpr:
 		line, next := node(indent, last, "RecordType "+n.Name.Lexeme)
 		var out strings.Builder
@@ -226,6 +287,32 @@ func dump(e Expr, indent string, last bool) string {
 			}
 		}
 		return out.String()
+	case *VariantTypeExpr:
+		line, next := node(indent, last, "VariantType "+n.Name.Lexeme)
+		var out strings.Builder
+		out.WriteString(line)
+		for i, v := range n.Variants {
+			vLine, vNext := node(next, i == len(n.Variants)-1, "Variant "+v.Name.Lexeme)
+			out.WriteString(vLine)
+			for j, f := range v.Fields {
+				out.WriteString(dump(f, vNext, j == len(v.Fields)-1))
+			}
+		}
+		return out.String()
+	case *RecordExpr:
+		name := n.Name.Lexeme
+		if n.Module != nil {
+			name = n.Module.Name + ":" + name
+		}
+		line, next := node(indent, last, "Record "+name)
+		var out strings.Builder
+		out.WriteString(line)
+		for i, f := range n.Fields {
+			fieldLine, fNext := node(next, i == len(n.Fields)-1, "Field "+f.Name.Lexeme)
+			out.WriteString(fieldLine)
+			out.WriteString(dump(f.Value, fNext, true))
+		}
+		return out.String()
 	case *UseExpr:
 		line, next := node(indent, last, "Use")
 		var out strings.Builder
@@ -286,3 +373,63 @@ func nodeWith(indent string, last bool, label string) string {
 	line, _ := node(indent, last, label)
 	return line
 }
+
+func dumpPattern(p Pattern, indent string, last bool) string {
+	switch n := p.(type) {
+	case *WildcardPattern:
+		line, _ := node(indent, last, "Wildcard")
+		return line
+	case *BindingPattern:
+		line, _ := node(indent, last, "Binding "+n.Name.Lexeme)
+		return line
+	case *LiteralPattern:
+		line, next := node(indent, last, "Literal")
+		return line + dump(n.Value, next, true)
+	case *RangePattern:
+		line, next := node(indent, last, "Range")
+		return line + dump(n.Low, next, false) + dump(n.High, next, true)
+	case *TuplePattern:
+		line, next := node(indent, last, "TuplePattern")
+		var out strings.Builder
+		out.WriteString(line)
+		for i, e := range n.Elements {
+			out.WriteString(dumpPattern(e, next, i == len(n.Elements)-1))
+		}
+		return out.String()
+	case *ListPattern:
+		line, next := node(indent, last, "ListPattern")
+		var out strings.Builder
+		out.WriteString(line)
+		for i, e := range n.Elements {
+			out.WriteString(dumpPattern(e, next, i == len(n.Elements)-1 && n.Rest == nil))
+		}
+		if n.Rest != nil {
+			rLine, rNext := node(next, true, "Rest")
+			out.WriteString(rLine)
+			out.WriteString(dumpPattern(n.Rest, rNext, true))
+		}
+		return out.String()
+	case *ConstructorPattern:
+		line, next := node(indent, last, "Constructor "+n.Name.Lexeme)
+		var out strings.Builder
+		out.WriteString(line)
+		for i, e := range n.Args {
+			out.WriteString(dumpPattern(e, next, i == len(n.Args)-1))
+		}
+		return out.String()
+	case *OrPattern:
+		line, next := node(indent, last, "Or")
+		var out strings.Builder
+		out.WriteString(line)
+		for i, e := range n.Alternatives {
+			out.WriteString(dumpPattern(e, next, i == len(n.Alternatives)-1))
+		}
+		return out.String()
+	case *AsPattern:
+		line, next := node(indent, last, "As "+n.Name.Lexeme)
+		return line + dumpPattern(n.Pattern, next, true)
+	default:
+		line, _ := node(indent, last, fmt.Sprintf("<unknown pattern %T>", p))
+		return line
+	}
+}
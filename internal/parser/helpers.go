@@ -3,6 +3,7 @@ package parser
 import (
 	"flint/internal/lexer"
 	"fmt"
+	"strings"
 )
 
 func (p *Parser) cur() lexer.Token {
@@ -20,6 +21,25 @@ func (p *Parser) peek(n int) lexer.Token {
 	return p.tokens[i]
 }
 
+// prev returns the last token consumed.
+func (p *Parser) prev() lexer.Token {
+	if p.pos == 0 {
+		return p.cur()
+	}
+	return p.tokens[p.pos-1]
+}
+
+// rangeFrom returns the range of a node starting at first and ending at the
+// last token consumed.
+func (p *Parser) rangeFrom(first lexer.Token) Range {
+	return Range{First: first, Last: p.prev()}
+}
+
+func first(n Node) lexer.Token {
+	first, _ := n.Tokens()
+	return first
+}
+
 func (p *Parser) eat() lexer.Token {
 	t := p.cur()
 	if p.pos < len(p.tokens) {
@@ -40,3 +60,29 @@ func (p *Parser) expect(kind lexer.TokenKind) (lexer.Token, bool) {
 	p.synchronize()
 	return tok, false
 }
+
+// docComment returns the text of the run of /// comments that ends on the
+// line before a declaration. A blank line or an ordinary comment ends the
+// run, so only comments touching the declaration document it.
+func docComment(leading []lexer.Trivia) string {
+	var lines []string
+	newlines := 0
+	for _, t := range leading {
+		switch t.Kind {
+		case lexer.Newline:
+			newlines++
+			if newlines > 1 {
+				lines = nil
+			}
+		case lexer.LineComment:
+			newlines = 0
+			if !strings.HasPrefix(t.Text, "///") {
+				lines = nil
+				continue
+			}
+			line := strings.TrimPrefix(t.Text, "///")
+			lines = append(lines, strings.TrimPrefix(line, " "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,25 +1,26 @@
 package parser
 
 import (
+	"flint/internal/diag"
 	"flint/internal/lexer"
 	"fmt"
 	"strconv"
+	"unicode"
 )
 
 type Parser struct {
 	tokens []lexer.Token
 	pos    int
-	errors []string
+	errors diag.List
 }
 
-func ParseProgram(tokens []lexer.Token) (*Program, []string) {
+// ParseProgram parses every top-level expression it can, recovering after
+// errors. Illegal tokens have been reported by the lexer and are skipped, as
+// are comments; those from lexer.Tokenize are trivia on the tokens anyway.
+func ParseProgram(tokens []lexer.Token) (*Program, diag.List) {
 	p := new(tokens)
 	out := &Program{Exprs: []Expr{}}
 	for p.cur().Kind != lexer.EndOfFile {
-		if p.cur().Kind == lexer.Comment {
-			p.eat()
-			continue
-		}
 		decorators := p.parseDecorators()
 		expr := p.parseExpression(0)
 		if expr == nil {
@@ -28,6 +29,13 @@ func ParseProgram(tokens []lexer.Token) (*Program, []string) {
 		}
 		if fn, ok := expr.(*FuncDeclExpr); ok {
 			fn.Decorators = decorators
+			if len(decorators) > 0 {
+				fn.First = decorators[0].First
+			}
+			fn.Doc = docComment(fn.First.Leading)
+		}
+		if td, ok := expr.(*TypeDeclExpr); ok {
+			td.Doc = docComment(td.First.Leading)
 		}
 		out.Exprs = append(out.Exprs, expr)
 	}
@@ -36,7 +44,13 @@ func ParseProgram(tokens []lexer.Token) (*Program, []string) {
 }
 
 func new(tokens []lexer.Token) *Parser {
-	return &Parser{tokens: tokens, pos: 0, errors: []string{}}
+	legal := make([]lexer.Token, 0, len(tokens))
+	for _, tok := range tokens {
+		if tok.Kind != lexer.Illegal && tok.Kind != lexer.Comment {
+			legal = append(legal, tok)
+		}
+	}
+	return &Parser{tokens: legal, pos: 0}
 }
 
 func dectectRecursion(prog *Program) {
@@ -77,10 +91,11 @@ func containsSelfCall(e Expr, fnName string) bool {
 			(n.Else != nil && containsSelfCall(n.Else, fnName)) {
 			return true
 		}
+	case *ForExpr:
+		return containsSelfCall(n.Iter, fnName) || containsSelfCall(n.Body, fnName)
 	case *MatchExpr:
 		for _, arm := range n.Arms {
-			if containsSelfCall(arm.Pattern, fnName) ||
-				(arm.Guard != nil && containsSelfCall(arm.Guard, fnName)) ||
+			if (arm.Guard != nil && containsSelfCall(arm.Guard, fnName)) ||
 				containsSelfCall(arm.Body, fnName) {
 				return true
 			}
@@ -107,6 +122,12 @@ func containsSelfCall(e Expr, fnName string) bool {
 		}
 	case *FieldAccessExpr:
 		return containsSelfCall(n.Left, fnName)
+	case *RecordExpr:
+		for _, f := range n.Fields {
+			if containsSelfCall(f.Value, fnName) {
+				return true
+			}
+		}
 	case *QualifiedExpr:
 		return containsSelfCall(n.Left, fnName)
 	case *VarDeclExpr:
@@ -133,6 +154,7 @@ func (p *Parser) parseExpression(minPrec int) Expr {
 			p.errorAt(assignTok, fmt.Sprintf("missing right-hand side for assignment to %s", id.Name))
 		}
 		return &AssignExpr{
+			Range: p.rangeFrom(id.Pos),
 			Name:  id,
 			Value: right,
 			Pos:   assignTok,
@@ -147,6 +169,7 @@ func (p *Parser) parseExpression(minPrec int) Expr {
 				return nil
 			}
 			left = &QualifiedExpr{
+				Range: p.rangeFrom(first(left)),
 				Left:  left,
 				Right: rightTok,
 				Pos:   opTok,
@@ -160,6 +183,7 @@ func (p *Parser) parseExpression(minPrec int) Expr {
 				return nil
 			}
 			left = &FieldAccessExpr{
+				Range: p.rangeFrom(first(left)),
 				Left:  left,
 				Right: rightTok.Lexeme,
 				Pos:   opTok,
@@ -181,11 +205,14 @@ func (p *Parser) parseExpression(minPrec int) Expr {
 		}
 		if opTok.Kind == lexer.Pipe {
 			left = &PipelineExpr{
+				Range: p.rangeFrom(first(left)),
 				Left:  left,
 				Right: right,
+				Pos:   opTok,
 			}
 		} else {
 			left = &InfixExpr{
+				Range:    p.rangeFrom(first(left)),
 				Left:     left,
 				Operator: opTok,
 				Right:    right,
@@ -199,8 +226,11 @@ func (p *Parser) parsePrimary() Expr {
 	tok := p.cur()
 	switch tok.Kind {
 	case lexer.Identifier:
+		if p.isRecordLiteralStart() {
+			return p.parseRecordLiteral()
+		}
 		p.eat()
-		var expr Expr = &Identifier{Name: tok.Lexeme, Pos: tok}
+		var expr Expr = &Identifier{Range: p.rangeFrom(tok), Name: tok.Lexeme, Pos: tok}
 		for {
 			switch p.cur().Kind {
 			case lexer.Colon:
@@ -210,6 +240,7 @@ func (p *Parser) parsePrimary() Expr {
 					return nil
 				}
 				expr = &QualifiedExpr{
+					Range: p.rangeFrom(tok),
 					Left:  expr,
 					Right: rightTok,
 					Pos:   opTok,
@@ -221,6 +252,7 @@ func (p *Parser) parsePrimary() Expr {
 					return nil
 				}
 				expr = &FieldAccessExpr{
+					Range: p.rangeFrom(tok),
 					Left:  expr,
 					Right: fieldTok.Lexeme,
 					Pos:   opTok,
@@ -235,6 +267,7 @@ func (p *Parser) parsePrimary() Expr {
 					return nil
 				}
 				expr = &IndexExpr{
+					Range:  p.rangeFrom(tok),
 					Target: expr,
 					Index:  index,
 					Pos:    lb,
@@ -255,7 +288,7 @@ func (p *Parser) parsePrimary() Expr {
 		if err != nil {
 			p.errorAt(tok, fmt.Sprintf("invalid int literal %q", tok.Lexeme))
 		}
-		return &IntLiteral{Value: v, Raw: tok.Lexeme, Pos: tok}
+		return &IntLiteral{Range: p.rangeFrom(tok), Value: v, Raw: tok.Lexeme, Pos: tok}
 	case lexer.Float:
 		p.eat()
 		clean := lexer.StripNumericSeparators(tok.Lexeme)
@@ -263,30 +296,25 @@ func (p *Parser) parsePrimary() Expr {
 		if err != nil {
 			p.errorAt(tok, fmt.Sprintf("invalid float literal %q", tok.Lexeme))
 		}
-		return &FloatLiteral{Value: f, Raw: tok.Lexeme, Pos: tok}
+		return &FloatLiteral{Range: p.rangeFrom(tok), Value: f, Raw: tok.Lexeme, Pos: tok}
 	case lexer.String:
 		p.eat()
-		value, err := strconv.Unquote(tok.Lexeme)
-		if err != nil {
-			p.errorAt(tok, "invalid string literal")
-			return nil
-		}
-		return &StringLiteral{Value: value, Pos: tok}
+		return p.parseString(tok)
 	case lexer.Byte:
 		p.eat()
 		if len(tok.Lexeme) != 3 || tok.Lexeme[0] != '\'' || tok.Lexeme[2] != '\'' {
 			p.errorAt(tok, fmt.Sprintf("invalid byte literal %q", tok.Lexeme))
 		}
-		return &ByteLiteral{Value: tok.Lexeme[1], Raw: tok.Lexeme, Pos: tok}
+		return &ByteLiteral{Range: p.rangeFrom(tok), Value: tok.Lexeme[1], Raw: tok.Lexeme, Pos: tok}
 	case lexer.Bool:
 		p.eat()
 		val := tok.Lexeme == "True"
-		return &BoolLiteral{Value: val, Pos: tok}
+		return &BoolLiteral{Range: p.rangeFrom(tok), Value: val, Pos: tok}
 	case lexer.LeftParen:
 		p.eat()
 		if p.cur().Kind == lexer.RightParen {
 			p.eat()
-			return &TupleExpr{Elements: []Expr{}, Pos: tok}
+			return &TupleExpr{Range: p.rangeFrom(tok), Elements: []Expr{}, Pos: tok}
 		}
 		elements := []Expr{}
 		for {
@@ -308,14 +336,14 @@ func (p *Parser) parsePrimary() Expr {
 		if len(elements) == 1 {
 			return elements[0]
 		}
-		return &TupleExpr{Elements: elements, Pos: tok}
+		return &TupleExpr{Range: p.rangeFrom(tok), Elements: elements, Pos: tok}
 	case lexer.Bang, lexer.Minus:
 		p.eat()
 		right := p.parseExpression(7)
 		if right == nil {
 			p.errorAt(tok, fmt.Sprintf("missing expression after prefix %q", tok.Lexeme))
 		}
-		return &PrefixExpr{Operator: tok, Right: right}
+		return &PrefixExpr{Range: p.rangeFrom(tok), Operator: tok, Right: right}
 	case lexer.KwPub:
 		p.eat()
 		switch p.cur().Kind {
@@ -328,6 +356,9 @@ func (p *Parser) parsePrimary() Expr {
 			return nil
 		}
 	case lexer.KwFn:
+		if p.peek(1).Kind == lexer.LeftParen {
+			return p.parseLambda()
+		}
 		return p.parseFunc(false)
 	case lexer.LeftBrace:
 		return p.parseBlock()
@@ -337,6 +368,8 @@ func (p *Parser) parsePrimary() Expr {
 		return p.parseIf()
 	case lexer.KwMatch:
 		return p.parseMatch()
+	case lexer.KwFor:
+		return p.parseFor()
 	case lexer.LeftBracket:
 		return p.parseList()
 	case lexer.KwType:
@@ -369,22 +402,94 @@ func (p *Parser) parseCall(callee Expr) Expr {
 		p.synchronize()
 		return nil
 	}
-	return &CallExpr{Callee: callee, Args: args, Pos: lparen}
+	call := &CallExpr{Range: p.rangeFrom(first(callee)), Callee: callee, Args: args, Pos: lparen}
+	if p.cur().Kind == lexer.LeftParen {
+		return p.parseCall(call)
+	}
+	return call
 }
 
-func (p *Parser) parseVarDecl(mutable bool) Expr {
-	p.eat()
-	nameTok, ok := p.expect(lexer.Identifier)
-	if !ok {
+// isRecordLiteralStart reports whether the parser is looking at
+// `Name { field: ...` or `Name {}`, with Name possibly qualified as in
+// `models:Name`. Type names start with an upper-case letter, which keeps
+// `if flag { ... }` and `match x { ... }` unambiguous.
+func (p *Parser) isRecordLiteralStart() bool {
+	at := 0
+	if p.cur().Kind == lexer.Identifier && p.peek(1).Kind == lexer.Colon {
+		at = 2
+	}
+	name := p.peek(at)
+	if name.Kind != lexer.Identifier || !unicode.IsUpper([]rune(name.Lexeme)[0]) {
+		return false
+	}
+	if p.peek(at+1).Kind != lexer.LeftBrace {
+		return false
+	}
+	next := p.peek(at + 2)
+	return next.Kind == lexer.RightBrace ||
+		(next.Kind == lexer.Identifier && p.peek(at+3).Kind == lexer.Colon)
+}
+
+func (p *Parser) parseRecordLiteral() Expr {
+	start := p.cur()
+	var module *Identifier
+	if p.peek(1).Kind == lexer.Colon {
+		modTok := p.eat()
+		p.eat()
+		module = &Identifier{Range: p.rangeFrom(modTok), Name: modTok.Lexeme, Pos: modTok}
+	}
+	nameTok := p.eat()
+	lbrace := p.eat()
+	fields := []FieldInit{}
+	for p.cur().Kind != lexer.RightBrace && p.cur().Kind != lexer.EndOfFile {
+		fieldTok, ok := p.expect(lexer.Identifier)
+		if !ok {
+			return nil
+		}
+		if _, ok := p.expect(lexer.Colon); !ok {
+			return nil
+		}
+		value := p.parseExpression(0)
+		if value == nil {
+			p.errorAt(fieldTok, fmt.Sprintf("missing value for field %s", fieldTok.Lexeme))
+			return nil
+		}
+		fields = append(fields, FieldInit{Name: fieldTok, Value: value})
+		if p.cur().Kind == lexer.Comma {
+			p.eat()
+			continue
+		}
+		if p.cur().Kind != lexer.RightBrace && !(p.cur().Kind == lexer.Identifier && p.peek(1).Kind == lexer.Colon) {
+			break
+		}
+	}
+	if _, ok := p.expect(lexer.RightBrace); !ok {
 		return nil
 	}
+	return &RecordExpr{Range: p.rangeFrom(start), Module: module, Name: nameTok, Fields: fields, Pos: lbrace}
+}
+
+func (p *Parser) parseVarDecl(mutable bool) Expr {
+	kw := p.eat()
+	var pattern Pattern
+	var nameTok lexer.Token
+	if k := p.cur().Kind; k == lexer.LeftParen || k == lexer.LeftBracket {
+		nameTok = p.cur()
+		if pattern = p.parsePattern(); pattern == nil {
+			return nil
+		}
+	} else {
+		var ok bool
+		if nameTok, ok = p.expect(lexer.Identifier); !ok {
+			return nil
+		}
+	}
 	var typeAnn Expr
 	if p.cur().Kind == lexer.Colon {
 		p.eat()
 		typeAnn = p.parseType()
 	}
-	_, ok = p.expect(lexer.Equal)
-	if !ok {
+	if _, ok := p.expect(lexer.Equal); !ok {
 		p.synchronize()
 		return nil
 	}
@@ -397,8 +502,10 @@ func (p *Parser) parseVarDecl(mutable bool) Expr {
 		p.errorAt(nameTok, fmt.Sprintf("missing initializer for %s %s", kind, nameTok.Lexeme))
 	}
 	return &VarDeclExpr{
+		Range:   p.rangeFrom(kw),
 		Mutable: mutable,
 		Name:    nameTok,
+		Pattern: pattern,
 		Type:    typeAnn,
 		Value:   value,
 	}
@@ -413,15 +520,72 @@ func (p *Parser) parseMutDecl() Expr {
 }
 
 func (p *Parser) parseFunc(pub bool) Expr {
+	start := p.cur()
+	if pub {
+		start = p.prev()
+	}
 	p.expect(lexer.KwFn)
 	nameTok, ok := p.expect(lexer.Identifier)
 	if !ok {
 		return nil
 	}
 
+	params, ok := p.parseParams()
+	if !ok {
+		return nil
+	}
+
+	var retType Expr
+	if p.cur().Kind != lexer.LeftBrace && p.cur().Kind != lexer.EndOfFile {
+		retType = p.parseType()
+	}
+
+	var body Expr
+	if p.cur().Kind == lexer.LeftBrace {
+		body = p.parseBlock()
+	}
+
+	return &FuncDeclExpr{
+		Range:      p.rangeFrom(start),
+		Pub:        pub,
+		Name:       nameTok,
+		Params:     params,
+		Ret:        retType,
+		Body:       body,
+		Decorators: nil,
+	}
+}
+
+// parseLambda parses `fn(params) Ret { body }`, where the return type is
+// optional.
+func (p *Parser) parseLambda() Expr {
+	fnTok := p.eat()
+	params, ok := p.parseParams()
+	if !ok {
+		return nil
+	}
+	var retType Expr
+	if p.cur().Kind != lexer.LeftBrace {
+		retType = p.parseType()
+	}
+	if p.cur().Kind != lexer.LeftBrace {
+		p.errorAt(p.cur(), "expected `{` to start the body of the function")
+		return nil
+	}
+	body := p.parseBlock()
+	lambda := &LambdaExpr{Range: p.rangeFrom(fnTok), Params: params, Ret: retType, Body: body, Pos: fnTok}
+	if p.cur().Kind == lexer.LeftParen {
+		return p.parseCall(lambda)
+	}
+	return lambda
+}
+
+// parseParams parses a parenthesised parameter list whose types are
+// optional.
+func (p *Parser) parseParams() ([]Param, bool) {
 	if _, ok := p.expect(lexer.LeftParen); !ok {
 		p.synchronize()
-		return nil
+		return nil, false
 	}
 
 	params := []Param{}
@@ -429,14 +593,14 @@ func (p *Parser) parseFunc(pub bool) Expr {
 		for {
 			paramTok, ok := p.expect(lexer.Identifier)
 			if !ok {
-				return nil
+				return nil, false
 			}
 			var typ Expr
 			if p.cur().Kind == lexer.Colon {
 				p.eat()
 				typ = p.parseType()
 			}
-			params = append(params, Param{Name: paramTok, Type: typ})
+			params = append(params, Param{Range: p.rangeFrom(paramTok), Name: paramTok, Type: typ})
 			if p.cur().Kind == lexer.Comma {
 				p.eat()
 				continue
@@ -447,40 +611,19 @@ func (p *Parser) parseFunc(pub bool) Expr {
 
 	if _, ok := p.expect(lexer.RightParen); !ok {
 		p.synchronize()
-		return nil
-	}
-
-	var retType Expr
-	if p.cur().Kind != lexer.LeftBrace && p.cur().Kind != lexer.EndOfFile {
-		retType = p.parseType()
-	}
-
-	var body Expr
-	if p.cur().Kind == lexer.LeftBrace {
-		body = p.parseBlock()
-	}
-
-	return &FuncDeclExpr{
-		Pub:        pub,
-		Name:       nameTok,
-		Params:     params,
-		Ret:        retType,
-		Body:       body,
-		Decorators: nil,
+		return nil, false
 	}
+	return params, true
 }
 
 func (p *Parser) parseBlock() Expr {
-	if _, ok := p.expect(lexer.LeftBrace); !ok {
+	lbrace, ok := p.expect(lexer.LeftBrace)
+	if !ok {
 		p.synchronize()
 		return nil
 	}
 	exprs := []Expr{}
 	for p.cur().Kind != lexer.RightBrace && p.cur().Kind != lexer.EndOfFile {
-		if p.cur().Kind == lexer.Comment {
-			p.eat()
-			continue
-		}
 		e := p.parseExpression(0)
 		if e == nil {
 			p.eat()
@@ -491,12 +634,13 @@ func (p *Parser) parseBlock() Expr {
 	if _, ok := p.expect(lexer.RightBrace); !ok {
 		p.synchronize()
 	}
-	return &BlockExpr{Exprs: exprs}
+	return &BlockExpr{Range: p.rangeFrom(lbrace), Exprs: exprs}
 }
 
 func (p *Parser) parseUse() Expr {
 	start := p.eat()
 	path := []string{}
+	var last lexer.Token
 	for {
 		tok, ok := p.expect(lexer.Identifier)
 		if !ok {
@@ -504,6 +648,7 @@ func (p *Parser) parseUse() Expr {
 			return nil
 		}
 		path = append(path, tok.Lexeme)
+		last = tok
 
 		if p.cur().Kind == lexer.Slash {
 			p.eat()
@@ -512,6 +657,7 @@ func (p *Parser) parseUse() Expr {
 		break
 	}
 	members := []string{}
+	var names []lexer.Token
 	alias := ""
 	if p.cur().Kind == lexer.Dot && p.peek(1).Kind == lexer.LeftBrace {
 		p.eat()
@@ -523,6 +669,7 @@ func (p *Parser) parseUse() Expr {
 				return nil
 			}
 			members = append(members, memberTok.Lexeme)
+			names = append(names, memberTok)
 
 			if p.cur().Kind == lexer.Comma {
 				p.eat()
@@ -544,12 +691,18 @@ func (p *Parser) parseUse() Expr {
 			return nil
 		}
 		alias = aliasTok.Lexeme
+		last = aliasTok
+	}
+	if len(members) == 0 {
+		names = []lexer.Token{last}
 	}
 	return &UseExpr{
+		Range:   p.rangeFrom(start),
 		Path:    path,
 		Alias:   alias,
 		Members: members,
 		Pos:     start,
+		Names:   names,
 	}
 }
 
@@ -595,15 +748,50 @@ func (p *Parser) parseIf() Expr {
 		return nil
 	}
 	return &IfExpr{
-		Pos:  start,
-		Cond: cond,
-		Then: thenExpr,
-		Else: elseExpr,
+		Range: p.rangeFrom(start),
+		Pos:   start,
+		Cond:  cond,
+		Then:  thenExpr,
+		Else:  elseExpr,
+	}
+}
+
+func (p *Parser) parseFor() Expr {
+	start := p.eat()
+	pattern := p.parsePattern()
+	if pattern == nil {
+		p.synchronize()
+		return nil
+	}
+	if _, ok := p.expect(lexer.KwIn); !ok {
+		p.synchronize()
+		return nil
+	}
+	iter := p.parseExpression(0)
+	if iter == nil {
+		p.errorAt(p.cur(), "expected expression after 'in'")
+		return nil
+	}
+	if p.cur().Kind != lexer.LeftBrace {
+		p.errorAt(p.cur(), "expected '{' after the iterable of 'for'")
+		p.synchronize()
+		return nil
+	}
+	body, ok := p.parseBlock().(*BlockExpr)
+	if !ok {
+		return nil
+	}
+	return &ForExpr{
+		Range:   p.rangeFrom(start),
+		Pattern: pattern,
+		Iter:    iter,
+		Body:    body,
+		Pos:     start,
 	}
 }
 
 func (p *Parser) parseMatch() Expr {
-	p.eat()
+	start := p.eat()
 	value := p.parseExpression(0)
 	if value == nil {
 		p.errorAt(p.cur(), "expected expression after 'match'")
@@ -615,18 +803,14 @@ func (p *Parser) parseMatch() Expr {
 	}
 	arms := []*MatchArm{}
 	for p.cur().Kind != lexer.RightBrace && p.cur().Kind != lexer.EndOfFile {
+		armTok := p.cur()
 		if p.cur().Kind == lexer.Vbar {
 			p.eat()
 		}
-		var pattern Expr
-		if p.cur().Kind == lexer.Underscore {
-			pattern = &Identifier{Name: "_", Pos: p.cur()}
-			p.eat()
-		} else {
-			pattern = p.parseExpression(0)
-			if pattern == nil {
-				p.errorAt(p.cur(), "expected pattern in match arm")
-			}
+		pattern := p.parsePattern()
+		if pattern == nil {
+			p.synchronize()
+			return nil
 		}
 		var guard Expr
 		if p.cur().Kind == lexer.KwIf {
@@ -645,9 +829,11 @@ func (p *Parser) parseMatch() Expr {
 			p.errorAt(p.cur(), "expected body expression in match arm")
 		}
 		arms = append(arms, &MatchArm{
+			Range:   p.rangeFrom(armTok),
 			Pattern: pattern,
 			Guard:   guard,
 			Body:    body,
+			Pos:     armTok,
 		})
 	}
 	_, ok = p.expect(lexer.RightBrace)
@@ -656,8 +842,10 @@ func (p *Parser) parseMatch() Expr {
 		return nil
 	}
 	return &MatchExpr{
+		Range: p.rangeFrom(start),
 		Value: value,
 		Arms:  arms,
+		Pos:   start,
 	}
 }
 
@@ -680,6 +868,7 @@ func (p *Parser) parseList() Expr {
 		return nil
 	}
 	return &ListExpr{
+		Range:    p.rangeFrom(start),
 		Elements: elements,
 		Pos:      start,
 	}
@@ -690,10 +879,10 @@ func (p *Parser) parseType() Expr {
 	switch tok.Kind {
 	case lexer.KwInt, lexer.KwFloat, lexer.KwBool, lexer.KwByte, lexer.KwString, lexer.KwNil:
 		p.eat()
-		return &TypeExpr{Name: tok.Lexeme, Pos: tok}
+		return &TypeExpr{Range: p.rangeFrom(tok), Name: tok.Lexeme, Pos: tok}
 	case lexer.Identifier:
 		p.eat()
-		return &TypeExpr{Name: tok.Lexeme, Pos: tok}
+		return &TypeExpr{Range: p.rangeFrom(tok), Name: tok.Lexeme, Pos: tok}
 	case lexer.KwList:
 		p.eat()
 		var elemType Expr
@@ -702,11 +891,11 @@ func (p *Parser) parseType() Expr {
 			elemType = p.parseType()
 			p.expect(lexer.RightParen)
 		}
-		return &TypeExpr{Name: "List", Pos: tok, Generic: elemType}
+		return &TypeExpr{Range: p.rangeFrom(tok), Name: "List", Pos: tok, Generic: elemType}
 	case lexer.LeftParen:
 		p.eat()
 		types := []Expr{}
-		for {
+		for p.cur().Kind != lexer.RightParen {
 			t := p.parseType()
 			if t == nil {
 				return nil
@@ -722,7 +911,15 @@ func (p *Parser) parseType() Expr {
 			p.synchronize()
 			return nil
 		}
-		return &TupleTypeExpr{Types: types, Pos: tok}
+		if p.cur().Kind == lexer.RArrow {
+			p.eat()
+			ret := p.parseType()
+			if ret == nil {
+				return nil
+			}
+			return &FuncTypeExpr{Range: p.rangeFrom(tok), Params: types, Ret: ret, Pos: tok}
+		}
+		return &TupleTypeExpr{Range: p.rangeFrom(tok), Types: types, Pos: tok}
 	default:
 		p.errorAt(tok, fmt.Sprintf("expected type, got %q (%v)", tok.Lexeme, tok.Kind))
 		return nil
@@ -730,6 +927,10 @@ func (p *Parser) parseType() Expr {
 }
 
 func (p *Parser) recordTypeExpr(pub bool) Expr {
+	start := p.cur()
+	if pub {
+		start = p.prev()
+	}
 	p.expect(lexer.KwType)
 	nameTok, ok := p.expect(lexer.Identifier)
 	if !ok {
@@ -737,48 +938,115 @@ func (p *Parser) recordTypeExpr(pub bool) Expr {
 	}
 	var body Expr
 	if p.cur().Kind == lexer.LeftBrace {
+		lbrace := p.eat()
+		if p.isVariantBody() {
+			body = p.parseVariants(nameTok, lbrace)
+		} else {
+			body = p.parseRecordFields(nameTok, lbrace)
+		}
+		if body == nil {
+			return nil
+		}
+	}
+	return &TypeDeclExpr{
+		Range: p.rangeFrom(start),
+		Pub:   pub,
+		Name:  nameTok,
+		Body:  body,
+		Pos:   nameTok,
+	}
+}
+
+// isVariantBody reports whether a type body lists constructors
+// (`A(Int) | B`) rather than record fields (`a: Int`).
+func (p *Parser) isVariantBody() bool {
+	if p.cur().Kind == lexer.Vbar {
+		return true
+	}
+	if p.cur().Kind != lexer.Identifier {
+		return false
+	}
+	switch p.peek(1).Kind {
+	case lexer.LeftParen, lexer.Vbar, lexer.RightBrace:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) parseRecordFields(nameTok, lbrace lexer.Token) Expr {
+	fields := []Param{}
+	for p.cur().Kind != lexer.RightBrace && p.cur().Kind != lexer.EndOfFile {
+		fieldTok, ok := p.expect(lexer.Identifier)
+		if !ok {
+			return nil
+		}
+		if p.cur().Kind != lexer.Colon {
+			p.errorAt(fieldTok, fmt.Sprintf("expected ':' after field name %s", fieldTok.Lexeme))
+		}
 		p.eat()
-		fields := []Param{}
-		for p.cur().Kind != lexer.RightBrace && p.cur().Kind != lexer.EndOfFile {
-			if p.cur().Kind == lexer.Comment {
+		fieldType := p.parseType()
+		if fieldType == nil {
+			return nil
+		}
+		fields = append(fields, Param{Range: p.rangeFrom(fieldTok), Name: fieldTok, Type: fieldType})
+		if p.cur().Kind == lexer.Comma {
+			p.eat()
+		}
+	}
+	if _, ok := p.expect(lexer.RightBrace); !ok {
+		p.synchronize()
+		return nil
+	}
+	return &RecordTypeExpr{Range: p.rangeFrom(lbrace), Name: nameTok, Fields: fields, Pos: nameTok}
+}
+
+func (p *Parser) parseVariants(nameTok, lbrace lexer.Token) Expr {
+	variants := []Variant{}
+	for p.cur().Kind != lexer.RightBrace && p.cur().Kind != lexer.EndOfFile {
+		if p.cur().Kind == lexer.Vbar {
+			p.eat()
+			continue
+		}
+		ctorTok, ok := p.expect(lexer.Identifier)
+		if !ok {
+			return nil
+		}
+		fields := []Expr{}
+		if p.cur().Kind == lexer.LeftParen {
+			p.eat()
+			for p.cur().Kind != lexer.RightParen && p.cur().Kind != lexer.EndOfFile {
+				fieldType := p.parseType()
+				if fieldType == nil {
+					return nil
+				}
+				fields = append(fields, fieldType)
+				if p.cur().Kind != lexer.Comma {
+					break
+				}
 				p.eat()
-				continue
 			}
-			fieldTok, ok := p.expect(lexer.Identifier)
-			if !ok {
+			if _, ok := p.expect(lexer.RightParen); !ok {
 				return nil
 			}
-			if p.cur().Kind != lexer.Colon {
-				p.errorAt(fieldTok, fmt.Sprintf("expected ':' after field name %s", fieldTok.Lexeme))
-			}
-			p.eat()
-			fieldType := p.parseType()
-			if fieldType == nil {
-				return nil
-			}
-			fields = append(fields, Param{Name: fieldTok, Type: fieldType})
-			if p.cur().Kind == lexer.Comma {
-				p.eat()
-			}
 		}
-		if _, ok := p.expect(lexer.RightBrace); !ok {
+		variants = append(variants, Variant{Name: ctorTok, Fields: fields})
+		if p.cur().Kind != lexer.Vbar && p.cur().Kind != lexer.RightBrace {
+			p.errorAt(p.cur(), fmt.Sprintf("expected '|' or '}' after constructor %s", ctorTok.Lexeme))
 			p.synchronize()
 			return nil
 		}
-		body = &RecordTypeExpr{Name: nameTok, Fields: fields, Pos: nameTok}
 	}
-	return &TypeDeclExpr{
-		Pub:  pub,
-		Name: nameTok,
-		Body: body,
-		Pos:  nameTok,
+	if _, ok := p.expect(lexer.RightBrace); !ok {
+		p.synchronize()
+		return nil
 	}
+	return &VariantTypeExpr{Range: p.rangeFrom(lbrace), Name: nameTok, Variants: variants, Pos: nameTok}
 }
 
 func (p *Parser) parseDecorators() []Decorator {
 	decorators := []Decorator{}
 	for p.cur().Kind == lexer.At {
-		p.eat()
+		at := p.eat()
 		nameTok, ok := p.expect(lexer.Identifier)
 		if !ok {
 			p.errorAt(p.cur(), "expected decorator name after '@'")
@@ -800,7 +1068,7 @@ func (p *Parser) parseDecorators() []Decorator {
 			}
 			p.expect(lexer.RightParen)
 		}
-		decorators = append(decorators, Decorator{Name: nameTok.Lexeme, Args: args, Pos: nameTok})
+		decorators = append(decorators, Decorator{Range: p.rangeFrom(at), Name: nameTok.Lexeme, Args: args, Pos: nameTok})
 	}
 	return decorators
 }
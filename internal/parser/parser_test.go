@@ -1,11 +1,14 @@
 package parser
 
 import (
+	"flint/internal/diag"
 	"flint/internal/lexer"
+	"fmt"
+	"strings"
 	"testing"
 )
 
-func parseSrc(t *testing.T, src string) (*Program, []string) {
+func parseSrc(t *testing.T, src string) (*Program, diag.List) {
 	t.Helper()
 
 	l := lexer.New(src, "test.flint")
@@ -60,6 +63,44 @@ func TestParseStringLiteral(t *testing.T) {
 	}
 }
 
+func TestInterpolatedString(t *testing.T) {
+	prog, errs := parseSrc(t, `"x = {x + 1}, {f("\{")}!"`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	s, ok := prog.Exprs[0].(*InterpolatedString)
+	if !ok {
+		t.Fatalf("expected InterpolatedString, got %T", prog.Exprs[0])
+	}
+	if len(s.Parts) != 5 {
+		t.Fatalf("expected 5 parts, got %d", len(s.Parts))
+	}
+	if lit, ok := s.Parts[0].(*StringLiteral); !ok || lit.Value != "x = " {
+		t.Fatalf("unexpected first part %#v", s.Parts[0])
+	}
+	sum, ok := s.Parts[1].(*InfixExpr)
+	if !ok {
+		t.Fatalf("expected InfixExpr, got %T", s.Parts[1])
+	}
+	if x := sum.Left.(*Identifier); x.Pos.Column != 7 {
+		t.Fatalf("expected x at column 7, got %d", x.Pos.Column)
+	}
+	call, ok := s.Parts[3].(*CallExpr)
+	if !ok {
+		t.Fatalf("expected CallExpr, got %T", s.Parts[3])
+	}
+	if arg := call.Args[0].(*StringLiteral); arg.Value != "{" {
+		t.Fatalf("expected an escaped brace, got %q", arg.Value)
+	}
+
+	_, errs = parseSrc(t, `"{}"`)
+	if len(errs) != 1 || errs[0].Message != "empty {} in string literal" {
+		t.Fatalf("expected an empty hole to be reported, got %v", errs)
+	}
+}
+
 func TestOperatorPrecedence(t *testing.T) {
 	prog, errs := parseSrc(t, "1 + 2 * 3")
 
@@ -169,9 +210,319 @@ func TestMissingRHS(t *testing.T) {
 }
 
 func TestBadFunctionSyntax(t *testing.T) {
-	_, errs := parseSrc(t, `fn (x) { x }`)
+	_, errs := parseSrc(t, `pub fn (x) { x }`)
 
 	if len(errs) == 0 {
 		t.Fatal("expected error for missing function name")
 	}
 }
+
+func TestRecordLiteral(t *testing.T) {
+	prog, errs := parseSrc(t, `Point { x: 1, y: 2 }.x`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	access, ok := prog.Exprs[0].(*FieldAccessExpr)
+	if !ok {
+		t.Fatalf("expected FieldAccessExpr, got %T", prog.Exprs[0])
+	}
+
+	rec, ok := access.Left.(*RecordExpr)
+	if !ok {
+		t.Fatalf("expected RecordExpr, got %T", access.Left)
+	}
+
+	if rec.Name.Lexeme != "Point" || len(rec.Fields) != 2 {
+		t.Fatalf("unexpected record literal %s with %d fields", rec.Name.Lexeme, len(rec.Fields))
+	}
+}
+
+func TestQualifiedRecordLiteral(t *testing.T) {
+	prog, errs := parseSrc(t, `models:User { name: "x" }`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	rec, ok := prog.Exprs[0].(*RecordExpr)
+	if !ok {
+		t.Fatalf("expected RecordExpr, got %T", prog.Exprs[0])
+	}
+	if rec.Module == nil || rec.Module.Name != "models" || rec.Name.Lexeme != "User" || len(rec.Fields) != 1 {
+		t.Fatalf("unexpected record literal %s", DumpExpr(rec))
+	}
+	if rec.First.Lexeme != "models" {
+		t.Fatalf("expected the literal to start at its module, got %q", rec.First.Lexeme)
+	}
+}
+
+func TestLowercaseBraceIsNotRecord(t *testing.T) {
+	prog, errs := parseSrc(t, `match x { | 1 -> 2 | _ -> 3 }`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	if _, ok := prog.Exprs[0].(*MatchExpr); !ok {
+		t.Fatalf("expected MatchExpr, got %T", prog.Exprs[0])
+	}
+}
+
+func TestVariantTypeDecl(t *testing.T) {
+	prog, errs := parseSrc(t, `type Shape {
+	| Circle(Float)
+	| Rect(Float, Float)
+	| Empty
+}`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	decl, ok := prog.Exprs[0].(*TypeDeclExpr)
+	if !ok {
+		t.Fatalf("expected TypeDeclExpr, got %T", prog.Exprs[0])
+	}
+
+	body, ok := decl.Body.(*VariantTypeExpr)
+	if !ok {
+		t.Fatalf("expected VariantTypeExpr, got %T", decl.Body)
+	}
+
+	if len(body.Variants) != 3 || len(body.Variants[1].Fields) != 2 || len(body.Variants[2].Fields) != 0 {
+		t.Fatalf("unexpected variants: %+v", body.Variants)
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	prog, errs := parseSrc(t, `match x {
+	| (a, _, 3) -> 1
+	| [head, ..tail] -> 2
+	| 1..10 -> 3
+	| 1 | 2 as n -> 4
+	| Some(-1) -> 5
+	| _ -> 6
+}`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	m, ok := prog.Exprs[0].(*MatchExpr)
+	if !ok {
+		t.Fatalf("expected MatchExpr, got %T", prog.Exprs[0])
+	}
+
+	want := []string{"TuplePattern", "ListPattern", "RangePattern", "AsPattern", "ConstructorPattern", "WildcardPattern"}
+	if len(m.Arms) != len(want) {
+		t.Fatalf("expected %d arms, got %d", len(want), len(m.Arms))
+	}
+	for i, arm := range m.Arms {
+		if arm.Pattern.NodeType() != want[i] {
+			t.Fatalf("arm %d: expected %s, got %s", i, want[i], arm.Pattern.NodeType())
+		}
+	}
+
+	list := m.Arms[1].Pattern.(*ListPattern)
+	if len(list.Elements) != 1 || list.Rest == nil {
+		t.Fatalf("unexpected list pattern: %+v", list)
+	}
+	as := m.Arms[3].Pattern.(*AsPattern)
+	if or, ok := as.Pattern.(*OrPattern); !ok || len(or.Alternatives) != 2 {
+		t.Fatalf("expected or-pattern under as, got %T", as.Pattern)
+	}
+}
+
+func TestDestructuringVal(t *testing.T) {
+	prog, errs := parseSrc(t, `val (q, r) = divmod(a, b)`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	decl, ok := prog.Exprs[0].(*VarDeclExpr)
+	if !ok {
+		t.Fatalf("expected VarDeclExpr, got %T", prog.Exprs[0])
+	}
+	if _, ok := decl.Pattern.(*TuplePattern); !ok {
+		t.Fatalf("expected tuple pattern, got %T", decl.Pattern)
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	prog, errs := parseSrc(t, `for (i, x) in pairs { total = total + x }
+for i in 0..n {}`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	loop, ok := prog.Exprs[0].(*ForExpr)
+	if !ok {
+		t.Fatalf("expected ForExpr, got %T", prog.Exprs[0])
+	}
+	if _, ok := loop.Pattern.(*TuplePattern); !ok {
+		t.Fatalf("expected tuple pattern, got %T", loop.Pattern)
+	}
+	if len(loop.Body.Exprs) != 1 {
+		t.Fatalf("expected one expression in the body, got %d", len(loop.Body.Exprs))
+	}
+	rng, ok := prog.Exprs[1].(*ForExpr).Iter.(*InfixExpr)
+	if !ok || rng.Operator.Kind != lexer.DotDot {
+		t.Fatalf("expected a range, got %T", prog.Exprs[1].(*ForExpr).Iter)
+	}
+}
+
+func TestFunctionTypeAnnotation(t *testing.T) {
+	prog, errs := parseSrc(t, `fn apply(f: (a, Int) -> b, x: a) b { f(x, 1) }`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	fn, ok := prog.Exprs[0].(*FuncDeclExpr)
+	if !ok {
+		t.Fatalf("expected FuncDeclExpr, got %T", prog.Exprs[0])
+	}
+	ft, ok := fn.Params[0].Type.(*FuncTypeExpr)
+	if !ok {
+		t.Fatalf("expected FuncTypeExpr, got %T", fn.Params[0].Type)
+	}
+	if len(ft.Params) != 2 || ft.Ret.(*TypeExpr).Name != "b" {
+		t.Fatalf("unexpected function type: %+v", ft)
+	}
+}
+
+func TestLambda(t *testing.T) {
+	prog, errs := parseSrc(t, `xs |> fn(x: Int) Int { x + 1 }`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	pipe, ok := prog.Exprs[0].(*PipelineExpr)
+	if !ok {
+		t.Fatalf("expected PipelineExpr, got %T", prog.Exprs[0])
+	}
+	lambda, ok := pipe.Right.(*LambdaExpr)
+	if !ok {
+		t.Fatalf("expected LambdaExpr, got %T", pipe.Right)
+	}
+	if len(lambda.Params) != 1 || lambda.Ret == nil {
+		t.Fatalf("unexpected lambda: %+v", lambda)
+	}
+}
+
+func TestChainedCall(t *testing.T) {
+	prog, errs := parseSrc(t, `make_adder(1)(2)`)
+
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	call, ok := prog.Exprs[0].(*CallExpr)
+	if !ok {
+		t.Fatalf("expected CallExpr, got %T", prog.Exprs[0])
+	}
+	if _, ok := call.Callee.(*CallExpr); !ok {
+		t.Fatalf("expected call as callee, got %T", call.Callee)
+	}
+}
+
+func TestNodesSpanTheirTokens(t *testing.T) {
+	src := "@inline\npub fn add(a: Int, b: Int) Int {\n\tval sum = f(a, [b, 2])\n\tsum + (a * b)\n}"
+	prog, errs := parseSrc(t, src)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	text := func(n Node) string {
+		s := n.Span()
+		lines := strings.Split(src, "\n")
+		if s.Line != s.EndLine {
+			return fmt.Sprintf("%d:%d-%d:%d", s.Line, s.Column, s.EndLine, s.EndColumn)
+		}
+		return lines[s.Line-1][s.Column-1 : s.EndColumn-1]
+	}
+
+	fn := prog.Exprs[0].(*FuncDeclExpr)
+	if got := text(fn); got != "1:1-5:2" {
+		t.Fatalf("function spans %s", got)
+	}
+	if got := text(&fn.Params[1]); got != "b: Int" {
+		t.Fatalf("parameter spans %q", got)
+	}
+	body := fn.Body.(*BlockExpr)
+	decl := body.Exprs[0].(*VarDeclExpr)
+	if got := text(decl); got != "val sum = f(a, [b, 2])" {
+		t.Fatalf("declaration spans %q", got)
+	}
+	if got := text(decl.Value.(*CallExpr).Args[1]); got != "[b, 2]" {
+		t.Fatalf("list spans %q", got)
+	}
+	if got := text(body.Exprs[1]); got != "sum + (a * b)" {
+		t.Fatalf("infix spans %q", got)
+	}
+}
+
+func TestCommentsMayAppearBetweenAnyTokens(t *testing.T) {
+	tokens, err := lexer.Tokenize(`fn main() Int {
+	match f(1, // first
+		2) {
+		// zero
+		| 0 -> 1
+		| _ -> 2 // other
+	}
+}`, "test.flint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, errs := ParseProgram(tokens)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	m := prog.Exprs[0].(*FuncDeclExpr).Body.(*BlockExpr).Exprs[0].(*MatchExpr)
+	if len(m.Arms) != 2 {
+		t.Fatalf("expected 2 arms, got %d", len(m.Arms))
+	}
+	found := false
+	for _, tr := range m.Arms[0].First.Leading {
+		found = found || (tr.Kind == lexer.LineComment && tr.Text == "// zero")
+	}
+	if !found {
+		t.Fatalf("expected the comment before the first arm, got %+v", m.Arms[0].First.Leading)
+	}
+}
+
+func TestDocCommentsAttachToDeclarations(t *testing.T) {
+	tokens, err := lexer.Tokenize(`/// Not attached: a blank line follows.
+
+/// Adds two numbers.
+///
+///   add(1, 2) == 3
+@external(c, "m", "add")
+pub fn add(a: Int, b: Int) Int
+
+// An ordinary comment.
+fn hidden() Int { 1 }
+
+/// A point.
+pub type Point { x: Int, y: Int }
+`, "test.flint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, errs := ParseProgram(tokens)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got, want := prog.Exprs[0].(*FuncDeclExpr).Doc, "Adds two numbers.\n\n  add(1, 2) == 3"; got != want {
+		t.Errorf("add: expected doc %q, got %q", want, got)
+	}
+	if got := prog.Exprs[1].(*FuncDeclExpr).Doc; got != "" {
+		t.Errorf("hidden: expected no doc, got %q", got)
+	}
+	if got := prog.Exprs[2].(*TypeDeclExpr).Doc; got != "A point." {
+		t.Errorf("Point: expected doc %q, got %q", "A point.", got)
+	}
+}
@@ -0,0 +1,170 @@
+package parser
+
+import (
+	"fmt"
+	"unicode"
+
+	"flint/internal/lexer"
+)
+
+// parsePattern parses `alt | alt | ...` optionally followed by `as name`.
+func (p *Parser) parsePattern() Pattern {
+	start := p.cur()
+	pat := p.parsePatternPrimary()
+	if pat == nil {
+		return nil
+	}
+	if p.cur().Kind == lexer.Vbar {
+		alts := []Pattern{pat}
+		for p.cur().Kind == lexer.Vbar {
+			p.eat()
+			alt := p.parsePatternPrimary()
+			if alt == nil {
+				return nil
+			}
+			alts = append(alts, alt)
+		}
+		pat = &OrPattern{Range: p.rangeFrom(start), Alternatives: alts, Pos: start}
+	}
+	if p.cur().Kind == lexer.KwAs {
+		p.eat()
+		name, ok := p.expect(lexer.Identifier)
+		if !ok {
+			return nil
+		}
+		pat = &AsPattern{Range: p.rangeFrom(start), Pattern: pat, Name: name}
+	}
+	return pat
+}
+
+func (p *Parser) parsePatternPrimary() Pattern {
+	tok := p.cur()
+	switch tok.Kind {
+	case lexer.Underscore:
+		p.eat()
+		return &WildcardPattern{Range: p.rangeFrom(tok), Pos: tok}
+	case lexer.Identifier:
+		p.eat()
+		if tok.Lexeme == "_" {
+			return &WildcardPattern{Range: p.rangeFrom(tok), Pos: tok}
+		}
+		if p.cur().Kind == lexer.LeftParen {
+			p.eat()
+			args, ok := p.parsePatternList(lexer.RightParen)
+			if !ok {
+				return nil
+			}
+			return &ConstructorPattern{Range: p.rangeFrom(tok), Name: tok, Args: args}
+		}
+		if unicode.IsUpper([]rune(tok.Lexeme)[0]) {
+			return &ConstructorPattern{Range: p.rangeFrom(tok), Name: tok}
+		}
+		return &BindingPattern{Range: p.rangeFrom(tok), Name: tok}
+	case lexer.LeftParen:
+		p.eat()
+		elems, ok := p.parsePatternList(lexer.RightParen)
+		if !ok {
+			return nil
+		}
+		if len(elems) == 1 {
+			return elems[0]
+		}
+		return &TuplePattern{Range: p.rangeFrom(tok), Elements: elems, Pos: tok}
+	case lexer.LeftBracket:
+		return p.parseListPattern()
+	case lexer.Int, lexer.Float, lexer.String, lexer.Byte, lexer.Bool, lexer.Minus:
+		low := p.parseLiteralPattern()
+		if low == nil {
+			return nil
+		}
+		if p.cur().Kind != lexer.DotDot {
+			return &LiteralPattern{Range: p.rangeFrom(tok), Value: low, Pos: tok}
+		}
+		p.eat()
+		high := p.parseLiteralPattern()
+		if high == nil {
+			return nil
+		}
+		return &RangePattern{Range: p.rangeFrom(tok), Low: low, High: high, Pos: tok}
+	}
+	p.errorAt(tok, fmt.Sprintf("expected pattern, got %q", tok.Lexeme))
+	return nil
+}
+
+// parseLiteralPattern parses a literal, folding a leading minus into
+// numeric literals.
+func (p *Parser) parseLiteralPattern() Expr {
+	tok := p.cur()
+	if tok.Kind == lexer.Minus {
+		p.eat()
+		switch lit := p.parsePrimary().(type) {
+		case *IntLiteral:
+			return &IntLiteral{Range: p.rangeFrom(tok), Value: -lit.Value, Raw: "-" + lit.Raw, Pos: tok}
+		case *FloatLiteral:
+			return &FloatLiteral{Range: p.rangeFrom(tok), Value: -lit.Value, Raw: "-" + lit.Raw, Pos: tok}
+		}
+		p.errorAt(tok, "expected number after '-' in pattern")
+		return nil
+	}
+	switch tok.Kind {
+	case lexer.Int, lexer.Float, lexer.String, lexer.Byte, lexer.Bool:
+		lit := p.parsePrimary()
+		if _, ok := lit.(*InterpolatedString); ok {
+			p.errorAt(tok, "interpolated strings cannot be used as patterns")
+			return nil
+		}
+		return lit
+	}
+	p.errorAt(tok, fmt.Sprintf("expected literal in pattern, got %q", tok.Lexeme))
+	return nil
+}
+
+// parsePatternList parses comma separated patterns up to and including the
+// closing token.
+func (p *Parser) parsePatternList(closing lexer.TokenKind) ([]Pattern, bool) {
+	pats := []Pattern{}
+	for p.cur().Kind != closing && p.cur().Kind != lexer.EndOfFile {
+		pat := p.parsePattern()
+		if pat == nil {
+			return nil, false
+		}
+		pats = append(pats, pat)
+		if p.cur().Kind != lexer.Comma {
+			break
+		}
+		p.eat()
+	}
+	if _, ok := p.expect(closing); !ok {
+		return nil, false
+	}
+	return pats, true
+}
+
+func (p *Parser) parseListPattern() Pattern {
+	start := p.eat()
+	list := &ListPattern{Elements: []Pattern{}, Pos: start}
+	for p.cur().Kind != lexer.RightBracket && p.cur().Kind != lexer.EndOfFile {
+		if p.cur().Kind == lexer.DotDot {
+			dots := p.eat()
+			list.Rest = &WildcardPattern{Range: p.rangeFrom(dots), Pos: dots}
+			if p.cur().Kind == lexer.Identifier || p.cur().Kind == lexer.Underscore {
+				list.Rest = p.parsePatternPrimary()
+			}
+			break
+		}
+		pat := p.parsePattern()
+		if pat == nil {
+			return nil
+		}
+		list.Elements = append(list.Elements, pat)
+		if p.cur().Kind != lexer.Comma {
+			break
+		}
+		p.eat()
+	}
+	if _, ok := p.expect(lexer.RightBracket); !ok {
+		return nil
+	}
+	list.Range = p.rangeFrom(start)
+	return list
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"flint/internal/diag"
+	"flint/internal/lexer"
+)
+
+var escapes = map[rune]rune{
+	'n': '\n', 't': '\t', 'r': '\r', '0': 0,
+	'\\': '\\', '\'': '\'', '"': '"', '{': '{', '}': '}',
+}
+
+// parseString parses the string literal tok. A literal holding `{expr}`
+// holes becomes an InterpolatedString; literal braces are written \{ and \}.
+func (p *Parser) parseString(tok lexer.Token) Expr {
+	raw := []rune(tok.Lexeme)
+	raw = raw[1 : len(raw)-1]
+	var parts []Expr
+	var text []rune
+	holes := false
+	// line and col are the position of raw[i] in the source.
+	line, col := tok.Line, tok.Column+1
+	advance := func(rs ...rune) {
+		for _, r := range rs {
+			if r == '\n' {
+				line, col = line+1, 1
+			} else {
+				col++
+			}
+		}
+	}
+	flush := func() {
+		if len(text) > 0 {
+			parts = append(parts, &StringLiteral{Range: Range{First: tok, Last: tok}, Value: string(text), Pos: tok})
+			text = nil
+		}
+	}
+	for i := 0; i < len(raw); i++ {
+		switch r := raw[i]; r {
+		case '\\':
+			if i+1 == len(raw) {
+				p.errorAt(tok, "invalid string literal")
+				return nil
+			}
+			c, ok := escapes[raw[i+1]]
+			if !ok {
+				p.errorAt(tok, "invalid string literal")
+				return nil
+			}
+			text = append(text, c)
+			advance(raw[i : i+2]...)
+			i++
+		case '{':
+			end := holeEnd(raw, i)
+			if end < 0 {
+				p.errorAt(tok, `unterminated { in string literal: write \{ for a brace`)
+				return nil
+			}
+			advance(r)
+			flush()
+			holes = true
+			expr := p.parseHole(tok, raw[i+1:end], line, col)
+			if expr == nil {
+				return nil
+			}
+			parts = append(parts, expr)
+			advance(raw[i+1 : end+1]...)
+			i = end
+		case '}':
+			p.errorAt(tok, `unmatched } in string literal: write \} for a brace`)
+			return nil
+		default:
+			text = append(text, r)
+			advance(r)
+		}
+	}
+	if !holes {
+		return &StringLiteral{Range: p.rangeFrom(tok), Value: string(text), Pos: tok}
+	}
+	flush()
+	return &InterpolatedString{Range: p.rangeFrom(tok), Parts: parts, Pos: tok}
+}
+
+// parseHole parses src, the expression in a hole of the string literal tok,
+// which starts at line and col.
+func (p *Parser) parseHole(tok lexer.Token, src []rune, line, col int) Expr {
+	shift := func(l, c int) (int, int) {
+		if l == 1 {
+			return line, col + c - 1
+		}
+		return line + l - 1, c
+	}
+	tokens, err := lexer.Tokenize(string(src), tok.File)
+	for i := range tokens {
+		tokens[i].Line, tokens[i].Column = shift(tokens[i].Line, tokens[i].Column)
+		tokens[i].Source = tok.Source
+	}
+	if lexDiags, ok := err.(diag.List); ok {
+		for _, d := range lexDiags {
+			d.Span.Line, d.Span.Column = shift(d.Span.Line, d.Span.Column)
+			d.Span.EndLine, d.Span.EndColumn = shift(d.Span.EndLine, d.Span.EndColumn)
+			d.Source = tok.Source
+			p.errors.Add(d)
+		}
+		return nil
+	}
+	sub := new(tokens)
+	if sub.cur().Kind == lexer.EndOfFile {
+		p.errorAt(tok, "empty {} in string literal")
+		return nil
+	}
+	expr := sub.parseExpression(0)
+	if expr != nil && sub.cur().Kind != lexer.EndOfFile {
+		sub.errorAt(sub.cur(), "expected } after the expression in the string literal")
+		expr = nil
+	}
+	p.errors = append(p.errors, sub.errors...)
+	return expr
+}
+
+// holeEnd returns the index of the brace closing the hole that starts at
+// raw[i], or -1 if there is none.
+func holeEnd(raw []rune, i int) int {
+	depth := 0
+	for ; i < len(raw); i++ {
+		switch raw[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '"':
+			if i = stringEnd(raw, i); i < 0 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+// stringEnd returns the index of the quote closing the string literal that
+// starts at raw[i], or -1 if there is none.
+func stringEnd(raw []rune, i int) int {
+	for i++; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '{':
+			if i = holeEnd(raw, i); i < 0 {
+				return -1
+			}
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
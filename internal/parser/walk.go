@@ -0,0 +1,106 @@
+package parser
+
+// Inspect calls f for e and then, while f returns true, for each of the
+// expressions nested in it, depth first. Type annotations are not visited;
+// the expressions inside patterns are.
+func Inspect(e Expr, f func(Expr) bool) {
+	if e == nil || !f(e) {
+		return
+	}
+	switch n := e.(type) {
+	case *PrefixExpr:
+		Inspect(n.Right, f)
+	case *InfixExpr:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *CallExpr:
+		Inspect(n.Callee, f)
+		for _, a := range n.Args {
+			Inspect(a, f)
+		}
+	case *VarDeclExpr:
+		inspectPattern(n.Pattern, f)
+		Inspect(n.Value, f)
+	case *FuncDeclExpr:
+		Inspect(n.Body, f)
+	case *LambdaExpr:
+		Inspect(n.Body, f)
+	case *BlockExpr:
+		for _, x := range n.Exprs {
+			Inspect(x, f)
+		}
+	case *QualifiedExpr:
+		Inspect(n.Left, f)
+	case *FieldAccessExpr:
+		Inspect(n.Left, f)
+	case *IfExpr:
+		Inspect(n.Cond, f)
+		Inspect(n.Then, f)
+		Inspect(n.Else, f)
+	case *ForExpr:
+		inspectPattern(n.Pattern, f)
+		Inspect(n.Iter, f)
+		Inspect(n.Body, f)
+	case *MatchExpr:
+		Inspect(n.Value, f)
+		for _, arm := range n.Arms {
+			inspectPattern(arm.Pattern, f)
+			Inspect(arm.Guard, f)
+			Inspect(arm.Body, f)
+		}
+	case *PipelineExpr:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *InterpolatedString:
+		for _, x := range n.Parts {
+			Inspect(x, f)
+		}
+	case *ListExpr:
+		for _, x := range n.Elements {
+			Inspect(x, f)
+		}
+	case *TupleExpr:
+		for _, x := range n.Elements {
+			Inspect(x, f)
+		}
+	case *RecordExpr:
+		for _, fi := range n.Fields {
+			Inspect(fi.Value, f)
+		}
+	case *AssignExpr:
+		Inspect(n.Name, f)
+		Inspect(n.Value, f)
+	case *IndexExpr:
+		Inspect(n.Target, f)
+		Inspect(n.Index, f)
+	}
+}
+
+func inspectPattern(p Pattern, f func(Expr) bool) {
+	switch n := p.(type) {
+	case *LiteralPattern:
+		Inspect(n.Value, f)
+	case *RangePattern:
+		Inspect(n.Low, f)
+		Inspect(n.High, f)
+	case *TuplePattern:
+		for _, el := range n.Elements {
+			inspectPattern(el, f)
+		}
+	case *ListPattern:
+		for _, el := range n.Elements {
+			inspectPattern(el, f)
+		}
+		inspectPattern(n.Rest, f)
+	case *ConstructorPattern:
+		for _, a := range n.Args {
+			inspectPattern(a, f)
+		}
+	case *OrPattern:
+		for _, alt := range n.Alternatives {
+			inspectPattern(alt, f)
+		}
+	case *AsPattern:
+		inspectPattern(n.Pattern, f)
+	}
+}
@@ -0,0 +1,123 @@
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flint/internal/codegen"
+	"flint/internal/module"
+	"flint/internal/parser"
+	flintrt "flint/internal/runtime"
+	"flint/internal/toolchain"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Build compiles mods, the modules loaded from the entry of p, each after
+// those it imports, to an executable in the build directory and returns
+// its path. The object of each module is kept under the hash of its LLVM
+// IR, so only modules whose code changed are compiled again, and the
+// executable is only linked again when an object or a library changed.
+// Progress is written to log.
+func (p *Project) Build(mods []*module.Module, entry *module.Module, tc *toolchain.Toolchain, log io.Writer) (string, error) {
+	if err := p.checkLibraries(mods); err != nil {
+		return "", err
+	}
+	objDir := filepath.Join(p.BuildDir(), "obj")
+	if err := os.MkdirAll(objDir, 0755); err != nil {
+		return "", err
+	}
+	var objs []string
+	for _, m := range mods {
+		name := strings.ReplaceAll(m.Path, "/", ".")
+		ir := codegen.GenerateModule(m, m == entry)
+		obj := filepath.Join(objDir, name+"-"+hash(ir)+toolchain.EmitObj.Ext())
+		objs = append(objs, obj)
+		if _, err := os.Stat(obj); err == nil {
+			continue
+		}
+		fmt.Fprintf(log, "Compiling %s\n", m.Path)
+		if err := tc.Object(ir, obj+".tmp"); err != nil {
+			return "", err
+		}
+		if err := os.Rename(obj+".tmp", obj); err != nil {
+			return "", err
+		}
+		if err := removeStale(objDir, name, obj); err != nil {
+			return "", err
+		}
+	}
+
+	exe := filepath.Join(p.BuildDir(), p.Name+toolchain.EmitExe.Ext())
+	stampFile := filepath.Join(objDir, p.Name+".link")
+	stamp := hash(strings.Join(objs, "\n"), strings.Join(p.Libraries, " "), flintrt.Hash())
+	old, _ := os.ReadFile(stampFile)
+	if _, err := os.Stat(exe); err == nil && string(old) == stamp {
+		fmt.Fprintf(log, "%s is up to date\n", p.Name)
+		return exe, nil
+	}
+	fmt.Fprintf(log, "Linking %s\n", p.Name)
+	if err := tc.Link(objs, p.Libraries, exe); err != nil {
+		return "", err
+	}
+	return exe, os.WriteFile(stampFile, []byte(stamp), 0644)
+}
+
+// checkLibraries reports the first @external declaration naming a library
+// that is neither the runtime nor listed in the manifest.
+func (p *Project) checkLibraries(mods []*module.Module) error {
+	for _, m := range mods {
+		for _, e := range m.Prog.Exprs {
+			fn, ok := e.(*parser.FuncDeclExpr)
+			if !ok {
+				continue
+			}
+			for _, d := range fn.Decorators {
+				if d.Name != "external" || len(d.Args) != 3 {
+					continue
+				}
+				lib, ok := d.Args[1].(*parser.StringLiteral)
+				if !ok || lib.Value == flintrt.Library || slices.Contains(p.Libraries, lib.Value) {
+					continue
+				}
+				s := lib.Span()
+				return fmt.Errorf("%s:%d:%d: library %q is not listed in the libraries of %s", s.File, s.Line, s.Column, lib.Value, ManifestFile)
+			}
+		}
+	}
+	return nil
+}
+
+// removeStale removes the objects of the module name other than keep. Only
+// files named after name and a hash match, so the objects of a module whose
+// name merely starts with name are left alone.
+func removeStale(dir, name, keep string) error {
+	pattern := name + "-" + strings.Repeat("[0-9a-f]", hashLen) + toolchain.EmitObj.Ext()
+	stale, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, f := range stale {
+		if f != keep {
+			errs = append(errs, os.Remove(f))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hashLen is the number of hex digits hash returns.
+const hashLen = 16
+
+func hash(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:hashLen]
+}
@@ -0,0 +1,185 @@
+// Package project handles Flint projects: a directory holding a flint.toml
+// manifest, the source roots it lists and the build/ directory that
+// `flint build` writes to.
+package project
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ManifestFile is the name of the manifest at the root of a project.
+const ManifestFile = "flint.toml"
+
+// A Manifest describes a project:
+//
+//	[package]
+//	name = "hello"
+//	version = "0.1.0"
+//
+//	[build]
+//	entry = "src/main.flint"
+//	sources = ["src"]
+//	libraries = ["m"]
+//
+// Libraries are the C libraries, other than the Flint runtime, that
+// @external declarations link against.
+type Manifest struct {
+	Name      string
+	Version   string
+	Entry     string
+	Sources   []string
+	Libraries []string
+}
+
+// A Project is a manifest and the directory it is in.
+type Project struct {
+	Dir string
+	Manifest
+}
+
+// ParseManifest reads the subset of TOML that manifests use: tables of
+// keys set to strings or arrays of strings.
+func ParseManifest(src string) (*Manifest, error) {
+	m := &Manifest{Entry: "src/main.flint", Sources: []string{"src"}}
+	table := ""
+	for i, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		errorf := func(format string, args ...any) error {
+			return fmt.Errorf("%s:%d: %s", ManifestFile, i+1, fmt.Sprintf(format, args...))
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, errorf("expected ] at the end of the table header")
+			}
+			table = strings.TrimSpace(line[1 : len(line)-1])
+			if table != "package" && table != "build" {
+				return nil, errorf("unknown table [%s]", table)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, errorf("expected key = value")
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		var err error
+		switch table + "." + key {
+		case "package.name":
+			m.Name, err = parseString(value)
+		case "package.version":
+			m.Version, err = parseString(value)
+		case "build.entry":
+			m.Entry, err = parseString(value)
+		case "build.sources":
+			m.Sources, err = parseStrings(value)
+		case "build.libraries":
+			m.Libraries, err = parseStrings(value)
+		default:
+			if table == "" {
+				return nil, errorf("key %s outside of a table", key)
+			}
+			return nil, errorf("unknown key %s in [%s]", key, table)
+		}
+		if err != nil {
+			return nil, errorf("%s: %v", key, err)
+		}
+	}
+	if m.Name == "" {
+		return nil, fmt.Errorf("%s: missing name in [package]", ManifestFile)
+	}
+	return m, nil
+}
+
+// stripComment removes a # comment that is not inside a string.
+func stripComment(line string) string {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"' && (i == 0 || line[i-1] != '\\'):
+			quoted = !quoted
+		case r == '#' && !quoted:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseString(value string) (string, error) {
+	s, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return "", errors.New("expected a string")
+	}
+	return s, nil
+}
+
+func parseStrings(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, errors.New("expected an array of strings")
+	}
+	out := []string{}
+	for _, item := range strings.Split(value[1:len(value)-1], ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		s, err := parseString(item)
+		if err != nil {
+			return nil, errors.New("expected an array of strings")
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// Find returns the project that dir is in, looking for a manifest in dir
+// and then in each of its parents. It returns nil if there is none.
+func Find(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		src, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+		if err == nil {
+			m, err := ParseManifest(string(src))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", dir, err)
+			}
+			return &Project{Dir: dir, Manifest: *m}, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// Roots returns the source roots of p, which modules are found under.
+func (p *Project) Roots() []string {
+	roots := make([]string, len(p.Sources))
+	for i, s := range p.Sources {
+		roots[i] = filepath.Join(p.Dir, filepath.FromSlash(s))
+	}
+	return roots
+}
+
+// EntryFile returns the path of the module holding main.
+func (p *Project) EntryFile() string {
+	return filepath.Join(p.Dir, filepath.FromSlash(p.Entry))
+}
+
+// BuildDir returns the directory build outputs are written to.
+func (p *Project) BuildDir() string {
+	return filepath.Join(p.Dir, "build")
+}
@@ -0,0 +1,50 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var validName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// New scaffolds a project called name in a new directory dir: a manifest,
+// a main module printing a greeting, and a .gitignore for the build
+// directory.
+func New(dir, name string) (*Project, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid project name %q: use lowercase letters, digits and underscores, starting with a letter", name)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("%s already exists", dir)
+	}
+	files := map[string]string{
+		ManifestFile: fmt.Sprintf(`[package]
+name = %q
+version = "0.1.0"
+
+[build]
+entry = "src/main.flint"
+sources = ["src"]
+libraries = []
+`, name),
+		"src/main.flint": fmt.Sprintf(`use flint/io
+
+fn main() Nil {
+    io:println("Hello from %s!")
+}
+`, name),
+		".gitignore": "/build/\n",
+	}
+	for file, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return Find(dir)
+}
@@ -0,0 +1,124 @@
+package project
+
+import (
+	"flint/internal/module"
+	"flint/internal/toolchain"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	m, err := ParseManifest(`# The manifest.
+[package]
+name = "hello" # trailing comment
+version = "1.2.0"
+
+[build]
+sources = ["src", "lib"]
+libraries = ["m", "curl"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "hello" || m.Version != "1.2.0" || m.Entry != "src/main.flint" {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+	if !slices.Equal(m.Sources, []string{"src", "lib"}) || !slices.Equal(m.Libraries, []string{"m", "curl"}) {
+		t.Fatalf("unexpected lists in %+v", m)
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	tests := []struct{ src, err string }{
+		{"[package]\nversion = \"1\"\n", "flint.toml: missing name in [package]"},
+		{"name = \"x\"\n", "flint.toml:1: key name outside of a table"},
+		{"[package]\nname = x\n", "flint.toml:2: name: expected a string"},
+		{"[package]\nname = \"x\"\n[build]\nsources = \"src\"\n", "flint.toml:4: sources: expected an array of strings"},
+		{"[deps]\n", "flint.toml:1: unknown table [deps]"},
+		{"[build]\nflags = []\n", "flint.toml:2: unknown key flags in [build]"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseManifest(tt.src); err == nil || err.Error() != tt.err {
+			t.Errorf("%q: expected error %q, got %v", tt.src, tt.err, err)
+		}
+	}
+}
+
+func TestNewScaffoldsAProjectFoundFromItsSources(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hello")
+	if _, err := New(dir, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Find(filepath.Join(dir, "src"))
+	if err != nil || p == nil {
+		t.Fatalf("expected to find the project, got %v, %v", p, err)
+	}
+	if p.Dir != dir || p.Name != "hello" || p.EntryFile() != filepath.Join(dir, "src", "main.flint") {
+		t.Fatalf("unexpected project %+v", p)
+	}
+	if _, err := os.Stat(p.EntryFile()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(dir, "hello"); err == nil {
+		t.Fatal("expected an error creating a project over an existing directory")
+	}
+	if _, err := New(filepath.Join(t.TempDir(), "x"), "Bad-Name"); err == nil {
+		t.Fatal("expected an error for an invalid name")
+	}
+}
+
+func TestLibrariesMustBeListed(t *testing.T) {
+	dir := t.TempDir()
+	src := `@external(c, "flint_stdlib", "print")
+fn print(s: String) Nil
+
+@external(c, "m", "sqrt")
+fn sqrt(x: Float) Float
+
+fn main() Nil { print("hi") }
+`
+	file := filepath.Join(dir, "main.flint")
+	m, diags := module.NewLoader(dir).Load(file, src)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %v", diags)
+	}
+	p := &Project{Dir: dir, Manifest: Manifest{Name: "app"}}
+	err := p.checkLibraries([]*module.Module{m})
+	if err == nil || !strings.HasSuffix(err.Error(), `main.flint:4:14: library "m" is not listed in the libraries of flint.toml`) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	p.Libraries = []string{"m"}
+	if err := p.checkLibraries([]*module.Module{m}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoveStaleKeepsOtherModules(t *testing.T) {
+	dir := t.TempDir()
+	ext := toolchain.EmitObj.Ext()
+	keep := filepath.Join(dir, "app-"+hash("new")+ext)
+	stale := filepath.Join(dir, "app-"+hash("old")+ext)
+	others := []string{
+		filepath.Join(dir, "app-util-"+hash("util")+ext),
+		filepath.Join(dir, "app-notes"+ext),
+	}
+	for _, f := range append([]string{keep, stale}, others...) {
+		if err := os.WriteFile(f, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := removeStale(dir, "app", keep); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed", stale)
+	}
+	for _, f := range append([]string{keep}, others...) {
+		if _, err := os.Stat(f); err != nil {
+			t.Fatalf("expected %s to be kept: %v", f, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Version of the bundled runtime. It must match FLINT_RUNTIME_VERSION in
+// src/flint_stdlib.h and is bumped whenever the C ABI changes.
+const Version = "0.5.0"
+
+// Library is the library name that @external declarations give the
+// runtime.
+const Library = "flint_stdlib"
+
+// MainFile is the translation unit compiled into the `flint_stdlib` library
+// that @external declarations link against.
+const MainFile = "flint_stdlib.c"
+
+//go:embed src
+var sources embed.FS
+
+// Hash identifies the exact runtime sources compiled into this binary.
+func Hash() string {
+	h := sha256.New()
+	fs.WalkDir(sources, "src", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, _ := sources.ReadFile(path)
+		h.Write([]byte(path))
+		h.Write(data)
+		return nil
+	})
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// WriteSources copies the runtime sources into dir and returns the path of
+// the file to compile.
+func WriteSources(dir string) (string, error) {
+	entries, err := sources.ReadDir("src")
+	if err != nil {
+		return "", err
+	}
+	for _, e := range entries {
+		data, err := sources.ReadFile("src/" + e.Name())
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(dir, e.Name()), data, 0644); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, MainFile), nil
+}
+
+// CacheDir is where the compiled runtime object for this version is kept
+// between builds.
+func CacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "flint", "runtime", Version+"-"+Hash()), nil
+}
@@ -0,0 +1,48 @@
+package runtime
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVersionMatchesHeader(t *testing.T) {
+	header, err := sources.ReadFile("src/flint_stdlib.h")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `#define FLINT_RUNTIME_VERSION "` + Version + `"`
+	if !strings.Contains(string(header), want) {
+		t.Fatalf("flint_stdlib.h does not define runtime version %s", Version)
+	}
+}
+
+func TestWriteSources(t *testing.T) {
+	dir := t.TempDir()
+
+	main, err := WriteSources(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sym := range []string{"flint_string_new", "flint_string_concat", "flint_println", "flint_list_at", "flint_list_push", "flint_panic", "flint_fs_read_file", "flint_os_args", "flint_set_args", "to_string"} {
+		if !strings.Contains(string(data), sym) {
+			t.Fatalf("runtime source is missing %s", sym)
+		}
+	}
+}
+
+func TestHashIsStable(t *testing.T) {
+	if Hash() != Hash() {
+		t.Fatal("runtime hash changed between calls")
+	}
+	if len(Hash()) != 12 {
+		t.Fatalf("expected 12 character hash, got %q", Hash())
+	}
+}
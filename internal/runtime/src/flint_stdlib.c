@@ -0,0 +1,511 @@
+#include "flint_stdlib.h"
+
+#include <errno.h>
+#include <math.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+const char *flint_runtime_version(void)
+{
+    return FLINT_RUNTIME_VERSION;
+}
+
+static void *flint_alloc(size_t size)
+{
+    void *p = malloc(size ? size : 1);
+    if (p == NULL)
+    {
+        fputs("panic: out of memory\n", stderr);
+        exit(1);
+    }
+    return p;
+}
+
+void flint_panic(const char *msg, const char *file, int64_t line, int64_t col)
+{
+    fflush(stdout);
+    fprintf(stderr, "panic: %s\n", msg);
+    if (file != NULL)
+    {
+        fprintf(stderr, "  --> %s:%lld:%lld\n", file, (long long)line, (long long)col);
+    }
+    fflush(stderr);
+    exit(101);
+}
+
+char *flint_string_new(const char *bytes, int64_t len)
+{
+    int64_t *header = flint_alloc(sizeof(int64_t) + (size_t)len + 1);
+    char *s = (char *)(header + 1);
+    *header = len;
+    memcpy(s, bytes, (size_t)len);
+    s[len] = '\0';
+    return s;
+}
+
+int64_t flint_string_length(const char *s)
+{
+    return FLINT_STRING_LENGTH(s);
+}
+
+uint8_t flint_string_at(const char *s, int64_t index, const char *file, int64_t line, int64_t col)
+{
+    int64_t len = FLINT_STRING_LENGTH(s);
+    if (index < 0 || index >= len)
+    {
+        char msg[96];
+        snprintf(msg, sizeof msg, "string index out of bounds: %lld (length %lld)",
+                 (long long)index, (long long)len);
+        flint_panic(msg, file, line, col);
+    }
+    return (uint8_t)s[index];
+}
+
+char *flint_string_concat(const char *a, const char *b)
+{
+    int64_t la = FLINT_STRING_LENGTH(a);
+    int64_t lb = FLINT_STRING_LENGTH(b);
+    char *out = flint_string_new(a, la + lb);
+    memcpy(out + la, b, (size_t)lb);
+    return out;
+}
+
+/* Returns the bytes of s from start up to end, both clamped to the string;
+ * the result is empty if end comes before start. */
+char *flint_string_slice(const char *s, int64_t start, int64_t end)
+{
+    int64_t len = FLINT_STRING_LENGTH(s);
+    start = start < 0 ? 0 : start > len ? len : start;
+    end = end < 0 ? 0 : end > len ? len : end;
+    return flint_string_new(s + start, end > start ? end - start : 0);
+}
+
+static void flint_list_append_string(flint_list *list, const char *bytes, int64_t len)
+{
+    *(char **)flint_list_push(list) = flint_string_new(bytes, len);
+}
+
+/* Splits s around each occurrence of sep, or into single bytes if sep is
+ * empty. */
+flint_list *flint_string_split(const char *s, const char *sep)
+{
+    int64_t len = FLINT_STRING_LENGTH(s);
+    int64_t seplen = FLINT_STRING_LENGTH(sep);
+    flint_list *out = flint_list_new(sizeof(char *), 0);
+    if (seplen == 0)
+    {
+        for (int64_t i = 0; i < len; i++)
+        {
+            flint_list_append_string(out, s + i, 1);
+        }
+        return out;
+    }
+    int64_t start = 0;
+    for (int64_t i = 0; i + seplen <= len;)
+    {
+        if (memcmp(s + i, sep, (size_t)seplen) == 0)
+        {
+            flint_list_append_string(out, s + start, i - start);
+            i += seplen;
+            start = i;
+        }
+        else
+        {
+            i++;
+        }
+    }
+    flint_list_append_string(out, s + start, len - start);
+    return out;
+}
+
+bool flint_string_contains(const char *s, const char *sub)
+{
+    int64_t len = FLINT_STRING_LENGTH(s);
+    int64_t sublen = FLINT_STRING_LENGTH(sub);
+    for (int64_t i = 0; i + sublen <= len; i++)
+    {
+        if (memcmp(s + i, sub, (size_t)sublen) == 0)
+        {
+            return true;
+        }
+    }
+    return false;
+}
+
+/* Upper-cases ASCII letters only, whatever the locale. */
+char *flint_string_to_upper(const char *s)
+{
+    int64_t len = FLINT_STRING_LENGTH(s);
+    char *out = flint_string_new(s, len);
+    for (int64_t i = 0; i < len; i++)
+    {
+        if (out[i] >= 'a' && out[i] <= 'z')
+        {
+            out[i] = (char)(out[i] - 'a' + 'A');
+        }
+    }
+    return out;
+}
+
+/* Parses s as a decimal integer with an optional sign, storing it in out.
+ * It returns false, leaving out at 0, if s holds anything else or the
+ * integer does not fit in 64 bits. */
+bool flint_string_parse_int(const char *s, int64_t *out)
+{
+    int64_t len = FLINT_STRING_LENGTH(s);
+    int64_t i = 0;
+    bool negative = false;
+    *out = 0;
+    if (i < len && (s[i] == '+' || s[i] == '-'))
+    {
+        negative = s[i++] == '-';
+    }
+    if (i == len)
+    {
+        return false;
+    }
+    /* Accumulate negatively, as INT64_MIN has no positive counterpart. */
+    int64_t n = 0;
+    for (; i < len; i++)
+    {
+        if (s[i] < '0' || s[i] > '9')
+        {
+            return false;
+        }
+        int digit = s[i] - '0';
+        if (n < (INT64_MIN + digit) / 10)
+        {
+            return false;
+        }
+        n = n * 10 - digit;
+    }
+    if (!negative)
+    {
+        if (n == INT64_MIN)
+        {
+            return false;
+        }
+        n = -n;
+    }
+    *out = n;
+    return true;
+}
+
+void flint_print(const char *s)
+{
+    fwrite(s, 1, (size_t)FLINT_STRING_LENGTH(s), stdout);
+}
+
+void flint_println(const char *s)
+{
+    flint_print(s);
+    putchar('\n');
+}
+
+char *flint_int_to_string(int64_t i)
+{
+    char buf[32];
+    int n = snprintf(buf, sizeof buf, "%lld", (long long)i);
+    return flint_string_new(buf, n);
+}
+
+/* Formats f as `flint run` does, with Go's strconv.FormatFloat(f, 'g', -1,
+ * 64): the fewest digits that read back as f, in exponent form only when
+ * the exponent is below -4 or at least 6, whatever the number of digits. */
+char *flint_float_to_string(double f)
+{
+    if (isnan(f))
+    {
+        return flint_string_new("NaN", 3);
+    }
+    if (isinf(f))
+    {
+        return f > 0 ? flint_string_new("+Inf", 4) : flint_string_new("-Inf", 4);
+    }
+    char buf[40];
+    int digits = 1;
+    for (; digits < 17; digits++)
+    {
+        snprintf(buf, sizeof buf, "%.*e", digits - 1, f);
+        if (strtod(buf, NULL) == f)
+        {
+            break;
+        }
+    }
+    snprintf(buf, sizeof buf, "%.*e", digits - 1, f);
+    int exp = atoi(strchr(buf, 'e') + 1);
+    int n;
+    if (exp < -4 || exp >= 6)
+    {
+        n = (int)strlen(buf);
+    }
+    else
+    {
+        int decimals = digits - 1 - exp;
+        n = snprintf(buf, sizeof buf, "%.*f", decimals > 0 ? decimals : 0, f);
+    }
+    return flint_string_new(buf, n);
+}
+
+char *flint_bool_to_string(bool b)
+{
+    return b ? flint_string_new("True", 4) : flint_string_new("False", 5);
+}
+
+char *flint_byte_to_string(uint8_t b)
+{
+    char c = (char)b;
+    return flint_string_new(&c, 1);
+}
+
+flint_list *flint_list_new(int64_t elem_size, int64_t len)
+{
+    if (len < 0)
+    {
+        flint_panic("negative list length", NULL, 0, 0);
+    }
+    flint_list *list = flint_alloc(sizeof(flint_list));
+    list->len = len;
+    list->cap = len;
+    list->elem_size = elem_size;
+    list->data = flint_alloc((size_t)(elem_size * len));
+    memset(list->data, 0, (size_t)(elem_size * len));
+    return list;
+}
+
+int64_t flint_list_length(const flint_list *list)
+{
+    return list->len;
+}
+
+void *flint_list_at(const flint_list *list, int64_t index, const char *file, int64_t line, int64_t col)
+{
+    if (index < 0 || index >= list->len)
+    {
+        char msg[96];
+        snprintf(msg, sizeof msg, "list index out of bounds: %lld (length %lld)",
+                 (long long)index, (long long)list->len);
+        flint_panic(msg, file, line, col);
+    }
+    return (char *)list->data + index * list->elem_size;
+}
+
+/* Appends an element to list, doubling its capacity when it is full, and
+ * returns a pointer to the new element for the caller to store into. */
+void *flint_list_push(flint_list *list)
+{
+    if (list->len == list->cap)
+    {
+        int64_t cap = list->cap ? list->cap * 2 : 4;
+        void *data = realloc(list->data, (size_t)(cap * list->elem_size));
+        if (data == NULL)
+        {
+            fputs("panic: out of memory\n", stderr);
+            exit(1);
+        }
+        list->data = data;
+        list->cap = cap;
+    }
+    return (char *)list->data + list->len++ * list->elem_size;
+}
+
+flint_list *flint_list_concat(const flint_list *a, const flint_list *b)
+{
+    flint_list *out = flint_list_new(a->elem_size, a->len + b->len);
+    memcpy(out->data, a->data, (size_t)(a->len * a->elem_size));
+    memcpy((char *)out->data + a->len * a->elem_size, b->data, (size_t)(b->len * b->elem_size));
+    return out;
+}
+
+flint_list *flint_list_reverse(const flint_list *list)
+{
+    flint_list *out = flint_list_new(list->elem_size, list->len);
+    for (int64_t i = 0; i < list->len; i++)
+    {
+        memcpy((char *)out->data + (list->len - 1 - i) * list->elem_size,
+               (char *)list->data + i * list->elem_size, (size_t)list->elem_size);
+    }
+    return out;
+}
+
+static int32_t flint_argc;
+static char **flint_argv;
+
+void flint_set_args(int32_t argc, char **argv)
+{
+    flint_argc = argc;
+    flint_argv = argv;
+}
+
+/* Returns the string a, b and c make, for error messages. */
+static char *flint_error_message(const char *a, const char *b, const char *c)
+{
+    size_t la = strlen(a), lb = strlen(b), lc = strlen(c);
+    char *buf = flint_alloc(la + lb + lc);
+    memcpy(buf, a, la);
+    memcpy(buf + la, b, lb);
+    memcpy(buf + la + lb, c, lc);
+    char *msg = flint_string_new(buf, (int64_t)(la + lb + lc));
+    free(buf);
+    return msg;
+}
+
+static char *flint_io_error(const char *path, char **err)
+{
+    *err = flint_error_message(path, ": ", strerror(errno));
+    return NULL;
+}
+
+char *flint_fs_read_file(const char *path, char **err)
+{
+    FILE *f = fopen(path, "rb");
+    if (f == NULL)
+    {
+        return flint_io_error(path, err);
+    }
+    size_t len = 0, cap = 4096;
+    char *buf = flint_alloc(cap);
+    for (;;)
+    {
+        len += fread(buf + len, 1, cap - len, f);
+        if (len < cap)
+        {
+            break;
+        }
+        cap *= 2;
+        char *grown = realloc(buf, cap);
+        if (grown == NULL)
+        {
+            fputs("panic: out of memory\n", stderr);
+            exit(1);
+        }
+        buf = grown;
+    }
+    if (ferror(f))
+    {
+        int saved = errno;
+        fclose(f);
+        free(buf);
+        errno = saved;
+        return flint_io_error(path, err);
+    }
+    fclose(f);
+    char *s = flint_string_new(buf, (int64_t)len);
+    free(buf);
+    return s;
+}
+
+/* Returns path on success, for Ok to hold. */
+char *flint_fs_write_file(const char *path, const char *contents, char **err)
+{
+    FILE *f = fopen(path, "wb");
+    if (f == NULL)
+    {
+        return flint_io_error(path, err);
+    }
+    size_t len = (size_t)FLINT_STRING_LENGTH(contents);
+    if (fwrite(contents, 1, len, f) != len)
+    {
+        int saved = errno;
+        fclose(f);
+        errno = saved;
+        return flint_io_error(path, err);
+    }
+    if (fclose(f) != 0)
+    {
+        return flint_io_error(path, err);
+    }
+    return (char *)path;
+}
+
+/* Reads a line without its "\n" or "\r\n". Standard output is flushed
+ * first so that a prompt written without a newline is shown. */
+char *flint_os_read_line(char **err)
+{
+    fflush(stdout);
+    size_t len = 0, cap = 128;
+    char *buf = flint_alloc(cap);
+    int c;
+    while ((c = getchar()) != EOF && c != '\n')
+    {
+        if (len == cap)
+        {
+            cap *= 2;
+            char *grown = realloc(buf, cap);
+            if (grown == NULL)
+            {
+                fputs("panic: out of memory\n", stderr);
+                exit(1);
+            }
+            buf = grown;
+        }
+        buf[len++] = (char)c;
+    }
+    if (c == EOF && (len == 0 || ferror(stdin)))
+    {
+        free(buf);
+        *err = ferror(stdin) ? flint_error_message("stdin", ": ", strerror(errno))
+                             : flint_string_new("end of input", 12);
+        return NULL;
+    }
+    if (len > 0 && buf[len - 1] == '\r')
+    {
+        len--;
+    }
+    char *s = flint_string_new(buf, (int64_t)len);
+    free(buf);
+    return s;
+}
+
+/* Returns the arguments flint_set_args was given, without the program
+ * name. */
+flint_list *flint_os_args(void)
+{
+    flint_list *out = flint_list_new(sizeof(char *), 0);
+    for (int32_t i = 1; i < flint_argc; i++)
+    {
+        flint_list_append_string(out, flint_argv[i], (int64_t)strlen(flint_argv[i]));
+    }
+    return out;
+}
+
+char *flint_os_env(const char *name, char **err)
+{
+    const char *v = getenv(name);
+    if (v == NULL)
+    {
+        *err = flint_error_message("environment variable ", name, " is not set");
+        return NULL;
+    }
+    return flint_string_new(v, (int64_t)strlen(v));
+}
+
+void flint_os_exit(int64_t code)
+{
+    fflush(stdout);
+    exit((int)code);
+}
+
+void print(const char *s)
+{
+    flint_print(s);
+}
+
+void println(const char *s)
+{
+    flint_println(s);
+}
+
+char *to_string(int64_t i)
+{
+    return flint_int_to_string(i);
+}
+
+void assert(bool cond)
+{
+    if (!cond)
+    {
+        flint_panic("assertion failed", NULL, 0, 0);
+    }
+}
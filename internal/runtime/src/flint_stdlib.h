@@ -0,0 +1,75 @@
+#ifndef FLINT_STDLIB_H
+#define FLINT_STDLIB_H
+
+#include <stdbool.h>
+#include <stdint.h>
+
+#define FLINT_RUNTIME_VERSION "0.5.0"
+
+/* Lists are heap allocated and never move once created. `data` holds
+ * `len` elements of `elem_size` bytes each, and room for `cap`; it is
+ * reallocated as the list grows. */
+typedef struct flint_list
+{
+    int64_t len;
+    int64_t cap;
+    int64_t elem_size;
+    void *data;
+} flint_list;
+
+/* Strings are NUL-terminated, so that they can be passed to C as they are,
+ * and carry their length in bytes in the int64_t just before the first
+ * byte. Every string a Flint program sees, literals included, is laid out
+ * this way: C functions returning strings to Flint must build them with
+ * flint_string_new. */
+#define FLINT_STRING_LENGTH(s) (((const int64_t *)(s))[-1])
+
+const char *flint_runtime_version(void);
+
+void flint_panic(const char *msg, const char *file, int64_t line, int64_t col);
+
+char *flint_string_new(const char *bytes, int64_t len);
+int64_t flint_string_length(const char *s);
+uint8_t flint_string_at(const char *s, int64_t index, const char *file, int64_t line, int64_t col);
+char *flint_string_concat(const char *a, const char *b);
+char *flint_string_slice(const char *s, int64_t start, int64_t end);
+flint_list *flint_string_split(const char *s, const char *sep);
+bool flint_string_contains(const char *s, const char *sub);
+char *flint_string_to_upper(const char *s);
+bool flint_string_parse_int(const char *s, int64_t *out);
+
+void flint_print(const char *s);
+void flint_println(const char *s);
+
+char *flint_int_to_string(int64_t i);
+char *flint_float_to_string(double f);
+char *flint_bool_to_string(bool b);
+char *flint_byte_to_string(uint8_t b);
+
+flint_list *flint_list_new(int64_t elem_size, int64_t len);
+int64_t flint_list_length(const flint_list *list);
+void *flint_list_at(const flint_list *list, int64_t index, const char *file, int64_t line, int64_t col);
+void *flint_list_push(flint_list *list);
+flint_list *flint_list_concat(const flint_list *a, const flint_list *b);
+flint_list *flint_list_reverse(const flint_list *list);
+
+/* Called by main with its arguments, for flint_os_args. */
+void flint_set_args(int32_t argc, char **argv);
+
+/* The functions of flint/fs and flint/os that can fail return NULL and set
+ * *err to a message saying why. */
+char *flint_fs_read_file(const char *path, char **err);
+char *flint_fs_write_file(const char *path, const char *contents, char **err);
+char *flint_os_read_line(char **err);
+flint_list *flint_os_args(void);
+char *flint_os_env(const char *name, char **err);
+void flint_os_exit(int64_t code);
+
+/* Symbols declared by `@external(c, "flint_stdlib", ...)` stubs, which
+ * programs written before the built-in modules existed still use. */
+void print(const char *s);
+void println(const char *s);
+char *to_string(int64_t i);
+void assert(bool cond);
+
+#endif
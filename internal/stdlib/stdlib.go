@@ -0,0 +1,140 @@
+// Package stdlib is the registry of the built-in modules, such as flint/io,
+// that every program may use without writing @external declarations. Each
+// function of a module is given by its Flint signature, from which the
+// typechecker builds the module's Env, and by the runtime function that
+// implements it, which code generation declares and calls. The few that
+// the runtime cannot implement alone, such as those taking a closure, are
+// defined by each backend instead. Modules may also declare types, such as
+// the Result that functions which can fail return.
+package stdlib
+
+import (
+	"slices"
+	"strings"
+)
+
+// A Func is a function of a built-in module.
+type Func struct {
+	// Sig declares the function without a body, as in
+	// `fn println(s: String) Nil`. Lowercase type names are type
+	// variables.
+	Sig string
+	Doc string
+	// Symbol is the C function of the runtime implementing the function,
+	// taking and returning int64_t for Int and double for Float. It is
+	// empty for functions that the backends define.
+	Symbol string
+	// Fallible functions return a Result. Their runtime function takes an
+	// extra `char **err` and returns the string Ok holds, or NULL after
+	// setting *err to the message Error holds.
+	Fallible bool
+}
+
+// Name returns the name Sig declares.
+func (f Func) Name() string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(f.Sig, "fn "), "(")
+	return name
+}
+
+// A Type is a type that built-in modules declare and export along with
+// its constructors.
+type Type struct {
+	// Decl declares the type, as in `type Result { Ok(String) | Error(String) }`.
+	Decl string
+	Doc  string
+}
+
+// Name returns the name Decl declares.
+func (t Type) Name() string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(t.Decl, "type "), " ")
+	return name
+}
+
+// A Module is a built-in module, its types and its functions.
+type Module struct {
+	Path  string
+	Types []Type
+	Funcs []Func
+}
+
+// Func returns the function of m called name.
+func (m *Module) Func(name string) (Func, bool) {
+	for _, f := range m.Funcs {
+		if f.Name() == name {
+			return f, true
+		}
+	}
+	return Func{}, false
+}
+
+var modules = map[string]*Module{}
+
+// Register adds m to the registry, replacing any module with its path.
+func Register(m *Module) {
+	modules[m.Path] = m
+}
+
+// Lookup returns the built-in module path names, such as "flint/io".
+func Lookup(path string) (*Module, bool) {
+	m, ok := modules[path]
+	return m, ok
+}
+
+// Modules returns the registered modules, sorted by path.
+func Modules() []*Module {
+	out := make([]*Module, 0, len(modules))
+	for _, m := range modules {
+		out = append(out, m)
+	}
+	slices.SortFunc(out, func(a, b *Module) int { return strings.Compare(a.Path, b.Path) })
+	return out
+}
+
+// Result is what fallible functions return: Ok with their result, or Error
+// with a message saying what went wrong. The modules returning it share it,
+// so a Result from one may be matched with the constructors of another.
+//
+// Types cannot take type parameters, so Ok always holds a String: fallible
+// functions return their result as text, and those with nothing to return,
+// such as fs:write_file, return the path they acted on. A function whose
+// result is not a String cannot be Fallible until Result is generic.
+var Result = Type{
+	Decl: "type Result { Ok(String) | Error(String) }",
+	Doc:  "The outcome of an operation that can fail: Ok with its result as a String, or Error with a message. Functions with no result of their own, such as fs:write_file, return Ok with the path they acted on.",
+}
+
+func init() {
+	Register(&Module{Path: "flint/io", Funcs: []Func{
+		{Sig: "fn print(s: String) Nil", Doc: "Writes s to standard output.", Symbol: "flint_print"},
+		{Sig: "fn println(s: String) Nil", Doc: "Writes s and a newline to standard output.", Symbol: "flint_println"},
+	}})
+	Register(&Module{Path: "flint/string", Funcs: []Func{
+		{Sig: "fn to_string(i: Int) String", Doc: "Formats i in decimal.", Symbol: "flint_int_to_string"},
+		{Sig: "fn from_float(f: Float) String", Doc: "Formats f in the shortest form that reads back as f.", Symbol: "flint_float_to_string"},
+		{Sig: "fn length(s: String) Int", Doc: "Returns the number of bytes in s.", Symbol: "flint_string_length"},
+		{Sig: "fn slice(s: String, start: Int, end: Int) String", Doc: "Returns the bytes of s from start up to end, both clamped to s. The result is empty if end comes before start.", Symbol: "flint_string_slice"},
+		{Sig: "fn split(s: String, sep: String) List(String)", Doc: "Splits s around each occurrence of sep, or into single bytes if sep is empty.", Symbol: "flint_string_split"},
+		{Sig: "fn contains(s: String, sub: String) Bool", Doc: "Reports whether sub occurs in s.", Symbol: "flint_string_contains"},
+		{Sig: "fn to_upper(s: String) String", Doc: "Upper-cases the ASCII letters of s.", Symbol: "flint_string_to_upper"},
+		{Sig: "fn parse_int(s: String) (Int, Bool)", Doc: "Parses s as a decimal integer with an optional sign. It returns the integer and True, or 0 and False if s holds anything else or the integer does not fit in an Int."},
+	}})
+	Register(&Module{Path: "flint/list", Funcs: []Func{
+		{Sig: "fn length(xs: List(a)) Int", Doc: "Returns the number of elements of xs."},
+		{Sig: "fn push(xs: List(a), x: a) Nil", Doc: "Appends x to xs."},
+		{Sig: "fn map(xs: List(a), f: (a) -> b) List(b)", Doc: "Returns a new list of f applied to each element of xs."},
+		{Sig: "fn filter(xs: List(a), keep: (a) -> Bool) List(a)", Doc: "Returns a new list of the elements of xs that keep holds for."},
+		{Sig: "fn fold(xs: List(a), init: b, f: (b, a) -> b) b", Doc: "Combines the elements of xs, from the first, with f, starting from init."},
+		{Sig: "fn reverse(xs: List(a)) List(a)", Doc: "Returns a new list of the elements of xs in reverse order.", Symbol: "flint_list_reverse"},
+		{Sig: "fn concat(xs: List(a), ys: List(a)) List(a)", Doc: "Returns a new list of the elements of xs followed by those of ys.", Symbol: "flint_list_concat"},
+	}})
+	Register(&Module{Path: "flint/fs", Types: []Type{Result}, Funcs: []Func{
+		{Sig: "fn read_file(path: String) Result", Doc: "Reads the whole file at path.", Symbol: "flint_fs_read_file", Fallible: true},
+		{Sig: "fn write_file(path: String, contents: String) Result", Doc: "Writes contents to the file at path, creating or truncating it. It returns Ok(path).", Symbol: "flint_fs_write_file", Fallible: true},
+	}})
+	Register(&Module{Path: "flint/os", Types: []Type{Result}, Funcs: []Func{
+		{Sig: "fn read_line() Result", Doc: "Reads a line from standard input, without its line ending. It returns Error(\"end of input\") once there are no more lines.", Symbol: "flint_os_read_line", Fallible: true},
+		{Sig: "fn args() List(String)", Doc: "Returns the command-line arguments of the program, without the program name.", Symbol: "flint_os_args"},
+		{Sig: "fn env(name: String) Result", Doc: "Returns the value of the environment variable name, or Error if it is not set.", Symbol: "flint_os_env", Fallible: true},
+		{Sig: "fn exit(code: Int) Nil", Doc: "Flushes standard output and ends the program with the status code.", Symbol: "flint_os_exit"},
+	}})
+}
@@ -0,0 +1,48 @@
+package stdlib
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestModulesDeclareEachFunctionOnce(t *testing.T) {
+	for _, m := range Modules() {
+		seen := map[string]bool{}
+		for _, f := range m.Funcs {
+			if seen[f.Name()] {
+				t.Errorf("%s declares %s twice", m.Path, f.Name())
+			}
+			seen[f.Name()] = true
+			if got, ok := m.Func(f.Name()); !ok || got.Sig != f.Sig {
+				t.Errorf("%s: cannot look up %s", m.Path, f.Name())
+			}
+		}
+	}
+	io, ok := Lookup("flint/io")
+	if !ok {
+		t.Fatal("expected flint/io to be registered")
+	}
+	if f, _ := io.Func("println"); f.Symbol != "flint_println" {
+		t.Fatalf("unexpected symbol %q for io:println", f.Symbol)
+	}
+}
+
+func TestFallibleFunctionsReturnResult(t *testing.T) {
+	for _, m := range Modules() {
+		for _, f := range m.Funcs {
+			if !f.Fallible {
+				continue
+			}
+			if !strings.HasSuffix(f.Sig, ") "+Result.Name()) {
+				t.Errorf("%s:%s is fallible but does not return Result: %s", m.Path, f.Name(), f.Sig)
+			}
+			if !slices.Contains(m.Types, Result) || f.Symbol == "" {
+				t.Errorf("%s:%s needs both Result and a runtime symbol", m.Path, f.Name())
+			}
+		}
+	}
+	if Result.Name() != "Result" {
+		t.Fatalf("unexpected name %q", Result.Name())
+	}
+}
@@ -0,0 +1,250 @@
+package toolchain
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	flintrt "flint/internal/runtime"
+)
+
+type EmitKind string
+
+const (
+	EmitLL  EmitKind = "ll"
+	EmitBC  EmitKind = "bc"
+	EmitObj EmitKind = "obj"
+	EmitExe EmitKind = "exe"
+)
+
+func ParseEmitKind(s string) (EmitKind, error) {
+	switch k := EmitKind(s); k {
+	case EmitLL, EmitBC, EmitObj, EmitExe:
+		return k, nil
+	}
+	return "", fmt.Errorf("unknown emit kind %q (expected ll, bc, obj or exe)", s)
+}
+
+// Ext is the file extension used for an output of this kind when no
+// explicit output path is given.
+func (k EmitKind) Ext() string {
+	switch k {
+	case EmitLL:
+		return ".ll"
+	case EmitBC:
+		return ".bc"
+	case EmitObj:
+		if runtime.GOOS == "windows" {
+			return ".obj"
+		}
+		return ".o"
+	default:
+		if runtime.GOOS == "windows" {
+			return ".exe"
+		}
+		return ""
+	}
+}
+
+// Toolchain holds the paths of the host tools used to turn LLVM IR into
+// object files and executables. Clang covers every step on its own; llc,
+// llvm-as and the system C compiler are used as a fallback when it is not
+// installed.
+type Toolchain struct {
+	Clang  string
+	LLC    string
+	LLVMAs string
+	CC     string
+}
+
+func Detect() *Toolchain {
+	return &Toolchain{
+		Clang:  lookPath("clang"),
+		LLC:    lookPath("llc"),
+		LLVMAs: lookPath("llvm-as"),
+		CC:     lookPath("cc", "gcc"),
+	}
+}
+
+func lookPath(names ...string) string {
+	for _, name := range names {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// Build writes the textual LLVM module ir to output in the requested form.
+// Executables are linked against the bundled Flint runtime.
+func (tc *Toolchain) Build(ir string, kind EmitKind, output string) error {
+	return tc.BuildUnits([]Unit{{Name: "module", IR: ir}}, kind, output)
+}
+
+// A Unit is the LLVM module of one Flint module.
+type Unit struct {
+	Name string
+	IR   string
+}
+
+// BuildUnits is Build for a program compiled to one LLVM module per Flint
+// module, the first of which holds main. Executables link them all; for
+// the other kinds the first unit is written to output and each of the
+// others next to it, named after the unit.
+func (tc *Toolchain) BuildUnits(units []Unit, kind EmitKind, output string) error {
+	tmp, err := os.MkdirTemp("", "flint-build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	var objs []string
+	for i, u := range units {
+		out := output
+		if i > 0 {
+			out = filepath.Join(filepath.Dir(output), u.Name+kind.Ext())
+		}
+		switch kind {
+		case EmitLL:
+			err = os.WriteFile(out, []byte(u.IR), 0644)
+		case EmitBC:
+			llFile := filepath.Join(tmp, fmt.Sprintf("module%d.ll", i))
+			if err = os.WriteFile(llFile, []byte(u.IR), 0644); err == nil {
+				err = tc.assemble(llFile, out)
+			}
+		case EmitObj:
+			err = tc.Object(u.IR, out)
+		default:
+			obj := filepath.Join(tmp, fmt.Sprintf("module%d%s", i, EmitObj.Ext()))
+			err = tc.Object(u.IR, obj)
+			objs = append(objs, obj)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if kind != EmitExe {
+		return nil
+	}
+	return tc.Link(objs, nil, output)
+}
+
+// Object compiles the textual LLVM module ir to the object file output.
+func (tc *Toolchain) Object(ir, output string) error {
+	tmp, err := os.MkdirTemp("", "flint-build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	llFile := filepath.Join(tmp, "module.ll")
+	if err := os.WriteFile(llFile, []byte(ir), 0644); err != nil {
+		return err
+	}
+	return tc.compileIR(llFile, output)
+}
+
+// Link links object files with the bundled Flint runtime and the C
+// libraries libs into the executable output.
+func (tc *Toolchain) Link(objs, libs []string, output string) error {
+	tmp, err := os.MkdirTemp("", "flint-build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	rt, err := tc.compileRuntime(tmp)
+	if err != nil {
+		return err
+	}
+	args := append(slices.Clone(objs), rt)
+	for _, lib := range libs {
+		args = append(args, "-l"+lib)
+	}
+	return tc.link(args, output)
+}
+
+func (tc *Toolchain) assemble(llFile, output string) error {
+	switch {
+	case tc.Clang != "":
+		return run(tc.Clang, "-c", "-emit-llvm", "-Wno-override-module", llFile, "-o", output)
+	case tc.LLVMAs != "":
+		return run(tc.LLVMAs, llFile, "-o", output)
+	}
+	return errors.New("cannot emit bitcode: neither clang nor llvm-as found in PATH")
+}
+
+func (tc *Toolchain) compileIR(llFile, output string) error {
+	switch {
+	case tc.Clang != "":
+		return run(tc.Clang, "-c", "-Wno-override-module", llFile, "-o", output)
+	case tc.LLC != "":
+		return run(tc.LLC, "-filetype=obj", "-relocation-model=pic", llFile, "-o", output)
+	}
+	return errors.New("cannot emit object code: neither clang nor llc found in PATH")
+}
+
+// compileRuntime returns an object file for the bundled runtime. The object
+// is cached per runtime version so it is only compiled on first use; dir is
+// used for scratch files when no cache directory is available.
+func (tc *Toolchain) compileRuntime(dir string) (string, error) {
+	cacheDir, err := flintrt.CacheDir()
+	if err != nil {
+		cacheDir = filepath.Join(dir, "runtime")
+	}
+	obj := filepath.Join(cacheDir, strings.TrimSuffix(flintrt.MainFile, ".c")+EmitObj.Ext())
+	if _, err := os.Stat(obj); err == nil {
+		return obj, nil
+	}
+	cc, err := tc.cCompiler()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", err
+	}
+	srcDir, err := os.MkdirTemp(cacheDir, "src-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(srcDir)
+	src, err := flintrt.WriteSources(srcDir)
+	if err != nil {
+		return "", err
+	}
+	tmpObj := filepath.Join(srcDir, filepath.Base(obj))
+	if err := run(cc, "-c", "-O2", "-fPIC", src, "-o", tmpObj); err != nil {
+		return "", err
+	}
+	return obj, os.Rename(tmpObj, obj)
+}
+
+func (tc *Toolchain) link(objs []string, output string) error {
+	cc, err := tc.cCompiler()
+	if err != nil {
+		return err
+	}
+	args := append(objs, "-o", output)
+	return run(cc, args...)
+}
+
+func (tc *Toolchain) cCompiler() (string, error) {
+	switch {
+	case tc.Clang != "":
+		return tc.Clang, nil
+	case tc.CC != "":
+		return tc.CC, nil
+	}
+	return "", errors.New("cannot link: neither clang nor cc found in PATH")
+}
+
+func run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %v\n%s", filepath.Base(name), err, out)
+	}
+	return nil
+}
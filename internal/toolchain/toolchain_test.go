@@ -0,0 +1,60 @@
+package toolchain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseEmitKind(t *testing.T) {
+	for _, s := range []string{"ll", "bc", "obj", "exe"} {
+		k, err := ParseEmitKind(s)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", s, err)
+		}
+		if string(k) != s {
+			t.Fatalf("expected %q, got %q", s, k)
+		}
+	}
+
+	if _, err := ParseEmitKind("asm"); err == nil {
+		t.Fatal("expected error for unknown emit kind")
+	}
+}
+
+func TestBuildEmitLLWritesModule(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "app.ll")
+	ir := "define i32 @main() {\nentry:\n\tret i32 0\n}\n"
+
+	if err := Detect().Build(ir, EmitLL, out); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != ir {
+		t.Fatalf("expected IR to be written unchanged, got:\n%s", data)
+	}
+}
+
+func TestBuildUnitsEmitLLWritesAModuleEach(t *testing.T) {
+	dir := t.TempDir()
+	units := []Unit{
+		{Name: "main", IR: "define i32 @main() {\nentry:\n\tret i32 0\n}\n"},
+		{Name: "app.models", IR: "define i64 @app.models.answer() {\nentry:\n\tret i64 42\n}\n"},
+	}
+	if err := Detect().BuildUnits(units, EmitLL, filepath.Join(dir, "app.ll")); err != nil {
+		t.Fatal(err)
+	}
+	for file, ir := range map[string]string{"app.ll": units[0].IR, "app.models.ll": units[1].IR} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != ir {
+			t.Fatalf("expected %s to hold its unit unchanged, got:\n%s", file, data)
+		}
+	}
+}
@@ -1,9 +1,15 @@
 package typechecker
 
-import "maps"
+import (
+	"maps"
+
+	"flint/internal/lexer"
+	"flint/internal/parser"
+)
 
 type Env struct {
 	vars    map[string]VarInfo
+	types   map[string]*Type
 	parent  *Env
 	modules map[string]*Env
 }
@@ -11,6 +17,12 @@ type Env struct {
 type VarInfo struct {
 	Ty      *Type
 	Mutable bool
+	Ctor    bool
+
+	// Def is the name token of the declaration, and Func the declaration
+	// itself for functions.
+	Def  lexer.Token
+	Func *parser.FuncDeclExpr
 }
 
 func NewEnv(parent *Env) *Env {
@@ -20,6 +32,7 @@ func NewEnv(parent *Env) *Env {
 	}
 	return &Env{
 		vars:    make(map[string]VarInfo),
+		types:   make(map[string]*Type),
 		parent:  parent,
 		modules: modules,
 	}
@@ -39,6 +52,12 @@ func (e *Env) SetVar(name string, ty *Type, mutable bool) {
 	e.vars[name] = VarInfo{Ty: ty, Mutable: mutable}
 }
 
+// SetCtor registers a constructor of a variant type. Nullary constructors
+// are values of the variant type, the others are functions returning it.
+func (e *Env) SetCtor(name string, ty *Type) {
+	e.vars[name] = VarInfo{Ty: ty, Ctor: true}
+}
+
 func (e *Env) Get(name string) (*Type, bool) {
 	if v, ok := e.GetVar(name); ok {
 		return v.Ty, true
@@ -49,3 +68,17 @@ func (e *Env) Get(name string) (*Type, bool) {
 func (e *Env) Set(name string, ty *Type) {
 	e.SetVar(name, ty, true)
 }
+
+func (e *Env) GetType(name string) (*Type, bool) {
+	if t, ok := e.types[name]; ok {
+		return t, true
+	}
+	if e.parent != nil {
+		return e.parent.GetType(name)
+	}
+	return nil, false
+}
+
+func (e *Env) SetType(name string, ty *Type) {
+	e.types[name] = ty
+}
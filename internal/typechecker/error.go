@@ -1,55 +1,23 @@
 package typechecker
 
 import (
+	"flint/internal/diag"
 	"flint/internal/lexer"
-	"fmt"
-	"strings"
+	"flint/internal/parser"
 )
 
-func (tc *TypeChecker) errorAt(tok lexer.Token, msg string) *Type {
-	line := getLineText(tok.Source, tok.Line)
-	caret := makeCaret(tok.Column)
-
-	report := fmt.Sprintf(
-		"%s\n  --> %s:%d:%d\n   |\n%2d | %s\n   | %s\n",
-		msg,
-		tok.File,
-		tok.Line,
-		tok.Column,
-		tok.Line,
-		line,
-		caret,
-	)
-
-	tc.errors = append(tc.errors, report)
+func (tc *TypeChecker) errorAt(tok lexer.Token, msg string, notes ...string) *Type {
+	tc.diags.Add(diag.Diagnostic{Severity: diag.Error, Span: tok.Span(), Message: msg, Notes: notes, Source: tok.Source})
 	return &Type{TKind: TyError}
 }
 
-func getLineText(source []rune, lineNum int) string {
-	start := 0
-	cur := 1
-	for i, r := range source {
-		if cur == lineNum {
-			start = i
-			break
-		}
-		if r == '\n' {
-			cur++
-		}
-	}
-	end := len(source)
-	for i := start; i < len(source); i++ {
-		if source[i] == '\n' {
-			end = i
-			break
-		}
-	}
-	return string(source[start:end])
+// errorIn reports an error that covers the whole of n rather than one token.
+func (tc *TypeChecker) errorIn(n parser.Node, msg string, notes ...string) *Type {
+	first, _ := n.Tokens()
+	tc.diags.Add(diag.Diagnostic{Severity: diag.Error, Span: n.Span(), Message: msg, Notes: notes, Source: first.Source})
+	return &Type{TKind: TyError}
 }
 
-func makeCaret(col int) string {
-	if col < 1 {
-		col = 1
-	}
-	return strings.Repeat(" ", col-1) + "^"
+func (tc *TypeChecker) warnAt(tok lexer.Token, msg string) {
+	tc.diags.Add(diag.Diagnostic{Severity: diag.Warning, Span: tok.Span(), Message: msg, Source: tok.Source})
 }
@@ -0,0 +1,399 @@
+package typechecker
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"flint/internal/parser"
+)
+
+// The exhaustiveness check works on a simplified view of patterns: every
+// pattern is a wildcard, an or-pattern or a constructor applied to
+// sub-patterns. Tuples are the single constructor of their type, Bool has
+// True and False, lists are built from `[]` and `::`, and literals and
+// ranges of the other scalar types are constructors of an infinite type.
+type pattern struct {
+	wild bool
+	alts []*pattern
+	ctor string
+	args []*pattern
+}
+
+var wildcard = &pattern{wild: true}
+
+const (
+	tupleCtor = "()"
+	nilCtor   = "[]"
+	consCtor  = "::"
+)
+
+func (p *pattern) String() string {
+	switch {
+	case p.wild:
+		return "_"
+	case p.alts != nil:
+		return joinPatterns(p.alts, " | ")
+	case p.ctor == tupleCtor:
+		return "(" + joinPatterns(p.args, ", ") + ")"
+	case p.ctor == consCtor:
+		var elems []*pattern
+		tail := p
+		for tail.ctor == consCtor {
+			elems = append(elems, tail.args[0])
+			tail = tail.args[1]
+		}
+		switch {
+		case tail.ctor == nilCtor:
+			return "[" + joinPatterns(elems, ", ") + "]"
+		case tail.wild:
+			return "[" + joinPatterns(elems, ", ") + ", ..]"
+		}
+		return "[" + joinPatterns(elems, ", ") + ", .." + tail.String() + "]"
+	case len(p.args) == 0:
+		return p.ctor
+	}
+	return p.ctor + "(" + joinPatterns(p.args, ", ") + ")"
+}
+
+func joinPatterns(pats []*pattern, sep string) string {
+	parts := make([]string, len(pats))
+	for i, a := range pats {
+		parts[i] = a.String()
+	}
+	return strings.Join(parts, sep)
+}
+
+type ctorSig struct {
+	name string
+	args []*Type
+}
+
+// signature lists the constructors of ty. complete is false for types with
+// infinitely many values, which only a wildcard can cover.
+func signature(ty *Type) (sigs []ctorSig, complete bool) {
+	ty = prune(ty)
+	switch ty.TKind {
+	case TyBool:
+		return []ctorSig{{name: "True"}, {name: "False"}}, true
+	case TyTuple:
+		return []ctorSig{{name: tupleCtor, args: ty.TElems}}, true
+	case TyList:
+		return []ctorSig{{name: nilCtor}, {name: consCtor, args: []*Type{ty.Elem, ty}}}, true
+	case TyVariant:
+		for _, v := range ty.Variants {
+			sigs = append(sigs, ctorSig{name: v.Name, args: v.Fields})
+		}
+		return sigs, true
+	}
+	return nil, false
+}
+
+func ctorArgs(ty *Type, name string) []*Type {
+	sigs, _ := signature(ty)
+	for _, s := range sigs {
+		if s.name == name {
+			return s.args
+		}
+	}
+	return nil
+}
+
+// intRange returns the half-open interval of Ints a literal or range
+// constructor stands for.
+func intRange(name string) (lo, hi int64, ok bool) {
+	if l, h, isRange := strings.Cut(name, ".."); isRange {
+		lo, err1 := strconv.ParseInt(l, 10, 64)
+		hi, err2 := strconv.ParseInt(h, 10, 64)
+		return lo, hi, err1 == nil && err2 == nil
+	}
+	n, err := strconv.ParseInt(name, 10, 64)
+	return n, n + 1, err == nil
+}
+
+// covers reports whether a row starting with head matches every value built
+// by the constructor name.
+func covers(head *pattern, name string) bool {
+	if head.ctor == name {
+		return true
+	}
+	hlo, hhi, ok := intRange(head.ctor)
+	if !ok || !strings.Contains(head.ctor, "..") {
+		return false
+	}
+	lo, hi, ok := intRange(name)
+	return ok && hlo <= lo && hi <= hhi
+}
+
+func literalName(e parser.Expr) string {
+	switch lit := e.(type) {
+	case *parser.BoolLiteral:
+		if lit.Value {
+			return "True"
+		}
+		return "False"
+	case *parser.IntLiteral:
+		return strconv.FormatInt(lit.Value, 10)
+	case *parser.FloatLiteral:
+		return strconv.FormatFloat(lit.Value, 'g', -1, 64)
+	case *parser.StringLiteral:
+		return strconv.Quote(lit.Value)
+	case *parser.ByteLiteral:
+		return lit.Raw
+	}
+	return parser.DumpExpr(e)
+}
+
+// toPattern converts a type-checked pattern.
+func toPattern(pat parser.Pattern) *pattern {
+	switch p := pat.(type) {
+	case *parser.AsPattern:
+		return toPattern(p.Pattern)
+	case *parser.LiteralPattern:
+		return &pattern{ctor: literalName(p.Value)}
+	case *parser.RangePattern:
+		return &pattern{ctor: literalName(p.Low) + ".." + literalName(p.High)}
+	case *parser.TuplePattern:
+		return &pattern{ctor: tupleCtor, args: toPatterns(p.Elements)}
+	case *parser.ListPattern:
+		tail := &pattern{ctor: nilCtor}
+		if p.Rest != nil {
+			tail = toPattern(p.Rest)
+		}
+		for i := len(p.Elements) - 1; i >= 0; i-- {
+			tail = &pattern{ctor: consCtor, args: []*pattern{toPattern(p.Elements[i]), tail}}
+		}
+		return tail
+	case *parser.ConstructorPattern:
+		return &pattern{ctor: p.Name.Lexeme, args: toPatterns(p.Args)}
+	case *parser.OrPattern:
+		return &pattern{alts: toPatterns(p.Alternatives)}
+	}
+	return wildcard
+}
+
+func toPatterns(pats []parser.Pattern) []*pattern {
+	out := make([]*pattern, len(pats))
+	for i, p := range pats {
+		out[i] = toPattern(p)
+	}
+	return out
+}
+
+// expandOr replaces every row starting with an or-pattern by one row per
+// alternative.
+func expandOr(matrix [][]*pattern) [][]*pattern {
+	var out [][]*pattern
+	for _, row := range matrix {
+		if row[0].alts == nil {
+			out = append(out, row)
+			continue
+		}
+		for _, alt := range row[0].alts {
+			out = append(out, expandOr([][]*pattern{append([]*pattern{alt}, row[1:]...)})...)
+		}
+	}
+	return out
+}
+
+// specialize keeps the rows of matrix whose first column matches every
+// value built by the constructor name, replacing that column by its
+// sub-patterns.
+func specialize(matrix [][]*pattern, name string, arity int) [][]*pattern {
+	var out [][]*pattern
+	for _, row := range expandOr(matrix) {
+		head := row[0]
+		switch {
+		case head.wild:
+			args := make([]*pattern, arity)
+			for i := range args {
+				args[i] = wildcard
+			}
+			out = append(out, append(args, row[1:]...))
+		case covers(head, name):
+			out = append(out, append(append([]*pattern{}, head.args...), row[1:]...))
+		}
+	}
+	return out
+}
+
+// defaultMatrix keeps the rows whose first column is a wildcard.
+func defaultMatrix(matrix [][]*pattern) [][]*pattern {
+	var out [][]*pattern
+	for _, row := range matrix {
+		if row[0].wild {
+			out = append(out, row[1:])
+		}
+	}
+	return out
+}
+
+func headCtors(matrix [][]*pattern) map[string]bool {
+	seen := map[string]bool{}
+	for _, row := range matrix {
+		if !row[0].wild {
+			seen[row[0].ctor] = true
+		}
+	}
+	return seen
+}
+
+// useful reports whether the row q matches some value that no row of
+// matrix matches.
+func useful(matrix [][]*pattern, q []*pattern, tys []*Type) bool {
+	if len(q) == 0 {
+		return len(matrix) == 0
+	}
+	matrix = expandOr(matrix)
+	head := q[0]
+	if head.alts != nil {
+		for _, alt := range head.alts {
+			if useful(matrix, append([]*pattern{alt}, q[1:]...), tys) {
+				return true
+			}
+		}
+		return false
+	}
+	if !head.wild {
+		args := ctorArgs(tys[0], head.ctor)
+		return useful(
+			specialize(matrix, head.ctor, len(head.args)),
+			specialize([][]*pattern{q}, head.ctor, len(head.args))[0],
+			append(append([]*Type{}, args...), tys[1:]...),
+		)
+	}
+	sigs, complete := signature(tys[0])
+	seen := headCtors(matrix)
+	if complete && len(seen) == len(sigs) {
+		for _, s := range sigs {
+			if useful(
+				specialize(matrix, s.name, len(s.args)),
+				specialize([][]*pattern{q}, s.name, len(s.args))[0],
+				append(append([]*Type{}, s.args...), tys[1:]...),
+			) {
+				return true
+			}
+		}
+		return false
+	}
+	return useful(defaultMatrix(matrix), q[1:], tys[1:])
+}
+
+// missing returns a row of patterns matching values that no row of matrix
+// matches, or nil when the matrix is exhaustive.
+func missing(matrix [][]*pattern, tys []*Type) []*pattern {
+	if len(tys) == 0 {
+		if len(matrix) == 0 {
+			return []*pattern{}
+		}
+		return nil
+	}
+	matrix = expandOr(matrix)
+	sigs, complete := signature(tys[0])
+	seen := headCtors(matrix)
+	if complete && len(seen) == len(sigs) {
+		for _, s := range sigs {
+			rest := append(append([]*Type{}, s.args...), tys[1:]...)
+			if w := missing(specialize(matrix, s.name, len(s.args)), rest); w != nil {
+				head := &pattern{ctor: s.name, args: w[:len(s.args)]}
+				return append([]*pattern{head}, w[len(s.args):]...)
+			}
+		}
+		return nil
+	}
+	w := missing(defaultMatrix(matrix), tys[1:])
+	if w == nil {
+		return nil
+	}
+	return append([]*pattern{unseenValue(tys[0], sigs, seen)}, w...)
+}
+
+// unseenValue picks an example of a value of ty not covered by the
+// constructors in seen.
+func unseenValue(ty *Type, sigs []ctorSig, seen map[string]bool) *pattern {
+	ty = prune(ty)
+	if len(seen) == 0 {
+		return wildcard
+	}
+	for _, s := range sigs {
+		if !seen[s.name] {
+			args := make([]*pattern, len(s.args))
+			for i := range args {
+				args[i] = wildcard
+			}
+			return &pattern{ctor: s.name, args: args}
+		}
+	}
+	if ty.TKind == TyInt {
+		if n, ok := unseenInt(seen); ok {
+			return &pattern{ctor: strconv.FormatInt(n, 10)}
+		}
+	}
+	return wildcard
+}
+
+// unseenInt returns an Int that none of the literals and ranges in seen
+// covers: the first one from 0 up, or else the first one below 0.
+func unseenInt(seen map[string]bool) (int64, bool) {
+	type interval struct{ lo, hi int64 }
+	var ivs []interval
+	for s := range seen {
+		lo, hi, ok := intRange(s)
+		if !ok || hi <= lo && hi != math.MinInt64 {
+			continue
+		}
+		if hi < lo {
+			// The literal math.MaxInt64, whose end overflowed.
+			hi = math.MaxInt64
+		}
+		ivs = append(ivs, interval{lo, hi})
+	}
+	slices.SortFunc(ivs, func(a, b interval) int { return cmp.Compare(a.lo, b.lo) })
+	n := int64(0)
+	for _, iv := range ivs {
+		if iv.lo > n {
+			return n, true
+		}
+		if n < iv.hi {
+			n = iv.hi
+		}
+	}
+	if n != math.MaxInt64 || !seen[strconv.FormatInt(n, 10)] {
+		return n, true
+	}
+	n = -1
+	for _, iv := range slices.Backward(ivs) {
+		if iv.lo <= n && n < iv.hi {
+			if iv.lo == math.MinInt64 {
+				return 0, false
+			}
+			n = iv.lo - 1
+		}
+	}
+	return n, true
+}
+
+// checkExhaustive reports a match that does not cover every value of its
+// scrutinee, and warns about arms that can never be reached. Guarded arms
+// may fail, so they do not count towards covering later arms.
+func (tc *TypeChecker) checkExhaustive(m *parser.MatchExpr, ty *Type) *Type {
+	var matrix [][]*pattern
+	tys := []*Type{ty}
+	for _, arm := range m.Arms {
+		row := []*pattern{toPattern(arm.Pattern)}
+		if !useful(matrix, row, tys) {
+			tc.warnAt(arm.Pos, "unreachable match arm: previous arms already cover this pattern")
+		}
+		if arm.Guard == nil {
+			matrix = append(matrix, row)
+		}
+	}
+	if w := missing(matrix, tys); w != nil {
+		return tc.errorAt(m.Pos, fmt.Sprintf("non-exhaustive match: pattern %s is not covered", w[0]),
+			"add an arm for it, or a `_` arm to cover every remaining value")
+	}
+	return ty
+}
@@ -2,6 +2,8 @@ package typechecker
 
 import (
 	"flint/internal/parser"
+	"fmt"
+	"unicode"
 )
 
 var PlatformIntBits int = 0
@@ -29,6 +31,18 @@ func (tc *TypeChecker) resolveType(t parser.Expr) *Type {
 			}
 			return &Type{TKind: TyList, Elem: elemTy}
 		}
+		if ty, ok := tc.env.GetType(typ.Name); ok {
+			return ty
+		}
+		if tc.typeVars != nil && unicode.IsLower([]rune(typ.Name)[0]) {
+			if v, ok := tc.typeVars[typ.Name]; ok {
+				return v
+			}
+			v := tc.newVar()
+			tc.typeVars[typ.Name] = v
+			return v
+		}
+		return tc.errorAt(typ.Pos, fmt.Sprintf("unknown type: '%s'", typ.Name))
 	case *parser.TupleTypeExpr:
 		elems := []*Type{}
 		for _, te := range typ.Types {
@@ -36,6 +50,12 @@ func (tc *TypeChecker) resolveType(t parser.Expr) *Type {
 			elems = append(elems, e)
 		}
 		return &Type{TKind: TyTuple, TElems: elems}
+	case *parser.FuncTypeExpr:
+		fnTy := &Type{TKind: TyFunc, Ret: tc.resolveType(typ.Ret)}
+		for _, pt := range typ.Params {
+			fnTy.Params = append(fnTy.Params, tc.resolveType(pt))
+		}
+		return fnTy
 	}
 	return &Type{TKind: TyError}
 }
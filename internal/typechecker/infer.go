@@ -0,0 +1,244 @@
+package typechecker
+
+import "flint/internal/parser"
+
+// newVar returns a fresh type variable at the current level.
+func (tc *TypeChecker) newVar() *Type {
+	tc.nextVar++
+	return &Type{TKind: TyVar, ID: tc.nextVar, Level: tc.level}
+}
+
+// prune follows the instances of bound type variables.
+func prune(t *Type) *Type {
+	for t != nil && t.TKind == TyVar && t.Inst != nil {
+		t = t.Inst
+	}
+	return t
+}
+
+// unify makes t and u the same type by binding type variables, reporting
+// whether that is possible. Error types unify with anything so that one
+// mistake is not reported again at every use.
+func unify(t, u *Type) bool {
+	t, u = prune(t), prune(u)
+	if t == nil || u == nil {
+		return t == u
+	}
+	if t == u || t.TKind == TyError || u.TKind == TyError {
+		return true
+	}
+	if t.TKind == TyVar {
+		return bindVar(t, u)
+	}
+	if u.TKind == TyVar {
+		return bindVar(u, t)
+	}
+	if t.TKind != u.TKind {
+		return false
+	}
+	switch t.TKind {
+	case TyFunc:
+		if len(t.Params) != len(u.Params) {
+			return false
+		}
+		for i := range t.Params {
+			if !unify(t.Params[i], u.Params[i]) {
+				return false
+			}
+		}
+		return unify(t.Ret, u.Ret)
+	case TyList, TyRange:
+		return unify(t.Elem, u.Elem)
+	case TyTuple:
+		if len(t.TElems) != len(u.TElems) {
+			return false
+		}
+		for i := range t.TElems {
+			if !unify(t.TElems[i], u.TElems[i]) {
+				return false
+			}
+		}
+		return true
+	case TyRecord, TyVariant:
+		return t.Name == u.Name
+	}
+	return true
+}
+
+func bindVar(v, t *Type) bool {
+	if occurs(v, t) {
+		return false
+	}
+	v.Inst = t
+	return true
+}
+
+// occurs reports whether v appears in t, lowering the level of the
+// variables of t to v's so they are not generalised while v is still in
+// scope.
+func occurs(v, t *Type) bool {
+	t = prune(t)
+	if t == nil {
+		return false
+	}
+	if t == v {
+		return true
+	}
+	if t.TKind == TyVar {
+		if t.Level > v.Level {
+			t.Level = v.Level
+		}
+		return false
+	}
+	for _, c := range children(t) {
+		if occurs(v, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func children(t *Type) []*Type {
+	switch t.TKind {
+	case TyFunc:
+		return append(append([]*Type{}, t.Params...), t.Ret)
+	case TyList, TyRange:
+		return []*Type{t.Elem}
+	case TyTuple:
+		return t.TElems
+	}
+	return nil
+}
+
+// generalize quantifies over the type variables of t created inside the
+// function being left.
+func (tc *TypeChecker) generalize(t *Type) {
+	t = prune(t)
+	if t == nil {
+		return
+	}
+	if t.TKind == TyVar {
+		if t.Level > tc.level {
+			t.Generic = true
+		}
+		return
+	}
+	for _, c := range children(t) {
+		tc.generalize(c)
+	}
+}
+
+// instantiate replaces the quantified variables of t with fresh ones.
+func (tc *TypeChecker) instantiate(t *Type) *Type {
+	return tc.instantiateWith(t, map[*Type]*Type{})
+}
+
+func (tc *TypeChecker) instantiateWith(t *Type, fresh map[*Type]*Type) *Type {
+	t = prune(t)
+	if t == nil || !hasGeneric(t) {
+		return t
+	}
+	if t.TKind == TyVar {
+		if v, ok := fresh[t]; ok {
+			return v
+		}
+		v := tc.newVar()
+		fresh[t] = v
+		return v
+	}
+	cp := *t
+	switch t.TKind {
+	case TyFunc:
+		cp.Params = make([]*Type, len(t.Params))
+		for i, p := range t.Params {
+			cp.Params[i] = tc.instantiateWith(p, fresh)
+		}
+		cp.Ret = tc.instantiateWith(t.Ret, fresh)
+	case TyList, TyRange:
+		cp.Elem = tc.instantiateWith(t.Elem, fresh)
+	case TyTuple:
+		cp.TElems = make([]*Type, len(t.TElems))
+		for i, e := range t.TElems {
+			cp.TElems[i] = tc.instantiateWith(e, fresh)
+		}
+	}
+	return &cp
+}
+
+func hasGeneric(t *Type) bool {
+	t = prune(t)
+	if t == nil {
+		return false
+	}
+	if t.TKind == TyVar {
+		return t.Generic
+	}
+	for _, c := range children(t) {
+		if hasGeneric(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns t with every bound type variable replaced by its
+// instance. Unbound variables are kept as they are.
+func Resolve(t *Type) *Type {
+	t = prune(t)
+	if t == nil || t.TKind == TyVar {
+		return t
+	}
+	cs := children(t)
+	if len(cs) == 0 {
+		return t
+	}
+	cp := *t
+	switch t.TKind {
+	case TyFunc:
+		cp.Params = make([]*Type, len(t.Params))
+		for i, p := range t.Params {
+			cp.Params[i] = Resolve(p)
+		}
+		cp.Ret = Resolve(t.Ret)
+	case TyList, TyRange:
+		cp.Elem = Resolve(t.Elem)
+	case TyTuple:
+		cp.TElems = make([]*Type, len(t.TElems))
+		for i, e := range t.TElems {
+			cp.TElems[i] = Resolve(e)
+		}
+	}
+	return &cp
+}
+
+// HasVars reports whether t still mentions type variables once resolved.
+func (t *Type) HasVars() bool {
+	t = prune(t)
+	if t == nil {
+		return false
+	}
+	if t.TKind == TyVar {
+		return true
+	}
+	for _, c := range children(t) {
+		if c.HasVars() {
+			return true
+		}
+	}
+	return false
+}
+
+// TypeOf returns the type inferred for an expression that has been
+// checked, or nil.
+func (tc *TypeChecker) TypeOf(e parser.Expr) *Type {
+	t, ok := tc.types[e]
+	if !ok {
+		return nil
+	}
+	return Resolve(t)
+}
+
+// TypeNamed returns the type declared as name in the scope being checked.
+func (tc *TypeChecker) TypeNamed(name string) (*Type, bool) {
+	return tc.env.GetType(name)
+}
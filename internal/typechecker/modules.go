@@ -1,39 +1,129 @@
 package typechecker
 
-import "strings"
+import (
+	"flint/internal/lexer"
+	"flint/internal/parser"
+	"flint/internal/stdlib"
+	"fmt"
+	"slices"
+	"strings"
+)
 
+// modules holds the built-in modules, which every program may use.
 var modules = map[string]*Env{}
 
 func RegisterModule(path []string, env *Env) {
 	modules[strings.Join(path, "/")] = env
 }
 
-func getModule(path []string) (*Env, bool) {
-	env, ok := modules[strings.Join(path, "/")]
+// HasModule reports whether path names a built-in module.
+func HasModule(path string) bool {
+	_, ok := modules[path]
+	return ok
+}
+
+// Import makes the members of env, the exports of another source module,
+// available to `use path` in the program being checked.
+func (tc *TypeChecker) Import(path string, env *Env) {
+	if tc.imports == nil {
+		tc.imports = map[string]*Env{}
+	}
+	tc.imports[path] = env
+}
+
+func (tc *TypeChecker) getModule(path []string) (*Env, bool) {
+	key := strings.Join(path, "/")
+	if env, ok := tc.imports[key]; ok {
+		return env, true
+	}
+	env, ok := modules[key]
 	return env, ok
 }
 
-// Module testing
+// Exports returns the public functions and types that prog, which tc has
+// checked, declares at the top level, along with the constructors of those
+// types.
+func (tc *TypeChecker) Exports(prog *parser.Program) *Env {
+	env := NewEnv(nil)
+	for _, e := range prog.Exprs {
+		switch n := e.(type) {
+		case *parser.FuncDeclExpr:
+			if v, ok := tc.env.vars[n.Name.Lexeme]; ok && n.Pub {
+				env.vars[n.Name.Lexeme] = v
+			}
+		case *parser.TypeDeclExpr:
+			t, ok := tc.env.types[n.Name.Lexeme]
+			if !ok || !n.Pub {
+				continue
+			}
+			env.types[n.Name.Lexeme] = t
+			for _, v := range t.Variants {
+				if info, ok := tc.env.vars[v.Name]; ok {
+					env.vars[v.Name] = info
+				}
+			}
+		}
+	}
+	return env
+}
+
+// ModulesNamed returns the paths of the registered modules whose last
+// segment is name, such as "flint/io" for "io".
+func ModulesNamed(name string) []string {
+	var out []string
+	for path := range modules {
+		if path == name || strings.HasSuffix(path, "/"+name) {
+			out = append(out, path)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
 
 func init() {
-	ioEnv := NewEnv(nil)
-	ioEnv.Set("print", &Type{
-		TKind:  TyFunc,
-		Params: []*Type{{TKind: TyString}},
-		Ret:    &Type{TKind: TyNil},
-	})
-	ioEnv.Set("println", &Type{
-		TKind:  TyFunc,
-		Params: []*Type{{TKind: TyString}},
-		Ret:    &Type{TKind: TyNil},
-	})
-	RegisterModule([]string{"flint", "io"}, ioEnv)
-
-	strEnv := NewEnv(nil)
-	strEnv.Set("to_string", &Type{
-		TKind:  TyFunc,
-		Params: []*Type{{TKind: TyInt}},
-		Ret:    &Type{TKind: TyString},
-	})
-	RegisterModule([]string{"flint", "string"}, strEnv)
+	for _, m := range stdlib.Modules() {
+		RegisterModule(strings.Split(m.Path, "/"), stdEnv(m))
+	}
+}
+
+// stdEnv builds the Env of a built-in module by checking the declarations
+// of its types and the signatures of its functions as declarations without
+// a body, so that they are generalised like those of source modules and
+// carry their doc comments. Types are exported with their constructors.
+func stdEnv(m *stdlib.Module) *Env {
+	tc := New()
+	env := NewEnv(nil)
+	for _, t := range m.Types {
+		checkStd(tc, m.Path, t.Doc, t.Decl)
+		ty := tc.env.types[t.Name()]
+		env.types[t.Name()] = ty
+		for _, v := range ty.Variants {
+			env.vars[v.Name] = stdMember(tc, v.Name)
+		}
+	}
+	for _, f := range m.Funcs {
+		checkStd(tc, m.Path, f.Doc, f.Sig)
+		env.vars[f.Name()] = stdMember(tc, f.Name())
+	}
+	return env
+}
+
+// checkStd checks decl, a declaration of the built-in module path, in tc.
+func checkStd(tc *TypeChecker, path, doc, decl string) {
+	tokens, err := lexer.Tokenize("/// "+doc+"\n"+decl, path)
+	prog, diags := parser.ParseProgram(tokens)
+	if err != nil || len(diags) > 0 || len(prog.Exprs) != 1 {
+		panic(fmt.Sprintf("bad declaration in %s: %s", path, decl))
+	}
+	if _, err := tc.CheckExpr(prog.Exprs[0]); err != nil {
+		panic(fmt.Sprintf("bad declaration in %s: %s: %v", path, decl, err))
+	}
+}
+
+// stdMember returns the variable name that tc declared for a built-in
+// module. Members of built-in modules have no declaration in the source.
+func stdMember(tc *TypeChecker, name string) VarInfo {
+	v := tc.env.vars[name]
+	v.Def = lexer.Token{}
+	return v
 }
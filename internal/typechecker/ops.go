@@ -40,6 +40,8 @@ var binOps = map[lexer.TokenKind][]BinOpSig{
 
 	lexer.LtGt: {{Type{TKind: TyString}, Type{TKind: TyString}, Type{TKind: TyString}}},
 
+	lexer.DotDot: {{Type{TKind: TyInt}, Type{TKind: TyInt}, Type{TKind: TyRange, Elem: &Type{TKind: TyInt}}}},
+
 	lexer.EqualEqual: {
 		{Type{TKind: TyInt}, Type{TKind: TyInt}, Type{TKind: TyBool}},
 		{Type{TKind: TyFloat}, Type{TKind: TyFloat}, Type{TKind: TyBool}},
@@ -0,0 +1,57 @@
+package typechecker
+
+import (
+	"flint/internal/lexer"
+	"flint/internal/parser"
+)
+
+// A Ref is an occurrence of a variable or function name, either where it
+// is declared or where it is used. The typechecker records one for each
+// name it resolves so that tools such as the language server can find out
+// what is at a position in the source.
+type Ref struct {
+	Name string
+	Pos  lexer.Token
+	Type *Type
+
+	// Def is the name token of the declaration the name refers to. Names
+	// declared outside the source, such as module members, have none.
+	Def lexer.Token
+
+	// Func is the declaration of the function the name refers to, if any.
+	Func *parser.FuncDeclExpr
+}
+
+// IsDecl reports whether r is the declaration of the name.
+func (r Ref) IsDecl() bool {
+	return r.Def.Line == r.Pos.Line && r.Def.Column == r.Pos.Column && r.Def.Line > 0
+}
+
+// Refs returns the names recorded so far, in the order they were checked.
+func (tc *TypeChecker) Refs() []Ref {
+	return tc.refs
+}
+
+// RefAt returns the name covering a 1-based line and column.
+func (tc *TypeChecker) RefAt(line, col int) (Ref, bool) {
+	for _, r := range tc.refs {
+		s := r.Pos.Span()
+		if s.Line == line && col >= s.Column && col < s.EndColumn {
+			return r, true
+		}
+	}
+	return Ref{}, false
+}
+
+// declare binds name in the current scope and records its declaration.
+func (tc *TypeChecker) declare(name lexer.Token, ty *Type, mutable bool, fn *parser.FuncDeclExpr) {
+	tc.env.vars[name.Lexeme] = VarInfo{Ty: ty, Mutable: mutable, Def: name, Func: fn}
+	if name.Line > 0 {
+		tc.refs = append(tc.refs, Ref{Name: name.Lexeme, Pos: name, Type: ty, Def: name, Func: fn})
+	}
+}
+
+// use records a use of the variable v at tok, with the type it has there.
+func (tc *TypeChecker) use(tok lexer.Token, v VarInfo, ty *Type) {
+	tc.refs = append(tc.refs, Ref{Name: tok.Lexeme, Pos: tok, Type: ty, Def: v.Def, Func: v.Func})
+}
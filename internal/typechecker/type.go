@@ -9,11 +9,32 @@ import (
 type TypeKind int
 
 type Type struct {
-	TKind  TypeKind
-	Params []*Type
-	Ret    *Type
-	Elem   *Type
-	TElems []*Type
+	TKind    TypeKind
+	Params   []*Type
+	Ret      *Type
+	Elem     *Type
+	TElems   []*Type
+	Name     string
+	Fields   []Field
+	Variants []Variant
+
+	// Type variables: Inst is the type the variable has been unified with,
+	// Level the function nesting depth it was created at, and Generic marks
+	// variables quantified over by a generalised function type.
+	ID      int
+	Inst    *Type
+	Level   int
+	Generic bool
+}
+
+type Field struct {
+	Name string
+	Ty   *Type
+}
+
+type Variant struct {
+	Name   string
+	Fields []*Type
 }
 
 const (
@@ -28,10 +49,26 @@ const (
 	TyList
 	TyTuple
 	TyRange
+	TyRecord
+	TyVariant
+	TyVar
 )
 
-func (t Type) String() string {
+func (t *Type) String() string {
+	return t.format(map[*Type]string{})
+}
+
+// format prints t, naming type variables a, b, c... in order of appearance.
+func (t *Type) format(names map[*Type]string) string {
+	t = prune(t)
 	switch t.TKind {
+	case TyVar:
+		if name, ok := names[t]; ok {
+			return name
+		}
+		name := varName(len(names))
+		names[t] = name
+		return name
 	case TyInt:
 		if PlatformIntBits == 32 {
 			return "Int"
@@ -49,7 +86,7 @@ func (t Type) String() string {
 		return "Nil"
 	case TyList:
 		if t.Elem != nil {
-			return fmt.Sprintf("List(%s)", t.Elem.String())
+			return fmt.Sprintf("List(%s)", t.Elem.format(names))
 		}
 		return "List(<unknown>)"
 	case TyTuple:
@@ -58,31 +95,56 @@ func (t Type) String() string {
 			if e == nil {
 				parts = append(parts, "<unknown>")
 			} else {
-				parts = append(parts, e.String())
+				parts = append(parts, e.format(names))
 			}
 		}
 		return fmt.Sprintf("(%s)", strings.Join(parts, ", "))
 	case TyRange:
 		if t.Elem != nil {
-			return fmt.Sprintf("Range(%s)", t.Elem.String())
+			return fmt.Sprintf("Range(%s)", t.Elem.format(names))
 		}
 		return "Range(Int)"
 	case TyFunc:
 		parts := []string{}
 		for _, p := range t.Params {
-			parts = append(parts, p.String())
+			parts = append(parts, p.format(names))
 		}
-		return fmt.Sprintf("(%s) -> %s", strings.Join(parts, ", "), t.Ret.String())
+		return fmt.Sprintf("(%s) -> %s", strings.Join(parts, ", "), t.Ret.format(names))
+	case TyRecord, TyVariant:
+		return t.Name
 	}
 	return "<error>"
 }
 
+// TypeStrings prints several types sharing the names of their type
+// variables, so that a message or signature mentioning them reads
+// consistently.
+func TypeStrings(ts ...*Type) []string {
+	names := map[*Type]string{}
+	out := make([]string, len(ts))
+	for i, t := range ts {
+		out[i] = t.format(names)
+	}
+	return out
+}
+
+func varName(i int) string {
+	if i < 26 {
+		return string(rune('a' + i))
+	}
+	return fmt.Sprintf("t%d", i)
+}
+
 func (t Type) Kind() TypeKind { return t.TKind }
 
 func (t *Type) Equal(u *Type) bool {
 	if t == nil || u == nil {
 		return t == u
 	}
+	t, u = prune(t), prune(u)
+	if t.TKind == TyVar || u.TKind == TyVar {
+		return t == u
+	}
 	if t.TKind != u.TKind {
 		return false
 	}
@@ -117,11 +179,35 @@ func (t *Type) Equal(u *Type) bool {
 			return t.Elem == u.Elem
 		}
 		return t.Elem.Equal(u.Elem)
+	case TyRecord, TyVariant:
+		return t.Name == u.Name
 	default:
 		return true
 	}
 }
 
+// Field looks up a record field by name, returning its index in declaration
+// order.
+func (t *Type) Field(name string) (int, *Type, bool) {
+	for i, f := range t.Fields {
+		if f.Name == name {
+			return i, f.Ty, true
+		}
+	}
+	return -1, nil, false
+}
+
+// Variant looks up a constructor of a variant type by name, returning its
+// tag.
+func (t *Type) Variant(name string) (int, *Variant, bool) {
+	for i := range t.Variants {
+		if t.Variants[i].Name == name {
+			return i, &t.Variants[i], true
+		}
+	}
+	return -1, nil, false
+}
+
 func init() {
 	if strconv.IntSize == 32 {
 		PlatformIntBits = 32
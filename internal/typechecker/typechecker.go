@@ -4,40 +4,68 @@ import (
 	"fmt"
 	"strings"
 
+	"flint/internal/diag"
 	"flint/internal/lexer"
 	"flint/internal/parser"
 )
 
 type TypeChecker struct {
-	errors []string
-	env    *Env
-	ctx    Context
+	diags    diag.List
+	reported int
+	env      *Env
+	ctx      Context
+
+	level    int
+	nextVar  int
+	typeVars map[string]*Type
+	types    map[parser.Expr]*Type
+
+	refs []Ref
+	// imports holds the exports of the source modules the program uses.
+	imports map[string]*Env
+	// bindings holds the name tokens of the variables bound by the
+	// pattern being checked.
+	bindings map[string]lexer.Token
 }
 
 func New() *TypeChecker {
 	return &TypeChecker{
-		errors: []string{},
-		env:    NewEnv(nil),
-		ctx:    TopLevel,
+		env:   NewEnv(nil),
+		ctx:   TopLevel,
+		types: map[parser.Expr]*Type{},
 	}
 }
 
+// CheckExpr checks a top-level expression. The error, if any, is the
+// diag.List of the errors found in expr.
 func (tc *TypeChecker) CheckExpr(expr parser.Expr) (*Type, error) {
 	ty := tc.Check(expr)
-	if len(tc.errors) > 0 {
-		err := fmt.Errorf("%s", tc.errors[0])
-		tc.errors = tc.errors[1:]
+	found := tc.diags[tc.reported:]
+	tc.reported = len(tc.diags)
+	if err := found.Errors().Err(); err != nil {
 		return &Type{TKind: TyError}, err
 	}
 	return ty, nil
 }
 
+// Diagnostics returns the errors and warnings reported so far.
+func (tc *TypeChecker) Diagnostics() diag.List {
+	return tc.diags
+}
+
+// Check infers the type of expr and records it for TypeOf.
 func (tc *TypeChecker) Check(expr parser.Expr) *Type {
+	ty := prune(tc.check(expr))
+	tc.types[expr] = ty
+	return ty
+}
+
+func (tc *TypeChecker) check(expr parser.Expr) *Type {
 	if tc.ctx == TopLevel {
 		switch expr.(type) {
-		case *parser.VarDeclExpr, *parser.IfExpr,
-			*parser.MatchExpr, *parser.PipelineExpr:
-			return tc.errorAt(lexer.Token{}, fmt.Sprintf("%T is not allowed at top-level; must be inside a function/block", expr))
+		case *parser.VarDeclExpr, *parser.IfExpr, *parser.ForExpr,
+			*parser.MatchExpr, *parser.PipelineExpr, *parser.LambdaExpr:
+			return tc.errorIn(expr, fmt.Sprintf("%T is not allowed at top-level; must be inside a function/block", expr))
 		}
 	}
 	switch e := expr.(type) {
@@ -49,6 +77,8 @@ func (tc *TypeChecker) Check(expr parser.Expr) *Type {
 		return &Type{TKind: TyBool}
 	case *parser.StringLiteral:
 		return &Type{TKind: TyString}
+	case *parser.InterpolatedString:
+		return tc.visitInterpolatedString(e)
 	case *parser.ByteLiteral:
 		return &Type{TKind: TyByte}
 	case *parser.PrefixExpr:
@@ -65,6 +95,8 @@ func (tc *TypeChecker) Check(expr parser.Expr) *Type {
 		ty := tc.visitFuncDecl(e)
 		tc.ctx = oldCtx
 		return ty
+	case *parser.LambdaExpr:
+		return tc.visitLambda(e, nil)
 	case *parser.CallExpr:
 		oldCtx := tc.ctx
 		tc.ctx = FunctionBody
@@ -81,6 +113,8 @@ func (tc *TypeChecker) Check(expr parser.Expr) *Type {
 		return tc.visitIf(e)
 	case *parser.MatchExpr:
 		return tc.visitMatch(e)
+	case *parser.ForExpr:
+		return tc.visitFor(e)
 	case *parser.PipelineExpr:
 		return tc.visitPipeline(e)
 	case *parser.ListExpr:
@@ -91,20 +125,31 @@ func (tc *TypeChecker) Check(expr parser.Expr) *Type {
 		return tc.visitIndex(e)
 	case *parser.TupleExpr:
 		return tc.visitTuple(e)
+	case *parser.TypeDeclExpr:
+		return tc.visitTypeDecl(e)
+	case *parser.RecordExpr:
+		return tc.visitRecord(e)
+	case *parser.FieldAccessExpr:
+		return tc.visitFieldAccess(e)
 	default:
 		return &Type{TKind: TyError}
 	}
 }
 
 func (tc *TypeChecker) visitIdentifier(id *parser.Identifier) *Type {
-	ty, ok := tc.env.Get(id.Name)
+	v, ok := tc.env.GetVar(id.Name)
 	if !ok {
 		return tc.errorAt(id.Pos, fmt.Sprintf("undefined variable: '%s'", id.Name))
 	}
+	ty := tc.instantiate(v.Ty)
+	tc.use(id.Pos, v, ty)
 	return ty
 }
 
 func (tc *TypeChecker) visitVarDecl(d *parser.VarDeclExpr) *Type {
+	if d.Pattern != nil {
+		return tc.visitDestructure(d)
+	}
 	if _, exists := tc.env.currentScopeGet(d.Name.Lexeme); exists {
 		return tc.errorAt(d.Name, fmt.Sprintf(
 			"variable '%s' already declared in this scope", d.Name.Lexeme))
@@ -125,8 +170,8 @@ func (tc *TypeChecker) visitVarDecl(d *parser.VarDeclExpr) *Type {
 	}
 	if d.Type != nil {
 		declTy := tc.resolveType(d.Type)
-		if varTy != nil && !declTy.Equal(varTy) {
-			return tc.errorAt(d.Name, fmt.Sprintf(
+		if varTy != nil && !unify(declTy, varTy) {
+			return tc.errorIn(d.Value, fmt.Sprintf(
 				"type mismatch in %s '%s': expected %s, got %s",
 				func() string {
 					if d.Mutable {
@@ -135,24 +180,49 @@ func (tc *TypeChecker) visitVarDecl(d *parser.VarDeclExpr) *Type {
 					return "val"
 				}(), d.Name.Lexeme, declTy.String(), varTy.String()))
 		}
-		tc.env.SetVar(d.Name.Lexeme, declTy, d.Mutable)
+		tc.declare(d.Name, declTy, d.Mutable, nil)
 		return declTy
 	}
-	tc.env.SetVar(d.Name.Lexeme, varTy, d.Mutable)
+	tc.declare(d.Name, varTy, d.Mutable, nil)
 	return varTy
 }
 
+// visitFuncDecl infers the type of a function. Parameters and return
+// types without annotations get fresh type variables, and lowercase type
+// names in annotations stand for type variables. Once the body has been
+// checked, the variables that did not escape are generalised so that each
+// use of the function can instantiate them differently.
 func (tc *TypeChecker) visitFuncDecl(fn *parser.FuncDeclExpr) *Type {
 	if _, exists := tc.env.currentScopeGet(fn.Name.Lexeme); exists {
 		return tc.errorAt(fn.Name, fmt.Sprintf("function '%s' already declared in this scope", fn.Name.Lexeme))
 	}
+	oldTypeVars := tc.typeVars
+	tc.typeVars = map[string]*Type{}
+	for name, v := range oldTypeVars {
+		tc.typeVars[name] = v
+	}
+	tc.level++
+	defer func() {
+		tc.typeVars = oldTypeVars
+	}()
 	paramTypes := make([]*Type, len(fn.Params))
 	for i, p := range fn.Params {
+		if p.Type == nil && fn.Body == nil {
+			// There is no body to infer the type from. The variable is
+			// left out of the generalisation so that the first call fixes
+			// it, which tells a fix what annotation to add.
+			tc.errorAt(p.Name, fmt.Sprintf("parameter '%s' of external function '%s' needs a type annotation", p.Name.Lexeme, fn.Name.Lexeme))
+			paramTypes[i] = tc.newVar()
+			paramTypes[i].Level--
+			continue
+		}
 		if p.Type == nil {
-			return tc.errorAt(p.Name, fmt.Sprintf("parameter '%s' missing type annotation", p.Name.Lexeme))
+			paramTypes[i] = tc.newVar()
+			continue
 		}
 		pt := tc.resolveType(p.Type)
 		if pt.TKind == TyError {
+			tc.level--
 			return &Type{TKind: TyError}
 		}
 		paramTypes[i] = pt
@@ -161,8 +231,11 @@ func (tc *TypeChecker) visitFuncDecl(fn *parser.FuncDeclExpr) *Type {
 	if fn.Ret != nil {
 		retType = tc.resolveType(fn.Ret)
 		if retType.TKind == TyError {
+			tc.level--
 			return &Type{TKind: TyError}
 		}
+	} else if fn.Body != nil {
+		retType = tc.newVar()
 	} else {
 		retType = &Type{TKind: TyNil}
 	}
@@ -171,42 +244,134 @@ func (tc *TypeChecker) visitFuncDecl(fn *parser.FuncDeclExpr) *Type {
 		Params: paramTypes,
 		Ret:    retType,
 	}
-	tc.env.Set(fn.Name.Lexeme, fnType)
+	tc.declare(fn.Name, fnType, true, fn)
 	oldEnv := tc.env
 	tc.env = NewEnv(oldEnv)
 	for i, p := range fn.Params {
-		tc.env.Set(p.Name.Lexeme, paramTypes[i])
+		tc.declare(p.Name, paramTypes[i], true, nil)
 	}
 	if fn.Body != nil {
 		bodyTy := tc.Check(fn.Body)
-		if fn.Ret != nil && !bodyTy.Equal(retType) {
-			return tc.errorAt(fn.Name, fmt.Sprintf("function '%s' annotated return %s but body has type %s", fn.Name.Lexeme, retType.String(), bodyTy.String()))
+		if bodyTy.TKind == TyError {
+			tc.env = oldEnv
+			tc.level--
+			return bodyTy
 		}
-		if fn.Ret == nil {
-			fnType.Ret = bodyTy
+		if !unify(retType, bodyTy) {
+			tc.env = oldEnv
+			tc.level--
+			names := TypeStrings(retType, bodyTy)
+			return tc.errorAt(fn.Name, fmt.Sprintf("function '%s' annotated return %s but body has type %s", fn.Name.Lexeme, names[0], names[1]))
 		}
 	}
 	tc.env = oldEnv
+	tc.level--
+	tc.generalize(fnType)
 	return fnType
 }
 
+// visitLambda infers the type of an anonymous function. Unlike declared
+// functions, lambdas are not generalised: they are values, and like other
+// values they have a single type. When l is passed where a function of
+// type want is expected, its unannotated parameters take their types from
+// want, so that its body may use what the other arguments of the call tell
+// of them.
+func (tc *TypeChecker) visitLambda(l *parser.LambdaExpr, want *Type) *Type {
+	if want != nil && (want.TKind != TyFunc || len(want.Params) != len(l.Params)) {
+		want = nil
+	}
+	paramTypes := make([]*Type, len(l.Params))
+	for i, p := range l.Params {
+		if p.Type == nil && want != nil {
+			paramTypes[i] = want.Params[i]
+			continue
+		}
+		if p.Type == nil {
+			paramTypes[i] = tc.newVar()
+			continue
+		}
+		paramTypes[i] = tc.resolveType(p.Type)
+		if paramTypes[i].TKind == TyError {
+			return paramTypes[i]
+		}
+	}
+	oldEnv := tc.env
+	tc.env = NewEnv(oldEnv)
+	for i, p := range l.Params {
+		tc.declare(p.Name, paramTypes[i], true, nil)
+	}
+	bodyTy := tc.Check(l.Body)
+	tc.env = oldEnv
+	if bodyTy.TKind == TyError {
+		return bodyTy
+	}
+	retTy := bodyTy
+	if l.Ret != nil {
+		retTy = tc.resolveType(l.Ret)
+		if !unify(retTy, bodyTy) {
+			names := TypeStrings(retTy, bodyTy)
+			return tc.errorAt(l.Pos, fmt.Sprintf("function annotated return %s but body has type %s", names[0], names[1]))
+		}
+	}
+	return &Type{TKind: TyFunc, Params: paramTypes, Ret: retTy}
+}
+
 func (tc *TypeChecker) visitCall(c *parser.CallExpr) *Type {
 	calleeTy := tc.Check(c.Callee)
+	if calleeTy.TKind == TyError {
+		return calleeTy
+	}
+	if calleeTy.TKind == TyVar {
+		fnTy := &Type{TKind: TyFunc, Ret: tc.newVar()}
+		for range c.Args {
+			fnTy.Params = append(fnTy.Params, tc.newVar())
+		}
+		unify(calleeTy, fnTy)
+		calleeTy = fnTy
+	}
 	if calleeTy.TKind != TyFunc {
 		return tc.errorAt(c.Pos, fmt.Sprintf("attempt to call non-function value of type %s", calleeTy.String()))
 	}
 	if len(c.Args) != len(calleeTy.Params) {
-		return tc.errorAt(c.Pos, fmt.Sprintf("wrong number of arguments: expected %d, got %d", len(calleeTy.Params), len(c.Args)))
+		return tc.errorIn(c, fmt.Sprintf("wrong number of arguments: expected %d, got %d", len(calleeTy.Params), len(c.Args)))
 	}
+	// Lambdas come last, so that their parameters get the types the other
+	// arguments give them.
+	var lambdas []int
 	for i, a := range c.Args {
-		argTy := tc.Check(a)
-		if !argTy.Equal(calleeTy.Params[i]) {
-			return tc.errorAt(c.Pos, fmt.Sprintf("argument %d expected %s, got %s", i+1, calleeTy.Params[i].String(), argTy.String()))
+		if _, ok := a.(*parser.LambdaExpr); ok && tc.ctx != TopLevel {
+			lambdas = append(lambdas, i)
+			continue
+		}
+		if err := tc.checkArg(calleeTy, i, a, tc.Check(a)); err != nil {
+			return err
+		}
+	}
+	for _, i := range lambdas {
+		l := c.Args[i].(*parser.LambdaExpr)
+		argTy := prune(tc.visitLambda(l, prune(calleeTy.Params[i])))
+		tc.types[l] = argTy
+		if err := tc.checkArg(calleeTy, i, l, argTy); err != nil {
+			return err
 		}
 	}
 	return calleeTy.Ret
 }
 
+// checkArg unifies argTy, the type of the argument a at index i of a call,
+// with the parameter of calleeTy it is passed as, returning the error type
+// if they differ.
+func (tc *TypeChecker) checkArg(calleeTy *Type, i int, a parser.Expr, argTy *Type) *Type {
+	if argTy.TKind == TyError {
+		return argTy
+	}
+	if !unify(calleeTy.Params[i], argTy) {
+		names := TypeStrings(calleeTy.Params[i], argTy)
+		return tc.errorIn(a, fmt.Sprintf("argument %d expected %s, got %s", i+1, names[0], names[1]))
+	}
+	return nil
+}
+
 func (tc *TypeChecker) visitBlock(b *parser.BlockExpr) *Type {
 	old := tc.env
 	tc.env = NewEnv(old)
@@ -224,7 +389,8 @@ func (tc *TypeChecker) visitPrefix(e *parser.PrefixExpr) *Type {
 	if !ok {
 		return tc.errorAt(e.Operator, "unknown unary operator")
 	}
-	if arg.TKind != sig.Arg.TKind {
+	want := sig.Arg
+	if !unify(arg, &want) {
 		return tc.errorAt(e.Operator, fmt.Sprintf("invalid operand type for '%s': %s", e.Operator.Lexeme, arg.String()))
 	}
 	out := sig.Out
@@ -238,17 +404,28 @@ func (tc *TypeChecker) visitInfix(e *parser.InfixExpr) *Type {
 	if !ok {
 		return tc.errorAt(e.Operator, "unknown operator")
 	}
+	if len(sigs) == 1 {
+		wantLeft, wantRight, out := sigs[0].Left, sigs[0].Right, sigs[0].Out
+		if unify(left, &wantLeft) && unify(right, &wantRight) {
+			return &out
+		}
+	}
+	left, right = prune(left), prune(right)
 	for _, sig := range sigs {
 		if left.TKind == sig.Left.TKind && right.TKind == sig.Right.TKind {
 			out := sig.Out
 			return &out
 		}
 	}
-	return tc.errorAt(e.Operator, fmt.Sprintf("invalid operands for '%s': %s and %s", e.Operator.Lexeme, left.String(), right.String()))
+	if (left.TKind == TyVar || right.TKind == TyVar) && unify(left, right) {
+		out := sigs[0].Out
+		return &out
+	}
+	return tc.errorIn(e, fmt.Sprintf("invalid operands for '%s': %s and %s", e.Operator.Lexeme, left.String(), right.String()))
 }
 
 func (tc *TypeChecker) visitUse(u *parser.UseExpr) *Type {
-	modEnv, ok := getModule(u.Path)
+	modEnv, ok := tc.getModule(u.Path)
 	if !ok {
 		return tc.errorAt(u.Pos, fmt.Sprintf("cannot find module %s", strings.Join(u.Path, "/")))
 	}
@@ -260,12 +437,18 @@ func (tc *TypeChecker) visitUse(u *parser.UseExpr) *Type {
 		tc.env.modules[name] = modEnv
 	} else {
 		for _, m := range u.Members {
-			ty, ok := modEnv.Get(m)
-			if !ok {
+			v, isVar := modEnv.GetVar(m)
+			t, isType := modEnv.GetType(m)
+			if !isVar && !isType {
 				tc.errorAt(u.Pos, fmt.Sprintf("module %s has no member %s", strings.Join(u.Path, "/"), m))
 				continue
 			}
-			tc.env.Set(m, ty)
+			if isVar {
+				tc.env.vars[m] = v
+			}
+			if isType {
+				tc.env.SetType(m, t)
+			}
 		}
 	}
 	return &Type{TKind: TyNil}
@@ -280,28 +463,67 @@ func (tc *TypeChecker) visitQualified(q *parser.QualifiedExpr) *Type {
 	if !ok {
 		return tc.errorAt(q.Pos, fmt.Sprintf("unknown module: %s", leftIdent.Name))
 	}
-	ty, ok := modEnv.Get(q.Right.Lexeme)
+	v, ok := modEnv.GetVar(q.Right.Lexeme)
 	if !ok {
 		return tc.errorAt(q.Pos, fmt.Sprintf("module %s has no member %s", leftIdent.Name, q.Right.Lexeme))
 	}
+	ty := tc.instantiate(v.Ty)
+	tc.use(q.Right, v, ty)
 	return ty
 }
 
 func (tc *TypeChecker) visitIf(i *parser.IfExpr) *Type {
 	condTy := tc.Check(i.Cond)
-	if condTy.TKind != TyBool {
-		return tc.errorAt(i.Pos, fmt.Sprintf("if condition must be Bool, got %s", condTy.String()))
+	if !unify(condTy, &Type{TKind: TyBool}) {
+		return tc.errorIn(i.Cond, fmt.Sprintf("if condition must be Bool, got %s", condTy.String()))
 	}
 	thenTy := tc.Check(i.Then)
 	if i.Else != nil {
 		elseTy := tc.Check(i.Else)
-		if !thenTy.Equal(elseTy) {
-			return tc.errorAt(i.Pos, fmt.Sprintf("then branch has type %s but else branch has type %s", thenTy.String(), elseTy.String()))
+		if !unify(thenTy, elseTy) {
+			return tc.errorIn(i.Else, fmt.Sprintf("then branch has type %s but else branch has type %s", thenTy.String(), elseTy.String()))
 		}
 	}
 	return thenTy
 }
 
+// visitFor checks `for pattern in iter { ... }`. iter is a range or a list,
+// and the pattern has to match each of its elements.
+func (tc *TypeChecker) visitFor(f *parser.ForExpr) *Type {
+	iterTy := tc.Check(f.Iter)
+	if iterTy.TKind == TyError {
+		return iterTy
+	}
+	if iterTy.TKind == TyVar {
+		unify(iterTy, &Type{TKind: TyList, Elem: tc.newVar()})
+		iterTy = prune(iterTy)
+	}
+	if iterTy.TKind != TyList && iterTy.TKind != TyRange {
+		return tc.errorIn(f.Iter, fmt.Sprintf("cannot iterate over %s: expected a Range or a List", iterTy.String()))
+	}
+	elemTy := iterTy.Elem
+	if elemTy == nil {
+		elemTy = &Type{TKind: TyInt}
+	}
+	old := tc.env
+	tc.env = NewEnv(old)
+	defer func() { tc.env = old }()
+	pos := parser.PatternPos(f.Pattern)
+	binds := map[string]*Type{}
+	tc.bindings = map[string]lexer.Token{}
+	if patTy := tc.checkPattern(f.Pattern, elemTy, binds); patTy.TKind == TyError {
+		return patTy
+	}
+	if w := missing([][]*pattern{{toPattern(f.Pattern)}}, []*Type{elemTy}); w != nil {
+		return tc.errorAt(pos, fmt.Sprintf("refutable pattern in for loop: %s is not covered", w[0]))
+	}
+	for name, t := range binds {
+		tc.declare(tc.bindings[name], t, false, nil)
+	}
+	tc.Check(f.Body)
+	return &Type{TKind: TyNil}
+}
+
 func (tc *TypeChecker) visitMatch(m *parser.MatchExpr) *Type {
 	valueTy := tc.Check(m.Value)
 	if valueTy.TKind == TyError {
@@ -311,37 +533,199 @@ func (tc *TypeChecker) visitMatch(m *parser.MatchExpr) *Type {
 	for _, arm := range m.Arms {
 		oldEnv := tc.env
 		tc.env = NewEnv(oldEnv)
-		switch p := arm.Pattern.(type) {
-		case *parser.Identifier:
-			tc.env.Set(p.Name, valueTy)
-			patternTy := valueTy
-			_ = patternTy
-		default:
-			patternTy := tc.Check(arm.Pattern)
-			if !patternTy.Equal(valueTy) {
-				return tc.errorAt(arm.Pos, fmt.Sprintf("pattern type %s does not match value type %s", patternTy.String(), valueTy.String()))
-			}
+		binds := map[string]*Type{}
+		tc.bindings = map[string]lexer.Token{}
+		if patTy := tc.checkPattern(arm.Pattern, valueTy, binds); patTy.TKind == TyError {
+			tc.env = oldEnv
+			return patTy
+		}
+		for name, t := range binds {
+			tc.declare(tc.bindings[name], t, false, nil)
 		}
 		if arm.Guard != nil {
 			guardTy := tc.Check(arm.Guard)
-			if guardTy.TKind != TyBool {
-				return tc.errorAt(arm.Pos, fmt.Sprintf("guard must be Bool, got %s", guardTy.String()))
+			if !unify(guardTy, &Type{TKind: TyBool}) {
+				return tc.errorIn(arm.Guard, fmt.Sprintf("guard must be Bool, got %s", guardTy.String()))
 			}
 		}
 		bodyTy := tc.Check(arm.Body)
 		if armType == nil {
 			armType = bodyTy
-		} else if !armType.Equal(bodyTy) {
-			return tc.errorAt(arm.Pos, fmt.Sprintf("match arm has type %s, expected %s", bodyTy.String(), armType.String()))
+		} else if !unify(armType, bodyTy) {
+			return tc.errorIn(arm.Body, fmt.Sprintf("match arm has type %s, expected %s", bodyTy.String(), armType.String()))
 		}
 		tc.env = oldEnv
 	}
+	if exh := tc.checkExhaustive(m, valueTy); exh.TKind == TyError {
+		return exh
+	}
 	if armType == nil {
 		return &Type{TKind: TyNil}
 	}
 	return armType
 }
 
+// checkPattern checks pat against the scrutinee type ty, collecting the
+// variables it binds into binds.
+func (tc *TypeChecker) checkPattern(pat parser.Pattern, ty *Type, binds map[string]*Type) *Type {
+	switch p := pat.(type) {
+	case *parser.WildcardPattern:
+		return ty
+	case *parser.BindingPattern:
+		return tc.bindPattern(p.Name, ty, binds)
+	case *parser.AsPattern:
+		if inner := tc.checkPattern(p.Pattern, ty, binds); inner.TKind == TyError {
+			return inner
+		}
+		return tc.bindPattern(p.Name, ty, binds)
+	case *parser.LiteralPattern:
+		if litTy := tc.Check(p.Value); !unify(litTy, ty) {
+			return tc.errorAt(p.Pos, fmt.Sprintf("pattern type %s does not match value type %s", litTy.String(), ty.String()))
+		}
+		return ty
+	case *parser.RangePattern:
+		for _, bound := range []parser.Expr{p.Low, p.High} {
+			if boundTy := tc.Check(bound); !unify(boundTy, ty) {
+				return tc.errorAt(p.Pos, fmt.Sprintf("range bound has type %s, expected %s", boundTy.String(), ty.String()))
+			}
+		}
+		if ty = prune(ty); ty.TKind != TyInt && ty.TKind != TyByte {
+			return tc.errorAt(p.Pos, fmt.Sprintf("range patterns need an Int or Byte value, got %s", ty.String()))
+		}
+		low, lok := p.Low.(*parser.IntLiteral)
+		high, hok := p.High.(*parser.IntLiteral)
+		if lok && hok && low.Value >= high.Value {
+			return tc.errorAt(p.Pos, fmt.Sprintf("range pattern %d..%d is empty", low.Value, high.Value))
+		}
+		return ty
+	case *parser.TuplePattern:
+		if ty = prune(ty); ty.TKind == TyVar {
+			tupleTy := &Type{TKind: TyTuple}
+			for range p.Elements {
+				tupleTy.TElems = append(tupleTy.TElems, tc.newVar())
+			}
+			unify(ty, tupleTy)
+			ty = tupleTy
+		}
+		if ty.TKind != TyTuple || len(ty.TElems) != len(p.Elements) {
+			return tc.errorAt(p.Pos, fmt.Sprintf("tuple pattern of length %d does not match value type %s", len(p.Elements), ty.String()))
+		}
+		for i, el := range p.Elements {
+			if elTy := tc.checkPattern(el, ty.TElems[i], binds); elTy.TKind == TyError {
+				return elTy
+			}
+		}
+		return ty
+	case *parser.ListPattern:
+		if !unify(ty, &Type{TKind: TyList, Elem: tc.newVar()}) {
+			return tc.errorAt(p.Pos, fmt.Sprintf("list pattern does not match value type %s", ty.String()))
+		}
+		ty = prune(ty)
+		for _, el := range p.Elements {
+			if elTy := tc.checkPattern(el, ty.Elem, binds); elTy.TKind == TyError {
+				return elTy
+			}
+		}
+		if p.Rest != nil {
+			return tc.checkPattern(p.Rest, ty, binds)
+		}
+		return ty
+	case *parser.ConstructorPattern:
+		sumTy, variant, ok := tc.lookupConstructor(p.Name.Lexeme)
+		if !ok {
+			return tc.errorAt(p.Name, fmt.Sprintf("unknown constructor '%s'", p.Name.Lexeme))
+		}
+		if !unify(sumTy, ty) {
+			return tc.errorAt(p.Name, fmt.Sprintf("pattern type %s does not match value type %s", sumTy.String(), ty.String()))
+		}
+		if len(p.Args) != len(variant.Fields) {
+			return tc.errorAt(p.Name, fmt.Sprintf("constructor %s expects %d arguments, got %d", p.Name.Lexeme, len(variant.Fields), len(p.Args)))
+		}
+		for i, arg := range p.Args {
+			if argTy := tc.checkPattern(arg, variant.Fields[i], binds); argTy.TKind == TyError {
+				return argTy
+			}
+		}
+		return ty
+	case *parser.OrPattern:
+		var first map[string]*Type
+		for i, alt := range p.Alternatives {
+			altBinds := map[string]*Type{}
+			if altTy := tc.checkPattern(alt, ty, altBinds); altTy.TKind == TyError {
+				return altTy
+			}
+			if i == 0 {
+				first = altBinds
+				continue
+			}
+			if !sameBindings(first, altBinds) {
+				return tc.errorAt(parser.PatternPos(alt), "alternatives of an or-pattern must bind the same variables with the same types")
+			}
+		}
+		for name, t := range first {
+			if res := tc.bindPattern(lexer.Token{Lexeme: name}, t, binds); res.TKind == TyError {
+				return res
+			}
+		}
+		return ty
+	}
+	return tc.errorAt(parser.PatternPos(pat), fmt.Sprintf("unsupported pattern %s", pat.NodeType()))
+}
+
+func (tc *TypeChecker) bindPattern(name lexer.Token, ty *Type, binds map[string]*Type) *Type {
+	if _, dup := binds[name.Lexeme]; dup {
+		return tc.errorAt(name, fmt.Sprintf("variable '%s' is bound more than once in the same pattern", name.Lexeme))
+	}
+	binds[name.Lexeme] = ty
+	if _, ok := tc.bindings[name.Lexeme]; !ok {
+		tc.bindings[name.Lexeme] = name
+	}
+	return ty
+}
+
+func sameBindings(a, b map[string]*Type) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, t := range a {
+		if u, ok := b[name]; !ok || !unify(t, u) {
+			return false
+		}
+	}
+	return true
+}
+
+// visitDestructure checks `val <pattern> = value`. The pattern has to match
+// every value of its type since there is no other arm to fall back to.
+func (tc *TypeChecker) visitDestructure(d *parser.VarDeclExpr) *Type {
+	pos := parser.PatternPos(d.Pattern)
+	ty := tc.Check(d.Value)
+	if ty.TKind == TyError {
+		return ty
+	}
+	if d.Type != nil {
+		declTy := tc.resolveType(d.Type)
+		if !unify(declTy, ty) {
+			return tc.errorAt(pos, fmt.Sprintf("type mismatch in destructuring declaration: expected %s, got %s", declTy.String(), ty.String()))
+		}
+	}
+	binds := map[string]*Type{}
+	tc.bindings = map[string]lexer.Token{}
+	if patTy := tc.checkPattern(d.Pattern, ty, binds); patTy.TKind == TyError {
+		return patTy
+	}
+	if w := missing([][]*pattern{{toPattern(d.Pattern)}}, []*Type{ty}); w != nil {
+		return tc.errorAt(pos, fmt.Sprintf("refutable pattern in declaration: %s is not covered", w[0]))
+	}
+	for name, t := range binds {
+		if _, exists := tc.env.currentScopeGet(name); exists {
+			return tc.errorAt(pos, fmt.Sprintf("variable '%s' already declared in this scope", name))
+		}
+		tc.declare(tc.bindings[name], t, d.Mutable, nil)
+	}
+	return ty
+}
+
 func (tc *TypeChecker) visitPipeline(p *parser.PipelineExpr) *Type {
 	leftTy := tc.Check(p.Left)
 	if leftTy.TKind == TyError {
@@ -349,82 +733,51 @@ func (tc *TypeChecker) visitPipeline(p *parser.PipelineExpr) *Type {
 	}
 	switch r := p.Right.(type) {
 	case *parser.Identifier:
-		fnTy, ok := tc.env.Get(r.Name)
-		if !ok {
+		if _, ok := tc.env.Get(r.Name); !ok {
 			return tc.errorAt(r.Pos, "undefined function: "+r.Name)
 		}
+		fnTy := tc.Check(r)
+		if fnTy.TKind == TyVar {
+			unify(fnTy, &Type{TKind: TyFunc, Params: []*Type{leftTy}, Ret: tc.newVar()})
+			fnTy = prune(fnTy)
+		}
 		if fnTy.TKind != TyFunc || len(fnTy.Params) == 0 {
 			return tc.errorAt(r.Pos, fmt.Sprintf("cannot pipe to non-function or function with no parameters: %s", r.Name))
 		}
-		if !fnTy.Params[0].Equal(leftTy) {
+		if !unify(fnTy.Params[0], leftTy) {
 			return tc.errorAt(r.Pos, fmt.Sprintf("type mismatch in pipeline: expected %s, got %s", fnTy.Params[0].String(), leftTy.String()))
 		}
 		return fnTy.Ret
 	case *parser.CallExpr:
 		args := append([]parser.Expr{p.Left}, r.Args...)
 		call := &parser.CallExpr{
+			Range:  p.Range,
 			Callee: r.Callee,
 			Args:   args,
 			Pos:    r.Pos,
 		}
 		return tc.Check(call)
+	case *parser.LambdaExpr, *parser.QualifiedExpr:
+		return tc.Check(&parser.CallExpr{Range: p.Range, Callee: r, Args: []parser.Expr{p.Left}, Pos: p.Pos})
 	default:
 		return tc.errorAt(p.Pos, "right side of pipeline must be a function or call")
 	}
 }
 
 func (tc *TypeChecker) visitList(l *parser.ListExpr, annotated *Type) *Type {
-	if len(l.Elements) == 0 {
-		if annotated != nil {
-			return annotated
-		}
-		return &Type{TKind: TyList, Elem: &Type{TKind: TyNil}}
-	}
 	var expected *Type
 	if annotated != nil && annotated.TKind == TyList {
 		expected = annotated.Elem
 	} else {
-		first := l.Elements[0]
-		switch tup := first.(type) {
-		case *parser.TupleExpr:
-			tElems := make([]*Type, len(tup.Elements))
-			for i, e := range tup.Elements {
-				subTy := tc.Check(e)
-				if subTy == nil || subTy.TKind == TyError {
-					return tc.errorAt(l.Pos, "cannot infer element type for tuple in list")
-				}
-				tElems[i] = subTy
-			}
-			expected = &Type{TKind: TyTuple, TElems: tElems}
-		default:
-			expected = tc.Check(first)
-			if expected == nil || expected.TKind == TyError {
-				return tc.errorAt(l.Pos, "cannot infer element type for list (first element error)")
-			}
-		}
+		expected = tc.newVar()
 	}
-	if expected.TKind == TyTuple {
-		for i, e := range l.Elements {
-			tup, ok := e.(*parser.TupleExpr)
-			if !ok {
-				return tc.errorAt(l.Pos, fmt.Sprintf("element %d: expected tuple %s, got %s", i+1, expected.String(), tc.Check(e).String()))
-			}
-			if len(tup.Elements) != len(expected.TElems) {
-				return tc.errorAt(tup.Pos, fmt.Sprintf("element %d: expected tuple of length %d, got %d", i+1, len(expected.TElems), len(tup.Elements)))
-			}
-			for k, sub := range tup.Elements {
-				subTy := tc.Check(sub)
-				if !expected.TElems[k].Equal(subTy) {
-					return tc.errorAt(l.Pos, fmt.Sprintf("element %d.%d: expected %s, got %s", i+1, k+1, expected.TElems[k].String(), subTy.String()))
-				}
-			}
+	for i, e := range l.Elements {
+		ty := tc.Check(e)
+		if ty.TKind == TyError {
+			return tc.errorAt(l.Pos, fmt.Sprintf("cannot infer element type for list (element %d error)", i+1))
 		}
-	} else {
-		for i, e := range l.Elements {
-			ty := tc.Check(e)
-			if !expected.Equal(ty) {
-				return tc.errorAt(l.Pos, fmt.Sprintf("element %d type %s does not match expected type %s", i+1, ty.String(), expected.String()))
-			}
+		if !unify(expected, ty) {
+			return tc.errorIn(e, fmt.Sprintf("element %d type %s does not match expected type %s", i+1, ty.String(), expected.String()))
 		}
 	}
 	return &Type{TKind: TyList, Elem: expected}
@@ -439,25 +792,28 @@ func (tc *TypeChecker) visitAssign(a *parser.AssignExpr) *Type {
 		return tc.errorAt(a.Pos, fmt.Sprintf("cannot assign to immutable variable '%s'", a.Name.Name))
 	}
 	valueTy := tc.Check(a.Value)
-	if !varInfo.Ty.Equal(valueTy) {
-		return tc.errorAt(a.Pos, fmt.Sprintf("type mismatch in assignment to '%s': expected %s, got %s", a.Name.Name, varInfo.Ty.String(), valueTy.String()))
+	if !unify(varInfo.Ty, valueTy) {
+		return tc.errorIn(a.Value, fmt.Sprintf("type mismatch in assignment to '%s': expected %s, got %s", a.Name.Name, varInfo.Ty.String(), valueTy.String()))
 	}
-	tc.env.SetVar(a.Name.Name, valueTy, true)
+	tc.use(a.Name.Pos, varInfo, valueTy)
+	varInfo.Ty = valueTy
+	tc.env.vars[a.Name.Name] = varInfo
 	return valueTy
 }
 
 func (tc *TypeChecker) visitIndex(idx *parser.IndexExpr) *Type {
 	targetTy := tc.Check(idx.Target)
 	indexTy := tc.Check(idx.Index)
-	if indexTy.TKind != TyInt {
-		return tc.errorAt(idx.Pos, fmt.Sprintf("index must be Int, got %s", indexTy.String()))
+	if !unify(indexTy, &Type{TKind: TyInt}) {
+		return tc.errorIn(idx.Index, fmt.Sprintf("index must be Int, got %s", indexTy.String()))
+	}
+	if targetTy.TKind == TyVar {
+		unify(targetTy, &Type{TKind: TyList, Elem: tc.newVar()})
+		targetTy = prune(targetTy)
 	}
 	switch targetTy.TKind {
 	case TyList:
-		if targetTy.Elem != nil {
-			return targetTy.Elem
-		}
-		return &Type{TKind: TyNil}
+		return targetTy.Elem
 	case TyString:
 		return &Type{TKind: TyByte}
 	case TyTuple:
@@ -474,6 +830,19 @@ func (tc *TypeChecker) visitIndex(idx *parser.IndexExpr) *Type {
 	}
 }
 
+// visitInterpolatedString checks the holes of a string literal, which are
+// converted with the to_string of their type.
+func (tc *TypeChecker) visitInterpolatedString(s *parser.InterpolatedString) *Type {
+	for _, part := range s.Parts {
+		switch ty := tc.Check(part); ty.TKind {
+		case TyInt, TyFloat, TyBool, TyByte, TyString, TyError:
+		default:
+			tc.errorIn(part, fmt.Sprintf("cannot interpolate %s: expected Int, Float, Bool, Byte or String", ty.String()))
+		}
+	}
+	return &Type{TKind: TyString}
+}
+
 func (tc *TypeChecker) visitTuple(t *parser.TupleExpr) *Type {
 	elems := make([]*Type, len(t.Elements))
 	for i, e := range t.Elements {
@@ -486,4 +855,124 @@ func (tc *TypeChecker) visitTuple(t *parser.TupleExpr) *Type {
 	return &Type{TKind: TyTuple, TElems: elems}
 }
 
-// TODO: Add records
+func (tc *TypeChecker) visitTypeDecl(t *parser.TypeDeclExpr) *Type {
+	name := t.Name.Lexeme
+	if _, exists := tc.env.types[name]; exists {
+		return tc.errorAt(t.Name, fmt.Sprintf("type '%s' already declared in this scope", name))
+	}
+	switch body := t.Body.(type) {
+	case *parser.RecordTypeExpr:
+		recTy := &Type{TKind: TyRecord, Name: name}
+		tc.env.SetType(name, recTy)
+		seen := map[string]bool{}
+		for _, f := range body.Fields {
+			if seen[f.Name.Lexeme] {
+				return tc.errorAt(f.Name, fmt.Sprintf("duplicate field '%s' in type %s", f.Name.Lexeme, name))
+			}
+			seen[f.Name.Lexeme] = true
+			fieldTy := tc.resolveType(f.Type)
+			if fieldTy.TKind == TyError {
+				return fieldTy
+			}
+			recTy.Fields = append(recTy.Fields, Field{Name: f.Name.Lexeme, Ty: fieldTy})
+		}
+	case *parser.VariantTypeExpr:
+		sumTy := &Type{TKind: TyVariant, Name: name}
+		tc.env.SetType(name, sumTy)
+		for _, v := range body.Variants {
+			if _, _, dup := sumTy.Variant(v.Name.Lexeme); dup {
+				return tc.errorAt(v.Name, fmt.Sprintf("duplicate constructor '%s' in type %s", v.Name.Lexeme, name))
+			}
+			fields := make([]*Type, len(v.Fields))
+			for i, f := range v.Fields {
+				fields[i] = tc.resolveType(f)
+				if fields[i].TKind == TyError {
+					return fields[i]
+				}
+			}
+			sumTy.Variants = append(sumTy.Variants, Variant{Name: v.Name.Lexeme, Fields: fields})
+		}
+		for _, v := range sumTy.Variants {
+			if len(v.Fields) == 0 {
+				tc.env.SetCtor(v.Name, sumTy)
+				continue
+			}
+			tc.env.SetCtor(v.Name, &Type{TKind: TyFunc, Params: v.Fields, Ret: sumTy})
+		}
+	default:
+		return tc.errorAt(t.Name, fmt.Sprintf("type '%s' has no body", name))
+	}
+	return &Type{TKind: TyNil}
+}
+
+// lookupConstructor resolves name to a constructor in scope, returning the
+// variant type it builds and the constructor itself.
+func (tc *TypeChecker) lookupConstructor(name string) (*Type, *Variant, bool) {
+	info, ok := tc.env.GetVar(name)
+	if !ok || !info.Ctor {
+		return nil, nil, false
+	}
+	sumTy := info.Ty
+	if sumTy.TKind == TyFunc {
+		sumTy = sumTy.Ret
+	}
+	_, variant, ok := sumTy.Variant(name)
+	return sumTy, variant, ok
+}
+
+func (tc *TypeChecker) visitRecord(r *parser.RecordExpr) *Type {
+	env := tc.env
+	if r.Module != nil {
+		modEnv, ok := tc.env.modules[r.Module.Name]
+		if !ok {
+			return tc.errorAt(r.Module.Pos, fmt.Sprintf("unknown module: %s", r.Module.Name))
+		}
+		env = modEnv
+	}
+	recTy, ok := env.GetType(r.Name.Lexeme)
+	if !ok && r.Module != nil {
+		return tc.errorAt(r.Name, fmt.Sprintf("module %s has no type %s", r.Module.Name, r.Name.Lexeme))
+	}
+	if !ok {
+		return tc.errorAt(r.Name, fmt.Sprintf("unknown type: '%s'", r.Name.Lexeme))
+	}
+	if recTy.TKind != TyRecord {
+		return tc.errorAt(r.Name, fmt.Sprintf("type %s is not a record", recTy.String()))
+	}
+	seen := map[string]bool{}
+	for _, f := range r.Fields {
+		_, fieldTy, ok := recTy.Field(f.Name.Lexeme)
+		if !ok {
+			return tc.errorAt(f.Name, fmt.Sprintf("type %s has no field '%s'", recTy.Name, f.Name.Lexeme))
+		}
+		if seen[f.Name.Lexeme] {
+			return tc.errorAt(f.Name, fmt.Sprintf("field '%s' given more than once", f.Name.Lexeme))
+		}
+		seen[f.Name.Lexeme] = true
+		valueTy := tc.Check(f.Value)
+		if !unify(fieldTy, valueTy) {
+			return tc.errorIn(f.Value, fmt.Sprintf("field '%s' of %s expects %s, got %s", f.Name.Lexeme, recTy.Name, fieldTy.String(), valueTy.String()))
+		}
+	}
+	for _, f := range recTy.Fields {
+		if !seen[f.Name] {
+			return tc.errorAt(r.Name, fmt.Sprintf("missing field '%s' in %s", f.Name, recTy.Name))
+		}
+	}
+	return recTy
+}
+
+func (tc *TypeChecker) visitFieldAccess(f *parser.FieldAccessExpr) *Type {
+	leftTy := tc.Check(f.Left)
+	if leftTy.TKind == TyError {
+		return leftTy
+	}
+	if leftTy.TKind != TyRecord {
+		return tc.errorAt(f.Pos, fmt.Sprintf("cannot access field '%s' on value of type %s", f.Right, leftTy.String()))
+	}
+	_, fieldTy, ok := leftTy.Field(f.Right)
+	if !ok {
+		return tc.errorAt(f.Pos, fmt.Sprintf("type %s has no field '%s'", leftTy.Name, f.Right))
+	}
+	return fieldTy
+}
@@ -1,8 +1,10 @@
 package typechecker
 
 import (
+	"flint/internal/diag"
 	"flint/internal/lexer"
 	"flint/internal/parser"
+	"strings"
 	"testing"
 )
 
@@ -104,3 +106,687 @@ fn bad(x: Int) Bool {
 		t.Fatal("expected type error for return mismatch")
 	}
 }
+
+func checkProgram(t *testing.T, src string) (*Type, error) {
+	t.Helper()
+
+	tokens, err := lexer.Tokenize(src, "test.flint")
+	if err != nil {
+		t.Fatalf("lex error: %v", err)
+	}
+
+	prog, errs := parser.ParseProgram(tokens)
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	tc := New()
+	var ty *Type
+	for _, ex := range prog.Exprs {
+		ty, err = tc.CheckExpr(ex)
+		if err != nil {
+			return ty, err
+		}
+	}
+	return ty, nil
+}
+
+func TestRecordConstructionAndFieldAccess(t *testing.T) {
+	ty, err := checkProgram(t, `
+type Point { x: Int, y: Float }
+
+fn getY(p: Point) Float {
+	p.y
+}
+
+fn make() Point {
+	Point { y: 1.5, x: 2 }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ty.TKind != TyFunc || ty.Ret.TKind != TyRecord || ty.Ret.Name != "Point" {
+		t.Fatalf("expected () -> Point, got %s", ty)
+	}
+}
+
+func TestRecordMissingField(t *testing.T) {
+	_, err := checkProgram(t, `
+type Point { x: Int, y: Int }
+
+fn make() Point {
+	Point { x: 1 }
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "missing field 'y'") {
+		t.Fatalf("expected missing field error, got %v", err)
+	}
+}
+
+func TestRecordFieldTypeMismatch(t *testing.T) {
+	_, err := checkProgram(t, `
+type Point { x: Int, y: Int }
+
+fn make() Point {
+	Point { x: 1, y: "two" }
+}
+`)
+	if err == nil {
+		t.Fatal("expected type error for field value")
+	}
+}
+
+func TestUnknownFieldAccess(t *testing.T) {
+	_, err := checkProgram(t, `
+type Point { x: Int, y: Int }
+
+fn z(p: Point) Int {
+	p.z
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "has no field 'z'") {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+}
+
+func TestVariantConstructorsAndMatch(t *testing.T) {
+	ty, err := checkProgram(t, `
+type Shape { | Circle(Float) | Rect(Float, Float) | Empty }
+
+fn area(s: Shape) Float {
+	match s {
+		| Circle(r) -> 3.14 *. r *. r
+		| Rect(w, h) -> w *. h
+		| Empty -> 0.0
+	}
+}
+
+fn unit() Shape {
+	Rect(1.0, 1.0)
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ty.TKind != TyFunc || ty.Ret.TKind != TyVariant || ty.Ret.Name != "Shape" {
+		t.Fatalf("expected () -> Shape, got %s", ty)
+	}
+}
+
+func TestVariantConstructorArity(t *testing.T) {
+	_, err := checkProgram(t, `
+type Shape { | Circle(Float) | Empty }
+
+fn area(s: Shape) Float {
+	match s {
+		| Circle(r, extra) -> r
+		| Empty -> 0.0
+	}
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "expects 1 arguments") {
+		t.Fatalf("expected arity error, got %v", err)
+	}
+}
+
+func TestVariantConstructorWrongArgument(t *testing.T) {
+	_, err := checkProgram(t, `
+type Shape { | Circle(Float) | Empty }
+
+fn make() Shape {
+	Circle("big")
+}
+`)
+	if err == nil {
+		t.Fatal("expected type error for constructor argument")
+	}
+}
+
+func TestMatchMissingBoolCase(t *testing.T) {
+	_, err := checkProgram(t, `
+fn f(b: Bool) Int {
+	match b {
+		| True -> 1
+	}
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "pattern False is not covered") {
+		t.Fatalf("expected non-exhaustive error, got %v", err)
+	}
+}
+
+func TestMatchIntWithoutWildcard(t *testing.T) {
+	_, err := checkProgram(t, `
+fn f(n: Int) Int {
+	match n {
+		| 0 -> 1
+		| 1 -> 1
+	}
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "pattern 2 is not covered") {
+		t.Fatalf("expected non-exhaustive error, got %v", err)
+	}
+}
+
+func TestMatchMissingNestedConstructor(t *testing.T) {
+	_, err := checkProgram(t, `
+type Opt { | Some(Bool) | None }
+
+fn f(o: Opt) Int {
+	match o {
+		| Some(True) -> 1
+		| None -> 0
+	}
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "pattern Some(False) is not covered") {
+		t.Fatalf("expected non-exhaustive error, got %v", err)
+	}
+}
+
+func TestMatchGuardedArmDoesNotCover(t *testing.T) {
+	_, err := checkProgram(t, `
+fn f(n: Int) Int {
+	match n {
+		| x if x > 0 -> 1
+	}
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "non-exhaustive match") {
+		t.Fatalf("expected non-exhaustive error, got %v", err)
+	}
+}
+
+func TestMatchExhaustiveTuple(t *testing.T) {
+	_, err := checkProgram(t, `
+fn f(p: (Bool, Bool)) Int {
+	match p {
+		| (True, _) -> 1
+		| (False, True) -> 2
+		| (_, False) -> 3
+	}
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatchUnreachableArmWarning(t *testing.T) {
+	tokens, err := lexer.Tokenize(`
+fn f(n: Int) Int {
+	match n {
+		| _ -> 0
+		| 1 -> 1
+	}
+}
+`, "test.flint")
+	if err != nil {
+		t.Fatalf("lex error: %v", err)
+	}
+	prog, errs := parser.ParseProgram(tokens)
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	tc := New()
+	for _, ex := range prog.Exprs {
+		if _, err := tc.CheckExpr(ex); err != nil {
+			t.Fatal(err)
+		}
+	}
+	warnings := tc.Diagnostics()
+	if len(warnings) != 1 || warnings[0].Severity != diag.Warning || !strings.Contains(warnings[0].Message, "unreachable match arm") {
+		t.Fatalf("expected one unreachable arm warning, got %v", warnings)
+	}
+}
+
+func TestDestructuringValBindsElements(t *testing.T) {
+	ty, err := checkProgram(t, `
+fn divmod(a: Int, b: Int) (Int, Int) {
+	(a / b, a % b)
+}
+
+fn f() Int {
+	val (q, r) = divmod(7, 2)
+	q + r
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ty.TKind != TyFunc || ty.Ret.TKind != TyInt {
+		t.Fatalf("expected () -> Int, got %s", ty)
+	}
+}
+
+func TestDestructuringValMustBeIrrefutable(t *testing.T) {
+	_, err := checkProgram(t, `
+fn f(xs: List(Int)) Int {
+	val [x, ..rest] = xs
+	x
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "refutable pattern") {
+		t.Fatalf("expected refutable pattern error, got %v", err)
+	}
+}
+
+func TestOrPatternBindingsMustAgree(t *testing.T) {
+	_, err := checkProgram(t, `
+fn f(p: (Int, Int)) Int {
+	match p {
+		| (x, 0) | (0, y) -> 1
+		| _ -> 0
+	}
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "must bind the same variables") {
+		t.Fatalf("expected or-pattern binding error, got %v", err)
+	}
+}
+
+func TestRangeAndListPatternsExhaustive(t *testing.T) {
+	_, err := checkProgram(t, `
+fn f(xs: List(Int)) Int {
+	match xs {
+		| [] -> 0
+		| [x] -> x
+		| [x, y, ..rest] -> x + y
+	}
+}
+
+fn g(n: Int) Int {
+	match n {
+		| 0..10 | 20..30 -> 1
+		| 10..20 -> 2
+		| _ -> 3
+	}
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = checkProgram(t, `
+fn f(xs: List(Int)) Int {
+	match xs {
+		| [] -> 0
+		| [x] -> x
+	}
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "pattern [_, _, ..] is not covered") {
+		t.Fatalf("expected missing list pattern, got %v", err)
+	}
+}
+
+func TestHugeRangesAreCheckedQuickly(t *testing.T) {
+	for src, want := range map[string]string{
+		"| 0..2000000000 -> 1":                                "pattern 2000000000 is not covered",
+		"| 0..9223372036854775807 | 9223372036854775807 -> 1": "pattern -1 is not covered",
+		"| 5 | 0..3 | 3..5 -> 1":                              "pattern 6 is not covered",
+	} {
+		_, err := checkProgram(t, "fn f(x: Int) Int {\n\tmatch x {\n\t\t"+src+"\n\t}\n}\n")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected %q, got %v", src, want, err)
+		}
+	}
+}
+
+func TestInferGenericFunction(t *testing.T) {
+	ty, err := checkProgram(t, `
+fn id(x) { x }
+
+fn f() (Int, String) {
+	(id(1), id("one"))
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ty.String(); got != "() -> (Int, String)" {
+		t.Fatalf("unexpected type %s", got)
+	}
+
+	ty, err = checkProgram(t, `
+fn twice(f, x) { f(f(x)) }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ty.String(); got != "((a) -> a, a) -> a" {
+		t.Fatalf("expected ((a) -> a, a) -> a, got %s", got)
+	}
+}
+
+func TestFunctionTypeAnnotations(t *testing.T) {
+	ty, err := checkProgram(t, `
+fn apply(f: (a) -> b, x: a) b {
+	f(x)
+}
+
+fn double(n: Int) Int { n * 2 }
+
+fn g() Int {
+	apply(double, 21)
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ty.Ret.TKind != TyInt {
+		t.Fatalf("expected Int, got %s", ty.Ret)
+	}
+
+	_, err = checkProgram(t, `
+fn apply(f: (a) -> b, x: a) b {
+	f(x)
+}
+
+fn double(n: Int) Int { n * 2 }
+
+fn g() Int {
+	apply(double, "21")
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "argument 2 expected Int, got String") {
+		t.Fatalf("expected argument mismatch, got %v", err)
+	}
+}
+
+func TestInferRejectsInfiniteType(t *testing.T) {
+	_, err := checkProgram(t, `
+fn selfapply(x) { x(x) }
+`)
+	if err == nil || !strings.Contains(err.Error(), "expected a, got (a) -> b") {
+		t.Fatalf("expected occurs check failure, got %v", err)
+	}
+}
+
+func TestLambdaClosesOverScope(t *testing.T) {
+	ty, err := checkProgram(t, `
+fn make_adder(n: Int) (Int) -> Int {
+	fn(x) { x + n }
+}
+
+fn f() Int {
+	val add = make_adder(1)
+	add(2) |> fn(x) { x * 2 }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ty.Ret.TKind != TyInt {
+		t.Fatalf("expected Int, got %s", ty.Ret)
+	}
+}
+
+func TestLambdaIsMonomorphic(t *testing.T) {
+	_, err := checkProgram(t, `
+fn f() Nil {
+	val id = fn(x) { x }
+	id(1)
+	id("one")
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "argument 1 expected Int, got String") {
+		t.Fatalf("expected mismatch, got %v", err)
+	}
+}
+
+func TestForLoopsIterateOverRangesAndLists(t *testing.T) {
+	_, err := checkProgram(t, `
+fn sum(xs) Int {
+	mut total = 0
+	for (i, x) in xs {
+		total = total + i * x
+	}
+	for i in 0..10 {
+		total = total + i
+	}
+	total
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for src, want := range map[string]string{
+		`fn f() Nil { for c in "abc" {} }`:         "cannot iterate over String: expected a Range or a List",
+		`fn f() Nil { for Some(x) in [None] {} }`:  "refutable pattern in for loop: None is not covered",
+		`fn f() Nil { for i in 0..3 { i = 1 } }`:   "cannot assign to immutable variable 'i'",
+		"fn f() Nil {\n\tfor i in 0..3 {}\n\ti\n}": "undefined variable: 'i'",
+		`fn f() Nil { for i in 0.."3" {} }`:        "invalid operands for '..': Int and String",
+	} {
+		_, err := checkProgram(t, "type Option { | Some(Int) | None }\n"+src)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected %q, got %v", src, want, err)
+		}
+	}
+}
+
+func TestListModuleIsGeneric(t *testing.T) {
+	ty, err := checkProgram(t, `
+use flint/list
+
+fn f() List(String) {
+	val xs = []
+	list:push(xs, 1)
+	val total = list:fold(xs, 0.0, fn(acc, x) { acc +. 1.0 })
+	list:map(list:reverse(xs), fn(x) { "item" })
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ty.String(); got != "() -> List(String)" {
+		t.Fatalf("unexpected type %s", got)
+	}
+	_, err = checkProgram(t, `
+use flint/list
+
+fn f() Nil {
+	list:push([1], "two")
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "argument 2 expected Int, got String") {
+		t.Fatalf("expected the element type to be checked, got %v", err)
+	}
+}
+
+func TestLambdaArgumentsSeeTheOtherArguments(t *testing.T) {
+	ty, err := checkProgram(t, `
+use flint/list
+
+type Point { x: Int, y: Int }
+
+fn f(pts: List(Point)) List(Int) {
+	val total = list:fold(pts, 0, fn(acc, p) { acc + p.x })
+	list:map(pts, fn(p) { p.y + total })
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ty.String(); got != "(List(Point)) -> List(Int)" {
+		t.Fatalf("unexpected type %s", got)
+	}
+	_, err = checkProgram(t, `
+use flint/list
+
+type Point { x: Int, y: Int }
+
+fn f(pts: List(Point)) Int {
+	list:fold(pts, 0, fn(acc, p) { acc + p.z })
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "type Point has no field 'z'") {
+		t.Fatalf("expected the missing field to be reported, got %v", err)
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	ty, err := checkProgram(t, `
+use flint/string
+
+fn f(n: Int, s: String) String {
+	val (m, ok) = string:parse_int(s)
+	"{n} {m + 1} {ok} {1.5} {s[0]} {string:to_upper(s)}"
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ty.String(); got != "(Int, String) -> String" {
+		t.Fatalf("unexpected type %s", got)
+	}
+	_, err = checkProgram(t, `
+use flint/string
+
+fn f(s: String) String {
+	"{string:split(s, ",")}"
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "cannot interpolate List(String): expected Int, Float, Bool, Byte or String") {
+		t.Fatalf("expected the hole to be rejected, got %v", err)
+	}
+}
+
+func TestFsAndOsShareResult(t *testing.T) {
+	ty, err := checkProgram(t, `
+use flint/fs
+use flint/os
+use flint/fs.{Result, Ok, Error}
+
+fn f(path: String) Result {
+	match fs:read_file(path) {
+		| Ok(s) -> fs:write_file(path <> ".bak", s)
+		| Error(_) -> os:env("HOME")
+	}
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ty.String(); got != "(String) -> Result" {
+		t.Fatalf("unexpected type %s", got)
+	}
+	_, err = checkProgram(t, `
+use flint/os
+use flint/os.{Ok}
+
+fn f() String {
+	match os:read_line() {
+		| Ok(line) -> line
+	}
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), "non-exhaustive match: pattern Error(_) is not covered") {
+		t.Fatalf("expected Error to be required, got %v", err)
+	}
+}
+
+func TestCheckExprReportsEveryError(t *testing.T) {
+	_, err := checkProgram(t, `
+fn f(x: Int) Bool {
+	val s: String = x
+	x + "one"
+	unknown
+}
+`)
+	diags, ok := err.(diag.List)
+	if !ok || len(diags) != 3 {
+		t.Fatalf("expected 3 errors, got %v", err)
+	}
+	if !strings.Contains(diags[2].Message, "undefined variable: 'unknown'") {
+		t.Fatalf("unexpected last error: %s", diags[2].Message)
+	}
+}
+
+func TestRefsRecordTypesAtPositions(t *testing.T) {
+	tokens, _ := lexer.Tokenize(`
+fn id(x) { x }
+fn f() String {
+	val (a, b) = (1, "one")
+	id(b)
+}
+`, "test.flint")
+	prog, _ := parser.ParseProgram(tokens)
+	tc := New()
+	for _, ex := range prog.Exprs {
+		if _, err := tc.CheckExpr(ex); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		line, col int
+		want      string
+	}{
+		{2, 4, "id: (a) -> a"},
+		{2, 12, "x: a"},
+		{4, 7, "a: Int"},
+		{5, 2, "id: (String) -> String"},
+		{5, 5, "b: String"},
+	}
+	for _, tt := range tests {
+		ref, ok := tc.RefAt(tt.line, tt.col)
+		if !ok {
+			t.Fatalf("no name at %d:%d", tt.line, tt.col)
+		}
+		if got := ref.Name + ": " + Resolve(ref.Type).String(); got != tt.want {
+			t.Errorf("at %d:%d got %q, want %q", tt.line, tt.col, got, tt.want)
+		}
+	}
+
+	use, _ := tc.RefAt(5, 5)
+	decl, _ := tc.RefAt(4, 10)
+	if use.IsDecl() || !decl.IsDecl() || use.Def.Line != 4 || use.Def.Column != 10 {
+		t.Fatalf("use of b does not point at its declaration: %+v", use.Def)
+	}
+}
+
+func TestExternalParamsNeedAnnotations(t *testing.T) {
+	tokens, _ := lexer.Tokenize(`
+@external(c, "flint_stdlib", "print")
+fn print(s) Nil
+
+fn main() Nil {
+	print("hi")
+}
+`, "test.flint")
+	prog, _ := parser.ParseProgram(tokens)
+	tc := New()
+	for _, ex := range prog.Exprs {
+		tc.CheckExpr(ex)
+	}
+	diags := tc.Diagnostics()
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, "parameter 's' of external function 'print' needs a type annotation") {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	ref, ok := tc.RefAt(3, 10)
+	if !ok || Resolve(ref.Type).String() != "String" {
+		t.Fatalf("call did not fix the parameter type: %+v", ref)
+	}
+}
+
+func TestErrorsSpanTheOffendingExpression(t *testing.T) {
+	src := "fn f(a: Int, b: String) Int { a }\nfn main() Int {\n    f(1, 2 + 3)\n}\n"
+	tokens, _ := lexer.Tokenize(src, "test.flint")
+	prog, _ := parser.ParseProgram(tokens)
+	tc := New()
+	for _, ex := range prog.Exprs {
+		tc.CheckExpr(ex)
+	}
+	diags := tc.Diagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("expected one diagnostic, got %v", diags)
+	}
+	want := diag.Span{File: "test.flint", Line: 3, Column: 10, EndLine: 3, EndColumn: 15}
+	if diags[0].Span != want {
+		t.Fatalf("expected %+v, got %+v", want, diags[0].Span)
+	}
+}